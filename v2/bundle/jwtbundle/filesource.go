@@ -0,0 +1,162 @@
+package jwtbundle
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/fileutil"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/zeebo/errs"
+)
+
+// x509SVIDUse is the "use" of the X.509 authorities of SPIFFE bundles, which
+// are skipped when loading JWT bundles from files.
+const x509SVIDUse = "x509-svid"
+
+// FileSourceOption is an option used when creating a FileSource.
+type FileSourceOption interface {
+	applyFileSource(config *fileSourceConfig)
+}
+
+// WithFileReload makes the FileSource reload the bundle files when their size
+// or modification time changes, checked on every lookup. If not used, the
+// files are only loaded when the source is created.
+func WithFileReload() FileSourceOption {
+	return fileSourceOption(func(config *fileSourceConfig) {
+		config.reload = true
+	})
+}
+
+// FileSource is a source of JWT bundles loaded from files on disk instead of
+// a live bundle source such as the Workload API, e.g. for air-gapped
+// verifiers and CLI tooling. Each file holds the JWT authorities of a trust
+// domain, either as a standard RFC 7517 JWKS document or as a SPIFFE bundle,
+// whose X.509 authorities are ignored. If a reload fails, the previously
+// loaded bundle keeps being returned. It is safe for concurrent use.
+type FileSource struct {
+	reload bool
+
+	mtx   sync.Mutex
+	files map[spiffeid.TrustDomain]*bundleFile
+}
+
+type bundleFile struct {
+	path   string
+	stamp  fileutil.Stamp
+	bundle *Bundle
+}
+
+// NewFileSource creates a new FileSource loading the JWT bundles of the
+// trust domains from the given files. An error is returned if the files
+// cannot be loaded initially.
+func NewFileSource(files map[spiffeid.TrustDomain]string, opts ...FileSourceOption) (*FileSource, error) {
+	config := &fileSourceConfig{}
+	for _, opt := range opts {
+		opt.applyFileSource(config)
+	}
+
+	s := &FileSource{
+		reload: config.reload,
+		files:  make(map[spiffeid.TrustDomain]*bundleFile, len(files)),
+	}
+	for trustDomain, path := range files {
+		file := &bundleFile{path: path}
+		if err := file.load(trustDomain); err != nil {
+			return nil, err
+		}
+		s.files[trustDomain] = file
+	}
+	return s, nil
+}
+
+// GetJWTBundleForTrustDomain returns the JWT bundle loaded for the given
+// trust domain, reloading its file if enabled and it has changed. It
+// implements the Source interface.
+func (s *FileSource) GetJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*Bundle, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	file, ok := s.files[trustDomain]
+	if !ok {
+		return nil, jwtbundleErr.New("no JWT bundle file for trust domain %q", trustDomain)
+	}
+	if s.reload {
+		if stamp, err := fileutil.Stat(file.path); err == nil && !stamp.Equal(file.stamp) {
+			// Keep serving the previous bundle if the file cannot be loaded.
+			_ = file.load(trustDomain)
+		}
+	}
+	return file.bundle, nil
+}
+
+// RefreshJWTBundleForTrustDomain reloads the file of the given trust domain
+// and returns its JWT bundle, whether reloading is enabled or not. If the
+// file cannot be loaded, an error is returned and the previously loaded
+// bundle is kept. It implements the RefreshableSource interface.
+func (s *FileSource) RefreshJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*Bundle, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	file, ok := s.files[trustDomain]
+	if !ok {
+		return nil, jwtbundleErr.New("no JWT bundle file for trust domain %q", trustDomain)
+	}
+	if err := file.load(trustDomain); err != nil {
+		return nil, err
+	}
+	return file.bundle, nil
+}
+
+func (f *bundleFile) load(trustDomain spiffeid.TrustDomain) error {
+	stamp, err := fileutil.Stat(f.path)
+	if err != nil {
+		return jwtbundleErr.New("unable to read JWT bundle: %w", err)
+	}
+	// The file is not loaded again until it changes, even if loading it
+	// fails.
+	f.stamp = stamp
+
+	bundleBytes, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return jwtbundleErr.New("unable to read JWT bundle: %w", err)
+	}
+	bundle, err := parseBundleFile(trustDomain, bundleBytes)
+	if err != nil {
+		return err
+	}
+	f.bundle = bundle
+	return nil
+}
+
+// parseBundleFile parses a JWKS document like Parse does, skipping the X.509
+// authorities of SPIFFE bundles.
+func parseBundleFile(trustDomain spiffeid.TrustDomain, bundleBytes []byte) (*Bundle, error) {
+	jwks := new(jose.JSONWebKeySet)
+	if err := json.Unmarshal(bundleBytes, jwks); err != nil {
+		return nil, jwtbundleErr.New("unable to parse JWKS: %v", err)
+	}
+
+	bundle := New(trustDomain)
+	for i, key := range jwks.Keys {
+		if key.Use == x509SVIDUse {
+			continue
+		}
+		if err := bundle.AddJWTAuthority(key.KeyID, key.Key); err != nil {
+			return nil, jwtbundleErr.New("error adding authority %d of JWKS: %v", i, errs.Unwrap(err))
+		}
+	}
+
+	return bundle, nil
+}
+
+type fileSourceConfig struct {
+	reload bool
+}
+
+type fileSourceOption func(config *fileSourceConfig)
+
+func (fn fileSourceOption) applyFileSource(config *fileSourceConfig) {
+	fn(config)
+}
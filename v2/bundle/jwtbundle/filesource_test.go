@@ -0,0 +1,99 @@
+package jwtbundle_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSource(t *testing.T) {
+	td2 := spiffeid.RequireTrustDomainFromString("domain2.test")
+	source, err := jwtbundle.NewFileSource(map[spiffeid.TrustDomain]string{
+		td:  "testdata/jwks_valid_2.json",
+		td2: "../spiffebundle/testdata/spiffebundle_valid_2.json",
+	})
+	require.NoError(t, err)
+
+	bundle, err := source.GetJWTBundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Equal(t, td, bundle.TrustDomain())
+	assert.Len(t, bundle.JWTAuthorities(), 2)
+
+	// The X.509 authorities of SPIFFE bundles are ignored.
+	bundle, err = source.GetJWTBundleForTrustDomain(td2)
+	require.NoError(t, err)
+	assert.Equal(t, td2, bundle.TrustDomain())
+	assert.Len(t, bundle.JWTAuthorities(), 6)
+
+	_, err = source.GetJWTBundleForTrustDomain(spiffeid.RequireTrustDomainFromString("other.test"))
+	require.EqualError(t, err, `jwtbundle: no JWT bundle file for trust domain "other.test"`)
+
+	_, err = jwtbundle.NewFileSource(map[spiffeid.TrustDomain]string{td: "testdata/jwks_missing_kid.json"})
+	require.EqualError(t, err, "jwtbundle: error adding authority 1 of JWKS: keyID cannot be empty")
+	_, err = jwtbundle.NewFileSource(map[spiffeid.TrustDomain]string{td: "testdata/does-not-exist.json"})
+	require.Error(t, err)
+}
+
+func TestFileSourceReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle.json")
+	copyFile(t, "testdata/jwks_valid_1.json", path)
+
+	static, err := jwtbundle.NewFileSource(map[spiffeid.TrustDomain]string{td: path})
+	require.NoError(t, err)
+	reloading, err := jwtbundle.NewFileSource(map[spiffeid.TrustDomain]string{td: path}, jwtbundle.WithFileReload())
+	require.NoError(t, err)
+
+	copyFile(t, "testdata/jwks_valid_2.json", path)
+	bundle, err := static.GetJWTBundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Len(t, bundle.JWTAuthorities(), 1)
+	bundle, err = reloading.GetJWTBundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Len(t, bundle.JWTAuthorities(), 2)
+
+	// The previous bundle is kept if the file cannot be loaded.
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a JWKS"), 0600))
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)))
+	bundle, err = reloading.GetJWTBundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Len(t, bundle.JWTAuthorities(), 2)
+}
+
+func TestFileSourceRefresh(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bundle.json")
+	copyFile(t, "testdata/jwks_valid_1.json", path)
+
+	source, err := jwtbundle.NewFileSource(map[spiffeid.TrustDomain]string{td: path})
+	require.NoError(t, err)
+
+	// Refreshes reload the file even if reloading is not enabled.
+	copyFile(t, "testdata/jwks_valid_2.json", path)
+	bundle, err := source.RefreshJWTBundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Len(t, bundle.JWTAuthorities(), 2)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a JWKS"), 0600))
+	_, err = source.RefreshJWTBundleForTrustDomain(td)
+	require.Error(t, err)
+	bundle, err = source.GetJWTBundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Len(t, bundle.JWTAuthorities(), 2)
+
+	_, err = source.RefreshJWTBundleForTrustDomain(spiffeid.RequireTrustDomainFromString("other.test"))
+	require.EqualError(t, err, `jwtbundle: no JWT bundle file for trust domain "other.test"`)
+}
+
+func copyFile(t *testing.T, src, dst string) {
+	data, err := ioutil.ReadFile(src)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(dst, data, 0600))
+}
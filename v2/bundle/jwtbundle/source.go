@@ -10,3 +10,15 @@ type Source interface {
 	// domain.
 	GetJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*Bundle, error)
 }
+
+// RefreshableSource is a Source whose bundles can be refreshed on demand,
+// e.g. when a JWT-SVID is signed by a JWT authority that is not in the bundle
+// yet. Since refreshes can be triggered by untrusted tokens, implementations
+// should make them cheap or limit their rate.
+type RefreshableSource interface {
+	Source
+
+	// RefreshJWTBundleForTrustDomain refreshes the JWT bundle for the given
+	// trust domain and returns it.
+	RefreshJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*Bundle, error)
+}
@@ -0,0 +1,116 @@
+package federation
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+)
+
+// AccessLogEntry describes a request served by a bundle endpoint handler.
+type AccessLogEntry struct {
+	// Time is when the request was received.
+	Time time.Time
+
+	// RemoteAddr is the network address of the client.
+	RemoteAddr string
+
+	// ClientID is the SPIFFE ID of the client, if it authenticated with an
+	// X509-SVID over mTLS and the X509-SVID was verified, either by the TLS
+	// configuration of the server (see tls.ConnectionState.VerifiedChains)
+	// or against the bundles provided with WithAccessLogBundles. Otherwise,
+	// it is zero.
+	ClientID spiffeid.ID
+
+	// Method is the HTTP method of the request.
+	Method string
+
+	// Path is the path of the request.
+	Path string
+
+	// TrustDomain is the trust domain requested. It is zero if the request
+	// was rejected before the trust domain was determined.
+	TrustDomain spiffeid.TrustDomain
+
+	// StatusCode is the status code of the response.
+	StatusCode int
+
+	// Duration is how long the request took to serve.
+	Duration time.Duration
+}
+
+// String returns the entry formatted as a single log line.
+func (e AccessLogEntry) String() string {
+	clientID := "-"
+	if !e.ClientID.IsZero() {
+		clientID = e.ClientID.String()
+	}
+	trustDomain := "-"
+	if !e.TrustDomain.IsZero() {
+		trustDomain = e.TrustDomain.String()
+	}
+	return fmt.Sprintf("%s %s %s %s trust_domain=%s status=%d duration=%s",
+		e.RemoteAddr, clientID, e.Method, e.Path, trustDomain, e.StatusCode, e.Duration)
+}
+
+// WithAccessLog provides a function that is called with an entry for each
+// request served by the handler, e.g. to keep an audit trail of which
+// federation partners fetched bundles. The function is called synchronously
+// and should return quickly.
+func WithAccessLog(fn func(AccessLogEntry)) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		c.accessLog = fn
+		return nil
+	})
+}
+
+// WithAccessLogBundles provides the X.509 bundles used to verify the client
+// X509-SVIDs for the access log. It is needed when the TLS configuration of
+// the server verifies client certificates itself instead of letting
+// crypto/tls do it, e.g. with tlsconfig.MTLSServerConfig, as crypto/tls then
+// does not report the verified chains. Client certificates that cannot be
+// verified are logged as unauthenticated.
+func WithAccessLogBundles(bundles x509bundle.Source) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		c.accessLogBundles = bundles
+		return nil
+	})
+}
+
+func newAccessLogEntry(r *http.Request, conf *handlerConfig, start time.Time, duration time.Duration, info *requestInfo, rec *responseRecorder) AccessLogEntry {
+	entry := AccessLogEntry{
+		Time:        start,
+		RemoteAddr:  r.RemoteAddr,
+		Method:      r.Method,
+		Path:        r.URL.Path,
+		TrustDomain: info.trustDomain,
+		StatusCode:  rec.statusCode,
+		Duration:    duration,
+	}
+	entry.ClientID = verifiedClientID(r, conf.accessLogBundles)
+	return entry
+}
+
+// verifiedClientID returns the SPIFFE ID of the client X509-SVID of the
+// request, or a zero ID if the client did not present a verified X509-SVID.
+// The peer certificates are only trusted if crypto/tls verified them, or if
+// they verify against the given bundles, since servers that request client
+// certificates without requiring them to be valid accept any certificate.
+func verifiedClientID(r *http.Request, bundles x509bundle.Source) spiffeid.ID {
+	switch {
+	case r.TLS == nil:
+		return spiffeid.ID{}
+	case len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0:
+		if id, err := x509svid.IDFromCert(r.TLS.VerifiedChains[0][0]); err == nil {
+			return id
+		}
+	case bundles != nil && len(r.TLS.PeerCertificates) > 0:
+		if id, _, err := x509svid.Verify(r.TLS.PeerCertificates, bundles); err == nil {
+			return id
+		}
+	}
+	return spiffeid.ID{}
+}
@@ -0,0 +1,124 @@
+package federation_test
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessLog(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+	serverSVID := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/bundle-endpoint"), test.WithIPAddresses(localhostIPs...))
+	clientID := spiffeid.RequireFromPath(td, "/partner")
+	clientSVID := ca.CreateX509SVID(clientID)
+
+	entry := serveWithAccessLog(t,
+		tlsconfig.MTLSServerConfig(serverSVID, bundle, tlsconfig.AuthorizeAny()),
+		tlsconfig.MTLSClientConfig(clientSVID, bundle, tlsconfig.AuthorizeAny()),
+		federation.WithAccessLogBundles(bundle))
+	assert.Equal(t, clientID, entry.ClientID)
+	assert.Equal(t, td, entry.TrustDomain)
+	assert.Equal(t, http.StatusOK, entry.StatusCode)
+	assert.Equal(t, http.MethodGet, entry.Method)
+	assert.Equal(t, "/bundle", entry.Path)
+	host, _, err := net.SplitHostPort(entry.RemoteAddr)
+	require.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", host)
+	assert.Contains(t, entry.String(), "spiffe://domain.test/partner GET /bundle trust_domain=domain.test status=200")
+
+	// Without bundles, the client X509-SVID verified by the TLS configuration
+	// is not reported by crypto/tls, so it is not logged.
+	entry = serveWithAccessLog(t,
+		tlsconfig.MTLSServerConfig(serverSVID, bundle, tlsconfig.AuthorizeAny()),
+		tlsconfig.MTLSClientConfig(clientSVID, bundle, tlsconfig.AuthorizeAny()))
+	assert.True(t, entry.ClientID.IsZero())
+}
+
+func TestAccessLogVerifiedByCryptoTLS(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+	serverSVID := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/bundle-endpoint"), test.WithIPAddresses(localhostIPs...))
+	clientID := spiffeid.RequireFromPath(td, "/partner")
+	clientSVID := ca.CreateX509SVID(clientID)
+
+	serverConfig := tlsconfig.TLSServerConfig(serverSVID)
+	serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	serverConfig.ClientCAs = x509util.NewCertPool(ca.X509Authorities())
+	entry := serveWithAccessLog(t, serverConfig, tlsconfig.MTLSClientConfig(clientSVID, bundle, tlsconfig.AuthorizeAny()))
+	assert.Equal(t, clientID, entry.ClientID)
+}
+
+func TestAccessLogUnverifiedClientCertificate(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+	serverSVID := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/bundle-endpoint"), test.WithIPAddresses(localhostIPs...))
+	// The client claims an ID of the trust domain with an X509-SVID from
+	// another CA.
+	spoofedSVID := test.NewCA(t, td).CreateX509SVID(spiffeid.RequireFromPath(td, "/partner"))
+
+	serverConfig := tlsconfig.TLSServerConfig(serverSVID)
+	serverConfig.ClientAuth = tls.RequestClientCert
+	clientConfig := tlsconfig.MTLSClientConfig(spoofedSVID, bundle, tlsconfig.AuthorizeAny())
+
+	entry := serveWithAccessLog(t, serverConfig, clientConfig)
+	assert.True(t, entry.ClientID.IsZero())
+	entry = serveWithAccessLog(t, serverConfig, clientConfig, federation.WithAccessLogBundles(bundle))
+	assert.True(t, entry.ClientID.IsZero())
+	assert.Contains(t, entry.String(), " - GET /bundle")
+}
+
+// serveWithAccessLog serves a bundle with the given TLS configurations and
+// returns the access log entry of the request.
+func serveWithAccessLog(t *testing.T, serverConfig, clientConfig *tls.Config, options ...federation.HandlerOption) federation.AccessLogEntry {
+	var mtx sync.Mutex
+	var entries []federation.AccessLogEntry
+	options = append(options, federation.WithAccessLog(func(entry federation.AccessLogEntry) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		entries = append(entries, entry)
+	}))
+	handler, err := federation.NewHandler(td, test.NewCA(t, td).Bundle(), options...)
+	require.NoError(t, err)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+	require.NoError(t, err)
+	server := &http.Server{Handler: handler} //nolint:gosec // test server
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: clientConfig,
+		},
+	}
+	res, err := client.Get("https://" + listener.Addr().String() + "/bundle")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, entries, 1)
+	return entries[0]
+}
+
+func TestAccessLogEntryString(t *testing.T) {
+	entry := federation.AccessLogEntry{
+		RemoteAddr: "192.0.2.1:1234",
+		Method:     http.MethodPost,
+		Path:       "/",
+		StatusCode: http.StatusMethodNotAllowed,
+	}
+	assert.Equal(t, "192.0.2.1:1234 - POST / trust_domain=- status=405 duration=0s", entry.String())
+}
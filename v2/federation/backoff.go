@@ -0,0 +1,51 @@
+package federation
+
+import (
+	"math/rand"
+	"time"
+)
+
+type backoffConfig struct {
+	initial time.Duration
+	max     time.Duration
+}
+
+// backoff is an exponential backoff used to retry failed polls of the bundle
+// endpoint.
+type backoff struct {
+	config backoffConfig
+	next   time.Duration
+}
+
+func newBackoff(config backoffConfig) *backoff {
+	return &backoff{
+		config: config,
+		next:   config.initial,
+	}
+}
+
+// Duration returns the next interval to wait and doubles the following one,
+// up to the maximum.
+func (b *backoff) Duration() time.Duration {
+	d := b.next
+	b.next *= 2
+	if b.next > b.config.max {
+		b.next = b.config.max
+	}
+	return d
+}
+
+// Reset resets the backoff to the initial interval.
+func (b *backoff) Reset() {
+	b.next = b.config.initial
+}
+
+// applyJitter randomizes the duration by up to the given fraction in either
+// direction.
+func applyJitter(d time.Duration, fraction float64) time.Duration {
+	if fraction == 0 || d <= 0 {
+		return d
+	}
+	delta := fraction * float64(d)
+	return d + time.Duration(delta*(2*rand.Float64()-1)) //nolint:gosec // jitter does not need a secure random source
+}
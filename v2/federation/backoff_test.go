@@ -0,0 +1,31 @@
+package federation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(backoffConfig{initial: time.Second, max: 5 * time.Second})
+	assert.Equal(t, time.Second, b.Duration())
+	assert.Equal(t, 2*time.Second, b.Duration())
+	assert.Equal(t, 4*time.Second, b.Duration())
+	assert.Equal(t, 5*time.Second, b.Duration())
+	assert.Equal(t, 5*time.Second, b.Duration())
+
+	b.Reset()
+	assert.Equal(t, time.Second, b.Duration())
+}
+
+func TestApplyJitter(t *testing.T) {
+	assert.Equal(t, time.Minute, applyJitter(time.Minute, 0))
+	assert.Equal(t, time.Duration(0), applyJitter(0, 0.5))
+
+	for i := 0; i < 100; i++ {
+		d := applyJitter(time.Minute, 0.1)
+		assert.GreaterOrEqual(t, d, 54*time.Second)
+		assert.LessOrEqual(t, d, 66*time.Second)
+	}
+}
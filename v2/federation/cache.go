@@ -0,0 +1,180 @@
+package federation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// WatchCheckpoint is the state of a bundle watch, persisted so that a
+// restarted watch resumes where it left off.
+type WatchCheckpoint struct {
+	// Bundle is the latest bundle. Its sequence number is used to reject
+	// rollbacks after the restart.
+	Bundle *spiffebundle.Bundle
+
+	// ETag is the ETag of the latest bundle, if provided by the endpoint.
+	ETag string
+}
+
+// CheckpointStore persists the state of bundle watches. Implementations must
+// be safe for concurrent use.
+type CheckpointStore interface {
+	// LoadCheckpoint returns the checkpoint for the trust domain, or nil if
+	// there is none.
+	LoadCheckpoint(trustDomain spiffeid.TrustDomain) (*WatchCheckpoint, error)
+
+	// StoreCheckpoint stores the checkpoint for the trust domain.
+	StoreCheckpoint(trustDomain spiffeid.TrustDomain, checkpoint WatchCheckpoint) error
+}
+
+// WithCheckpointStore stores the latest fetched bundle, along with its ETag,
+// in the checkpoint store. FetchBundle returns the stored bundle if the
+// bundle cannot be fetched, and WatchBundle delivers the stored bundle to the
+// watcher before the first fetch, so that relying parties can survive an
+// endpoint outage across restarts. WatchBundle also uses the stored ETag
+// for the first conditional request, avoiding a full refetch, and rejects
+// bundles rolling back the stored sequence number.
+func WithCheckpointStore(store CheckpointStore) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if store == nil {
+			return federationErr.New("checkpoint store cannot be nil")
+		}
+		o.checkpoints = store
+		return nil
+	})
+}
+
+// WithCacheFile is like WithCheckpointStore with a store that keeps the
+// checkpoint, along with the fetch time, in the given file.
+func WithCacheFile(path string) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if path == "" {
+			return federationErr.New("cache file path cannot be empty")
+		}
+		o.checkpoints = NewFileCheckpointStore(path)
+		return nil
+	})
+}
+
+// NewFileCheckpointStore returns a CheckpointStore keeping the checkpoint of
+// a single trust domain in the given file. The file is written atomically.
+func NewFileCheckpointStore(path string) CheckpointStore {
+	return fileCheckpointStore{path: path}
+}
+
+type fileCheckpointStore struct {
+	path string
+}
+
+func (s fileCheckpointStore) LoadCheckpoint(trustDomain spiffeid.TrustDomain) (*WatchCheckpoint, error) {
+	bundle, etag, err := loadCachedBundle(s.path, trustDomain)
+	if err != nil || bundle == nil {
+		return nil, err
+	}
+	return &WatchCheckpoint{Bundle: bundle, ETag: etag}, nil
+}
+
+func (s fileCheckpointStore) StoreCheckpoint(trustDomain spiffeid.TrustDomain, checkpoint WatchCheckpoint) error {
+	return storeCachedBundle(s.path, trustDomain, checkpoint.Bundle, checkpoint.ETag)
+}
+
+// cachedBundle is the content of the cache file.
+type cachedBundle struct {
+	TrustDomain string          `json:"trust_domain"`
+	ETag        string          `json:"etag,omitempty"`
+	FetchedAt   time.Time       `json:"fetched_at"`
+	Bundle      json.RawMessage `json:"bundle"`
+}
+
+// loadCheckpoint loads the checkpoint for the trust domain from the store,
+// checking that its bundle is for the trust domain.
+func loadCheckpoint(store CheckpointStore, trustDomain spiffeid.TrustDomain) (*WatchCheckpoint, error) {
+	checkpoint, err := store.LoadCheckpoint(trustDomain)
+	switch {
+	case err != nil:
+		return nil, err
+	case checkpoint == nil || checkpoint.Bundle == nil:
+		return nil, nil
+	case checkpoint.Bundle.TrustDomain() != trustDomain:
+		return nil, &FetchError{
+			Class: ErrWrongTrustDomain,
+			Err:   federationErr.New("checkpoint is for trust domain %q, not %q", checkpoint.Bundle.TrustDomain(), trustDomain),
+		}
+	}
+	return checkpoint, nil
+}
+
+// loadCachedBundle loads the bundle for the trust domain from the cache
+// file. It returns a nil bundle if the file does not exist.
+func loadCachedBundle(path string, trustDomain spiffeid.TrustDomain) (*spiffebundle.Bundle, string, error) {
+	data, err := ioutil.ReadFile(path)
+	switch {
+	case os.IsNotExist(err):
+		return nil, "", nil
+	case err != nil:
+		return nil, "", federationErr.New("unable to read bundle cache: %w", err)
+	}
+
+	var cached cachedBundle
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, "", federationErr.New("unable to parse bundle cache: %w", err)
+	}
+	if cached.TrustDomain != trustDomain.String() {
+		return nil, "", &FetchError{
+			Class: ErrWrongTrustDomain,
+			Err:   federationErr.New("bundle cache is for trust domain %q, not %q", cached.TrustDomain, trustDomain),
+		}
+	}
+	bundle, err := spiffebundle.Parse(trustDomain, cached.Bundle)
+	if err != nil {
+		return nil, "", federationErr.New("unable to parse cached bundle: %w", err)
+	}
+	return bundle, cached.ETag, nil
+}
+
+// storeCachedBundle atomically writes the bundle to the cache file.
+func storeCachedBundle(path string, trustDomain spiffeid.TrustDomain, bundle *spiffebundle.Bundle, etag string) error {
+	raw, err := bundle.Marshal()
+	if err != nil {
+		return federationErr.New("unable to marshal bundle for cache: %w", err)
+	}
+	data, err := json.Marshal(cachedBundle{
+		TrustDomain: trustDomain.String(),
+		ETag:        etag,
+		FetchedAt:   time.Now().UTC(),
+		Bundle:      raw,
+	})
+	if err != nil {
+		return federationErr.New("unable to marshal bundle cache: %w", err)
+	}
+
+	if err := writeFileAtomically(path, data); err != nil {
+		return federationErr.New("unable to write bundle cache: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomically writes the data to a temporary file first, then
+// renames it, so that a crash does not leave a partially written file behind.
+func writeFileAtomically(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
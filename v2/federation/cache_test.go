@@ -0,0 +1,228 @@
+package federation_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakebundleendpoint"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheFile(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "bundle.json")
+	bundle := test.NewCA(t, td).Bundle()
+
+	be := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(bundle))
+	defer be.Shutdown()
+
+	// FetchBundle stores the fetched bundle.
+	fetchedBundle, err := federation.FetchBundle(context.Background(), td, be.FetchBundleURL(),
+		federation.WithWebPKIRoots(be.RootCAs()),
+		federation.WithCacheFile(cacheFile))
+	require.NoError(t, err)
+	require.Equal(t, bundle, fetchedBundle)
+	data, err := ioutil.ReadFile(cacheFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), `"trust_domain":"domain.test"`)
+
+	// The endpoint is now failing, but FetchBundle falls back to the cached
+	// bundle.
+	_, err = federation.FetchBundle(context.Background(), td, be.FetchBundleURL(),
+		federation.WithWebPKIRoots(be.RootCAs()))
+	require.EqualError(t, err, "federation: spiffebundle: unable to parse JWKS: unexpected end of JSON input")
+	fetchedBundle, err = federation.FetchBundle(context.Background(), td, be.FetchBundleURL(),
+		federation.WithWebPKIRoots(be.RootCAs()),
+		federation.WithCacheFile(cacheFile))
+	require.NoError(t, err)
+	require.Equal(t, bundle, fetchedBundle)
+	_, err = federation.FetchBundle(context.Background(), spiffeid.RequireTrustDomainFromString("other.test"), be.FetchBundleURL(),
+		federation.WithWebPKIRoots(be.RootCAs()),
+		federation.WithCacheFile(cacheFile))
+	require.EqualError(t, err, "federation: spiffebundle: unable to parse JWKS: unexpected end of JSON input")
+
+	// WatchBundle serves the cached bundle too.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &recordingWatcher{cancelAfter: 1, cancel: cancel}
+	err = federation.WatchBundle(ctx, td, be.FetchBundleURL(), watcher,
+		federation.WithWebPKIRoots(be.RootCAs()),
+		federation.WithCacheFile(cacheFile))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []*spiffebundle.Bundle{bundle}, watcher.updates)
+	require.Len(t, watcher.errs, 1)
+	assert.EqualError(t, watcher.errs[0], "federation: spiffebundle: unable to parse JWKS: unexpected end of JSON input")
+
+	// The cache is not used for other trust domains.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	watcher = &recordingWatcher{cancelAfter: 1, cancel: cancel}
+	err = federation.WatchBundle(ctx, spiffeid.RequireTrustDomainFromString("other.test"), be.FetchBundleURL(), watcher,
+		federation.WithWebPKIRoots(be.RootCAs()),
+		federation.WithCacheFile(cacheFile))
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, watcher.updates)
+	require.Len(t, watcher.errs, 2)
+	assert.EqualError(t, watcher.errs[0], `federation: bundle cache is for trust domain "domain.test", not "other.test"`)
+}
+
+func TestWatchBundle_StoresCacheFile(t *testing.T) {
+	cacheFile := filepath.Join(t.TempDir(), "bundle.json")
+	bundle := test.NewCA(t, td).Bundle()
+
+	be := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(bundle))
+	defer be.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &recordingWatcher{cancelAfter: 1, cancel: cancel}
+	err := federation.WatchBundle(ctx, td, be.FetchBundleURL(), watcher,
+		federation.WithWebPKIRoots(be.RootCAs()),
+		federation.WithCacheFile(cacheFile))
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, watcher.errs)
+
+	// The next watch starts from the cached bundle.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	watcher = &recordingWatcher{cancelAfter: 1, cancel: cancel}
+	_ = federation.WatchBundle(ctx, td, be.FetchBundleURL(), watcher,
+		federation.WithWebPKIRoots(be.RootCAs()),
+		federation.WithCacheFile(cacheFile))
+	assert.Equal(t, []*spiffebundle.Bundle{bundle}, watcher.updates)
+}
+
+func TestWatchBundle_CheckpointStore(t *testing.T) {
+	bundle := test.NewCA(t, td).Bundle()
+	bundle.SetSequenceNumber(5)
+	staleBundle := test.NewCA(t, td).Bundle()
+	staleBundle.SetSequenceNumber(4)
+
+	var mtx sync.Mutex
+	served := bundle
+	var ifNoneMatch []string
+	handler, err := federation.NewHandler(td, sourceFunc(func(spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return served, nil
+	}))
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		mtx.Unlock()
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	store := &memoryCheckpointStore{}
+	watch := func() *recordingWatcher {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		watcher := &recordingWatcher{cancelAfter: 1, cancel: cancel}
+		err := federation.WatchBundle(ctx, td, server.URL, watcher, federation.WithCheckpointStore(store))
+		require.Equal(t, context.Canceled, err)
+		return watcher
+	}
+
+	// The first watch stores its checkpoint.
+	watcher := watch()
+	assert.Equal(t, []*spiffebundle.Bundle{bundle}, watcher.updates)
+	require.NotNil(t, store.checkpoint)
+	assert.Equal(t, bundle, store.checkpoint.Bundle)
+	assert.NotEmpty(t, store.checkpoint.ETag)
+
+	// A restarted watch resumes from the checkpoint, so the bundle is not
+	// fetched again.
+	watcher = watch()
+	assert.Equal(t, []*spiffebundle.Bundle{bundle}, watcher.updates)
+	assert.Empty(t, watcher.errs)
+
+	// A restarted watch rejects rollbacks of the sequence number.
+	mtx.Lock()
+	served = staleBundle
+	mtx.Unlock()
+	watcher = watch()
+	assert.Equal(t, []*spiffebundle.Bundle{bundle}, watcher.updates)
+	require.Len(t, watcher.errs, 1)
+	var rollbackErr *federation.SequenceNumberRollbackError
+	assert.True(t, errors.As(watcher.errs[0], &rollbackErr))
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, ifNoneMatch, 3)
+	assert.Empty(t, ifNoneMatch[0])
+	assert.Equal(t, store.checkpoint.ETag, ifNoneMatch[1])
+	assert.Equal(t, store.checkpoint.ETag, ifNoneMatch[2])
+}
+
+func TestFetchBundle_CheckpointStoreRejectsRollback(t *testing.T) {
+	ca := test.NewCA(t, td)
+	latest := ca.Bundle()
+	latest.SetSequenceNumber(2)
+	stale := ca.Bundle()
+	stale.SetSequenceNumber(1)
+	store := &memoryCheckpointStore{checkpoint: &federation.WatchCheckpoint{Bundle: latest, ETag: "latest"}}
+
+	be := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(stale))
+	defer be.Shutdown()
+
+	_, err := federation.FetchBundle(context.Background(), td, be.FetchBundleURL(),
+		federation.WithWebPKIRoots(be.RootCAs()),
+		federation.WithCheckpointStore(store))
+	var rollbackErr *federation.SequenceNumberRollbackError
+	require.True(t, errors.As(err, &rollbackErr))
+	assert.Equal(t, &federation.SequenceNumberRollbackError{TrustDomain: td, Latest: 2, Received: 1}, rollbackErr)
+
+	// The stale bundle is not stored.
+	assert.Equal(t, &federation.WatchCheckpoint{Bundle: latest, ETag: "latest"}, store.checkpoint)
+}
+
+func TestWatchBundle_CheckpointStoreWrongTrustDomain(t *testing.T) {
+	otherTD := spiffeid.RequireTrustDomainFromString("other.test")
+	store := &memoryCheckpointStore{checkpoint: &federation.WatchCheckpoint{Bundle: test.NewCA(t, otherTD).Bundle()}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &recordingWatcher{cancelAfter: 1, cancel: cancel}
+	err := federation.WatchBundle(ctx, td, "https://127.0.0.1:1", watcher, federation.WithCheckpointStore(store))
+	require.Equal(t, context.Canceled, err)
+	require.NotEmpty(t, watcher.errs)
+	assert.EqualError(t, watcher.errs[0], `federation: checkpoint is for trust domain "other.test", not "domain.test"`)
+	assert.True(t, errors.Is(watcher.errs[0], federation.ErrWrongTrustDomain))
+	assert.Empty(t, watcher.updates)
+}
+
+type memoryCheckpointStore struct {
+	mtx        sync.Mutex
+	checkpoint *federation.WatchCheckpoint
+}
+
+func (s *memoryCheckpointStore) LoadCheckpoint(spiffeid.TrustDomain) (*federation.WatchCheckpoint, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.checkpoint, nil
+}
+
+func (s *memoryCheckpointStore) StoreCheckpoint(_ spiffeid.TrustDomain, checkpoint federation.WatchCheckpoint) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.checkpoint = &checkpoint
+	return nil
+}
+
+type sourceFunc func(spiffeid.TrustDomain) (*spiffebundle.Bundle, error)
+
+func (f sourceFunc) GetBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+	return f(trustDomain)
+}
@@ -0,0 +1,137 @@
+package federation
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize is the size under which bundles are not worth compressing.
+const minCompressSize = 1024
+
+// WithoutCompression disables the compression of bundles. By default,
+// bundles of at least 1 KiB are compressed with gzip or deflate when the
+// client accepts it.
+func WithoutCompression() HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		c.disableCompression = true
+		return nil
+	})
+}
+
+// negotiateEncoding returns the content encoding to use for the response
+// given the Accept-Encoding header value of the request, or an empty string
+// if the response should not be encoded. gzip is preferred over deflate when
+// the client has no preference.
+func negotiateEncoding(acceptEncoding string) string {
+	qs := parseQValues(acceptEncoding)
+
+	// The wildcard applies to the encodings not explicitly listed.
+	if q, ok := qs["*"]; ok {
+		for _, coding := range []string{"gzip", "deflate"} {
+			if _, ok := qs[coding]; !ok {
+				qs[coding] = q
+			}
+		}
+	}
+
+	switch {
+	case qs["gzip"] > 0 && qs["gzip"] >= qs["deflate"]:
+		return "gzip"
+	case qs["deflate"] > 0:
+		return "deflate"
+	default:
+		return ""
+	}
+}
+
+// parseQValues parses a header value listing values with optional quality
+// values, like Accept or Accept-Encoding, returning the quality value of
+// each lowercased value.
+func parseQValues(header string) map[string]float64 {
+	qs := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		value := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				v, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					v = 0
+				}
+				q = v
+			}
+		}
+		qs[value] = q
+	}
+	return qs
+}
+
+// compressionCache keeps the last compressed representation of each
+// encoding so that unchanged bundles are not compressed on every request.
+type compressionCache struct {
+	mtx     sync.Mutex
+	entries map[string]compressedEntry
+}
+
+type compressedEntry struct {
+	etag string
+	data []byte
+}
+
+func (c *compressionCache) compress(encoding, etag string, data []byte) ([]byte, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if entry, ok := c.entries[encoding]; ok && entry.etag == etag {
+		return entry.data, nil
+	}
+
+	buf := new(bytes.Buffer)
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	default:
+		// The HTTP deflate coding is the zlib format (RFC 1950), not raw
+		// DEFLATE.
+		w = zlib.NewWriter(buf)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[string]compressedEntry)
+	}
+	c.entries[encoding] = compressedEntry{etag: etag, data: buf.Bytes()}
+	return buf.Bytes(), nil
+}
+
+// encodeBundle returns the representation of the marshaled bundle to send
+// and its content encoding, if any.
+func encodeBundle(r *http.Request, conf *handlerConfig, etag string, data []byte) ([]byte, string) {
+	if conf.disableCompression || len(data) < minCompressSize {
+		return data, ""
+	}
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return data, ""
+	}
+	compressed, err := conf.compression.compress(encoding, etag, data)
+	if err != nil {
+		conf.log.Warnf("unable to compress bundle: %v", err)
+		return data, ""
+	}
+	return compressed, encoding
+}
@@ -0,0 +1,25 @@
+package federation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for acceptEncoding, expected := range map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"deflate":                 "deflate",
+		"deflate, gzip":           "gzip",
+		"gzip;q=0.5, deflate":     "deflate",
+		"GZIP;q=0.8, br":          "gzip",
+		"*":                       "gzip",
+		"gzip;q=0, *":             "deflate",
+		"gzip;q=0, deflate;q=0":   "",
+		"gzip;q=invalid, deflate": "deflate",
+	} {
+		assert.Equal(t, expected, negotiateEncoding(acceptEncoding), acceptEncoding)
+	}
+}
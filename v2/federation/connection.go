@@ -0,0 +1,86 @@
+package federation
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultDialTimeout is the dial timeout of http.DefaultTransport.
+const defaultDialTimeout = 30 * time.Second
+
+// WithHTTP2 enables or disables HTTP/2 when reaching the bundle endpoint.
+// HTTP/2 is enabled by default, which lets long-running watchers multiplex
+// polls over a single connection.
+func WithHTTP2(enabled bool) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		o.connection.http2 = &enabled
+		return nil
+	})
+}
+
+// WithIdleConnections sets how many idle connections to each bundle endpoint
+// host are kept, and how long, so that subsequent polls reuse them instead
+// of dialing and performing a TLS handshake again. The idle timeout should
+// be longer than the poll interval for connections to be reused by
+// WatchBundle. By default, two idle connections per host are kept for 90
+// seconds.
+func WithIdleConnections(maxPerHost int, timeout time.Duration) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if maxPerHost <= 0 || timeout <= 0 {
+			return federationErr.New("idle connection limit and timeout must be positive")
+		}
+		o.connection.maxIdlePerHost = maxPerHost
+		o.connection.idleTimeout = timeout
+		return nil
+	})
+}
+
+// WithKeepAlive sets the period of the TCP keep-alives sent on the
+// connections to the bundle endpoint. A negative period disables them. By
+// default, keep-alives are sent every 30 seconds.
+func WithKeepAlive(period time.Duration) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if period == 0 {
+			return federationErr.New("keep-alive period cannot be zero")
+		}
+		o.connection.keepAlive = period
+		return nil
+	})
+}
+
+// connectionConfig tunes the connections of the HTTP transport.
+type connectionConfig struct {
+	http2          *bool
+	maxIdlePerHost int
+	idleTimeout    time.Duration
+	keepAlive      time.Duration
+}
+
+func (c connectionConfig) isSet() bool {
+	return c != connectionConfig{}
+}
+
+func (c connectionConfig) apply(transport *http.Transport) {
+	if c.http2 != nil {
+		transport.ForceAttemptHTTP2 = *c.http2
+		if !*c.http2 {
+			// A non-nil empty map disables HTTP/2.
+			transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+		}
+	}
+	if c.maxIdlePerHost > 0 {
+		transport.MaxIdleConnsPerHost = c.maxIdlePerHost
+		if transport.MaxIdleConns != 0 && transport.MaxIdleConns < c.maxIdlePerHost {
+			transport.MaxIdleConns = c.maxIdlePerHost
+		}
+		transport.IdleConnTimeout = c.idleTimeout
+	}
+	if c.keepAlive != 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   defaultDialTimeout,
+			KeepAlive: c.keepAlive,
+		}).DialContext
+	}
+}
@@ -0,0 +1,94 @@
+package federation_test
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchBundle_ConnectionReuse(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		options    []federation.FetchOption
+		protoMajor int
+	}{
+		{
+			name:       "HTTP/2",
+			protoMajor: 2,
+		},
+		{
+			name: "HTTP/1.1",
+			options: []federation.FetchOption{
+				federation.WithHTTP2(false),
+				federation.WithIdleConnections(1, time.Minute),
+				federation.WithKeepAlive(time.Second),
+			},
+			protoMajor: 1,
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := federation.NewHandler(td, test.NewCA(t, td).Bundle())
+			require.NoError(t, err)
+
+			var mtx sync.Mutex
+			var newConns int
+			var protoMajors []int
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mtx.Lock()
+				protoMajors = append(protoMajors, r.ProtoMajor)
+				mtx.Unlock()
+				handler.ServeHTTP(w, r)
+			}))
+			server.EnableHTTP2 = true
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					mtx.Lock()
+					newConns++
+					mtx.Unlock()
+				}
+			}
+			server.StartTLS()
+			defer server.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			watcher := &recordingWatcher{cancelAfter: 3, cancel: cancel}
+			options := append([]federation.FetchOption{
+				federation.WithWebPKIRoots(x509util.NewCertPool([]*x509.Certificate{server.Certificate()})),
+			}, tt.options...)
+			err = federation.WatchBundle(ctx, td, server.URL, watcher, options...)
+			assert.Equal(t, context.Canceled, err)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			assert.Equal(t, 1, newConns)
+			assert.Equal(t, []int{tt.protoMajor, tt.protoMajor, tt.protoMajor}, protoMajors)
+		})
+	}
+}
+
+func TestConnectionOptions_Invalid(t *testing.T) {
+	for _, tt := range []struct {
+		option federation.FetchOption
+		err    string
+	}{
+		{option: federation.WithIdleConnections(0, time.Minute), err: "federation: idle connection limit and timeout must be positive"},
+		{option: federation.WithIdleConnections(1, 0), err: "federation: idle connection limit and timeout must be positive"},
+		{option: federation.WithKeepAlive(0), err: "federation: keep-alive period cannot be zero"},
+	} {
+		_, err := federation.FetchBundle(context.Background(), td, "url not used", tt.option)
+		assert.EqualError(t, err, tt.err)
+	}
+}
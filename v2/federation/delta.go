@@ -0,0 +1,147 @@
+package federation
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"sort"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/cryptoutil"
+)
+
+// BundleDelta describes how a bundle changed from the previous one.
+type BundleDelta struct {
+	// Previous is the previous bundle, or nil if Current is the first
+	// bundle.
+	Previous *spiffebundle.Bundle
+
+	// Current is the updated bundle.
+	Current *spiffebundle.Bundle
+
+	// AddedX509Authorities are the X.509 authorities in Current that are not
+	// in Previous.
+	AddedX509Authorities []*x509.Certificate
+
+	// RemovedX509Authorities are the X.509 authorities in Previous that are
+	// not in Current.
+	RemovedX509Authorities []*x509.Certificate
+
+	// AddedJWTAuthorities are the JWT authorities in Current that are not in
+	// Previous, keyed by key ID. An authority whose key changed while
+	// keeping its key ID is both removed and added.
+	AddedJWTAuthorities map[string]crypto.PublicKey
+
+	// RemovedJWTAuthorities are the JWT authorities in Previous that are not
+	// in Current, keyed by key ID.
+	RemovedJWTAuthorities map[string]crypto.PublicKey
+}
+
+// BundleDeltaWatcher is a BundleWatcher that is also notified of how the
+// bundle changed on each update. WatchBundle calls OnDelta, right after
+// OnUpdate, when the watcher passed to it implements this interface. Like
+// OnUpdate, it is called synchronously and should return quickly.
+type BundleDeltaWatcher interface {
+	BundleWatcher
+
+	// OnDelta is called with the changes of the bundle passed to the
+	// preceding OnUpdate call.
+	OnDelta(delta BundleDelta)
+}
+
+// DiffBundles returns the changes from the previous bundle, which can be nil,
+// to the current bundle.
+func DiffBundles(previous, current *spiffebundle.Bundle) BundleDelta {
+	delta := BundleDelta{
+		Previous:              previous,
+		Current:               current,
+		AddedJWTAuthorities:   make(map[string]crypto.PublicKey),
+		RemovedJWTAuthorities: make(map[string]crypto.PublicKey),
+	}
+
+	var previousX509Authorities []*x509.Certificate
+	var previousJWTAuthorities map[string]crypto.PublicKey
+	if previous != nil {
+		previousX509Authorities = previous.X509Authorities()
+		previousJWTAuthorities = previous.JWTAuthorities()
+	}
+	currentJWTAuthorities := current.JWTAuthorities()
+
+	delta.AddedX509Authorities = missingCertificates(current.X509Authorities(), previousX509Authorities)
+	delta.RemovedX509Authorities = missingCertificates(previousX509Authorities, current.X509Authorities())
+	for keyID, key := range currentJWTAuthorities {
+		if previousKey, ok := previousJWTAuthorities[keyID]; !ok || !publicKeyEqual(previousKey, key) {
+			delta.AddedJWTAuthorities[keyID] = key
+		}
+	}
+	for keyID, key := range previousJWTAuthorities {
+		if currentKey, ok := currentJWTAuthorities[keyID]; !ok || !publicKeyEqual(currentKey, key) {
+			delta.RemovedJWTAuthorities[keyID] = key
+		}
+	}
+	return delta
+}
+
+// AuthoritiesChanged returns true if authorities were added or removed.
+func (d BundleDelta) AuthoritiesChanged() bool {
+	return len(d.AddedX509Authorities) > 0 || len(d.RemovedX509Authorities) > 0 ||
+		len(d.AddedJWTAuthorities) > 0 || len(d.RemovedJWTAuthorities) > 0
+}
+
+// SequenceNumberChanged returns true if the sequence number of the current
+// bundle differs from the one of the previous bundle, including when only one
+// of them has a sequence number.
+func (d BundleDelta) SequenceNumberChanged() bool {
+	current, currentOK := d.Current.SequenceNumber()
+	if d.Previous == nil {
+		return currentOK
+	}
+	previous, previousOK := d.Previous.SequenceNumber()
+	return current != previous || currentOK != previousOK
+}
+
+// String returns a summary of the changes, suitable for logging.
+func (d BundleDelta) String() string {
+	s := fmt.Sprintf("X.509 authorities added: %d, removed: %d; JWT authorities added: %s, removed: %s",
+		len(d.AddedX509Authorities), len(d.RemovedX509Authorities),
+		keyIDList(d.AddedJWTAuthorities), keyIDList(d.RemovedJWTAuthorities))
+	if d.SequenceNumberChanged() {
+		current, _ := d.Current.SequenceNumber()
+		s += fmt.Sprintf("; sequence number: %d", current)
+	}
+	return s
+}
+
+// missingCertificates returns the certificates in certs that are not in
+// others.
+func missingCertificates(certs, others []*x509.Certificate) []*x509.Certificate {
+	var missing []*x509.Certificate
+	for _, cert := range certs {
+		found := false
+		for _, other := range others {
+			if cert.Equal(other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, cert)
+		}
+	}
+	return missing
+}
+
+func publicKeyEqual(a, b crypto.PublicKey) bool {
+	equal, _ := cryptoutil.PublicKeyEqual(a, b)
+	return equal
+}
+
+// keyIDList returns the sorted key IDs of the JWT authorities, e.g. [a b].
+func keyIDList(jwtAuthorities map[string]crypto.PublicKey) string {
+	keyIDs := make([]string, 0, len(jwtAuthorities))
+	for keyID := range jwtAuthorities {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	return fmt.Sprint(keyIDs)
+}
@@ -0,0 +1,52 @@
+package federation_test
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffBundles(t *testing.T) {
+	ca1 := test.NewCA(t, td)
+	ca2 := test.NewCA(t, td)
+	key1 := ca1.JWTAuthorities()
+	key2 := ca2.JWTAuthorities()
+
+	previous := spiffebundle.FromX509Authorities(td, ca1.X509Authorities())
+	current := spiffebundle.FromX509Authorities(td, ca2.X509Authorities())
+	for _, key := range key1 {
+		assert.NoError(t, previous.AddJWTAuthority("kept", key))
+		assert.NoError(t, previous.AddJWTAuthority("rotated", key))
+		assert.NoError(t, previous.AddJWTAuthority("removed", key))
+		assert.NoError(t, current.AddJWTAuthority("kept", key))
+	}
+	for _, key := range key2 {
+		assert.NoError(t, current.AddJWTAuthority("rotated", key))
+		assert.NoError(t, current.AddJWTAuthority("added", key))
+	}
+	current.SetSequenceNumber(1)
+
+	delta := federation.DiffBundles(previous, current)
+	assert.Equal(t, ca2.X509Authorities(), delta.AddedX509Authorities)
+	assert.Equal(t, ca1.X509Authorities(), delta.RemovedX509Authorities)
+	assert.Equal(t, map[string]crypto.PublicKey{"rotated": current.JWTAuthorities()["rotated"], "added": current.JWTAuthorities()["added"]}, delta.AddedJWTAuthorities)
+	assert.Equal(t, map[string]crypto.PublicKey{"rotated": previous.JWTAuthorities()["rotated"], "removed": previous.JWTAuthorities()["removed"]}, delta.RemovedJWTAuthorities)
+	assert.True(t, delta.AuthoritiesChanged())
+	assert.True(t, delta.SequenceNumberChanged())
+	assert.Equal(t, "X.509 authorities added: 1, removed: 1; JWT authorities added: [added rotated], removed: [removed rotated]; sequence number: 1", delta.String())
+
+	delta = federation.DiffBundles(current, current)
+	assert.False(t, delta.AuthoritiesChanged())
+	assert.False(t, delta.SequenceNumberChanged())
+	assert.Equal(t, "X.509 authorities added: 0, removed: 0; JWT authorities added: [], removed: []", delta.String())
+
+	delta = federation.DiffBundles(nil, previous)
+	assert.Nil(t, delta.Previous)
+	assert.Len(t, delta.AddedJWTAuthorities, 3)
+	assert.Empty(t, delta.RemovedX509Authorities)
+	assert.False(t, delta.SequenceNumberChanged())
+}
@@ -0,0 +1,117 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// DiscoveryPath is the well-known path at which a trust domain publishes the
+// location of its bundle endpoint.
+const DiscoveryPath = "/.well-known/spiffe-bundle-endpoint"
+
+// maxDiscoveryDocumentSize limits the size of the discovery document.
+const maxDiscoveryDocumentSize = 64 * 1024
+
+// discoveryDocument is the discovery document served at DiscoveryPath.
+type discoveryDocument struct {
+	URL              string `json:"url"`
+	Profile          string `json:"profile"`
+	EndpointSPIFFEID string `json:"endpoint_spiffe_id,omitempty"`
+}
+
+// DiscoverBundleEndpoint resolves a trust domain to its bundle endpoint, so
+// that federation can be configured with just trust domain names. The
+// discovery document is fetched over HTTPS from the host named after the
+// trust domain, at DiscoveryPath, and looks like:
+//
+//	{
+//	    "url": "https://spire.example.org:8443",
+//	    "profile": "https_spiffe",
+//	    "endpoint_spiffe_id": "spiffe://example.org/spire/server"
+//	}
+//
+// The profile is either "https_web" or "https_spiffe", in which case the
+// endpoint SPIFFE ID is required. The document is authenticated with Web
+// PKI; options like WithWebPKIRoots, WithTransport, WithHTTPClient and
+// WithFetchTimeout apply to the discovery request. The returned bundle
+// endpoint has no bootstrap bundle, which must be obtained out-of-band for
+// the https_spiffe profile.
+//
+// This discovery mechanism is not part of the SPIFFE Federation
+// specification, so it only works with trust domains that publish the
+// document.
+func DiscoverBundleEndpoint(ctx context.Context, trustDomain spiffeid.TrustDomain, options ...FetchOption) (BundleEndpoint, error) {
+	if trustDomain.IsZero() {
+		return BundleEndpoint{}, federationErr.New("trust domain is required")
+	}
+	opts, err := newFetchOptions(options)
+	if err != nil {
+		return BundleEndpoint{}, err
+	}
+	if opts.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.fetchTimeout)
+		defer cancel()
+	}
+
+	discoveryURL := (&url.URL{Scheme: "https", Host: trustDomain.String(), Path: DiscoveryPath}).String()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, http.NoBody)
+	if err != nil {
+		return BundleEndpoint{}, federationErr.New("could not create discovery request: %w", err)
+	}
+	opts.log.Debugf("Discovering bundle endpoint for trust domain %q at %q", trustDomain, discoveryURL)
+	response, err := opts.client.Do(request)
+	if err != nil {
+		return BundleEndpoint{}, classifyRequestError(federationErr.New("could not GET discovery document: %w", err))
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return BundleEndpoint{}, &FetchError{
+			Class: ErrEndpointUnavailable,
+			Err:   federationErr.New("unexpected discovery response status %d", response.StatusCode),
+		}
+	}
+
+	var doc discoveryDocument
+	if err := json.NewDecoder(io.LimitReader(response.Body, maxDiscoveryDocumentSize)).Decode(&doc); err != nil {
+		return BundleEndpoint{}, federationErr.New("unable to parse discovery document: %w", err)
+	}
+	return parseDiscoveryDocument(trustDomain, doc)
+}
+
+func parseDiscoveryDocument(trustDomain spiffeid.TrustDomain, doc discoveryDocument) (BundleEndpoint, error) {
+	endpointURL, err := url.Parse(doc.URL)
+	switch {
+	case err != nil:
+		return BundleEndpoint{}, federationErr.New("invalid bundle endpoint URL in discovery document: %w", err)
+	case endpointURL.Scheme != "https" || endpointURL.Host == "":
+		return BundleEndpoint{}, federationErr.New("bundle endpoint URL in discovery document must be an absolute HTTPS URL")
+	}
+
+	endpoint := BundleEndpoint{
+		TrustDomain: trustDomain,
+		URL:         doc.URL,
+	}
+	switch doc.Profile {
+	case "https_web":
+		endpoint.Profile = ProfileHTTPSWeb
+	case "https_spiffe":
+		endpoint.Profile = ProfileHTTPSSPIFFE
+		if doc.EndpointSPIFFEID == "" {
+			return BundleEndpoint{}, federationErr.New("endpoint SPIFFE ID is required in discovery document for the https_spiffe profile")
+		}
+		endpoint.EndpointID, err = spiffeid.FromString(doc.EndpointSPIFFEID)
+		if err != nil {
+			return BundleEndpoint{}, federationErr.New("invalid endpoint SPIFFE ID in discovery document: %w", err)
+		}
+	default:
+		return BundleEndpoint{}, federationErr.New("unsupported profile %q in discovery document", doc.Profile)
+	}
+	return endpoint, nil
+}
@@ -0,0 +1,111 @@
+package federation_test
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverBundleEndpoint(t *testing.T) {
+	// The certificate of the test server is valid for example.com.
+	exampleTD := spiffeid.RequireTrustDomainFromString("example.com")
+
+	for _, tt := range []struct {
+		name     string
+		doc      string
+		status   int
+		endpoint federation.BundleEndpoint
+		err      string
+	}{
+		{
+			name: "https_web",
+			doc:  `{"url": "https://bundle.example.com/bundle", "profile": "https_web"}`,
+			endpoint: federation.BundleEndpoint{
+				TrustDomain: exampleTD,
+				URL:         "https://bundle.example.com/bundle",
+				Profile:     federation.ProfileHTTPSWeb,
+			},
+		},
+		{
+			name: "https_spiffe",
+			doc:  `{"url": "https://spire.example.com:8443", "profile": "https_spiffe", "endpoint_spiffe_id": "spiffe://example.com/spire/server"}`,
+			endpoint: federation.BundleEndpoint{
+				TrustDomain: exampleTD,
+				URL:         "https://spire.example.com:8443",
+				Profile:     federation.ProfileHTTPSSPIFFE,
+				EndpointID:  spiffeid.RequireFromPath(exampleTD, "/spire/server"),
+			},
+		},
+		{
+			name: "https_spiffe without endpoint SPIFFE ID",
+			doc:  `{"url": "https://spire.example.com:8443", "profile": "https_spiffe"}`,
+			err:  "federation: endpoint SPIFFE ID is required in discovery document for the https_spiffe profile",
+		},
+		{
+			name: "invalid endpoint SPIFFE ID",
+			doc:  `{"url": "https://spire.example.com:8443", "profile": "https_spiffe", "endpoint_spiffe_id": "not-an-id"}`,
+			err:  "federation: invalid endpoint SPIFFE ID in discovery document: scheme is missing or invalid",
+		},
+		{
+			name: "unsupported profile",
+			doc:  `{"url": "https://spire.example.com:8443", "profile": "ftp"}`,
+			err:  `federation: unsupported profile "ftp" in discovery document`,
+		},
+		{
+			name: "insecure URL",
+			doc:  `{"url": "http://bundle.example.com/bundle", "profile": "https_web"}`,
+			err:  "federation: bundle endpoint URL in discovery document must be an absolute HTTPS URL",
+		},
+		{
+			name: "malformed document",
+			doc:  `{`,
+			err:  "federation: unable to parse discovery document: unexpected EOF",
+		},
+		{
+			name:   "not found",
+			status: http.StatusNotFound,
+			err:    "federation: unexpected discovery response status 404",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != federation.DiscoveryPath || r.Host != "example.com" {
+					http.NotFound(w, r)
+					return
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+					return
+				}
+				_, _ = w.Write([]byte(tt.doc))
+			}))
+			defer server.Close()
+
+			// Resolve the trust domain to the test server.
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+				return new(net.Dialer).DialContext(ctx, network, server.Listener.Addr().String())
+			}
+
+			endpoint, err := federation.DiscoverBundleEndpoint(context.Background(), exampleTD,
+				federation.WithTransport(transport),
+				federation.WithWebPKIRoots(x509util.NewCertPool([]*x509.Certificate{server.Certificate()})))
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.endpoint, endpoint)
+		})
+	}
+}
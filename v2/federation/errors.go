@@ -0,0 +1,199 @@
+package federation
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+var (
+	// ErrWrongTrustDomain classifies errors caused by a bundle for another
+	// trust domain than the one requested, e.g. because the bundle endpoint
+	// URL is misconfigured.
+	ErrWrongTrustDomain = errors.New("bundle is for the wrong trust domain")
+
+	// ErrUntrustedEndpointServer classifies errors caused by the bundle
+	// endpoint server failing authentication.
+	ErrUntrustedEndpointServer = errors.New("bundle endpoint server is not trusted")
+
+	// ErrBundleTooLarge classifies errors caused by a bundle endpoint
+	// response exceeding the maximum bundle size.
+	ErrBundleTooLarge = errors.New("bundle is too large")
+
+	// ErrEndpointUnavailable classifies errors caused by the bundle endpoint
+	// being unreachable or failing to serve the bundle. These are usually
+	// transient.
+	ErrEndpointUnavailable = errors.New("bundle endpoint is unavailable")
+
+	// ErrInvalidSignature classifies errors caused by a bundle payload
+	// without a valid signature from one of the keys pinned with
+	// WithSignatureVerification.
+	ErrInvalidSignature = errors.New("bundle signature is invalid")
+
+	// ErrBundlePolicyViolation classifies errors caused by a bundle that
+	// does not meet the requirements set with WithRequireRefreshHint,
+	// WithRequireX509Authorities or WithMaxAuthorities.
+	ErrBundlePolicyViolation = errors.New("bundle violates the policy")
+)
+
+// FetchError is returned from FetchBundle, and passed to the OnError method
+// of the BundleWatcher by WatchBundle, when the error could be classified.
+// It can be matched against ErrWrongTrustDomain, ErrUntrustedEndpointServer,
+// ErrBundleTooLarge, ErrEndpointUnavailable, ErrInvalidSignature or
+// ErrBundlePolicyViolation using errors.Is, and it still wraps the underlying
+// error.
+type FetchError struct {
+	// Class is the classification of the error.
+	Class error
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *FetchError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the target is the classification of the error.
+func (e *FetchError) Is(target error) bool {
+	return e.Class == target
+}
+
+// verificationError marks errors returned by the verification of the bundle
+// endpoint server certificate when using SPIFFE authentication.
+type verificationError struct {
+	err error
+}
+
+func (e verificationError) Error() string {
+	return e.err.Error()
+}
+
+func (e verificationError) Unwrap() error {
+	return e.err
+}
+
+// markVerificationErrors wraps the peer certificate verification of the TLS
+// configuration so that its errors can be classified.
+func markVerificationErrors(config *tls.Config) {
+	verify := config.VerifyPeerCertificate
+	if verify == nil {
+		return
+	}
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if err := verify(rawCerts, verifiedChains); err != nil {
+			return verificationError{err: err}
+		}
+		return nil
+	}
+}
+
+// classifyRequestError classifies an error returned by the HTTP client.
+func classifyRequestError(err error) error {
+	var (
+		verificationErr     verificationError
+		unknownAuthorityErr x509.UnknownAuthorityError
+		certInvalidErr      x509.CertificateInvalidError
+		hostnameErr         x509.HostnameError
+		urlErr              *url.Error
+		netErr              net.Error
+	)
+	// The HTTP client wraps all errors in a *url.Error, which is itself a
+	// net.Error, so the cause has to be inspected.
+	cause := err
+	if errors.As(err, &urlErr) {
+		cause = urlErr.Err
+	}
+	switch {
+	case errors.As(err, &verificationErr),
+		errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &certInvalidErr),
+		errors.As(err, &hostnameErr):
+		return &FetchError{Class: ErrUntrustedEndpointServer, Err: err}
+	case errors.As(cause, &netErr), errors.Is(cause, context.DeadlineExceeded):
+		return &FetchError{Class: ErrEndpointUnavailable, Err: err}
+	default:
+		return err
+	}
+}
+
+// checkBundleTrustDomain returns an error if the X.509 authorities of the
+// bundle identify themselves as belonging to another trust domain.
+func checkBundleTrustDomain(trustDomain spiffeid.TrustDomain, bundle *spiffebundle.Bundle) error {
+	for _, authority := range bundle.X509Authorities() {
+		for _, uri := range authority.URIs {
+			id, err := spiffeid.FromURI(uri)
+			if err != nil {
+				continue
+			}
+			if id.TrustDomain() != trustDomain {
+				return &FetchError{
+					Class: ErrWrongTrustDomain,
+					Err:   federationErr.New("bundle for %q contains an X.509 authority for %q", trustDomain, id.TrustDomain()),
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// SequenceNumberRollbackError is returned when a bundle endpoint serves a
+// bundle with a sequence number lower than the one of a bundle previously
+// served, or without a sequence number after a bundle with one, which may
+// indicate a replayed or stale response.
+type SequenceNumberRollbackError struct {
+	// TrustDomain is the trust domain of the bundle.
+	TrustDomain spiffeid.TrustDomain
+
+	// Latest is the sequence number of the latest accepted bundle.
+	Latest uint64
+
+	// Received is the sequence number of the rejected bundle, or zero if it
+	// has none.
+	Received uint64
+
+	// Missing is true if the rejected bundle has no sequence number.
+	Missing bool
+}
+
+// Error returns a message describing the rollback.
+func (e *SequenceNumberRollbackError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("federation: bundle sequence number for %q went backwards from %d to none", e.TrustDomain, e.Latest)
+	}
+	return fmt.Sprintf("federation: bundle sequence number for %q went backwards from %d to %d", e.TrustDomain, e.Latest, e.Received)
+}
+
+// checkSequenceNumber returns a *SequenceNumberRollbackError if the received
+// bundle has a sequence number lower than the latest one, or has none while
+// the latest one has one. If the latest bundle has no sequence number, the
+// received bundle is not checked.
+func checkSequenceNumber(trustDomain spiffeid.TrustDomain, latest, received *spiffebundle.Bundle) error {
+	latestSeq, ok := latest.SequenceNumber()
+	if !ok {
+		return nil
+	}
+	receivedSeq, ok := received.SequenceNumber()
+	if ok && receivedSeq >= latestSeq {
+		return nil
+	}
+	return &SequenceNumberRollbackError{
+		TrustDomain: trustDomain,
+		Latest:      latestSeq,
+		Received:    receivedSeq,
+		Missing:     !ok,
+	}
+}
@@ -0,0 +1,81 @@
+package federation_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakebundleendpoint"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchBundleErrorClasses(t *testing.T) {
+	t.Run("wrong trust domain", func(t *testing.T) {
+		otherTD := spiffeid.RequireTrustDomainFromString("other.test")
+		ca := test.NewCA(t, otherTD)
+		// Make the authority identify its trust domain, as SPIRE does.
+		authority, _ := test.CreateCACertificate(t, nil, nil, test.WithURIs(otherTD.ID().URL()))
+		bundle := ca.Bundle()
+		bundle.AddX509Authority(authority)
+
+		be := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(bundle))
+		defer be.Shutdown()
+
+		_, err := federation.FetchBundle(context.Background(), td, be.FetchBundleURL(), federation.WithWebPKIRoots(be.RootCAs()))
+		require.EqualError(t, err, `federation: bundle for "domain.test" contains an X.509 authority for "other.test"`)
+		assert.True(t, errors.Is(err, federation.ErrWrongTrustDomain))
+	})
+
+	t.Run("untrusted endpoint server with Web PKI", func(t *testing.T) {
+		be := fakebundleendpoint.New(t)
+		defer be.Shutdown()
+
+		_, err := federation.FetchBundle(context.Background(), td, be.FetchBundleURL())
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, federation.ErrUntrustedEndpointServer))
+	})
+
+	t.Run("untrusted endpoint server with SPIFFE authentication", func(t *testing.T) {
+		ca := test.NewCA(t, td)
+		id := spiffeid.RequireFromPath(td, "/bundle-endpoint")
+		be := fakebundleendpoint.New(t,
+			fakebundleendpoint.WithSPIFFEAuth(ca.Bundle(), ca.CreateX509SVID(id, test.WithIPAddresses(localhostIPs...))))
+		defer be.Shutdown()
+
+		_, err := federation.FetchBundle(context.Background(), td, be.FetchBundleURL(),
+			federation.WithSPIFFEAuth(ca.Bundle(), spiffeid.RequireFromPath(td, "/other")))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, federation.ErrUntrustedEndpointServer))
+	})
+
+	t.Run("endpoint unavailable", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		}))
+		url := server.URL
+		_, err := federation.FetchBundle(context.Background(), td, url)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, federation.ErrEndpointUnavailable))
+
+		// Connection refused
+		server.Close()
+		_, err = federation.FetchBundle(context.Background(), td, url)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, federation.ErrEndpointUnavailable))
+	})
+
+	t.Run("unclassified", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := federation.FetchBundle(ctx, td, "https://127.0.0.1:1")
+		require.Error(t, err)
+		var fetchErr *federation.FetchError
+		assert.False(t, errors.As(err, &fetchErr))
+	})
+}
@@ -2,7 +2,9 @@ package federation_test
 
 import (
 	"context"
+	"crypto/x509"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
@@ -28,6 +30,34 @@ func ExampleFetchBundle_webPKI() {
 	bundle = bundle
 }
 
+func ExampleFetchBundle_webPKIPrivateRoots() {
+	// Obtain a bundle from a server whose certificate is issued by a private
+	// corporate CA instead of a publicly trusted one.
+	endpointURL := "https://bundle.corp.example.org/bundle"
+	trustDomain, err := spiffeid.TrustDomainFromString("example.org")
+	if err != nil {
+		// TODO: handle error
+	}
+
+	caPEM, err := os.ReadFile("corporate-ca.pem")
+	if err != nil {
+		// TODO: handle error
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caPEM) {
+		// TODO: handle error
+	}
+
+	bundle, err := federation.FetchBundle(context.TODO(), trustDomain, endpointURL,
+		federation.WithWebPKIRoots(rootCAs))
+	if err != nil {
+		// TODO: handle error
+	}
+
+	// TODO: use bundle
+	bundle = bundle
+}
+
 func ExampleFetchBundle_sPIFFEAuth() {
 	// Obtain a bundle from the example.org trust domain from a server hosted
 	// at https://example.org/bundle with the
@@ -91,13 +121,13 @@ func ExampleWatchBundle_sPIFFEAuth() {
 	bundleSet := spiffebundle.NewSet(bundle)
 	bundleSet.Add(bundle)
 
-	// TODO: When implementing the watcher's OnUpdate, replace the bundle for
-	// the trust domain in the bundle set so the next connection uses the
-	// updated bundle.
+	// The bundle for the trust domain in the bundle set is replaced on every
+	// update so the next connection uses the updated bundle.
 	var watcher federation.BundleWatcher
 
 	err = federation.WatchBundle(context.TODO(), trustDomain, endpointURL,
-		watcher, federation.WithSPIFFEAuth(bundleSet, serverID))
+		watcher, federation.WithSPIFFEAuth(bundleSet, serverID),
+		federation.WithBundleSetUpdates())
 	if err != nil {
 		// TODO: handle error
 	}
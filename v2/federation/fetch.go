@@ -1,13 +1,21 @@
 package federation
 
 import (
+	"bytes"
 	"context"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
 	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
 	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
 	"github.com/damarescavalcante/go-spiffe/v2/spiffetls/tlsconfig"
 	"github.com/zeebo/errs"
@@ -15,14 +23,43 @@ import (
 
 var federationErr = errs.Class("federation")
 
+// maxDrainSize is the maximum number of bytes read from an unconsumed
+// response body to reuse its connection.
+const maxDrainSize = 4096
+
 // FetchOption is an option used when dialing the bundle endpoint.
 type FetchOption interface {
-	apply(*fetchOptions) error
+	applyFetch(*fetchOptions) error
 }
 
 type fetchOptions struct {
 	transport  *http.Transport
+	httpClient *http.Client
+	tlsConfig  *tls.Config
 	authMethod authMethod
+
+	// spiffeAuthSource is the bundle source passed to WithSPIFFEAuth.
+	spiffeAuthSource x509bundle.Source
+
+	// client is the HTTP client built from the options above.
+	client *http.Client
+
+	checkpoints   CheckpointStore
+	metrics       ClientMetrics
+	maxBundleSize int64
+	fetchTimeout  time.Duration
+	signatureKeys []crypto.PublicKey
+	log           logger.Logger
+	connection    connectionConfig
+	format        BundleFormat
+	policy        bundlePolicy
+
+	// The following are only used by WatchBundle.
+	pollInterval      time.Duration
+	jitter            float64
+	backoff           *backoffConfig
+	degradedThreshold int
+	updateBundleSet   bool
 }
 
 // WithSPIFFEAuth authenticates the bundle endpoint with SPIFFE authentication
@@ -34,21 +71,25 @@ func WithSPIFFEAuth(bundleSource x509bundle.Source, endpointID spiffeid.ID) Fetc
 		if o.authMethod != authMethodDefault {
 			return federationErr.New("cannot use both SPIFFE and Web PKI authentication")
 		}
-		o.transport.TLSClientConfig = tlsconfig.TLSClientConfig(bundleSource, tlsconfig.AuthorizeID(endpointID))
+		o.tlsConfig = tlsconfig.TLSClientConfig(bundleSource, tlsconfig.AuthorizeID(endpointID))
+		markVerificationErrors(o.tlsConfig)
+		o.spiffeAuthSource = bundleSource
 		o.authMethod = authMethodSPIFFE
 		return nil
 	})
 }
 
 // WithWebPKIRoots authenticates the bundle endpoint using Web PKI authentication
-// using the provided X.509 root certificates instead of the system ones. This option
-// cannot be used in conjuntion with WithSPIFFEAuth option.
+// using the provided X.509 root certificates instead of the system ones, e.g.
+// to reach an endpoint whose certificate is issued by a private corporate CA
+// with the https_web profile. This option cannot be used in conjuntion with
+// WithSPIFFEAuth option.
 func WithWebPKIRoots(rootCAs *x509.CertPool) FetchOption {
 	return fetchOption(func(o *fetchOptions) error {
 		if o.authMethod != authMethodDefault {
 			return federationErr.New("cannot use both SPIFFE and Web PKI authentication")
 		}
-		o.transport.TLSClientConfig = &tls.Config{
+		o.tlsConfig = &tls.Config{
 			RootCAs:    rootCAs,
 			MinVersion: tls.VersionTLS12,
 		}
@@ -57,41 +98,329 @@ func WithWebPKIRoots(rootCAs *x509.CertPool) FetchOption {
 	})
 }
 
-// FetchBundle retrieves a bundle from a bundle endpoint.
+// WithTransport provides the HTTP transport used to reach the bundle
+// endpoint, e.g. to go through a proxy or tune timeouts. By default, a clone
+// of http.DefaultTransport is used. When used in conjunction with
+// WithSPIFFEAuth or WithWebPKIRoots, the transport is cloned so that its TLS
+// configuration can be set. This option cannot be used in conjunction with
+// WithHTTPClient.
+func WithTransport(transport *http.Transport) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if o.httpClient != nil {
+			return federationErr.New("cannot use both a custom HTTP client and transport")
+		}
+		o.transport = transport
+		return nil
+	})
+}
+
+// WithHTTPClient provides the HTTP client used to reach the bundle endpoint,
+// e.g. to share a connection pool or set a timeout. When used in conjunction
+// with WithSPIFFEAuth or WithWebPKIRoots, the transport of the client must be
+// an *http.Transport, which is cloned so that its TLS configuration can be
+// set. This option cannot be used in conjunction with WithTransport.
+func WithHTTPClient(client *http.Client) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if o.transport != nil {
+			return federationErr.New("cannot use both a custom HTTP client and transport")
+		}
+		o.httpClient = client
+		return nil
+	})
+}
+
+// WithMaxBundleSize limits the size of the bundle endpoint response body, in
+// bytes. Fetching a larger bundle fails with an error matching
+// ErrBundleTooLarge. By default, the size is not limited.
+func WithMaxBundleSize(max int64) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if max <= 0 {
+			return federationErr.New("max bundle size must be positive")
+		}
+		o.maxBundleSize = max
+		return nil
+	})
+}
+
+// WithFetchTimeout limits the time taken to fetch the bundle, including
+// reading the response body. Fetches that time out fail with an error
+// matching ErrEndpointUnavailable. By default, fetches are only limited by
+// the context.
+func WithFetchTimeout(timeout time.Duration) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if timeout <= 0 {
+			return federationErr.New("fetch timeout must be positive")
+		}
+		o.fetchTimeout = timeout
+		return nil
+	})
+}
+
+// FetchBundle retrieves a bundle from a bundle endpoint. When using
+// WithCheckpointStore or WithCacheFile, the fetched bundle is stored, and the
+// stored bundle is returned if the bundle cannot be fetched, e.g. during an
+// endpoint outage, unless the context is done. The fetched bundle is also
+// checked against the stored one as WatchBundle does: a bundle rolling back
+// the sequence number of the stored one is rejected with a
+// *SequenceNumberRollbackError, and is not stored.
 func FetchBundle(ctx context.Context, trustDomain spiffeid.TrustDomain, url string, option ...FetchOption) (*spiffebundle.Bundle, error) {
-	opts := fetchOptions{
-		transport: http.DefaultTransport.(*http.Transport).Clone(),
+	opts, err := newFetchOptions(option)
+	if err != nil {
+		return nil, err
 	}
-	for _, o := range option {
-		if err := o.apply(&opts); err != nil {
+	var checkpoint *WatchCheckpoint
+	if opts.checkpoints != nil {
+		checkpoint, err = loadCheckpoint(opts.checkpoints, trustDomain)
+		if err != nil {
+			opts.log.Warnf("Unable to load the checkpointed bundle for trust domain %q: %v", trustDomain, err)
+		}
+	}
+
+	resp, err := fetchBundle(ctx, trustDomain, url, "", opts)
+	if err != nil {
+		if checkpoint != nil && ctx.Err() == nil {
+			opts.log.Warnf("Unable to fetch bundle for trust domain %q, using the checkpointed bundle: %v", trustDomain, err)
+			return checkpoint.Bundle, nil
+		}
+		return nil, err
+	}
+	if checkpoint != nil {
+		if err := checkSequenceNumber(trustDomain, checkpoint.Bundle, resp.bundle); err != nil {
 			return nil, err
 		}
 	}
+	if opts.checkpoints != nil {
+		if err := opts.checkpoints.StoreCheckpoint(trustDomain, WatchCheckpoint{Bundle: resp.bundle, ETag: resp.etag}); err != nil {
+			return nil, err
+		}
+	}
+	return resp.bundle, nil
+}
+
+// fetchResponse is the result of fetching a bundle from a bundle endpoint.
+type fetchResponse struct {
+	// bundle is the fetched bundle, or nil if the bundle has not been
+	// modified since it was fetched with the ETag passed to fetchBundle.
+	bundle *spiffebundle.Bundle
+
+	// etag is the ETag of the bundle, if provided by the endpoint.
+	etag string
+
+	// maxAge is the maximum age of the response advertised by the endpoint
+	// in the Cache-Control header, or zero if none.
+	maxAge time.Duration
+}
+
+// fetchBundle retrieves a bundle from a bundle endpoint. If etag is not
+// empty, a conditional request is issued so that the endpoint can avoid
+// sending the bundle again if it has not changed.
+func fetchBundle(ctx context.Context, trustDomain spiffeid.TrustDomain, url string, etag string, opts *fetchOptions) (resp *fetchResponse, err error) {
+	body := &countingReader{}
+	if opts.metrics != nil {
+		start := time.Now()
+		defer func() {
+			opts.metrics.FetchCompleted(FetchMetrics{
+				TrustDomain:  trustDomain,
+				Duration:     time.Since(start),
+				ResponseSize: body.n,
+				NotModified:  err == nil && resp.bundle == nil,
+				Err:          err,
+			})
+		}()
+	}
 
-	var client = &http.Client{
-		Transport: opts.transport,
+	if opts.fetchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.fetchTimeout)
+		defer cancel()
 	}
+
 	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
 	if err != nil {
 		return nil, federationErr.New("could not create request: %w", err)
 	}
-	response, err := client.Do(request)
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	if opts.format != FormatSPIFFE {
+		request.Header.Set("Accept", opts.format.MediaType())
+	}
+	opts.log.Debugf("Fetching bundle for trust domain %q from %q using %s authentication", trustDomain, url, opts.authMethod)
+	response, err := opts.client.Do(request)
 	if err != nil {
-		return nil, federationErr.New("could not GET bundle: %w", err)
+		opts.log.Debugf("Failed to fetch bundle for trust domain %q: %v", trustDomain, err)
+		return nil, classifyRequestError(federationErr.New("could not GET bundle: %w", err))
 	}
-	defer response.Body.Close()
+	defer func() {
+		// Drain what is left of the body so that the connection can be
+		// reused by the next fetch.
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxDrainSize))
+		response.Body.Close()
+	}()
 
-	bundle, err := spiffebundle.Read(trustDomain, response.Body)
-	if err != nil {
+	resp = &fetchResponse{
+		etag:   response.Header.Get("ETag"),
+		maxAge: parseMaxAge(response.Header.Get("Cache-Control")),
+	}
+	if etag != "" && response.StatusCode == http.StatusNotModified {
+		if resp.etag == "" {
+			resp.etag = etag
+		}
+		opts.log.Debugf("Bundle for trust domain %q not modified", trustDomain)
+		return resp, nil
+	}
+
+	if opts.maxBundleSize > 0 && response.ContentLength > opts.maxBundleSize {
+		return nil, bundleTooLargeError(opts.maxBundleSize)
+	}
+	limited := &sizeLimitedReader{r: response.Body, remaining: opts.maxBundleSize}
+	body.r = response.Body
+	if opts.maxBundleSize > 0 {
+		body.r = limited
+	}
+	var payload bytes.Buffer
+	var reader io.Reader = body
+	if opts.signatureKeys != nil {
+		reader = io.TeeReader(body, &payload)
+	}
+	resp.bundle, err = readBundle(trustDomain, response.Header.Get("Content-Type"), reader)
+	switch {
+	case limited.exceeded:
+		return nil, bundleTooLargeError(opts.maxBundleSize)
+	case err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// The response body could not be read in time.
+		return nil, &FetchError{Class: ErrEndpointUnavailable, Err: federationErr.Wrap(err)}
+	case err != nil && (response.StatusCode < 200 || response.StatusCode > 299):
+		// The endpoint did not serve a bundle, which is why it could not be
+		// read.
+		return nil, &FetchError{Class: ErrEndpointUnavailable, Err: federationErr.Wrap(err)}
+	case err != nil:
 		return nil, federationErr.Wrap(err)
 	}
+	if err := checkBundleTrustDomain(trustDomain, resp.bundle); err != nil {
+		return nil, err
+	}
+	if opts.signatureKeys != nil {
+		if err := verifySignature(response.Header.Get(SignatureHeader), payload.Bytes(), opts.signatureKeys); err != nil {
+			return nil, err
+		}
+	}
+	if err := opts.policy.check(trustDomain, resp.bundle); err != nil {
+		return nil, err
+	}
 
-	return bundle, nil
+	opts.log.Debugf("Fetched bundle for trust domain %q with status %d", trustDomain, response.StatusCode)
+	return resp, nil
+}
+
+func bundleTooLargeError(max int64) error {
+	return &FetchError{
+		Class: ErrBundleTooLarge,
+		Err:   federationErr.New("bundle exceeds the maximum size of %d bytes", max),
+	}
+}
+
+// sizeLimitedReader fails reads once more than the remaining number of bytes
+// have been read from the underlying reader.
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		l.exceeded = true
+		return 0, ErrBundleTooLarge
+	}
+	return n, err
+}
+
+// parseMaxAge returns the max-age directive of a Cache-Control header value,
+// or zero if it is not present or invalid.
+func parseMaxAge(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.TrimSpace(directive)
+		if !strings.HasPrefix(strings.ToLower(directive), "max-age=") {
+			continue
+		}
+		seconds, err := strconv.ParseInt(directive[len("max-age="):], 10, 64)
+		if err != nil || seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+func newFetchOptions(option []FetchOption) (*fetchOptions, error) {
+	opts := &fetchOptions{
+		log: logger.Null,
+	}
+	for _, o := range option {
+		if err := o.applyFetch(opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.updateBundleSet {
+		if _, ok := opts.spiffeAuthSource.(*spiffebundle.Set); !ok {
+			return nil, federationErr.New("bundle set updates require SPIFFE authentication with a bundle set")
+		}
+	}
+
+	// The transport is cloned when it needs to be customized, since it may
+	// be shared.
+	customize := opts.tlsConfig != nil || opts.connection.isSet()
+	configure := func(transport *http.Transport) {
+		if opts.tlsConfig != nil {
+			transport.TLSClientConfig = opts.tlsConfig
+		}
+		opts.connection.apply(transport)
+	}
+
+	if opts.httpClient != nil {
+		client := *opts.httpClient
+		if customize {
+			transport, ok := client.Transport.(*http.Transport)
+			switch {
+			case client.Transport == nil:
+				transport = http.DefaultTransport.(*http.Transport).Clone()
+			case ok:
+				transport = transport.Clone()
+			default:
+				return nil, federationErr.New("cannot configure a custom HTTP client transport of type %T", client.Transport)
+			}
+			configure(transport)
+			client.Transport = transport
+		}
+		opts.client = &client
+		return opts, nil
+	}
+
+	transport := opts.transport
+	switch {
+	case transport == nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		configure(transport)
+	case customize:
+		transport = transport.Clone()
+		configure(transport)
+	}
+	opts.client = &http.Client{
+		Transport: transport,
+	}
+	return opts, nil
 }
 
 type fetchOption func(*fetchOptions) error
 
-func (fo fetchOption) apply(opts *fetchOptions) error {
+func (fo fetchOption) applyFetch(opts *fetchOptions) error {
 	return fo(opts)
 }
 
@@ -102,3 +431,14 @@ const (
 	authMethodSPIFFE
 	authMethodWebPKI
 )
+
+func (m authMethod) String() string {
+	switch m {
+	case authMethodSPIFFE:
+		return "SPIFFE"
+	case authMethodWebPKI:
+		return "Web PKI (custom roots)"
+	default:
+		return "Web PKI"
+	}
+}
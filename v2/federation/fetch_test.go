@@ -2,14 +2,23 @@ package federation_test
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/damarescavalcante/go-spiffe/v2/federation"
 	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
 	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakebundleendpoint"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
 	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -108,3 +117,156 @@ func TestFetchBundle_ErrorReadingBundleBody(t *testing.T) {
 	assert.EqualError(t, err, `federation: spiffebundle: unable to parse JWKS: unexpected end of JSON input`)
 	assert.Nil(t, fetchedBundle)
 }
+
+func TestFetchBundle_WithTransport(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+
+	be := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(bundle))
+	defer be.Shutdown()
+
+	proxied := false
+	transport := &http.Transport{
+		Proxy: func(*http.Request) (*url.URL, error) {
+			proxied = true
+			return nil, nil
+		},
+	}
+
+	fetchedBundle, err := federation.FetchBundle(context.Background(), td, be.FetchBundleURL(),
+		federation.WithTransport(transport),
+		federation.WithWebPKIRoots(be.RootCAs()))
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, fetchedBundle)
+	assert.True(t, proxied)
+	// The provided transport is not given the roots.
+	if transport.TLSClientConfig != nil {
+		assert.Nil(t, transport.TLSClientConfig.RootCAs)
+	}
+}
+
+func TestFetchBundle_WithHTTPClient(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+
+	handler, err := federation.NewHandler(td, bundle)
+	require.NoError(t, err)
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	fetchedBundle, err := federation.FetchBundle(context.Background(), td, server.URL,
+		federation.WithHTTPClient(server.Client()))
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, fetchedBundle)
+
+	_, err = federation.FetchBundle(context.Background(), td, server.URL,
+		federation.WithHTTPClient(&http.Client{Transport: roundTripperFunc(http.DefaultTransport.RoundTrip)}),
+		federation.WithWebPKIRoots(x509util.NewCertPool([]*x509.Certificate{server.Certificate()})))
+	assert.EqualError(t, err, "federation: cannot configure a custom HTTP client transport of type federation_test.roundTripperFunc")
+}
+
+func TestFetchBundle_WithHTTPClientAndTransport(t *testing.T) {
+	_, err := federation.FetchBundle(context.Background(), td, "url not used",
+		federation.WithHTTPClient(http.DefaultClient),
+		federation.WithTransport(&http.Transport{}))
+	assert.EqualError(t, err, "federation: cannot use both a custom HTTP client and transport")
+
+	_, err = federation.FetchBundle(context.Background(), td, "url not used",
+		federation.WithTransport(&http.Transport{}),
+		federation.WithHTTPClient(http.DefaultClient))
+	assert.EqualError(t, err, "federation: cannot use both a custom HTTP client and transport")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestFetchBundle_WithMaxBundleSize(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundleBytes, err := ca.Bundle().Marshal()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chunked") != "" {
+			// Flushing before writing the body prevents the Content-Length
+			// header from being set.
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write(bundleBytes)
+	}))
+	defer server.Close()
+
+	_, err = federation.FetchBundle(context.Background(), td, server.URL,
+		federation.WithMaxBundleSize(int64(len(bundleBytes))))
+	require.NoError(t, err)
+
+	for _, url := range []string{server.URL, server.URL + "?chunked=1"} {
+		_, err = federation.FetchBundle(context.Background(), td, url,
+			federation.WithMaxBundleSize(int64(len(bundleBytes)-1)))
+		require.EqualError(t, err, fmt.Sprintf("federation: bundle exceeds the maximum size of %d bytes", len(bundleBytes)-1))
+		assert.True(t, errors.Is(err, federation.ErrBundleTooLarge))
+	}
+
+	_, err = federation.FetchBundle(context.Background(), td, server.URL, federation.WithMaxBundleSize(0))
+	require.EqualError(t, err, "federation: max bundle size must be positive")
+}
+
+func TestFetchBundle_WithFetchTimeout(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Send the headers, then stall while sending the body.
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("{"))
+		w.(http.Flusher).Flush()
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	_, err := federation.FetchBundle(context.Background(), td, server.URL,
+		federation.WithFetchTimeout(50*time.Millisecond))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, federation.ErrEndpointUnavailable))
+
+	_, err = federation.FetchBundle(context.Background(), td, server.URL, federation.WithFetchTimeout(0))
+	require.EqualError(t, err, "federation: fetch timeout must be positive")
+}
+
+func TestFetchBundle_WithBundleFormat(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+
+	newServer := func(opts ...federation.HandlerOption) *httptest.Server {
+		handler, err := federation.NewHandler(td, bundle, opts...)
+		require.NoError(t, err)
+		return httptest.NewServer(handler)
+	}
+	server := newServer(federation.WithAlternativeFormats())
+	defer server.Close()
+	legacyServer := newServer()
+	defer legacyServer.Close()
+
+	fetched, err := federation.FetchBundle(context.Background(), td, server.URL,
+		federation.WithBundleFormat(federation.FormatJWKS))
+	require.NoError(t, err)
+	assert.Empty(t, fetched.X509Authorities())
+	assert.Equal(t, bundle.JWTAuthorities(), fetched.JWTAuthorities())
+
+	fetched, err = federation.FetchBundle(context.Background(), td, server.URL,
+		federation.WithBundleFormat(federation.FormatPEM))
+	require.NoError(t, err)
+	assert.Equal(t, bundle.X509Authorities(), fetched.X509Authorities())
+	assert.Empty(t, fetched.JWTAuthorities())
+
+	// Endpoints without alternative formats serve the SPIFFE format.
+	fetched, err = federation.FetchBundle(context.Background(), td, legacyServer.URL,
+		federation.WithBundleFormat(federation.FormatPEM))
+	require.NoError(t, err)
+	assert.True(t, fetched.Equal(bundle))
+
+	_, err = federation.FetchBundle(context.Background(), td, server.URL,
+		federation.WithBundleFormat(federation.BundleFormat(42)))
+	assert.EqualError(t, err, "federation: unsupported bundle format 42")
+}
@@ -0,0 +1,167 @@
+package federation
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"sort"
+	"strings"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/pemutil"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/go-jose/go-jose/v3"
+)
+
+// BundleFormat is a representation of a bundle.
+type BundleFormat int
+
+const (
+	// FormatSPIFFE is the format outlined in the SPIFFE Trust Domain and
+	// Bundle specification, served as application/json.
+	FormatSPIFFE BundleFormat = iota
+
+	// FormatJWKS is a standard RFC 7517 JWKS document with the JWT
+	// authorities of the bundle and no SPIFFE-specific parameters, served as
+	// application/jwk-set+json.
+	FormatJWKS
+
+	// FormatPEM is the concatenation of the PEM-encoded X.509 authorities of
+	// the bundle, served as application/x-pem-file.
+	FormatPEM
+)
+
+const (
+	spiffeMediaType = "application/json"
+	jwksMediaType   = "application/jwk-set+json"
+	pemMediaType    = "application/x-pem-file"
+)
+
+// MediaType returns the media type of the format.
+func (f BundleFormat) MediaType() string {
+	switch f {
+	case FormatJWKS:
+		return jwksMediaType
+	case FormatPEM:
+		return pemMediaType
+	default:
+		return spiffeMediaType
+	}
+}
+
+// WithAlternativeFormats makes the handler serve the bundle as a JWKS
+// document or as PEM-encoded X.509 authorities, instead of in the SPIFFE
+// format, when the Accept header of the request prefers the media type of
+// FormatJWKS or FormatPEM. This aids interoperability with relying parties
+// that do not support SPIFFE bundles. Requests that accept none of the
+// formats are served the SPIFFE format.
+func WithAlternativeFormats() HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		c.alternativeFormats = true
+		return nil
+	})
+}
+
+// WithBundleFormat requests the bundle in the given format. The fetched
+// bundle only has the authorities the format can represent, i.e. the JWT
+// authorities for FormatJWKS and the X.509 authorities for FormatPEM. The
+// response is parsed according to its media type, so endpoints that do not
+// support alternative formats and serve the SPIFFE format are still
+// supported. This option can also be used to fetch JWKS or PEM documents
+// from endpoints that are not SPIFFE bundle endpoints.
+func WithBundleFormat(format BundleFormat) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		switch format {
+		case FormatSPIFFE, FormatJWKS, FormatPEM:
+		default:
+			return federationErr.New("unsupported bundle format %d", format)
+		}
+		o.format = format
+		return nil
+	})
+}
+
+// negotiateFormat returns the bundle format to serve given the Accept header
+// value of the request. The SPIFFE format is preferred when the client has
+// no preference.
+func negotiateFormat(accept string) BundleFormat {
+	if accept == "" {
+		return FormatSPIFFE
+	}
+	qs := parseQValues(accept)
+	quality := func(mediaType string) float64 {
+		if q, ok := qs[mediaType]; ok {
+			return q
+		}
+		if q, ok := qs["application/*"]; ok {
+			return q
+		}
+		return qs["*/*"]
+	}
+
+	best, bestQ := FormatSPIFFE, quality(spiffeMediaType)
+	for _, format := range []BundleFormat{FormatJWKS, FormatPEM} {
+		if q := quality(format.MediaType()); q > bestQ {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// marshalBundle marshals the bundle in the given format.
+func marshalBundle(bundle *spiffebundle.Bundle, format BundleFormat) ([]byte, error) {
+	switch format {
+	case FormatJWKS:
+		// Keys are sorted so that the document, and therefore its ETag, is
+		// stable.
+		jwtAuthorities := bundle.JWTAuthorities()
+		keyIDs := make([]string, 0, len(jwtAuthorities))
+		for keyID := range jwtAuthorities {
+			keyIDs = append(keyIDs, keyID)
+		}
+		sort.Strings(keyIDs)
+		jwks := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{}}
+		for _, keyID := range keyIDs {
+			jwks.Keys = append(jwks.Keys, jose.JSONWebKey{Key: jwtAuthorities[keyID], KeyID: keyID})
+		}
+		return json.Marshal(jwks)
+	case FormatPEM:
+		return pemutil.EncodeCertificates(bundle.X509Authorities()), nil
+	default:
+		return bundle.Marshal()
+	}
+}
+
+// readBundle reads a bundle in the format given by the media type of the
+// response.
+func readBundle(trustDomain spiffeid.TrustDomain, contentType string, r io.Reader) (*spiffebundle.Bundle, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch strings.ToLower(mediaType) {
+	case jwksMediaType:
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, federationErr.New("unable to read JWKS: %w", err)
+		}
+		var jwks jose.JSONWebKeySet
+		if err := json.Unmarshal(data, &jwks); err != nil {
+			return nil, federationErr.New("unable to parse JWKS: %w", err)
+		}
+		bundle := spiffebundle.New(trustDomain)
+		for i, key := range jwks.Keys {
+			if err := bundle.AddJWTAuthority(key.KeyID, key.Key); err != nil {
+				return nil, federationErr.New("unable to add authority %d of JWKS: %w", i, err)
+			}
+		}
+		return bundle, nil
+	case pemMediaType:
+		x509Bundle, err := x509bundle.Read(trustDomain, r)
+		if err != nil {
+			return nil, federationErr.Wrap(err)
+		}
+		return spiffebundle.FromX509Bundle(x509Bundle), nil
+	default:
+		return spiffebundle.Read(trustDomain, r)
+	}
+}
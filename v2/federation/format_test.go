@@ -0,0 +1,61 @@
+package federation
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	for accept, expected := range map[string]BundleFormat{
+		"":                                      FormatSPIFFE,
+		"*/*":                                   FormatSPIFFE,
+		"application/*":                         FormatSPIFFE,
+		"text/html":                             FormatSPIFFE,
+		"application/json":                      FormatSPIFFE,
+		"application/jwk-set+json":              FormatJWKS,
+		"APPLICATION/X-PEM-FILE":                FormatPEM,
+		"application/json;q=0.5, application/*": FormatJWKS,
+		"application/json;q=0.5, application/x-pem-file": FormatPEM,
+		"application/jwk-set+json;q=0.9, */*":            FormatSPIFFE,
+	} {
+		assert.Equal(t, expected, negotiateFormat(accept), accept)
+	}
+}
+
+func TestMarshalAndReadBundle(t *testing.T) {
+	trustDomain := spiffeid.RequireTrustDomainFromString("domain.test")
+	ca := test.NewCA(t, trustDomain)
+	bundle := ca.Bundle()
+
+	for _, tt := range []struct {
+		format          BundleFormat
+		x509Authorities int
+		jwtAuthorities  int
+	}{
+		{format: FormatSPIFFE, x509Authorities: 1, jwtAuthorities: 1},
+		{format: FormatJWKS, jwtAuthorities: 1},
+		{format: FormatPEM, x509Authorities: 1},
+	} {
+		data, err := marshalBundle(bundle, tt.format)
+		require.NoError(t, err)
+
+		read, err := readBundle(trustDomain, tt.format.MediaType()+"; charset=utf-8", bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, trustDomain, read.TrustDomain())
+		assert.Len(t, read.X509Authorities(), tt.x509Authorities, tt.format.MediaType())
+		assert.Len(t, read.JWTAuthorities(), tt.jwtAuthorities, tt.format.MediaType())
+		if tt.x509Authorities > 0 {
+			assert.True(t, read.HasX509Authority(bundle.X509Authorities()[0]))
+		}
+		for keyID := range bundle.JWTAuthorities() {
+			if tt.jwtAuthorities > 0 {
+				assert.True(t, read.HasJWTAuthority(keyID))
+			}
+		}
+	}
+}
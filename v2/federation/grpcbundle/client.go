@@ -0,0 +1,68 @@
+package grpcbundle
+
+import (
+	"context"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// WatchBundle watches the bundle of the trust domain served over the gRPC
+// connection. The watcher's OnUpdate is called with every new bundle pushed
+// by the server. When the stream fails, OnError is called and the stream is
+// reestablished after the interval returned by the watcher's NextRefresh,
+// which is passed a zero refresh hint. It returns when the context is
+// canceled, returning ctx.Err().
+func WatchBundle(ctx context.Context, conn grpc.ClientConnInterface, trustDomain spiffeid.TrustDomain, watcher federation.BundleWatcher) error {
+	if watcher == nil {
+		return grpcbundleErr.New("watcher cannot be nil")
+	}
+
+	var latest *spiffebundle.Bundle
+	for {
+		var err error
+		latest, err = watchBundle(ctx, conn, trustDomain, watcher, latest)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		watcher.OnError(err)
+
+		timer := time.NewTimer(watcher.NextRefresh(0))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// watchBundle receives bundles from a single stream until it fails,
+// returning the latest bundle received.
+func watchBundle(ctx context.Context, conn grpc.ClientConnInterface, trustDomain spiffeid.TrustDomain, watcher federation.BundleWatcher, latest *spiffebundle.Bundle) (*spiffebundle.Bundle, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := newWatchBundleStream(ctx, conn, trustDomain.String())
+	if err != nil {
+		return latest, grpcbundleErr.New("unable to watch bundle: %w", err)
+	}
+	for {
+		resp := new(wrapperspb.BytesValue)
+		if err := stream.RecvMsg(resp); err != nil {
+			return latest, grpcbundleErr.New("unable to receive bundle: %w", err)
+		}
+		bundle, err := spiffebundle.Parse(trustDomain, resp.GetValue())
+		if err != nil {
+			return latest, grpcbundleErr.Wrap(err)
+		}
+		if !bundle.Equal(latest) {
+			watcher.OnUpdate(bundle)
+			latest = bundle
+		}
+	}
+}
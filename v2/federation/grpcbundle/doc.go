@@ -0,0 +1,11 @@
+// Package grpcbundle provides an experimental gRPC transport for bundle
+// distribution, for use inside meshes where polling an HTTPS bundle endpoint
+// is undesirable. The server pushes a bundle to the client whenever it
+// changes. Unlike the https_web and https_spiffe profiles, this transport is
+// not part of the SPIFFE Federation specification, so it should only be used
+// between parties that both use it; the HTTPS profiles remain the
+// interoperable option. The API may change in future releases.
+//
+// Transport security is configured on the gRPC server and client
+// connection, e.g. with the spiffegrpc/grpccredentials package.
+package grpcbundle
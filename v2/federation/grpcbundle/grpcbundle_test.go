@@ -0,0 +1,159 @@
+package grpcbundle_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation/grpcbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+var td = spiffeid.RequireTrustDomainFromString("domain.test")
+
+func TestWatchBundle(t *testing.T) {
+	bundle := test.NewCA(t, td).Bundle()
+	source := spiffebundle.NewSet(bundle)
+	conn := startServer(t, source)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := newWatcher()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- grpcbundle.WatchBundle(ctx, conn, td, watcher)
+	}()
+
+	assert.Equal(t, bundle, watcher.nextUpdate(t))
+
+	// Changes are pushed to the client.
+	bundle = test.NewCA(t, td).Bundle()
+	source.Add(bundle)
+	assert.Equal(t, bundle, watcher.nextUpdate(t))
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-errCh)
+}
+
+func TestWatchBundle_Errors(t *testing.T) {
+	conn := startServer(t, spiffebundle.NewSet())
+
+	for _, tt := range []struct {
+		trustDomain spiffeid.TrustDomain
+		code        codes.Code
+		msg         string
+	}{
+		{trustDomain: td, code: codes.NotFound, msg: `no bundle for "domain.test"`},
+		{trustDomain: spiffeid.TrustDomain{}, code: codes.InvalidArgument, msg: "invalid trust domain: trust domain is missing"},
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		watcher := newWatcher()
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- grpcbundle.WatchBundle(ctx, conn, tt.trustDomain, watcher)
+		}()
+
+		err := watcher.nextError(t)
+		assert.Equal(t, tt.code, status.Code(err))
+		assert.Contains(t, err.Error(), tt.msg)
+
+		cancel()
+		assert.Equal(t, context.Canceled, <-errCh)
+	}
+
+	err := grpcbundle.WatchBundle(context.Background(), conn, td, nil)
+	assert.EqualError(t, err, "grpcbundle: watcher cannot be nil")
+}
+
+func TestWatchBundle_NonPositivePollInterval(t *testing.T) {
+	bundle := test.NewCA(t, td).Bundle()
+	for _, interval := range []time.Duration{0, -time.Second} {
+		conn := startServer(t, spiffebundle.NewSet(bundle), grpcbundle.WithPollInterval(interval))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		watcher := newWatcher()
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- grpcbundle.WatchBundle(ctx, conn, td, watcher)
+		}()
+
+		// The interval is ignored instead of making the server panic.
+		assert.Equal(t, bundle, watcher.nextUpdate(t))
+		cancel()
+		assert.Equal(t, context.Canceled, <-errCh)
+	}
+}
+
+func startServer(t *testing.T, source spiffebundle.Source, options ...grpcbundle.ServerOption) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	options = append([]grpcbundle.ServerOption{grpcbundle.WithPollInterval(10 * time.Millisecond)}, options...)
+	grpcbundle.NewServer(source, options...).Register(server)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+type watcher struct {
+	updates chan *spiffebundle.Bundle
+	errs    chan error
+}
+
+func newWatcher() *watcher {
+	return &watcher{
+		updates: make(chan *spiffebundle.Bundle, 10),
+		errs:    make(chan error, 10),
+	}
+}
+
+func (w *watcher) NextRefresh(time.Duration) time.Duration {
+	return time.Hour
+}
+
+func (w *watcher) OnUpdate(bundle *spiffebundle.Bundle) {
+	w.updates <- bundle
+}
+
+func (w *watcher) OnError(err error) {
+	w.errs <- err
+}
+
+func (w *watcher) nextUpdate(t *testing.T) *spiffebundle.Bundle {
+	select {
+	case bundle := <-w.updates:
+		return bundle
+	case err := <-w.errs:
+		require.FailNow(t, "unexpected error", "%v", err)
+	case <-time.After(time.Second):
+		require.FailNow(t, "timed out waiting for update")
+	}
+	return nil
+}
+
+func (w *watcher) nextError(t *testing.T) error {
+	select {
+	case err := <-w.errs:
+		return err
+	case <-time.After(time.Second):
+		require.FailNow(t, "timed out waiting for error")
+	}
+	return nil
+}
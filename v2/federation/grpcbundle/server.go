@@ -0,0 +1,122 @@
+package grpcbundle
+
+import (
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/zeebo/errs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const defaultPollInterval = time.Second
+
+var grpcbundleErr = errs.Class("grpcbundle")
+
+// ServerOption is an option for the server.
+type ServerOption interface {
+	apply(*serverConfig)
+}
+
+// WithLogger provides a logger to the server.
+func WithLogger(log logger.Logger) ServerOption {
+	return serverOption(func(c *serverConfig) {
+		c.log = log
+	})
+}
+
+// WithPollInterval sets how often the server checks the bundle source for
+// changes to push to the clients. Defaults to one second. Non-positive
+// intervals are ignored and the default is kept.
+func WithPollInterval(interval time.Duration) ServerOption {
+	return serverOption(func(c *serverConfig) {
+		if interval > 0 {
+			c.pollInterval = interval
+		}
+	})
+}
+
+// Server serves bundles from a bundle source over gRPC.
+type Server struct {
+	source spiffebundle.Source
+	config serverConfig
+}
+
+// NewServer returns a server for the bundles of the source. Clients can
+// watch the bundle of any trust domain the source has a bundle for.
+func NewServer(source spiffebundle.Source, options ...ServerOption) *Server {
+	config := serverConfig{
+		log:          logger.Null,
+		pollInterval: defaultPollInterval,
+	}
+	for _, option := range options {
+		option.apply(&config)
+	}
+	return &Server{
+		source: source,
+		config: config,
+	}
+}
+
+// Register registers the bundle distribution service on the gRPC server.
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	registrar.RegisterService(&serviceDesc, s)
+}
+
+// WatchBundle streams the bundle of the requested trust domain, then every
+// new version of it.
+func (s *Server) WatchBundle(req *wrapperspb.StringValue, stream grpc.ServerStream) error {
+	trustDomain, err := spiffeid.TrustDomainFromString(req.GetValue())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid trust domain: %v", err)
+	}
+
+	ticker := time.NewTicker(s.config.pollInterval)
+	defer ticker.Stop()
+
+	var sent *spiffebundle.Bundle
+	for {
+		bundle, err := s.source.GetBundleForTrustDomain(trustDomain)
+		switch {
+		case err != nil && sent == nil:
+			// The reason is not disclosed since the source may be shared
+			// with trust domains that are not meant to be published.
+			s.config.log.Debugf("Unable to get bundle for trust domain %q: %v", trustDomain, err)
+			return status.Errorf(codes.NotFound, "no bundle for %q", trustDomain)
+		case err != nil:
+			// Keep the stream open; the client still has the latest bundle.
+			s.config.log.Warnf("Unable to get bundle for trust domain %q: %v", trustDomain, err)
+		case !bundle.Equal(sent):
+			data, err := bundle.Marshal()
+			if err != nil {
+				s.config.log.Errorf("Unable to marshal bundle for trust domain %q: %v", trustDomain, err)
+				return status.Errorf(codes.Internal, "unable to serve bundle for %q", trustDomain)
+			}
+			if err := stream.SendMsg(wrapperspb.Bytes(data)); err != nil {
+				return err
+			}
+			sent = bundle
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+type serverConfig struct {
+	log          logger.Logger
+	pollInterval time.Duration
+}
+
+type serverOption func(*serverConfig)
+
+func (o serverOption) apply(c *serverConfig) {
+	o(c)
+}
@@ -0,0 +1,59 @@
+package grpcbundle
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// The service is described by hand, using well-known protobuf types for its
+// messages: the request is the name of the trust domain, and each response
+// is a bundle in the format outlined in the SPIFFE Trust Domain and Bundle
+// specification.
+//
+//	service BundleDistribution {
+//	    rpc WatchBundle(google.protobuf.StringValue) returns (stream google.protobuf.BytesValue);
+//	}
+const (
+	serviceName       = "spiffe.federation.experimental.BundleDistribution"
+	watchBundleMethod = "/" + serviceName + "/WatchBundle"
+)
+
+type bundleDistributionServer interface {
+	WatchBundle(*wrapperspb.StringValue, grpc.ServerStream) error
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*bundleDistributionServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchBundle",
+			Handler:       watchBundleHandler,
+			ServerStreams: true,
+		},
+	},
+}
+
+func watchBundleHandler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(wrapperspb.StringValue)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(bundleDistributionServer).WatchBundle(m, stream)
+}
+
+func newWatchBundleStream(ctx context.Context, cc grpc.ClientConnInterface, trustDomain string) (grpc.ClientStream, error) {
+	stream, err := cc.NewStream(ctx, &serviceDesc.Streams[0], watchBundleMethod)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(wrapperspb.String(trustDomain)); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
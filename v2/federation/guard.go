@@ -0,0 +1,197 @@
+package federation
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithRateLimit limits the rate of requests each client, identified by its
+// IP address, can make to the handler. Clients are allowed bursts of up to
+// burst requests and are refilled at requestsPerSecond. Requests over the
+// limit are answered with 429 Too Many Requests.
+func WithRateLimit(requestsPerSecond float64, burst int) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if requestsPerSecond <= 0 || burst < 1 {
+			return federationErr.New("rate limit must be positive and allow a burst of at least one request")
+		}
+		c.rateLimit = requestsPerSecond
+		c.rateBurst = burst
+		return nil
+	})
+}
+
+// WithMaxConcurrentRequests limits the number of requests the handler serves
+// concurrently. Requests over the limit are answered with 503 Service
+// Unavailable.
+func WithMaxConcurrentRequests(max int) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if max < 1 {
+			return federationErr.New("max concurrent requests must be at least one")
+		}
+		c.maxConcurrent = max
+		return nil
+	})
+}
+
+// WithMaxRequestSize limits the size of the request headers plus body, in
+// bytes, the handler accepts. Larger requests are answered with 413 Request
+// Entity Too Large. Bundle requests carry no body, so the limit can be small.
+func WithMaxRequestSize(max int64) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if max < 1 {
+			return federationErr.New("max request size must be positive")
+		}
+		c.maxRequestSize = max
+		return nil
+	})
+}
+
+// WithRequestTimeout limits the time the handler takes to serve a request,
+// e.g. when the bundle source is slow. Requests that time out are answered
+// with 503 Service Unavailable.
+func WithRequestTimeout(timeout time.Duration) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if timeout <= 0 {
+			return federationErr.New("request timeout must be positive")
+		}
+		c.requestTimeout = timeout
+		return nil
+	})
+}
+
+// guard wraps the handler with the request guards enabled in the
+// configuration.
+func guard(handler http.Handler, conf *handlerConfig) http.Handler {
+	if conf.requestTimeout > 0 {
+		handler = http.TimeoutHandler(handler, conf.requestTimeout, "request timed out")
+	}
+	if conf.maxConcurrent > 0 {
+		handler = limitConcurrency(handler, conf.maxConcurrent)
+	}
+	if conf.maxRequestSize > 0 {
+		handler = limitRequestSize(handler, conf.maxRequestSize)
+	}
+	if conf.rateLimit > 0 {
+		handler = newRateLimiter(conf.rateLimit, conf.rateBurst, time.Now).wrap(handler)
+	}
+	return handler
+}
+
+func limitConcurrency(handler http.Handler, max int) http.Handler {
+	sem := make(chan struct{}, max)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+			handler.ServeHTTP(w, r)
+		default:
+			http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+		}
+	})
+}
+
+func limitRequestSize(handler http.Handler, max int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size := r.ContentLength
+		for name, values := range r.Header {
+			for _, value := range values {
+				size += int64(len(name) + len(value))
+			}
+		}
+		if size > max {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max-size)
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// rateLimiter is a per-client token bucket rate limiter.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mtx         sync.Mutex
+	buckets     map[string]*bucket
+	lastCleanup time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int, now func() time.Time) *rateLimiter {
+	return &rateLimiter{
+		rate:        rate,
+		burst:       float64(burst),
+		now:         now,
+		buckets:     make(map[string]*bucket),
+		lastCleanup: now(),
+	}
+}
+
+func (l *rateLimiter) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if wait, ok := l.allow(clientKey(r)); !ok {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(wait.Seconds())), 10))
+			http.Error(w, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from the bucket of the client. If none is available,
+// it returns how long until one is.
+func (l *rateLimiter) allow(key string) (time.Duration, bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := l.now()
+	l.cleanup(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second)), false
+	}
+	b.tokens--
+	return 0, true
+}
+
+// cleanup drops the buckets of clients that have been idle long enough for
+// their bucket to be full again, so memory use is bounded by the number of
+// active clients.
+func (l *rateLimiter) cleanup(now time.Time) {
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	if now.Sub(l.lastCleanup) < refill {
+		return
+	}
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastCleanup = now
+}
+
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,39 @@
+package federation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := newRateLimiter(2, 3, func() time.Time { return now })
+
+	// The burst is available right away.
+	for i := 0; i < 3; i++ {
+		_, ok := l.allow("client1")
+		assert.True(t, ok)
+	}
+	wait, ok := l.allow("client1")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// Other clients have their own bucket.
+	_, ok = l.allow("client2")
+	assert.True(t, ok)
+
+	// Tokens are refilled over time.
+	now = now.Add(500 * time.Millisecond)
+	_, ok = l.allow("client1")
+	assert.True(t, ok)
+	_, ok = l.allow("client1")
+	assert.False(t, ok)
+
+	// Idle clients are dropped once their bucket would be full.
+	now = now.Add(2 * time.Second)
+	_, ok = l.allow("client1")
+	assert.True(t, ok)
+	assert.Len(t, l.buckets, 1)
+}
@@ -1,10 +1,16 @@
 package federation
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
 	"github.com/damarescavalcante/go-spiffe/v2/logger"
 	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
 )
@@ -13,35 +19,65 @@ type HandlerOption interface {
 	apply(*handlerConfig) error
 }
 
-func WithLogger(log logger.Logger) HandlerOption {
-	return handlerOption(func(c *handlerConfig) error {
-		c.log = log
-		return nil
-	})
+// LoggerOption is an option that can be used both as a HandlerOption and a
+// FetchOption.
+type LoggerOption interface {
+	HandlerOption
+	FetchOption
+}
+
+// WithLogger provides a logger. Handlers log errors serving bundles and, at
+// debug level, the bundles served. FetchBundle and WatchBundle log, at debug
+// level, the fetch attempts along with the authentication used, and their
+// outcome.
+func WithLogger(log logger.Logger) LoggerOption {
+	return loggerOption{log: log}
+}
+
+type loggerOption struct {
+	log logger.Logger
+}
+
+func (o loggerOption) apply(c *handlerConfig) error {
+	c.log = o.log
+	return nil
+}
+
+func (o loggerOption) applyFetch(opts *fetchOptions) error {
+	opts.log = o.log
+	return nil
 }
 
 // NewHandler returns an HTTP handler that provides the trust domain bundle for
 // the given trust domain. The bundle is encoded according to the format
 // outlined in the SPIFFE Trust Domain and Bundle specification. The bundle
 // source is used to obtain the bundle on each request. Source implementations
-// should consider a caching strategy if retrieval is expensive.
+// should consider a caching strategy if retrieval is expensive. Responses
+// carry an ETag, conditional requests using If-None-Match are supported,
+// large bundles are compressed when the client accepts it and HEAD requests
+// are answered with the headers only.
+// Since bundle endpoints are usually exposed to the internet, consider
+// guarding the handler with WithRateLimit, WithMaxConcurrentRequests,
+// WithMaxRequestSize and WithRequestTimeout.
 // See the specification for more details:
 // https://github.com/spiffe/spiffe/blob/main/standards/SPIFFE_Trust_Domain_and_Bundle.md
 func NewHandler(trustDomain spiffeid.TrustDomain, source spiffebundle.Source, opts ...HandlerOption) (http.Handler, error) {
-	conf := &handlerConfig{
-		log: logger.Null,
-	}
-
-	for _, opt := range opts {
-		if err := opt.apply(conf); err != nil {
-			return nil, fmt.Errorf("handler configuration is invalid: %w", err)
-		}
+	conf, err := newHandlerConfig(opts)
+	if err != nil {
+		return nil, err
 	}
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
+	liveSource := source
+	source = handlerSource(source, conf)
+	return wrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedMethod(r) {
 			http.Error(w, "method is not allowed", http.StatusMethodNotAllowed)
 			return
 		}
+		setRequestTrustDomain(r, trustDomain)
+		if _, ok := isHealthCheck(r, conf); ok {
+			serveHealthCheck(w, conf, liveSource, trustDomain)
+			return
+		}
 
 		bundle, err := source.GetBundleForTrustDomain(trustDomain)
 		if err != nil {
@@ -49,20 +85,129 @@ func NewHandler(trustDomain spiffeid.TrustDomain, source spiffebundle.Source, op
 			http.Error(w, fmt.Sprintf("unable to serve bundle for %q", trustDomain), http.StatusInternalServerError)
 			return
 		}
-		data, err := bundle.Marshal()
+		serveBundle(w, r, conf, trustDomain, bundle)
+	}), conf), nil
+}
+
+func newHandlerConfig(opts []HandlerOption) (*handlerConfig, error) {
+	conf := &handlerConfig{
+		log: logger.Null,
+	}
+
+	for _, opt := range opts {
+		if err := opt.apply(conf); err != nil {
+			return nil, fmt.Errorf("handler configuration is invalid: %w", err)
+		}
+	}
+	return conf, nil
+}
+
+func serveBundle(w http.ResponseWriter, r *http.Request, conf *handlerConfig, trustDomain spiffeid.TrustDomain, bundle *spiffebundle.Bundle) {
+	bundle = prepareBundle(conf, bundle)
+	format := FormatSPIFFE
+	if conf.alternativeFormats {
+		format = negotiateFormat(r.Header.Get("Accept"))
+	}
+	data, err := marshalBundle(bundle, format)
+	if err != nil {
+		conf.log.Errorf("unable to marshal bundle for trust domain %q: %v", trustDomain, err)
+		http.Error(w, fmt.Sprintf("unable to serve bundle for %q", trustDomain), http.StatusInternalServerError)
+		return
+	}
+
+	writeBundle(w, r, conf, bundle, data, format.MediaType())
+}
+
+// writeBundle writes the marshaled bundle, of the given content type, to the
+// response, compressed if the client accepts it. A strong ETag derived from
+// the bundle content and encoding is set so clients can issue conditional
+// requests, which are answered with 304 Not Modified when the bundle has not
+// changed. If the bundle has a refresh hint, it is advertised as the maximum
+// age of the response.
+func writeBundle(w http.ResponseWriter, r *http.Request, conf *handlerConfig, bundle *spiffebundle.Bundle, data []byte, contentType string) {
+	sum := sha256.Sum256(data)
+	tag := hex.EncodeToString(sum[:])
+
+	body, encoding := encodeBundle(r, conf, tag, data)
+	if encoding != "" {
+		// Each representation needs its own strong ETag.
+		tag += "-" + encoding
+	}
+	etag := `"` + tag + `"`
+
+	w.Header().Set("ETag", etag)
+	if conf.alternativeFormats {
+		w.Header().Add("Vary", "Accept")
+	}
+	if !conf.disableCompression {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if refreshHint, ok := bundle.RefreshHint(); ok {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int64(refreshHint/time.Second)))
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		conf.log.Debugf("bundle for trust domain %q not modified for %s", bundle.TrustDomain(), r.RemoteAddr)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if conf.signer != nil {
+		signature, err := conf.signatures.sign(conf.signer, tag, data)
 		if err != nil {
-			conf.log.Errorf("unable to marshal bundle for trust domain %q: %v", trustDomain, err)
-			http.Error(w, fmt.Sprintf("unable to serve bundle for %q", trustDomain), http.StatusInternalServerError)
+			conf.log.Errorf("unable to sign bundle for trust domain %q: %v", bundle.TrustDomain(), err)
+			http.Error(w, fmt.Sprintf("unable to serve bundle for %q", bundle.TrustDomain()), http.StatusInternalServerError)
 			return
 		}
+		w.Header().Set(SignatureHeader, signature)
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write(data)
-	}), nil
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	_, _ = w.Write(body)
+	conf.log.Debugf("served bundle for trust domain %q to %s", bundle.TrustDomain(), r.RemoteAddr)
+}
+
+// etagMatches returns true if the If-None-Match header value matches the
+// ETag. As mandated by RFC 7232, the weak comparison function is used.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
 }
 
 type handlerConfig struct {
-	log logger.Logger
+	log                  logger.Logger
+	trustDomainParameter string
+	rateLimit            float64
+	rateBurst            int
+	maxConcurrent        int
+	maxRequestSize       int64
+	requestTimeout       time.Duration
+	disableCompression   bool
+	metrics              HandlerMetrics
+	accessLog            func(AccessLogEntry)
+	accessLogBundles     x509bundle.Source
+	compression          compressionCache
+	store                BundleStore
+	signer               PayloadSigner
+	signatures           signatureCache
+	refreshHint          time.Duration
+	autoSequenceNumber   bool
+	sequenceNumbers      sequenceNumbers
+	served               *ServedBundles
+	healthPath           string
+	alternativeFormats   bool
 }
 
 type handlerOption func(*handlerConfig) error
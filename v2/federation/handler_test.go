@@ -2,16 +2,21 @@ package federation_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
 	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
 	"github.com/damarescavalcante/go-spiffe/v2/logger"
 	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
 	"github.com/stretchr/testify/require"
@@ -156,3 +161,364 @@ func (s *fakeSource) GetBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (
 	}
 	return b, nil
 }
+
+func TestHandlerConditionalRequests(t *testing.T) {
+	trustDomain := spiffeid.RequireTrustDomainFromString("test.domain")
+	bundle, err := spiffebundle.Parse(trustDomain, []byte(jwks))
+	require.NoError(t, err)
+	bundle.SetRefreshHint(5 * time.Minute)
+
+	source := &fakeSource{bundles: map[spiffeid.TrustDomain]*spiffebundle.Bundle{trustDomain: bundle}}
+	handler, err := federation.NewHandler(trustDomain, source)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func(ifNoneMatch string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return res
+	}
+
+	res := get("")
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "max-age=300", res.Header.Get("Cache-Control"))
+	etag := res.Header.Get("ETag")
+	require.Regexp(t, `^"[0-9a-f]{64}"$`, etag)
+
+	for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		res = get(ifNoneMatch)
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusNotModified, res.StatusCode, ifNoneMatch)
+		require.Equal(t, etag, res.Header.Get("ETag"))
+		require.Empty(t, body)
+	}
+
+	res = get(`"other"`)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Once the bundle changes, so does the ETag.
+	bundle.SetSequenceNumber(2)
+	res = get(etag)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NotEqual(t, etag, res.Header.Get("ETag"))
+}
+
+func TestHandlerGuards(t *testing.T) {
+	trustDomain := spiffeid.RequireTrustDomainFromString("test.domain")
+	bundle, err := spiffebundle.Parse(trustDomain, []byte(jwks))
+	require.NoError(t, err)
+
+	get := func(t *testing.T, handler http.Handler, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("rate limit", func(t *testing.T) {
+		handler, err := federation.NewHandler(trustDomain, bundle, federation.WithRateLimit(0.001, 2))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, get(t, handler, nil).Code)
+		require.Equal(t, http.StatusOK, get(t, handler, nil).Code)
+		rec := get(t, handler, nil)
+		require.Equal(t, http.StatusTooManyRequests, rec.Code)
+		require.NotEmpty(t, rec.Header().Get("Retry-After"))
+	})
+
+	t.Run("max request size", func(t *testing.T) {
+		handler, err := federation.NewHandler(trustDomain, bundle, federation.WithMaxRequestSize(64))
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, get(t, handler, nil).Code)
+		rec := get(t, handler, http.Header{"X-Padding": []string{strings.Repeat("a", 64)}})
+		require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("max concurrent requests and timeout", func(t *testing.T) {
+		source := &blockingSource{bundle: bundle, unblock: make(chan struct{}), blocked: make(chan struct{}, 1)}
+		handler, err := federation.NewHandler(trustDomain, source,
+			federation.WithMaxConcurrentRequests(1),
+			federation.WithRequestTimeout(time.Minute))
+		require.NoError(t, err)
+
+		done := make(chan int)
+		go func() {
+			done <- get(t, handler, nil).Code
+		}()
+		<-source.blocked
+
+		require.Equal(t, http.StatusServiceUnavailable, get(t, handler, nil).Code)
+		close(source.unblock)
+		require.Equal(t, http.StatusOK, <-done)
+
+		handler, err = federation.NewHandler(trustDomain, &blockingSource{bundle: bundle, unblock: make(chan struct{}), blocked: make(chan struct{}, 1)},
+			federation.WithRequestTimeout(10*time.Millisecond))
+		require.NoError(t, err)
+		require.Equal(t, http.StatusServiceUnavailable, get(t, handler, nil).Code)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		for _, option := range []federation.HandlerOption{
+			federation.WithRateLimit(0, 1),
+			federation.WithMaxConcurrentRequests(0),
+			federation.WithMaxRequestSize(0),
+			federation.WithRequestTimeout(0),
+		} {
+			_, err := federation.NewHandler(trustDomain, bundle, option)
+			require.Error(t, err)
+		}
+	})
+}
+
+type blockingSource struct {
+	bundle  *spiffebundle.Bundle
+	blocked chan struct{}
+	unblock chan struct{}
+}
+
+func (s *blockingSource) GetBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+	select {
+	case s.blocked <- struct{}{}:
+	default:
+	}
+	select {
+	case <-s.unblock:
+	case <-time.After(time.Second):
+	}
+	return s.bundle, nil
+}
+
+func TestHandlerCompression(t *testing.T) {
+	trustDomain := spiffeid.RequireTrustDomainFromString("test.domain")
+	bundle := spiffebundle.New(trustDomain)
+	// Add enough authorities for the bundle to be worth compressing.
+	for i := 0; i < 10; i++ {
+		bundle.AddX509Authority(test.NewCA(t, trustDomain).X509Authorities()[0])
+	}
+	expected, err := bundle.Marshal()
+	require.NoError(t, err)
+
+	handler, err := federation.NewHandler(trustDomain, bundle)
+	require.NoError(t, err)
+
+	get := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("gzip", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	require.Equal(t, "Accept-Encoding", rec.Header().Get("Vary"))
+	require.Less(t, rec.Body.Len(), len(expected))
+	gzipETag := rec.Header().Get("ETag")
+	r, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	actual, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	rec = get("deflate", "")
+	require.Equal(t, "deflate", rec.Header().Get("Content-Encoding"))
+	zr, err := zlib.NewReader(rec.Body)
+	require.NoError(t, err)
+	actual, err = ioutil.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, expected, actual)
+
+	rec = get("", "")
+	require.Empty(t, rec.Header().Get("Content-Encoding"))
+	require.Equal(t, expected, rec.Body.Bytes())
+	require.NotEqual(t, gzipETag, rec.Header().Get("ETag"))
+
+	// Conditional requests match the ETag of the negotiated representation.
+	require.Equal(t, http.StatusNotModified, get("gzip", gzipETag).Code)
+	require.Equal(t, http.StatusOK, get("", gzipETag).Code)
+
+	handler, err = federation.NewHandler(trustDomain, bundle, federation.WithoutCompression())
+	require.NoError(t, err)
+	rec = get("gzip", "")
+	require.Empty(t, rec.Header().Get("Content-Encoding"))
+	require.Equal(t, expected, rec.Body.Bytes())
+}
+
+func TestHandlerHEAD(t *testing.T) {
+	trustDomain := spiffeid.RequireTrustDomainFromString("test.domain")
+	bundle, err := spiffebundle.Parse(trustDomain, []byte(jwks))
+	require.NoError(t, err)
+	data, err := bundle.Marshal()
+	require.NoError(t, err)
+
+	handler, err := federation.NewHandler(trustDomain, bundle)
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	require.NoError(t, err)
+	res.Body.Close()
+	etag := res.Header.Get("ETag")
+
+	res, err = http.Head(server.URL)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Empty(t, body)
+	require.Equal(t, etag, res.Header.Get("ETag"))
+	require.Equal(t, int64(len(data)), res.ContentLength)
+	require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+}
+
+func TestHandlerHealthCheck(t *testing.T) {
+	trustDomain := spiffeid.RequireTrustDomainFromString("test.domain")
+	bundle, err := spiffebundle.Parse(trustDomain, []byte(jwks))
+	require.NoError(t, err)
+
+	store := federation.NewFileBundleStore(t.TempDir())
+	require.NoError(t, store.StoreBundle(bundle))
+	source := spiffebundle.NewSet(bundle)
+
+	handler, err := federation.NewHandler(trustDomain, source,
+		federation.WithHealthCheck("/healthz"),
+		federation.WithBundleStore(store))
+	require.NoError(t, err)
+	multiHandler, err := federation.NewMultiHandler(source, federation.WithHealthCheck("/healthz"))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/bundle/", handler)
+	mux.Handle("/bundles/", multiHandler)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	get := func(path string) (int, string) {
+		res, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(body)
+	}
+
+	status, body := get("/bundle/healthz")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "ok\n", body)
+	status, body = get("/bundles/test.domain/healthz")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "ok\n", body)
+
+	// The bundle is still served from the store, but the source is not
+	// healthy.
+	source.Remove(trustDomain)
+	status, _ = get("/bundle/")
+	require.Equal(t, http.StatusOK, status)
+	status, body = get("/bundle/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+	require.Equal(t, "bundle is not available\n", body)
+	status, _ = get("/bundles/test.domain/healthz")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+
+	_, err = federation.NewHandler(trustDomain, source, federation.WithHealthCheck("healthz"))
+	require.EqualError(t, err, "handler configuration is invalid: federation: health check sub-path must start with a slash and not be empty")
+}
+
+func TestHandlerAlternativeFormats(t *testing.T) {
+	trustDomain := spiffeid.RequireTrustDomainFromString("test.domain")
+	bundle, err := spiffebundle.Parse(trustDomain, []byte(jwks))
+	require.NoError(t, err)
+
+	handler, err := federation.NewHandler(trustDomain, bundle, federation.WithAlternativeFormats())
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func(accept string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		return res, body
+	}
+
+	res, body := get("")
+	require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	require.Equal(t, []string{"Accept", "Accept-Encoding"}, res.Header.Values("Vary"))
+	spiffeETag := res.Header.Get("ETag")
+	parsed, err := spiffebundle.Parse(trustDomain, body)
+	require.NoError(t, err)
+	require.True(t, parsed.Equal(bundle))
+
+	res, body = get("application/jwk-set+json")
+	require.Equal(t, "application/jwk-set+json", res.Header.Get("Content-Type"))
+	require.NotEqual(t, spiffeETag, res.Header.Get("ETag"))
+	require.JSONEq(t, `{"keys":[{"kty":"EC","kid":"KID","crv":"P-256","x":"fK-wKTnKL7KFLM27lqq5DC-bxrVaH6rDV-IcCSEOeL4","y":"wq-g3TQWxYlV51TCPH030yXsRxvujD4hUUaIQrXk4KI"}]}`, string(body))
+
+	res, body = get("application/x-pem-file")
+	require.Equal(t, "application/x-pem-file", res.Header.Get("Content-Type"))
+	certs, err := x509.ParseCertificates(pemBlocksDER(t, body))
+	require.NoError(t, err)
+	require.Equal(t, bundle.X509Authorities(), certs)
+}
+
+func TestHandlerAlternativeFormatsDisabled(t *testing.T) {
+	trustDomain := spiffeid.RequireTrustDomainFromString("test.domain")
+	bundle, err := spiffebundle.Parse(trustDomain, []byte(jwks))
+	require.NoError(t, err)
+
+	handler, err := federation.NewHandler(trustDomain, bundle)
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/x-pem-file")
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	require.Equal(t, []string{"Accept-Encoding"}, res.Header.Values("Vary"))
+}
+
+func pemBlocksDER(t *testing.T, data []byte) []byte {
+	var der []byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		der = append(der, block.Bytes...)
+	}
+	require.NotEmpty(t, der)
+	return der
+}
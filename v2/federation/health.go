@@ -0,0 +1,59 @@
+package federation
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// WithHealthCheck makes the handler answer requests whose path ends with
+// the given sub-path (e.g. /healthz) with a health check, so that load
+// balancers can check the bundle endpoint. The check succeeds with 200 OK
+// when the bundle source provides a non-empty bundle for the trust domain,
+// and fails with 503 Service Unavailable otherwise, including when the
+// bundle is only available from the store set with WithBundleStore. With
+// NewMultiHandler, the trust domain is taken from the request without the
+// sub-path, e.g. /bundles/example.org/healthz.
+func WithHealthCheck(subPath string) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if !strings.HasPrefix(subPath, "/") || subPath == "/" {
+			return federationErr.New("health check sub-path must start with a slash and not be empty")
+		}
+		c.healthPath = subPath
+		return nil
+	})
+}
+
+// isHealthCheck returns true if the request is for the health check, along
+// with the request path without the health check sub-path.
+func isHealthCheck(r *http.Request, conf *handlerConfig) (string, bool) {
+	if conf.healthPath == "" || !strings.HasSuffix(r.URL.Path, conf.healthPath) {
+		return r.URL.Path, false
+	}
+	return strings.TrimSuffix(r.URL.Path, conf.healthPath), true
+}
+
+// serveHealthCheck checks that the bundle source provides a non-empty
+// bundle for the trust domain.
+func serveHealthCheck(w http.ResponseWriter, conf *handlerConfig, source spiffebundle.Source, trustDomain spiffeid.TrustDomain) {
+	bundle, err := source.GetBundleForTrustDomain(trustDomain)
+	switch {
+	case err != nil:
+		conf.log.Warnf("health check failed: unable to get bundle for trust domain %q: %v", trustDomain, err)
+	case bundle.Empty():
+		conf.log.Warnf("health check failed: bundle for trust domain %q is empty", trustDomain)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+		return
+	}
+	http.Error(w, "bundle is not available", http.StatusServiceUnavailable)
+}
+
+// allowedMethod returns true if the request method can be served by the
+// handlers.
+func allowedMethod(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
@@ -0,0 +1,329 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// defaultManagerRefreshInterval is how often the Manager refreshes bundles
+// that have no refresh hint.
+const defaultManagerRefreshInterval = 5 * time.Minute
+
+// Profile is the bundle endpoint profile, which determines how the bundle
+// endpoint server is authenticated.
+type Profile int
+
+const (
+	// ProfileHTTPSWeb authenticates the bundle endpoint server using Web PKI.
+	ProfileHTTPSWeb Profile = iota
+
+	// ProfileHTTPSSPIFFE authenticates the bundle endpoint server using its
+	// X509-SVID.
+	ProfileHTTPSSPIFFE
+)
+
+// BundleEndpoint describes a bundle endpoint to synchronize a trust domain
+// bundle from.
+type BundleEndpoint struct {
+	// TrustDomain is the trust domain of the bundle served by the endpoint.
+	TrustDomain spiffeid.TrustDomain
+
+	// URL is the URL of the bundle endpoint.
+	URL string
+
+	// Profile is the bundle endpoint profile.
+	Profile Profile
+
+	// EndpointID is the SPIFFE ID of the bundle endpoint server. Required
+	// for ProfileHTTPSSPIFFE.
+	EndpointID spiffeid.ID
+
+	// Bootstrap is the bundle used to authenticate the bundle endpoint
+	// server until a bundle for the trust domain of EndpointID has been
+	// fetched. If it is the bundle of TrustDomain, it is also served until
+	// the first fetch. Only used with ProfileHTTPSSPIFFE.
+	Bootstrap *spiffebundle.Bundle
+
+	// Options are additional options used to fetch the bundle, e.g.
+	// WithWebPKIRoots or WithCacheFile.
+	Options []FetchOption
+}
+
+// ManagerOption is an option for the Manager.
+type ManagerOption interface {
+	applyManager(*managerConfig) error
+}
+
+// WithManagerLogger provides a logger to the Manager, used to report fetch
+// failures and bundle updates.
+func WithManagerLogger(log logger.Logger) ManagerOption {
+	return managerOption(func(c *managerConfig) error {
+		c.log = log
+		return nil
+	})
+}
+
+// WithRefreshInterval sets how often the Manager refreshes bundles that have
+// no refresh hint. Defaults to 5 minutes. The interval must be positive.
+func WithRefreshInterval(interval time.Duration) ManagerOption {
+	return managerOption(func(c *managerConfig) error {
+		if interval <= 0 {
+			return federationErr.New("refresh interval must be positive")
+		}
+		c.refreshInterval = interval
+		return nil
+	})
+}
+
+// Manager keeps the bundles of a set of federated trust domains up-to-date
+// by watching their bundle endpoints. It implements the spiffebundle.Source,
+// x509bundle.Source and jwtbundle.Source interfaces.
+//
+// Bundle endpoints using ProfileHTTPSSPIFFE are authenticated with the
+// latest bundle the Manager has for the trust domain of the endpoint server,
+// falling back to the bootstrap bundle. This makes trust domains that serve
+// their own bundle self-updating once bootstrapped.
+type Manager struct {
+	config    managerConfig
+	set       *spiffebundle.Set
+	updatedCh chan struct{}
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	closeMtx sync.RWMutex
+	closed   bool
+}
+
+// NewManager creates a new Manager and starts watching the bundle endpoints
+// in the background. The Manager should be closed when no longer in use to
+// stop the watches.
+func NewManager(endpoints []BundleEndpoint, options ...ManagerOption) (*Manager, error) {
+	config := managerConfig{
+		log:             logger.Null,
+		refreshInterval: defaultManagerRefreshInterval,
+	}
+	for _, option := range options {
+		if err := option.applyManager(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[spiffeid.TrustDomain]struct{})
+	for _, endpoint := range endpoints {
+		if err := validateBundleEndpoint(endpoint); err != nil {
+			return nil, err
+		}
+		if _, ok := seen[endpoint.TrustDomain]; ok {
+			return nil, federationErr.New("duplicate bundle endpoint for trust domain %q", endpoint.TrustDomain)
+		}
+		seen[endpoint.TrustDomain] = struct{}{}
+	}
+
+	m := &Manager{
+		config:    config,
+		set:       spiffebundle.NewSet(),
+		updatedCh: make(chan struct{}, 1),
+	}
+
+	// The fetch options are validated before watching, so that invalid
+	// options are reported here instead of by the watches.
+	fetchOptions := make([][]FetchOption, len(endpoints))
+	for i, endpoint := range endpoints {
+		fetchOptions[i] = endpointFetchOptions(endpoint, &endpointAuthSource{set: m.set, bootstrap: endpoint.Bootstrap})
+		if _, err := newFetchOptions(fetchOptions[i]); err != nil {
+			return nil, fmt.Errorf("bundle endpoint options for trust domain %q are invalid: %w", endpoint.TrustDomain, err)
+		}
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.Bootstrap != nil && endpoint.Bootstrap.TrustDomain() == endpoint.TrustDomain {
+			m.set.Add(endpoint.Bootstrap)
+		}
+	}
+
+	var ctx context.Context
+	ctx, m.cancel = context.WithCancel(context.Background())
+	for i, endpoint := range endpoints {
+		endpoint, options := endpoint, fetchOptions[i]
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.watch(ctx, endpoint, options)
+		}()
+	}
+
+	return m, nil
+}
+
+// Close stops watching the bundle endpoints. Other Manager methods will
+// return an error after Close has been called.
+func (m *Manager) Close() error {
+	m.closeMtx.Lock()
+	defer m.closeMtx.Unlock()
+
+	if !m.closed {
+		m.cancel()
+		m.wg.Wait()
+		m.closed = true
+	}
+	return nil
+}
+
+// Bundles returns the bundles currently held by the Manager, sorted by trust
+// domain.
+func (m *Manager) Bundles() ([]*spiffebundle.Bundle, error) {
+	if err := m.checkClosed(); err != nil {
+		return nil, err
+	}
+	return m.set.Bundles(), nil
+}
+
+// GetBundleForTrustDomain returns the bundle for the given trust domain. It
+// implements the spiffebundle.Source interface.
+func (m *Manager) GetBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+	if err := m.checkClosed(); err != nil {
+		return nil, err
+	}
+	return m.set.GetBundleForTrustDomain(trustDomain)
+}
+
+// GetX509BundleForTrustDomain returns the X.509 bundle for the given trust
+// domain. It implements the x509bundle.Source interface.
+func (m *Manager) GetX509BundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
+	if err := m.checkClosed(); err != nil {
+		return nil, err
+	}
+	return m.set.GetX509BundleForTrustDomain(trustDomain)
+}
+
+// GetJWTBundleForTrustDomain returns the JWT bundle for the given trust
+// domain. It implements the jwtbundle.Source interface.
+func (m *Manager) GetJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*jwtbundle.Bundle, error) {
+	if err := m.checkClosed(); err != nil {
+		return nil, err
+	}
+	return m.set.GetJWTBundleForTrustDomain(trustDomain)
+}
+
+// WaitUntilUpdated waits until a bundle is updated or the context is done,
+// in which case ctx.Err() is returned.
+func (m *Manager) WaitUntilUpdated(ctx context.Context) error {
+	select {
+	case <-m.updatedCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Updated returns a channel that is sent on whenever a bundle is updated.
+func (m *Manager) Updated() <-chan struct{} {
+	return m.updatedCh
+}
+
+func (m *Manager) watch(ctx context.Context, endpoint BundleEndpoint, options []FetchOption) {
+	watcher := &managerWatcher{m: m, trustDomain: endpoint.TrustDomain}
+	if err := WatchBundle(ctx, endpoint.TrustDomain, endpoint.URL, watcher, options...); err != nil && ctx.Err() == nil {
+		m.config.log.Errorf("Unable to watch bundle for trust domain %q: %v", endpoint.TrustDomain, err)
+	}
+}
+
+// validateBundleEndpoint checks that the bundle endpoint has the fields
+// required by its profile.
+func validateBundleEndpoint(endpoint BundleEndpoint) error {
+	if endpoint.TrustDomain.IsZero() {
+		return federationErr.New("bundle endpoint trust domain is required")
+	}
+	if endpoint.Profile == ProfileHTTPSSPIFFE && endpoint.EndpointID.IsZero() {
+		return federationErr.New("bundle endpoint SPIFFE ID is required for trust domain %q", endpoint.TrustDomain)
+	}
+	return nil
+}
+
+// endpointFetchOptions returns the options used to fetch the bundle from the
+// bundle endpoint. The auth source authenticates the bundle endpoint server
+// when using ProfileHTTPSSPIFFE.
+func endpointFetchOptions(endpoint BundleEndpoint, authSource x509bundle.Source) []FetchOption {
+	options := endpoint.Options
+	if endpoint.Profile == ProfileHTTPSSPIFFE {
+		auth := WithSPIFFEAuth(authSource, endpoint.EndpointID)
+		options = append([]FetchOption{auth}, options...)
+	}
+	return options
+}
+
+func (m *Manager) triggerUpdated() {
+	select {
+	case m.updatedCh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) checkClosed() error {
+	m.closeMtx.RLock()
+	defer m.closeMtx.RUnlock()
+	if m.closed {
+		return federationErr.New("manager is closed")
+	}
+	return nil
+}
+
+type managerWatcher struct {
+	m           *Manager
+	trustDomain spiffeid.TrustDomain
+}
+
+func (w *managerWatcher) NextRefresh(refreshHint time.Duration) time.Duration {
+	if refreshHint > 0 {
+		return refreshHint
+	}
+	return w.m.config.refreshInterval
+}
+
+func (w *managerWatcher) OnUpdate(bundle *spiffebundle.Bundle) {
+	w.m.config.log.Infof("Bundle for trust domain %q updated", w.trustDomain)
+	w.m.set.Add(bundle)
+	w.m.triggerUpdated()
+}
+
+func (w *managerWatcher) OnError(err error) {
+	w.m.config.log.Warnf("Unable to fetch bundle for trust domain %q: %v", w.trustDomain, err)
+}
+
+// endpointAuthSource provides the X.509 authorities used to authenticate a
+// bundle endpoint server, preferring the bundles held by the Manager over
+// the bootstrap bundle.
+type endpointAuthSource struct {
+	set       *spiffebundle.Set
+	bootstrap *spiffebundle.Bundle
+}
+
+func (s *endpointAuthSource) GetX509BundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
+	if bundle, ok := s.set.Get(trustDomain); ok {
+		return bundle.X509Bundle(), nil
+	}
+	if s.bootstrap != nil && s.bootstrap.TrustDomain() == trustDomain {
+		return s.bootstrap.X509Bundle(), nil
+	}
+	return nil, federationErr.New("no bundle to authenticate the endpoint of trust domain %q", trustDomain)
+}
+
+type managerConfig struct {
+	log             logger.Logger
+	refreshInterval time.Duration
+}
+
+type managerOption func(*managerConfig) error
+
+func (o managerOption) applyManager(c *managerConfig) error {
+	return o(c)
+}
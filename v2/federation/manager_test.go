@@ -0,0 +1,140 @@
+package federation_test
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakebundleendpoint"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	// Time out the test after a minute if something goes wrong.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// td1 serves its own bundle using SPIFFE authentication.
+	td1 := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca1 := test.NewCA(t, td1)
+	endpointID := spiffeid.RequireFromPath(td1, "/bundle-endpoint")
+	bootstrap := ca1.Bundle()
+	served1 := ca1.Bundle()
+	served1.SetRefreshHint(time.Hour)
+	be := fakebundleendpoint.New(t,
+		fakebundleendpoint.WithTestBundles(served1),
+		fakebundleendpoint.WithSPIFFEAuth(bootstrap, ca1.CreateX509SVID(endpointID, test.WithIPAddresses(localhostIPs...))))
+	defer be.Shutdown()
+
+	// td2 serves its bundle using Web PKI.
+	td2 := spiffeid.RequireTrustDomainFromString("domain2.test")
+	served2 := test.NewCA(t, td2).Bundle()
+	handler, err := federation.NewHandler(td2, served2)
+	require.NoError(t, err)
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	m, err := federation.NewManager([]federation.BundleEndpoint{
+		{
+			TrustDomain: td1,
+			URL:         be.FetchBundleURL(),
+			Profile:     federation.ProfileHTTPSSPIFFE,
+			EndpointID:  endpointID,
+			Bootstrap:   bootstrap,
+		},
+		{
+			TrustDomain: td2,
+			URL:         server.URL,
+			Profile:     federation.ProfileHTTPSWeb,
+			Options:     []federation.FetchOption{federation.WithWebPKIRoots(x509util.NewCertPool([]*x509.Certificate{server.Certificate()}))},
+		},
+	})
+	require.NoError(t, err)
+	defer m.Close()
+
+	for {
+		bundles, err := m.Bundles()
+		require.NoError(t, err)
+		if len(bundles) == 2 && bundles[0].Equal(served1) && bundles[1].Equal(served2) {
+			break
+		}
+		require.NoError(t, m.WaitUntilUpdated(ctx))
+	}
+
+	bundle, err := m.GetBundleForTrustDomain(td1)
+	require.NoError(t, err)
+	assert.Equal(t, served1, bundle)
+	x509Bundle, err := m.GetX509BundleForTrustDomain(td2)
+	require.NoError(t, err)
+	assert.Equal(t, served2.X509Bundle(), x509Bundle)
+	jwtBundle, err := m.GetJWTBundleForTrustDomain(td2)
+	require.NoError(t, err)
+	assert.Equal(t, served2.JWTBundle(), jwtBundle)
+
+	require.NoError(t, m.Close())
+	_, err = m.GetBundleForTrustDomain(td1)
+	require.EqualError(t, err, "federation: manager is closed")
+	_, err = m.Bundles()
+	require.EqualError(t, err, "federation: manager is closed")
+}
+
+func TestManagerBootstrapBundleIsServedBeforeFirstFetch(t *testing.T) {
+	bootstrap := test.NewCA(t, td).Bundle()
+	m, err := federation.NewManager([]federation.BundleEndpoint{
+		{
+			TrustDomain: td,
+			URL:         "https://127.0.0.1:1/unreachable",
+			Profile:     federation.ProfileHTTPSSPIFFE,
+			EndpointID:  spiffeid.RequireFromPath(td, "/bundle-endpoint"),
+			Bootstrap:   bootstrap,
+		},
+	})
+	require.NoError(t, err)
+	defer m.Close()
+
+	bundle, err := m.GetBundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Equal(t, bootstrap, bundle)
+}
+
+func TestNewManagerValidatesEndpoints(t *testing.T) {
+	for _, tt := range []struct {
+		endpoints []federation.BundleEndpoint
+		err       string
+	}{
+		{
+			endpoints: []federation.BundleEndpoint{{URL: "https://example.org"}},
+			err:       "federation: bundle endpoint trust domain is required",
+		},
+		{
+			endpoints: []federation.BundleEndpoint{{TrustDomain: td}, {TrustDomain: td}},
+			err:       `federation: duplicate bundle endpoint for trust domain "domain.test"`,
+		},
+		{
+			endpoints: []federation.BundleEndpoint{{TrustDomain: td, Profile: federation.ProfileHTTPSSPIFFE}},
+			err:       `federation: bundle endpoint SPIFFE ID is required for trust domain "domain.test"`,
+		},
+		{
+			endpoints: []federation.BundleEndpoint{{TrustDomain: td, Options: []federation.FetchOption{federation.WithCacheFile("")}}},
+			err:       `bundle endpoint options for trust domain "domain.test" are invalid: federation: cache file path cannot be empty`,
+		},
+	} {
+		_, err := federation.NewManager(tt.endpoints)
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestNewManagerValidatesOptions(t *testing.T) {
+	endpoints := []federation.BundleEndpoint{{TrustDomain: td, URL: "https://127.0.0.1:1/unreachable"}}
+	for _, interval := range []time.Duration{0, -time.Minute} {
+		_, err := federation.NewManager(endpoints, federation.WithRefreshInterval(interval))
+		assert.EqualError(t, err, "federation: refresh interval must be positive")
+	}
+}
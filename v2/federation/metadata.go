@@ -0,0 +1,214 @@
+package federation
+
+import (
+	"sync"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// WithRefreshHint overrides the refresh hint of the served bundles, e.g. to
+// make relying parties refresh more often ahead of a planned rotation. The
+// refresh hint is also advertised as the maximum age of the response.
+func WithRefreshHint(refreshHint time.Duration) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if refreshHint <= 0 {
+			return federationErr.New("refresh hint must be positive")
+		}
+		c.refreshHint = refreshHint
+		return nil
+	})
+}
+
+// WithAutoSequenceNumber makes the handler manage the sequence number of the
+// served bundles, incrementing it whenever the authorities of a bundle
+// change. The sequence number never goes below the Unix time of the most
+// recent NotBefore of the X.509 authorities, nor below the sequence number
+// of the bundle from the source. It therefore starts from the content of the
+// bundle, so that replicas of the handler serving the same bundle, e.g.
+// behind a load balancer, serve the same sequence number, and it keeps
+// increasing across restarts as long as new X.509 authorities are added.
+// Sources removing authorities or rotating only the JWT authorities should
+// set the sequence number themselves to keep replicas consistent.
+func WithAutoSequenceNumber() HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		c.autoSequenceNumber = true
+		return nil
+	})
+}
+
+// WithServedBundles records the metadata of the bundles served by the
+// handler, for monitoring.
+func WithServedBundles(served *ServedBundles) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if served == nil {
+			return federationErr.New("served bundles cannot be nil")
+		}
+		c.served = served
+		return nil
+	})
+}
+
+// ServedBundleMetadata is the metadata of a bundle served by a handler.
+type ServedBundleMetadata struct {
+	// TrustDomain is the trust domain of the bundle.
+	TrustDomain spiffeid.TrustDomain
+
+	// SequenceNumber is the sequence number of the bundle, if it has one.
+	SequenceNumber uint64
+
+	// HasSequenceNumber is true if the bundle has a sequence number.
+	HasSequenceNumber bool
+
+	// RefreshHint is the refresh hint of the bundle, or zero if it has none.
+	RefreshHint time.Duration
+
+	// X509AuthorityCount is the number of X.509 authorities in the bundle.
+	X509AuthorityCount int
+
+	// JWTAuthorityCount is the number of JWT authorities in the bundle.
+	JWTAuthorityCount int
+
+	// LastServed is when the bundle was last served.
+	LastServed time.Time
+
+	// LastChanged is when the served bundle last changed.
+	LastChanged time.Time
+}
+
+// ServedBundles holds the metadata of the bundles served by handlers using
+// WithServedBundles. It is safe for concurrent use.
+type ServedBundles struct {
+	mtx      sync.RWMutex
+	metadata map[spiffeid.TrustDomain]ServedBundleMetadata
+}
+
+// NewServedBundles returns an empty ServedBundles.
+func NewServedBundles() *ServedBundles {
+	return &ServedBundles{
+		metadata: make(map[spiffeid.TrustDomain]ServedBundleMetadata),
+	}
+}
+
+// Get returns the metadata of the bundle last served for the trust domain.
+func (s *ServedBundles) Get(trustDomain spiffeid.TrustDomain) (ServedBundleMetadata, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	metadata, ok := s.metadata[trustDomain]
+	return metadata, ok
+}
+
+// All returns the metadata of the bundles served for all trust domains.
+func (s *ServedBundles) All() []ServedBundleMetadata {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	all := make([]ServedBundleMetadata, 0, len(s.metadata))
+	for _, metadata := range s.metadata {
+		all = append(all, metadata)
+	}
+	return all
+}
+
+func (s *ServedBundles) record(bundle *spiffebundle.Bundle, now time.Time) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	metadata := ServedBundleMetadata{
+		TrustDomain:        bundle.TrustDomain(),
+		X509AuthorityCount: len(bundle.X509Authorities()),
+		JWTAuthorityCount:  len(bundle.JWTAuthorities()),
+		LastServed:         now,
+		LastChanged:        now,
+	}
+	metadata.SequenceNumber, metadata.HasSequenceNumber = bundle.SequenceNumber()
+	metadata.RefreshHint, _ = bundle.RefreshHint()
+
+	if previous, ok := s.metadata[bundle.TrustDomain()]; ok {
+		lastChanged := previous.LastChanged
+		previous.LastServed, previous.LastChanged = now, now
+		if previous == metadata {
+			metadata.LastChanged = lastChanged
+		}
+	}
+	s.metadata[bundle.TrustDomain()] = metadata
+}
+
+// sequenceNumbers tracks the sequence numbers managed by handlers using
+// WithAutoSequenceNumber.
+type sequenceNumbers struct {
+	mtx    sync.Mutex
+	states map[spiffeid.TrustDomain]*sequenceState
+}
+
+type sequenceState struct {
+	// content is the latest bundle with its sequence number and refresh
+	// hint cleared, to detect changes of its authorities.
+	content        *spiffebundle.Bundle
+	sequenceNumber uint64
+}
+
+// next returns the sequence number of the bundle, incremented if its
+// authorities changed since the last call.
+func (s *sequenceNumbers) next(bundle *spiffebundle.Bundle) uint64 {
+	content := bundle.Clone()
+	content.ClearSequenceNumber()
+	content.ClearRefreshHint()
+	minimum := minSequenceNumber(bundle)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.states == nil {
+		s.states = make(map[spiffeid.TrustDomain]*sequenceState)
+	}
+	state, ok := s.states[bundle.TrustDomain()]
+	switch {
+	case !ok:
+		state = &sequenceState{sequenceNumber: minimum}
+		s.states[bundle.TrustDomain()] = state
+	case !state.content.Equal(content):
+		state.sequenceNumber++
+	}
+	state.content = content
+	if minimum > state.sequenceNumber {
+		state.sequenceNumber = minimum
+	}
+	return state.sequenceNumber
+}
+
+// minSequenceNumber returns the lowest sequence number the bundle can be
+// served with: the Unix time of the most recent NotBefore of its X.509
+// authorities, or its own sequence number if greater.
+func minSequenceNumber(bundle *spiffebundle.Bundle) uint64 {
+	var sequenceNumber uint64
+	for _, authority := range bundle.X509Authorities() {
+		if notBefore := authority.NotBefore.Unix(); notBefore > 0 && uint64(notBefore) > sequenceNumber {
+			sequenceNumber = uint64(notBefore)
+		}
+	}
+	if fromSource, ok := bundle.SequenceNumber(); ok && fromSource > sequenceNumber {
+		sequenceNumber = fromSource
+	}
+	return sequenceNumber
+}
+
+// prepareBundle applies the refresh hint and sequence number management of
+// the configuration to the bundle to serve, and records its metadata.
+func prepareBundle(conf *handlerConfig, bundle *spiffebundle.Bundle) *spiffebundle.Bundle {
+	now := time.Now()
+	if conf.refreshHint > 0 || conf.autoSequenceNumber {
+		// The bundle belongs to the source, so it cannot be modified.
+		bundle = bundle.Clone()
+		if conf.refreshHint > 0 {
+			bundle.SetRefreshHint(conf.refreshHint)
+		}
+		if conf.autoSequenceNumber {
+			bundle.SetSequenceNumber(conf.sequenceNumbers.next(bundle))
+		}
+	}
+	if conf.served != nil {
+		conf.served.record(bundle, now)
+	}
+	return bundle
+}
@@ -0,0 +1,168 @@
+package federation_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerBundleMetadata(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+	bundle.SetRefreshHint(time.Hour)
+
+	var mtx sync.Mutex
+	source := sourceFunc(func(spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return bundle, nil
+	})
+	served := federation.NewServedBundles()
+	handler, err := federation.NewHandler(td, source,
+		federation.WithRefreshHint(time.Minute),
+		federation.WithAutoSequenceNumber(),
+		federation.WithServedBundles(served))
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	fetch := func() *spiffebundle.Bundle {
+		fetched, err := federation.FetchBundle(context.Background(), td, server.URL)
+		require.NoError(t, err)
+		return fetched
+	}
+
+	// The refresh hint is overridden and the sequence number is the Unix time
+	// of the NotBefore of the X.509 authority.
+	fetched := fetch()
+	refreshHint, ok := fetched.RefreshHint()
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, refreshHint)
+	first, ok := fetched.SequenceNumber()
+	require.True(t, ok)
+	assert.Equal(t, uint64(ca.X509Authorities()[0].NotBefore.Unix()), first)
+
+	metadata, ok := served.Get(td)
+	require.True(t, ok)
+	assert.Equal(t, td, metadata.TrustDomain)
+	assert.Equal(t, first, metadata.SequenceNumber)
+	assert.True(t, metadata.HasSequenceNumber)
+	assert.Equal(t, time.Minute, metadata.RefreshHint)
+	assert.Equal(t, 1, metadata.X509AuthorityCount)
+	assert.Equal(t, 1, metadata.JWTAuthorityCount)
+	firstChanged := metadata.LastChanged
+
+	// The sequence number is unchanged while the authorities are, even if
+	// other fields of the bundle change.
+	mtx.Lock()
+	bundle = bundle.Clone()
+	bundle.SetRefreshHint(2 * time.Hour)
+	mtx.Unlock()
+	fetched = fetch()
+	sequenceNumber, _ := fetched.SequenceNumber()
+	assert.Equal(t, first, sequenceNumber)
+	metadata, _ = served.Get(td)
+	assert.Equal(t, firstChanged, metadata.LastChanged)
+	assert.False(t, metadata.LastServed.Before(firstChanged))
+
+	// The sequence number increases when a newer X.509 authority is added.
+	notBefore := time.Unix(int64(first)+10, 0)
+	rotated, _ := test.CreateCACertificate(t, nil, nil, test.WithLifetime(notBefore, notBefore.Add(time.Hour)))
+	mtx.Lock()
+	bundle = bundle.Clone()
+	bundle.AddX509Authority(rotated)
+	mtx.Unlock()
+	fetched = fetch()
+	sequenceNumber, _ = fetched.SequenceNumber()
+	assert.Equal(t, first+10, sequenceNumber)
+	metadata, _ = served.Get(td)
+	assert.Equal(t, 2, metadata.X509AuthorityCount)
+	assert.Len(t, served.All(), 1)
+
+	// A greater sequence number from the source is adopted.
+	mtx.Lock()
+	bundle = bundle.Clone()
+	bundle.SetSequenceNumber(first + 100)
+	mtx.Unlock()
+	fetched = fetch()
+	sequenceNumber, _ = fetched.SequenceNumber()
+	assert.Equal(t, first+100, sequenceNumber)
+
+	// The sequence number increases when the newest X.509 authority is
+	// removed, e.g. because it was compromised.
+	mtx.Lock()
+	bundle = bundle.Clone()
+	bundle.RemoveX509Authority(rotated)
+	mtx.Unlock()
+	fetched = fetch()
+	sequenceNumber, _ = fetched.SequenceNumber()
+	assert.Equal(t, first+101, sequenceNumber)
+
+	// The sequence number increases when only the JWT authorities change.
+	mtx.Lock()
+	bundle = bundle.Clone()
+	require.NoError(t, bundle.AddJWTAuthority("rotated", test.NewEC256Key(t).Public()))
+	mtx.Unlock()
+	fetched = fetch()
+	sequenceNumber, _ = fetched.SequenceNumber()
+	assert.Equal(t, first+102, sequenceNumber)
+}
+
+func TestHandlerAutoSequenceNumberAcrossReplicas(t *testing.T) {
+	ca := test.NewCA(t, td)
+	var mtx sync.Mutex
+	bundle := ca.Bundle()
+	source := sourceFunc(func(spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return bundle, nil
+	})
+	newServer := func() *httptest.Server {
+		handler, err := federation.NewHandler(td, source, federation.WithAutoSequenceNumber())
+		require.NoError(t, err)
+		return httptest.NewServer(handler)
+	}
+	fetch := func(server *httptest.Server) uint64 {
+		fetched, err := federation.FetchBundle(context.Background(), td, server.URL)
+		require.NoError(t, err)
+		sequenceNumber, ok := fetched.SequenceNumber()
+		require.True(t, ok)
+		return sequenceNumber
+	}
+
+	// The first replica serves the bundle before and after a rotation, while
+	// the second one only serves it after, e.g. because it was restarted.
+	replica1 := newServer()
+	defer replica1.Close()
+	before := fetch(replica1)
+
+	notBefore := ca.X509Authorities()[0].NotBefore.Add(time.Minute)
+	rotated, _ := test.CreateCACertificate(t, nil, nil, test.WithLifetime(notBefore, notBefore.Add(time.Hour)))
+	mtx.Lock()
+	bundle = bundle.Clone()
+	bundle.AddX509Authority(rotated)
+	mtx.Unlock()
+
+	replica2 := newServer()
+	defer replica2.Close()
+	after := fetch(replica1)
+	assert.Greater(t, after, before)
+	assert.Equal(t, after, fetch(replica2))
+}
+
+func TestHandlerBundleMetadata_InvalidOptions(t *testing.T) {
+	bundle := test.NewCA(t, td).Bundle()
+	_, err := federation.NewHandler(td, bundle, federation.WithRefreshHint(0))
+	assert.EqualError(t, err, "handler configuration is invalid: federation: refresh hint must be positive")
+	_, err = federation.NewHandler(td, bundle, federation.WithServedBundles(nil))
+	assert.EqualError(t, err, "handler configuration is invalid: federation: served bundles cannot be nil")
+}
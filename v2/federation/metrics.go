@@ -0,0 +1,160 @@
+package federation
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// FetchMetrics describes a request made to a bundle endpoint.
+type FetchMetrics struct {
+	// TrustDomain is the trust domain of the bundle fetched.
+	TrustDomain spiffeid.TrustDomain
+
+	// Duration is how long the request took.
+	Duration time.Duration
+
+	// ResponseSize is the number of bytes read from the response body.
+	ResponseSize int64
+
+	// NotModified is true if the endpoint answered a conditional request
+	// with 304 Not Modified.
+	NotModified bool
+
+	// Err is the error fetching the bundle, if any.
+	Err error
+}
+
+// ClientMetrics receives measurements from FetchBundle and WatchBundle. The
+// methods are called synchronously and should return quickly.
+type ClientMetrics interface {
+	// FetchCompleted is called after each request to a bundle endpoint.
+	FetchCompleted(FetchMetrics)
+
+	// BundleAge is called by WatchBundle after each poll with the time
+	// elapsed since the bundle was last successfully fetched, which grows
+	// while the endpoint is failing.
+	BundleAge(trustDomain spiffeid.TrustDomain, age time.Duration)
+}
+
+// WithClientMetrics provides the ClientMetrics that receive measurements
+// from FetchBundle and WatchBundle.
+func WithClientMetrics(metrics ClientMetrics) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		o.metrics = metrics
+		return nil
+	})
+}
+
+// RequestMetrics describes a request served by a bundle endpoint handler.
+type RequestMetrics struct {
+	// TrustDomain is the trust domain requested. It is zero if the request
+	// was rejected before the trust domain was determined.
+	TrustDomain spiffeid.TrustDomain
+
+	// StatusCode is the status code of the response.
+	StatusCode int
+
+	// Duration is how long the request took to serve.
+	Duration time.Duration
+
+	// ResponseSize is the number of bytes written to the response body.
+	ResponseSize int64
+}
+
+// HandlerMetrics receives measurements from the bundle endpoint handlers.
+// The methods are called synchronously and should return quickly.
+type HandlerMetrics interface {
+	// RequestServed is called after each request.
+	RequestServed(RequestMetrics)
+}
+
+// WithHandlerMetrics provides the HandlerMetrics that receive measurements
+// from the handler.
+func WithHandlerMetrics(metrics HandlerMetrics) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		c.metrics = metrics
+		return nil
+	})
+}
+
+// wrapHandler wraps the handler with the request guards and the
+// instrumentation enabled in the configuration.
+func wrapHandler(handler http.Handler, conf *handlerConfig) http.Handler {
+	handler = guard(handler, conf)
+	if conf.metrics == nil && conf.accessLog == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{}
+		rec := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		handler.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+		duration := time.Since(start)
+
+		if conf.metrics != nil {
+			conf.metrics.RequestServed(RequestMetrics{
+				TrustDomain:  info.trustDomain,
+				StatusCode:   rec.statusCode,
+				Duration:     duration,
+				ResponseSize: rec.size,
+			})
+		}
+		if conf.accessLog != nil {
+			conf.accessLog(newAccessLogEntry(r, conf, start, duration, info, rec))
+		}
+	})
+}
+
+// requestInfo collects information about a request as it is served.
+type requestInfo struct {
+	trustDomain spiffeid.TrustDomain
+}
+
+type requestInfoKey struct{}
+
+// setRequestTrustDomain records the trust domain requested, if the request
+// is instrumented.
+func setRequestTrustDomain(r *http.Request, trustDomain spiffeid.TrustDomain) {
+	if info, ok := r.Context().Value(requestInfoKey{}).(*requestInfo); ok {
+		info.trustDomain = trustDomain
+	}
+}
+
+// responseRecorder records the status code and size of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	size        int64
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.size += int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,86 @@
+package federation_test
+
+import (
+	"context"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	bundle := test.NewCA(t, td).Bundle()
+
+	handlerMetrics := &fakeHandlerMetrics{}
+	handler, err := federation.NewHandler(td, bundle, federation.WithHandlerMetrics(handlerMetrics))
+	require.NoError(t, err)
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &recordingWatcher{cancelAfter: 2, cancel: cancel}
+	clientMetrics := &fakeClientMetrics{}
+
+	err = federation.WatchBundle(ctx, td, server.URL, watcher,
+		federation.WithWebPKIRoots(x509util.NewCertPool([]*x509.Certificate{server.Certificate()})),
+		federation.WithClientMetrics(clientMetrics))
+	assert.Equal(t, context.Canceled, err)
+
+	// The bundle is transferred once and then not modified.
+	require.Len(t, clientMetrics.fetches, 2)
+	assert.Equal(t, td, clientMetrics.fetches[0].TrustDomain)
+	assert.False(t, clientMetrics.fetches[0].NotModified)
+	assert.Greater(t, clientMetrics.fetches[0].ResponseSize, int64(0))
+	assert.NoError(t, clientMetrics.fetches[0].Err)
+	assert.True(t, clientMetrics.fetches[1].NotModified)
+	assert.Equal(t, int64(0), clientMetrics.fetches[1].ResponseSize)
+	assert.Len(t, clientMetrics.ages, 2)
+
+	res, err := server.Client().Post(server.URL, "application/json", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+
+	handlerMetrics.mtx.Lock()
+	defer handlerMetrics.mtx.Unlock()
+	require.Len(t, handlerMetrics.requests, 3)
+	assert.Equal(t, td, handlerMetrics.requests[0].TrustDomain)
+	assert.Equal(t, http.StatusOK, handlerMetrics.requests[0].StatusCode)
+	assert.Equal(t, clientMetrics.fetches[0].ResponseSize, handlerMetrics.requests[0].ResponseSize)
+	assert.Equal(t, http.StatusNotModified, handlerMetrics.requests[1].StatusCode)
+	assert.Equal(t, spiffeid.TrustDomain{}, handlerMetrics.requests[2].TrustDomain)
+	assert.Equal(t, http.StatusMethodNotAllowed, handlerMetrics.requests[2].StatusCode)
+}
+
+type fakeClientMetrics struct {
+	fetches []federation.FetchMetrics
+	ages    []time.Duration
+}
+
+func (m *fakeClientMetrics) FetchCompleted(fetch federation.FetchMetrics) {
+	m.fetches = append(m.fetches, fetch)
+}
+
+func (m *fakeClientMetrics) BundleAge(trustDomain spiffeid.TrustDomain, age time.Duration) {
+	m.ages = append(m.ages, age)
+}
+
+type fakeHandlerMetrics struct {
+	mtx      sync.Mutex
+	requests []federation.RequestMetrics
+}
+
+func (m *fakeHandlerMetrics) RequestServed(request federation.RequestMetrics) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.requests = append(m.requests, request)
+}
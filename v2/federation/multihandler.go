@@ -0,0 +1,74 @@
+package federation
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// WithTrustDomainQueryParameter makes the handler returned by
+// NewMultiHandler take the trust domain from the given query parameter
+// instead of the request path. It has no effect on NewHandler.
+func WithTrustDomainQueryParameter(name string) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if name == "" {
+			return federationErr.New("trust domain query parameter name cannot be empty")
+		}
+		c.trustDomainParameter = name
+		return nil
+	})
+}
+
+// NewMultiHandler returns an HTTP handler that provides the bundles for
+// multiple trust domains from a single endpoint. The trust domain is taken
+// from the last segment of the request path (e.g. /bundles/example.org), or
+// from a query parameter when WithTrustDomainQueryParameter is used. Bundles
+// are obtained from the source on each request, as in NewHandler, and
+// trust domains the source has no bundle for are answered with 404 Not
+// Found. The same request guards as NewHandler are available. A spiffebundle.Set can be used as the source.
+func NewMultiHandler(source spiffebundle.Source, opts ...HandlerOption) (http.Handler, error) {
+	conf, err := newHandlerConfig(opts)
+	if err != nil {
+		return nil, err
+	}
+	liveSource := source
+	source = handlerSource(source, conf)
+	return wrapHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowedMethod(r) {
+			http.Error(w, "method is not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		urlPath, healthCheck := isHealthCheck(r, conf)
+		var name string
+		if conf.trustDomainParameter != "" {
+			name = r.URL.Query().Get(conf.trustDomainParameter)
+		} else {
+			name = path.Base(urlPath)
+		}
+
+		trustDomain, err := spiffeid.TrustDomainFromString(name)
+		if err != nil {
+			http.Error(w, "invalid trust domain", http.StatusBadRequest)
+			return
+		}
+		setRequestTrustDomain(r, trustDomain)
+		if healthCheck {
+			serveHealthCheck(w, conf, liveSource, trustDomain)
+			return
+		}
+
+		bundle, err := source.GetBundleForTrustDomain(trustDomain)
+		if err != nil {
+			// The source may be shared with trust domains that are not
+			// meant to be published, so the reason is not disclosed.
+			conf.log.Debugf("unable to get bundle for trust domain %q: %v", trustDomain, err)
+			http.Error(w, fmt.Sprintf("no bundle for %q", trustDomain), http.StatusNotFound)
+			return
+		}
+		serveBundle(w, r, conf, trustDomain, bundle)
+	}), conf), nil
+}
@@ -0,0 +1,103 @@
+package federation_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiHandler(t *testing.T) {
+	td1 := spiffeid.RequireTrustDomainFromString("domain1.test")
+	td2 := spiffeid.RequireTrustDomainFromString("domain2.test")
+	bundle1 := test.NewCA(t, td1).Bundle()
+	bundle2 := test.NewCA(t, td2).Bundle()
+	set := spiffebundle.NewSet(bundle1, bundle2)
+
+	byPath, err := federation.NewMultiHandler(set)
+	require.NoError(t, err)
+	byQuery, err := federation.NewMultiHandler(set, federation.WithTrustDomainQueryParameter("trust_domain"))
+	require.NoError(t, err)
+
+	pathServer := httptest.NewServer(byPath)
+	defer pathServer.Close()
+	queryServer := httptest.NewServer(byQuery)
+	defer queryServer.Close()
+
+	testCases := []struct {
+		name       string
+		url        string
+		statusCode int
+		bundle     *spiffebundle.Bundle
+		response   string
+	}{
+		{
+			name:       "path domain1",
+			url:        pathServer.URL + "/bundles/domain1.test",
+			statusCode: http.StatusOK,
+			bundle:     bundle1,
+		},
+		{
+			name:       "path domain2",
+			url:        pathServer.URL + "/domain2.test",
+			statusCode: http.StatusOK,
+			bundle:     bundle2,
+		},
+		{
+			name:       "path unknown domain",
+			url:        pathServer.URL + "/bundles/domain3.test",
+			statusCode: http.StatusNotFound,
+			response:   "no bundle for \"domain3.test\"\n",
+		},
+		{
+			name:       "path invalid domain",
+			url:        pathServer.URL + "/bundles/Domain1.test",
+			statusCode: http.StatusBadRequest,
+			response:   "invalid trust domain\n",
+		},
+		{
+			name:       "query domain2",
+			url:        queryServer.URL + "/bundles?trust_domain=domain2.test",
+			statusCode: http.StatusOK,
+			bundle:     bundle2,
+		},
+		{
+			name:       "query missing",
+			url:        queryServer.URL + "/bundles/domain1.test",
+			statusCode: http.StatusBadRequest,
+			response:   "invalid trust domain\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			res, err := http.Get(testCase.url)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			actual, err := ioutil.ReadAll(res.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, testCase.statusCode, res.StatusCode)
+			if testCase.bundle != nil {
+				expected, err := testCase.bundle.Marshal()
+				require.NoError(t, err)
+				require.JSONEq(t, string(expected), string(actual))
+				return
+			}
+			require.Equal(t, testCase.response, string(actual))
+		})
+	}
+}
+
+func TestMultiHandlerInvalidOption(t *testing.T) {
+	_, err := federation.NewMultiHandler(spiffebundle.NewSet(), federation.WithTrustDomainQueryParameter(""))
+	require.EqualError(t, err, "handler configuration is invalid: federation: trust domain query parameter name cannot be empty")
+}
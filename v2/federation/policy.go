@@ -0,0 +1,63 @@
+package federation
+
+import (
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// WithRequireRefreshHint rejects fetched bundles without a refresh hint.
+func WithRequireRefreshHint() FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		o.policy.requireRefreshHint = true
+		return nil
+	})
+}
+
+// WithRequireX509Authorities rejects fetched bundles without X.509
+// authorities, which cannot be used to authenticate X509-SVIDs.
+func WithRequireX509Authorities() FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		o.policy.requireX509Authorities = true
+		return nil
+	})
+}
+
+// WithMaxAuthorities rejects fetched bundles with more than max authorities,
+// X.509 and JWT authorities combined.
+func WithMaxAuthorities(max int) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if max <= 0 {
+			return federationErr.New("maximum number of authorities must be positive")
+		}
+		o.policy.maxAuthorities = max
+		return nil
+	})
+}
+
+// bundlePolicy holds the requirements fetched bundles must meet.
+type bundlePolicy struct {
+	requireRefreshHint     bool
+	requireX509Authorities bool
+	maxAuthorities         int
+}
+
+// check returns a *FetchError classified as ErrBundlePolicyViolation if the
+// bundle does not meet the requirements of the policy.
+func (p bundlePolicy) check(trustDomain spiffeid.TrustDomain, bundle *spiffebundle.Bundle) error {
+	var err error
+	x509Authorities := len(bundle.X509Authorities())
+	authorities := x509Authorities + len(bundle.JWTAuthorities())
+	_, hasRefreshHint := bundle.RefreshHint()
+	switch {
+	case p.requireRefreshHint && !hasRefreshHint:
+		err = federationErr.New("bundle for %q has no refresh hint", trustDomain)
+	case p.requireX509Authorities && x509Authorities == 0:
+		err = federationErr.New("bundle for %q has no X.509 authorities", trustDomain)
+	case p.maxAuthorities > 0 && authorities > p.maxAuthorities:
+		err = federationErr.New("bundle for %q has %d authorities, more than the maximum of %d", trustDomain, authorities, p.maxAuthorities)
+	}
+	if err != nil {
+		return &FetchError{Class: ErrBundlePolicyViolation, Err: err}
+	}
+	return nil
+}
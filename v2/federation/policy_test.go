@@ -0,0 +1,88 @@
+package federation_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchBundle_Policy(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+	jwtOnly := spiffebundle.FromJWTAuthorities(td, ca.JWTAuthorities())
+
+	for _, tt := range []struct {
+		name   string
+		bundle *spiffebundle.Bundle
+		option federation.FetchOption
+		err    string
+	}{
+		{
+			name:   "refresh hint required and missing",
+			bundle: bundle,
+			option: federation.WithRequireRefreshHint(),
+			err:    `federation: bundle for "domain.test" has no refresh hint`,
+		},
+		{
+			name: "refresh hint required and present",
+			bundle: func() *spiffebundle.Bundle {
+				b := bundle.Clone()
+				b.SetRefreshHint(time.Minute)
+				return b
+			}(),
+			option: federation.WithRequireRefreshHint(),
+		},
+		{
+			name:   "X.509 authorities required and missing",
+			bundle: jwtOnly,
+			option: federation.WithRequireX509Authorities(),
+			err:    `federation: bundle for "domain.test" has no X.509 authorities`,
+		},
+		{
+			name:   "X.509 authorities required and present",
+			bundle: bundle,
+			option: federation.WithRequireX509Authorities(),
+		},
+		{
+			name:   "too many authorities",
+			bundle: bundle,
+			option: federation.WithMaxAuthorities(1),
+			err:    `federation: bundle for "domain.test" has 2 authorities, more than the maximum of 1`,
+		},
+		{
+			name:   "maximum number of authorities",
+			bundle: bundle,
+			option: federation.WithMaxAuthorities(2),
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := federation.NewHandler(td, tt.bundle)
+			require.NoError(t, err)
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			fetched, err := federation.FetchBundle(context.Background(), td, server.URL, tt.option)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				assert.ErrorIs(t, err, federation.ErrBundlePolicyViolation)
+				assert.Nil(t, fetched)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, fetched.Equal(tt.bundle))
+		})
+	}
+}
+
+func TestWithMaxAuthorities_Invalid(t *testing.T) {
+	_, err := federation.FetchBundle(context.Background(), td, "url not used", federation.WithMaxAuthorities(0))
+	assert.EqualError(t, err, "federation: maximum number of authorities must be positive")
+}
@@ -0,0 +1,150 @@
+package federation
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+)
+
+// ReciprocalConfig describes a two-way federation relationship between a
+// local trust domain and a remote one: the local bundle is served to the
+// remote trust domain, and the remote bundle is fetched from its bundle
+// endpoint.
+type ReciprocalConfig struct {
+	// Local describes the local bundle endpoint, as it is reached by the
+	// remote trust domain. It is used to verify that the local bundle is
+	// served correctly. With ProfileHTTPSSPIFFE, the server is authenticated
+	// with the bootstrap bundle if set, or else with the local bundle.
+	Local BundleEndpoint
+
+	// LocalSource is the source of the local bundle.
+	LocalSource spiffebundle.Source
+
+	// Remote describes the bundle endpoint of the remote trust domain.
+	Remote BundleEndpoint
+
+	// HandlerOptions are the options of the handler serving the local
+	// bundle.
+	HandlerOptions []HandlerOption
+}
+
+// ReciprocalStatus reports the outcome of establishing a reciprocal
+// federation relationship.
+type ReciprocalStatus struct {
+	// RemoteBundle is the bundle fetched from the remote bundle endpoint, or
+	// nil if it could not be fetched.
+	RemoteBundle *spiffebundle.Bundle
+
+	// RemoteErr is the error fetching the remote bundle, if any.
+	RemoteErr error
+
+	// LocalErr is the error verifying that the local bundle is served at the
+	// local bundle endpoint URL, if any.
+	LocalErr error
+}
+
+// Established returns true if the remote bundle was fetched and the local
+// bundle was verified to be served.
+func (s ReciprocalStatus) Established() bool {
+	return s.RemoteErr == nil && s.LocalErr == nil
+}
+
+// ReciprocalFederation serves the local bundle of a reciprocal federation
+// relationship. It is created with StartReciprocalFederation.
+type ReciprocalFederation struct {
+	server *http.Server
+	status ReciprocalStatus
+
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// StartReciprocalFederation establishes a reciprocal federation relationship
+// with a single call. It starts serving the local bundle on the listener,
+// which is usually a TLS listener, then fetches the remote bundle and
+// verifies that the local bundle can be fetched back from the local bundle
+// endpoint URL. The context bounds these fetches. Failures to fetch are not
+// returned as errors but reported in the status, so that the relationship
+// can be retried or monitored while the local bundle keeps being served. The
+// ReciprocalFederation should be closed to stop serving the local bundle.
+func StartReciprocalFederation(ctx context.Context, listener net.Listener, config ReciprocalConfig) (*ReciprocalFederation, error) {
+	if err := validateBundleEndpoint(config.Local); err != nil {
+		return nil, err
+	}
+	if err := validateBundleEndpoint(config.Remote); err != nil {
+		return nil, err
+	}
+	if config.Local.TrustDomain == config.Remote.TrustDomain {
+		return nil, federationErr.New("local and remote trust domains must differ")
+	}
+	if config.LocalSource == nil {
+		return nil, federationErr.New("local bundle source is required")
+	}
+
+	handler, err := NewHandler(config.Local.TrustDomain, config.LocalSource, config.HandlerOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &ReciprocalFederation{
+		server: &http.Server{
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		_ = f.server.Serve(listener)
+	}()
+
+	f.status.RemoteBundle, f.status.RemoteErr = fetchEndpointBundle(ctx, config.Remote,
+		&endpointAuthSource{set: spiffebundle.NewSet(), bootstrap: config.Remote.Bootstrap})
+	f.status.LocalErr = verifyLocalBundle(ctx, config)
+	return f, nil
+}
+
+// Status returns the status of the reciprocal federation relationship, as
+// established by StartReciprocalFederation.
+func (f *ReciprocalFederation) Status() ReciprocalStatus {
+	return f.status
+}
+
+// Close stops serving the local bundle.
+func (f *ReciprocalFederation) Close() error {
+	var err error
+	f.closeOnce.Do(func() {
+		err = f.server.Close()
+		f.wg.Wait()
+	})
+	return err
+}
+
+// verifyLocalBundle fetches the local bundle from the local bundle endpoint
+// URL and checks that it is the one provided by the local bundle source.
+func verifyLocalBundle(ctx context.Context, config ReciprocalConfig) error {
+	localBundle, err := config.LocalSource.GetBundleForTrustDomain(config.Local.TrustDomain)
+	if err != nil {
+		return federationErr.New("unable to get local bundle: %w", err)
+	}
+	authSource := &endpointAuthSource{set: spiffebundle.NewSet(), bootstrap: config.Local.Bootstrap}
+	if config.Local.Bootstrap == nil {
+		authSource.set.Add(localBundle)
+	}
+	servedBundle, err := fetchEndpointBundle(ctx, config.Local, authSource)
+	if err != nil {
+		return err
+	}
+	if !servedBundle.Equal(localBundle) {
+		return federationErr.New("bundle served at %q does not match the local bundle", config.Local.URL)
+	}
+	return nil
+}
+
+func fetchEndpointBundle(ctx context.Context, endpoint BundleEndpoint, authSource *endpointAuthSource) (*spiffebundle.Bundle, error) {
+	return FetchBundle(ctx, endpoint.TrustDomain, endpoint.URL, endpointFetchOptions(endpoint, authSource)...)
+}
@@ -0,0 +1,148 @@
+package federation_test
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakebundleendpoint"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartReciprocalFederation(t *testing.T) {
+	remoteTD := spiffeid.RequireTrustDomainFromString("remote.test")
+	remoteCA := test.NewCA(t, remoteTD)
+	remoteBundle := remoteCA.Bundle()
+	remoteID := spiffeid.RequireFromPath(remoteTD, "/bundle-endpoint")
+	remoteEndpoint := fakebundleendpoint.New(t,
+		fakebundleendpoint.WithTestBundles(remoteBundle),
+		fakebundleendpoint.WithSPIFFEAuth(remoteBundle, remoteCA.CreateX509SVID(remoteID, test.WithIPAddresses(localhostIPs...))))
+	defer remoteEndpoint.Shutdown()
+
+	localCA := test.NewCA(t, td)
+	localBundle := localCA.Bundle()
+	localID := spiffeid.RequireFromPath(td, "/bundle-endpoint")
+	localSVID := localCA.CreateX509SVID(localID, test.WithIPAddresses(localhostIPs...))
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsconfig.TLSServerConfig(localSVID))
+	require.NoError(t, err)
+
+	config := federation.ReciprocalConfig{
+		Local: federation.BundleEndpoint{
+			TrustDomain: td,
+			URL:         "https://" + listener.Addr().String(),
+			Profile:     federation.ProfileHTTPSSPIFFE,
+			EndpointID:  localID,
+		},
+		LocalSource: localBundle,
+		Remote: federation.BundleEndpoint{
+			TrustDomain: remoteTD,
+			URL:         remoteEndpoint.FetchBundleURL(),
+			Profile:     federation.ProfileHTTPSSPIFFE,
+			EndpointID:  remoteID,
+			Bootstrap:   remoteBundle,
+		},
+	}
+
+	f, err := federation.StartReciprocalFederation(context.Background(), listener, config)
+	require.NoError(t, err)
+	defer f.Close()
+
+	status := f.Status()
+	assert.NoError(t, status.RemoteErr)
+	assert.NoError(t, status.LocalErr)
+	assert.True(t, status.Established())
+	assert.Equal(t, remoteBundle, status.RemoteBundle)
+
+	// The local bundle keeps being served.
+	served, err := federation.FetchBundle(context.Background(), td, config.Local.URL,
+		federation.WithSPIFFEAuth(localBundle, localID))
+	require.NoError(t, err)
+	assert.Equal(t, localBundle, served)
+
+	require.NoError(t, f.Close())
+	_, err = federation.FetchBundle(context.Background(), td, config.Local.URL,
+		federation.WithSPIFFEAuth(localBundle, localID))
+	assert.Error(t, err)
+}
+
+func TestStartReciprocalFederation_Failures(t *testing.T) {
+	remoteTD := spiffeid.RequireTrustDomainFromString("remote.test")
+	localCA := test.NewCA(t, td)
+	localSVID := localCA.CreateX509SVID(spiffeid.RequireFromPath(td, "/bundle-endpoint"), test.WithIPAddresses(localhostIPs...))
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", tlsconfig.TLSServerConfig(localSVID))
+	require.NoError(t, err)
+
+	// The local bundle endpoint URL points to a server serving another
+	// bundle, e.g. because of a misconfigured load balancer, and the remote
+	// endpoint is unreachable.
+	misconfigured := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(test.NewCA(t, td).Bundle()))
+	defer misconfigured.Shutdown()
+
+	f, err := federation.StartReciprocalFederation(context.Background(), listener, federation.ReciprocalConfig{
+		Local: federation.BundleEndpoint{
+			TrustDomain: td,
+			URL:         misconfigured.FetchBundleURL(),
+			Options:     []federation.FetchOption{federation.WithWebPKIRoots(misconfigured.RootCAs())},
+		},
+		LocalSource: localCA.Bundle(),
+		Remote: federation.BundleEndpoint{
+			TrustDomain: remoteTD,
+			URL:         "https://127.0.0.1:1",
+		},
+	})
+	require.NoError(t, err)
+	defer f.Close()
+
+	status := f.Status()
+	assert.False(t, status.Established())
+	assert.Nil(t, status.RemoteBundle)
+	assert.ErrorIs(t, status.RemoteErr, federation.ErrEndpointUnavailable)
+	assert.EqualError(t, status.LocalErr, `federation: bundle served at "`+misconfigured.FetchBundleURL()+`" does not match the local bundle`)
+}
+
+func TestStartReciprocalFederation_InvalidConfig(t *testing.T) {
+	remoteTD := spiffeid.RequireTrustDomainFromString("remote.test")
+	localSource := spiffebundle.New(td)
+	for _, tt := range []struct {
+		name   string
+		config federation.ReciprocalConfig
+		err    string
+	}{
+		{
+			name:   "missing local trust domain",
+			config: federation.ReciprocalConfig{Remote: federation.BundleEndpoint{TrustDomain: remoteTD}, LocalSource: localSource},
+			err:    "federation: bundle endpoint trust domain is required",
+		},
+		{
+			name: "same trust domains",
+			config: federation.ReciprocalConfig{
+				Local:       federation.BundleEndpoint{TrustDomain: td},
+				Remote:      federation.BundleEndpoint{TrustDomain: td},
+				LocalSource: localSource,
+			},
+			err: "federation: local and remote trust domains must differ",
+		},
+		{
+			name: "missing local source",
+			config: federation.ReciprocalConfig{
+				Local:  federation.BundleEndpoint{TrustDomain: td},
+				Remote: federation.BundleEndpoint{TrustDomain: remoteTD},
+			},
+			err: "federation: local bundle source is required",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := federation.StartReciprocalFederation(context.Background(), nil, tt.config)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
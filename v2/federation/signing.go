@@ -0,0 +1,145 @@
+package federation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"sync"
+
+	"github.com/go-jose/go-jose/v3"
+)
+
+// SignatureHeader is the HTTP response header carrying the detached JWS
+// signature of the bundle, when the handler signs bundle payloads.
+const SignatureHeader = "X-JWS-Signature"
+
+// PayloadSigner signs the bundle payloads served by the handler.
+type PayloadSigner interface {
+	// SignPayload returns a JWS over the payload in compact serialization
+	// with a detached payload, i.e. with an empty payload part.
+	SignPayload(payload []byte) (string, error)
+}
+
+// NewJWSSigner returns a PayloadSigner signing with the private key, which
+// must be an *ecdsa.PrivateKey, an *rsa.PrivateKey or an ed25519.PrivateKey.
+// The algorithm is ES256, ES384 or ES512 depending on the curve for ECDSA
+// keys, RS256 for RSA keys and EdDSA for Ed25519 keys. If the key ID is not
+// empty, it is set in the JWS header.
+func NewJWSSigner(key crypto.Signer, keyID string) (PayloadSigner, error) {
+	var alg jose.SignatureAlgorithm
+	switch key := key.(type) {
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			alg = jose.ES256
+		case elliptic.P384():
+			alg = jose.ES384
+		case elliptic.P521():
+			alg = jose.ES512
+		default:
+			return nil, federationErr.New("unsupported ECDSA curve %q", key.Curve.Params().Name)
+		}
+	case *rsa.PrivateKey:
+		alg = jose.RS256
+	case ed25519.PrivateKey:
+		alg = jose.EdDSA
+	default:
+		return nil, federationErr.New("unsupported signing key type %T", key)
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: alg,
+		Key:       jose.JSONWebKey{Key: key, KeyID: keyID},
+	}, nil)
+	if err != nil {
+		return nil, federationErr.New("unable to create signer: %w", err)
+	}
+	return jwsSigner{signer: signer}, nil
+}
+
+// WithPayloadSigner makes the handler sign the bundle payloads, providing
+// integrity independent of the TLS channel, e.g. when bundles are
+// distributed through CDNs or mirrors. The detached JWS signature is sent in
+// the SignatureHeader response header and covers the uncompressed bundle.
+func WithPayloadSigner(signer PayloadSigner) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if signer == nil {
+			return federationErr.New("payload signer cannot be nil")
+		}
+		c.signer = signer
+		return nil
+	})
+}
+
+// WithSignatureVerification requires the bundle payload to be signed by one
+// of the pinned public keys, as done by a handler using WithPayloadSigner.
+// Bundles without a valid signature are rejected with an error matching
+// ErrInvalidSignature.
+func WithSignatureVerification(keys ...crypto.PublicKey) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if len(keys) == 0 {
+			return federationErr.New("at least one signature verification key is required")
+		}
+		o.signatureKeys = keys
+		return nil
+	})
+}
+
+// verifySignature verifies the detached JWS signature of the payload against
+// the pinned keys.
+func verifySignature(signature string, payload []byte, keys []crypto.PublicKey) error {
+	if signature == "" {
+		return signatureError(federationErr.New("bundle is not signed"))
+	}
+	jws, err := jose.ParseDetached(signature, payload)
+	if err != nil {
+		return signatureError(federationErr.New("unable to parse bundle signature: %w", err))
+	}
+	for _, key := range keys {
+		if _, err := jws.Verify(key); err == nil {
+			return nil
+		}
+	}
+	return signatureError(federationErr.New("bundle signature does not match any of the pinned keys"))
+}
+
+func signatureError(err error) error {
+	return &FetchError{Class: ErrInvalidSignature, Err: err}
+}
+
+type jwsSigner struct {
+	signer jose.Signer
+}
+
+func (s jwsSigner) SignPayload(payload []byte) (string, error) {
+	jws, err := s.signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return jws.DetachedCompactSerialize()
+}
+
+// signatureCache holds the signature of the latest served bundle, so that
+// the bundle is not signed on every request.
+type signatureCache struct {
+	mtx       sync.Mutex
+	tag       string
+	signature string
+}
+
+func (c *signatureCache) sign(signer PayloadSigner, tag string, data []byte) (string, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.tag == tag {
+		return c.signature, nil
+	}
+	signature, err := signer.SignPayload(data)
+	if err != nil {
+		return "", err
+	}
+	c.tag, c.signature = tag, signature
+	return signature, nil
+}
@@ -0,0 +1,112 @@
+package federation_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedBundles(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	bundle := test.NewCA(t, td).Bundle()
+	for _, key := range []crypto.Signer{ecKey, rsaKey, edKey} {
+		signer, err := federation.NewJWSSigner(key, "key-1")
+		require.NoError(t, err)
+		handler, err := federation.NewHandler(td, bundle, federation.WithPayloadSigner(signer))
+		require.NoError(t, err)
+		server := httptest.NewServer(handler)
+
+		fetched, err := federation.FetchBundle(context.Background(), td, server.URL,
+			federation.WithSignatureVerification(key.Public()))
+		require.NoError(t, err)
+		assert.Equal(t, bundle, fetched)
+
+		// Any of the pinned keys can have signed the bundle.
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		_, err = federation.FetchBundle(context.Background(), td, server.URL,
+			federation.WithSignatureVerification(otherKey.Public(), key.Public()))
+		require.NoError(t, err)
+
+		_, err = federation.FetchBundle(context.Background(), td, server.URL,
+			federation.WithSignatureVerification(otherKey.Public()))
+		require.EqualError(t, err, "federation: bundle signature does not match any of the pinned keys")
+		assert.True(t, errors.Is(err, federation.ErrInvalidSignature))
+
+		server.Close()
+	}
+}
+
+func TestSignedBundles_TamperedOrUnsigned(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer, err := federation.NewJWSSigner(key, "")
+	require.NoError(t, err)
+
+	bundle := test.NewCA(t, td).Bundle()
+	otherBundle := test.NewCA(t, td).Bundle()
+	signedHandler, err := federation.NewHandler(td, bundle, federation.WithPayloadSigner(signer))
+	require.NoError(t, err)
+	unsignedHandler, err := federation.NewHandler(td, otherBundle)
+	require.NoError(t, err)
+
+	tampered := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unsigned" {
+			unsignedHandler.ServeHTTP(w, r)
+			return
+		}
+		// Serve the other bundle with the signature of the bundle, as a
+		// compromised mirror could.
+		rec := httptest.NewRecorder()
+		signedHandler.ServeHTTP(rec, r)
+		w.Header().Set(federation.SignatureHeader, rec.Header().Get(federation.SignatureHeader))
+		unsignedHandler.ServeHTTP(w, r)
+		tampered = true
+	}))
+	defer server.Close()
+
+	_, err = federation.FetchBundle(context.Background(), td, server.URL+"/tampered",
+		federation.WithSignatureVerification(key.Public()))
+	require.EqualError(t, err, "federation: bundle signature does not match any of the pinned keys")
+	assert.True(t, tampered)
+
+	_, err = federation.FetchBundle(context.Background(), td, server.URL+"/unsigned",
+		federation.WithSignatureVerification(key.Public()))
+	require.EqualError(t, err, "federation: bundle is not signed")
+	assert.True(t, errors.Is(err, federation.ErrInvalidSignature))
+
+	// Bundles are not verified unless signature verification is enabled.
+	_, err = federation.FetchBundle(context.Background(), td, server.URL+"/unsigned")
+	require.NoError(t, err)
+}
+
+func TestSignedBundles_InvalidOptions(t *testing.T) {
+	_, err := federation.NewJWSSigner(nil, "")
+	assert.EqualError(t, err, "federation: unsupported signing key type <nil>")
+
+	_, err = federation.NewHandler(td, test.NewCA(t, td).Bundle(), federation.WithPayloadSigner(nil))
+	assert.EqualError(t, err, "handler configuration is invalid: federation: payload signer cannot be nil")
+
+	_, err = federation.FetchBundle(context.Background(), td, "url not used", federation.WithSignatureVerification())
+	assert.EqualError(t, err, "federation: at least one signature verification key is required")
+}
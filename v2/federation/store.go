@@ -0,0 +1,171 @@
+package federation
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// BundleStore stores the bundles served by bundle endpoint handlers, e.g. in
+// a filesystem, an object store or a database. Serving bundles from a store
+// decouples the availability of the bundle endpoint from the availability of
+// the bundle source, e.g. the Workload API. Implementations must be safe for
+// concurrent use.
+type BundleStore interface {
+	// LoadBundle returns the stored bundle for the trust domain, or an error
+	// if there is none.
+	LoadBundle(trustDomain spiffeid.TrustDomain) (*spiffebundle.Bundle, error)
+
+	// StoreBundle stores the bundle, replacing the stored bundle for the
+	// same trust domain.
+	StoreBundle(bundle *spiffebundle.Bundle) error
+}
+
+// WithBundleStore makes the handler serve the bundle from the store when the
+// bundle source fails to provide it. Use PublishBundles to keep the store
+// up-to-date with the bundle source.
+func WithBundleStore(store BundleStore) HandlerOption {
+	return handlerOption(func(c *handlerConfig) error {
+		if store == nil {
+			return federationErr.New("bundle store cannot be nil")
+		}
+		c.store = store
+		return nil
+	})
+}
+
+// NewStoreSource returns a bundle source that provides the bundles of the
+// store, so that a handler can serve bundles solely from the store, e.g. in
+// a process that has no access to the Workload API.
+func NewStoreSource(store BundleStore) spiffebundle.Source {
+	return storeSource{store: store}
+}
+
+// PublishBundles stores the bundles of the trust domains from the source in
+// the store, then again at every interval, until the context is canceled,
+// in which case ctx.Err() is returned. Only bundles that changed since they
+// were last published are stored. Failures are logged with the provided
+// logger, which can be nil, and retried at the next interval.
+func PublishBundles(ctx context.Context, source spiffebundle.Source, store BundleStore, interval time.Duration, log logger.Logger, trustDomains ...spiffeid.TrustDomain) error {
+	if interval <= 0 {
+		return federationErr.New("publish interval must be positive")
+	}
+	if log == nil {
+		log = logger.Null
+	}
+
+	published := make(map[spiffeid.TrustDomain]*spiffebundle.Bundle)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, trustDomain := range trustDomains {
+			bundle, err := source.GetBundleForTrustDomain(trustDomain)
+			if err != nil {
+				log.Warnf("Unable to get bundle for trust domain %q to publish: %v", trustDomain, err)
+				continue
+			}
+			if published[trustDomain].Equal(bundle) {
+				continue
+			}
+			if err := store.StoreBundle(bundle); err != nil {
+				log.Errorf("Unable to publish bundle for trust domain %q: %v", trustDomain, err)
+				continue
+			}
+			published[trustDomain] = bundle
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// FileBundleStore is a BundleStore that stores each bundle in a file named
+// after its trust domain in a directory.
+type FileBundleStore struct {
+	dir string
+}
+
+// NewFileBundleStore returns a FileBundleStore storing bundles in the
+// directory, which must exist.
+func NewFileBundleStore(dir string) *FileBundleStore {
+	return &FileBundleStore{dir: dir}
+}
+
+// LoadBundle returns the stored bundle for the trust domain.
+func (s *FileBundleStore) LoadBundle(trustDomain spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+	data, err := ioutil.ReadFile(s.path(trustDomain))
+	switch {
+	case os.IsNotExist(err):
+		return nil, federationErr.New("no stored bundle for trust domain %q", trustDomain)
+	case err != nil:
+		return nil, federationErr.New("unable to read stored bundle: %w", err)
+	}
+	bundle, err := spiffebundle.Parse(trustDomain, data)
+	if err != nil {
+		return nil, federationErr.New("unable to parse stored bundle: %w", err)
+	}
+	return bundle, nil
+}
+
+// StoreBundle atomically writes the bundle to the file of its trust domain.
+func (s *FileBundleStore) StoreBundle(bundle *spiffebundle.Bundle) error {
+	data, err := bundle.Marshal()
+	if err != nil {
+		return federationErr.New("unable to marshal bundle to store: %w", err)
+	}
+	if err := writeFileAtomically(s.path(bundle.TrustDomain()), data); err != nil {
+		return federationErr.New("unable to store bundle: %w", err)
+	}
+	return nil
+}
+
+func (s *FileBundleStore) path(trustDomain spiffeid.TrustDomain) string {
+	// Trust domain names cannot contain path separators.
+	return filepath.Join(s.dir, trustDomain.String()+".json")
+}
+
+type storeSource struct {
+	store BundleStore
+}
+
+func (s storeSource) GetBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+	return s.store.LoadBundle(trustDomain)
+}
+
+// storeFallbackSource provides the bundles of the source, falling back to
+// the store when the source fails.
+type storeFallbackSource struct {
+	source spiffebundle.Source
+	store  BundleStore
+	log    logger.Logger
+}
+
+func (s storeFallbackSource) GetBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*spiffebundle.Bundle, error) {
+	bundle, err := s.source.GetBundleForTrustDomain(trustDomain)
+	if err == nil {
+		return bundle, nil
+	}
+	stored, storeErr := s.store.LoadBundle(trustDomain)
+	if storeErr != nil {
+		return nil, err
+	}
+	s.log.Warnf("Serving stored bundle for trust domain %q: unable to get bundle from source: %v", trustDomain, err)
+	return stored, nil
+}
+
+// handlerSource returns the source the handler gets bundles from.
+func handlerSource(source spiffebundle.Source, conf *handlerConfig) spiffebundle.Source {
+	if conf.store == nil {
+		return source
+	}
+	return storeFallbackSource{source: source, store: conf.store, log: conf.log}
+}
@@ -0,0 +1,134 @@
+package federation_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/spiffebundle"
+	"github.com/damarescavalcante/go-spiffe/v2/federation"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBundleStore(t *testing.T) {
+	dir := t.TempDir()
+	store := federation.NewFileBundleStore(dir)
+
+	_, err := store.LoadBundle(td)
+	require.EqualError(t, err, `federation: no stored bundle for trust domain "domain.test"`)
+
+	bundle := test.NewCA(t, td).Bundle()
+	require.NoError(t, store.StoreBundle(bundle))
+	stored, err := store.LoadBundle(td)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, stored)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "domain.test.json"))
+	require.NoError(t, err)
+	expected, err := bundle.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, expected, data)
+
+	// The stored bundle is replaced.
+	bundle = test.NewCA(t, td).Bundle()
+	require.NoError(t, store.StoreBundle(bundle))
+	stored, err = store.LoadBundle(td)
+	require.NoError(t, err)
+	assert.Equal(t, bundle, stored)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "domain.test.json"), []byte("{"), 0600))
+	_, err = store.LoadBundle(td)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "federation: unable to parse stored bundle")
+}
+
+func TestHandlerWithBundleStore(t *testing.T) {
+	storedBundle := test.NewCA(t, td).Bundle()
+	store := federation.NewFileBundleStore(t.TempDir())
+	require.NoError(t, store.StoreBundle(storedBundle))
+
+	liveBundle := test.NewCA(t, td).Bundle()
+	source := spiffebundle.NewSet(liveBundle)
+
+	handler, err := federation.NewHandler(td, source, federation.WithBundleStore(store))
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// The live bundle is served while the source provides it.
+	fetched, err := federation.FetchBundle(context.Background(), td, server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, liveBundle, fetched)
+
+	// The stored bundle is served when the source fails.
+	source.Remove(td)
+	fetched, err = federation.FetchBundle(context.Background(), td, server.URL)
+	require.NoError(t, err)
+	assert.Equal(t, storedBundle, fetched)
+
+	_, err = federation.NewHandler(td, source, federation.WithBundleStore(nil))
+	require.EqualError(t, err, "handler configuration is invalid: federation: bundle store cannot be nil")
+}
+
+func TestHandlerWithStoreSource(t *testing.T) {
+	otherTD := spiffeid.RequireTrustDomainFromString("other.test")
+	bundle := test.NewCA(t, td).Bundle()
+	store := federation.NewFileBundleStore(t.TempDir())
+	require.NoError(t, store.StoreBundle(bundle))
+
+	handler, err := federation.NewMultiHandler(federation.NewStoreSource(store))
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	fetched, err := federation.FetchBundle(context.Background(), td, server.URL+"/domain.test")
+	require.NoError(t, err)
+	assert.Equal(t, bundle, fetched)
+
+	res, err := http.Get(server.URL + "/" + otherTD.String())
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestPublishBundles(t *testing.T) {
+	otherTD := spiffeid.RequireTrustDomainFromString("other.test")
+	bundle := test.NewCA(t, td).Bundle()
+	source := spiffebundle.NewSet(bundle)
+	store := federation.NewFileBundleStore(t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		// The bundle of other.test is not in the source, which is logged
+		// and does not prevent publishing the other bundles.
+		errCh <- federation.PublishBundles(ctx, source, store, 10*time.Millisecond, nil, td, otherTD)
+	}()
+
+	require.Eventually(t, func() bool {
+		stored, err := store.LoadBundle(td)
+		return err == nil && stored.Equal(bundle)
+	}, time.Second, 10*time.Millisecond)
+
+	// Changes to the source are published at the next interval.
+	bundle = test.NewCA(t, td).Bundle()
+	source.Add(bundle)
+	require.Eventually(t, func() bool {
+		stored, err := store.LoadBundle(td)
+		return err == nil && stored.Equal(bundle)
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-errCh)
+
+	err := federation.PublishBundles(context.Background(), source, store, 0, nil, td)
+	assert.EqualError(t, err, "federation: publish interval must be positive")
+}
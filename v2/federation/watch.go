@@ -32,17 +32,188 @@ type BundleWatcher interface {
 	OnError(err error)
 }
 
+// WatchState is the health of a bundle watch, as reported to a
+// BundleLifecycleWatcher.
+type WatchState int
+
+const (
+	// WatchStateHealthy means that the latest poll of the bundle endpoint
+	// succeeded.
+	WatchStateHealthy WatchState = iota + 1
+
+	// WatchStateDegraded means that polls of the bundle endpoint are
+	// failing, so the latest bundle may be stale.
+	WatchStateDegraded
+)
+
+// String returns the name of the state.
+func (s WatchState) String() string {
+	switch s {
+	case WatchStateHealthy:
+		return "healthy"
+	case WatchStateDegraded:
+		return "degraded"
+	default:
+		return "unknown"
+	}
+}
+
+// BundleLifecycleWatcher is a BundleWatcher that is also notified of the
+// lifecycle of the watch. WatchBundle calls the additional methods when the
+// watcher passed to it implements this interface. Like the BundleWatcher
+// methods, they are called synchronously and should return quickly.
+type BundleLifecycleWatcher interface {
+	BundleWatcher
+
+	// OnFetchError is called after OnError when polling the bundle endpoint
+	// fails, with the time until the next poll.
+	OnFetchError(err error, nextRetry time.Duration)
+
+	// OnStateChange is called when the watch becomes healthy or degraded.
+	// The watch becomes healthy on the first successful poll, and degraded
+	// once the number of consecutive failed polls reaches the threshold set
+	// with WithDegradedThreshold, which defaults to one.
+	OnStateChange(state WatchState)
+}
+
+// WithDegradedThreshold sets the number of consecutive failed polls after
+// which the watch is reported as degraded to a BundleLifecycleWatcher. This
+// option is only used by WatchBundle.
+func WithDegradedThreshold(failures int) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if failures <= 0 {
+			return federationErr.New("degraded threshold must be positive")
+		}
+		o.degradedThreshold = failures
+		return nil
+	})
+}
+
+// WithBundleSetUpdates makes WatchBundle add every updated bundle to the
+// bundle set passed to WithSPIFFEAuth, before passing it to the watcher, so
+// that the next polls authenticate the bundle endpoint with the latest keys.
+// The bundle source passed to WithSPIFFEAuth must be a *spiffebundle.Set.
+// This option is only used by WatchBundle.
+func WithBundleSetUpdates() FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		o.updateBundleSet = true
+		return nil
+	})
+}
+
+// WithPollInterval sets the maximum interval between polls of the bundle
+// endpoint. The interval returned by BundleWatcher.NextRefresh is capped to
+// it. This option is only used by WatchBundle.
+func WithPollInterval(interval time.Duration) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if interval <= 0 {
+			return federationErr.New("poll interval must be positive")
+		}
+		o.pollInterval = interval
+		return nil
+	})
+}
+
+// WithJitter randomizes the interval between polls of the bundle endpoint by
+// up to the given fraction in either direction (e.g. 0.1 for +/-10%), so that
+// a fleet of watchers does not poll the endpoint in lockstep. The fraction
+// must be between 0 and 1. This option is only used by WatchBundle.
+func WithJitter(fraction float64) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if fraction < 0 || fraction > 1 {
+			return federationErr.New("jitter must be between 0 and 1")
+		}
+		o.jitter = fraction
+		return nil
+	})
+}
+
+// WithBackoff makes WatchBundle retry failed polls of the bundle endpoint with
+// an exponential backoff, starting at the initial interval and doubling up to
+// the maximum interval, instead of waiting for the interval returned by
+// BundleWatcher.NextRefresh. The backoff is reset after a successful poll.
+// This option is only used by WatchBundle.
+func WithBackoff(initial, max time.Duration) FetchOption {
+	return fetchOption(func(o *fetchOptions) error {
+		if initial <= 0 || max < initial {
+			return federationErr.New("backoff intervals must be positive and the maximum cannot be less than the initial")
+		}
+		o.backoff = &backoffConfig{initial: initial, max: max}
+		return nil
+	})
+}
+
 // WatchBundle watches a bundle on a bundle endpoint. It returns when the
-// context is canceled, returning ctx.Err().
+// context is canceled, returning ctx.Err(). Conditional requests are used to
+// avoid transferring the bundle again when the endpoint reports, via its
+// ETag, that it has not changed. The refresh hint passed to the watcher is
+// the one in the bundle or, if the bundle does not have one, the maximum age
+// advertised by the endpoint. Bundles with a sequence number lower than the
+// one of the latest bundle, or without one once the latest bundle has one,
+// are rejected with a *SequenceNumberRollbackError passed to the watcher's
+// OnError, as they may be replayed or stale. With WithCheckpointStore or
+// WithCacheFile, the latest bundle is the stored one, so that rollbacks are
+// also rejected across watches and restarts. Watchers
+// implementing BundleDeltaWatcher are also told how the bundle changed.
 func WatchBundle(ctx context.Context, trustDomain spiffeid.TrustDomain, url string, watcher BundleWatcher, options ...FetchOption) error {
 	if watcher == nil {
 		return federationErr.New("watcher cannot be nil")
 	}
 
+	opts, err := newFetchOptions(options)
+	if err != nil {
+		return err
+	}
+
+	lifecycleWatcher, _ := watcher.(BundleLifecycleWatcher)
+	degradedThreshold := opts.degradedThreshold
+	if degradedThreshold == 0 {
+		degradedThreshold = 1
+	}
+	var state WatchState
+	var failures int
+
+	var backoff *backoff
+	if opts.backoff != nil {
+		backoff = newBackoff(*opts.backoff)
+	}
+
+	deltaWatcher, _ := watcher.(BundleDeltaWatcher)
+	var previousBundle *spiffebundle.Bundle
+	onUpdate := func(bundle *spiffebundle.Bundle) {
+		delta := DiffBundles(previousBundle, bundle)
+		previousBundle = bundle
+		opts.log.Debugf("Bundle for trust domain %q changed: %s", trustDomain, delta)
+		if opts.updateBundleSet {
+			opts.spiffeAuthSource.(*spiffebundle.Set).Add(bundle)
+		}
+		watcher.OnUpdate(bundle)
+		if deltaWatcher != nil {
+			deltaWatcher.OnDelta(delta)
+		}
+	}
+
 	latestBundle := &spiffebundle.Bundle{}
+	var latestETag string
+	if opts.checkpoints != nil {
+		checkpoint, err := loadCheckpoint(opts.checkpoints, trustDomain)
+		switch {
+		case err != nil:
+			watcher.OnError(err)
+		case checkpoint != nil:
+			onUpdate(checkpoint.Bundle)
+			latestBundle, latestETag = checkpoint.Bundle, checkpoint.ETag
+		}
+	}
+
+	var maxAge time.Duration
+	var lastFetched time.Time
 	var timer *time.Timer
 	for {
-		bundle, err := FetchBundle(ctx, trustDomain, url, options...)
+		resp, err := fetchBundle(ctx, trustDomain, url, latestETag, opts)
+		if err == nil && resp.bundle != nil {
+			err = checkSequenceNumber(trustDomain, latestBundle, resp.bundle)
+		}
 		switch {
 		// Context was canceled when fetching bundle, so to avoid
 		// more calls to FetchBundle (because the timer could be expired at
@@ -51,16 +222,64 @@ func WatchBundle(ctx context.Context, trustDomain spiffeid.TrustDomain, url stri
 			return ctx.Err()
 		case err != nil:
 			watcher.OnError(err)
-		case !latestBundle.Equal(bundle):
-			watcher.OnUpdate(bundle)
-			latestBundle = bundle
+		case resp.bundle == nil:
+			// The bundle has not been modified.
+			maxAge = resp.maxAge
+			lastFetched = time.Now()
+		default:
+			maxAge = resp.maxAge
+			lastFetched = time.Now()
+			changed := !latestBundle.Equal(resp.bundle)
+			if changed {
+				onUpdate(resp.bundle)
+				latestBundle = resp.bundle
+			}
+			if opts.checkpoints != nil && (changed || resp.etag != latestETag) {
+				if err := opts.checkpoints.StoreCheckpoint(trustDomain, WatchCheckpoint{Bundle: resp.bundle, ETag: resp.etag}); err != nil {
+					watcher.OnError(err)
+				}
+			}
+			latestETag = resp.etag
+		}
+
+		if opts.metrics != nil && !lastFetched.IsZero() {
+			opts.metrics.BundleAge(trustDomain, time.Since(lastFetched))
 		}
 
 		var nextRefresh time.Duration
-		if refreshHint, ok := latestBundle.RefreshHint(); ok {
+		switch refreshHint, ok := latestBundle.RefreshHint(); {
+		case err != nil && backoff != nil:
+			nextRefresh = backoff.Duration()
+		case ok:
 			nextRefresh = watcher.NextRefresh(refreshHint)
-		} else {
-			nextRefresh = watcher.NextRefresh(0)
+		default:
+			nextRefresh = watcher.NextRefresh(maxAge)
+		}
+		if err == nil && backoff != nil {
+			backoff.Reset()
+		}
+		if opts.pollInterval > 0 && nextRefresh > opts.pollInterval {
+			nextRefresh = opts.pollInterval
+		}
+		nextRefresh = applyJitter(nextRefresh, opts.jitter)
+		opts.log.Debugf("Next bundle refresh for trust domain %q in %s", trustDomain, nextRefresh)
+
+		if lifecycleWatcher != nil {
+			newState := state
+			if err != nil {
+				failures++
+				lifecycleWatcher.OnFetchError(err, nextRefresh)
+				if failures >= degradedThreshold {
+					newState = WatchStateDegraded
+				}
+			} else {
+				failures = 0
+				newState = WatchStateHealthy
+			}
+			if newState != state {
+				state = newState
+				lifecycleWatcher.OnStateChange(state)
+			}
 		}
 
 		if timer == nil {
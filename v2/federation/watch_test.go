@@ -1,7 +1,15 @@
 package federation_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -9,7 +17,11 @@ import (
 	"github.com/damarescavalcante/go-spiffe/v2/federation"
 	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
 	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakebundleendpoint"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWatchBundle_OnUpdate(t *testing.T) {
@@ -111,3 +123,352 @@ func (w *fakewatcher) OnError(err error) {
 	w.onErrorCalls++
 	w.cancel()
 }
+
+func TestWatchBundle_ConditionalRequests(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := ca.Bundle()
+
+	handler, err := federation.NewHandler(td, bundle)
+	require.NoError(t, err)
+
+	var mtx sync.Mutex
+	var ifNoneMatch []string
+	var statusCodes []int
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+
+		mtx.Lock()
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		statusCodes = append(statusCodes, rec.Code)
+		mtx.Unlock()
+
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		// Advertise a maximum age since the bundle has no refresh hint.
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &recordingWatcher{cancelAfter: 3, cancel: cancel}
+
+	err = federation.WatchBundle(ctx, td, server.URL, watcher, federation.WithWebPKIRoots(x509util.NewCertPool([]*x509.Certificate{server.Certificate()})))
+	assert.Equal(t, context.Canceled, err)
+
+	assert.Equal(t, []*spiffebundle.Bundle{bundle}, watcher.updates)
+	assert.Empty(t, watcher.errs)
+	assert.Equal(t, []time.Duration{time.Minute, time.Minute, time.Minute}, watcher.refreshHints)
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	require.Len(t, ifNoneMatch, 3)
+	assert.Empty(t, ifNoneMatch[0])
+	assert.NotEmpty(t, ifNoneMatch[1])
+	assert.Equal(t, ifNoneMatch[1], ifNoneMatch[2])
+	assert.Equal(t, []int{http.StatusOK, http.StatusNotModified, http.StatusNotModified}, statusCodes)
+}
+
+type recordingWatcher struct {
+	cancelAfter  int
+	nextRefresh  time.Duration
+	cancel       context.CancelFunc
+	refreshHints []time.Duration
+	updates      []*spiffebundle.Bundle
+	errs         []error
+}
+
+func (w *recordingWatcher) NextRefresh(refreshHint time.Duration) time.Duration {
+	w.refreshHints = append(w.refreshHints, refreshHint)
+	if len(w.refreshHints) == w.cancelAfter {
+		w.cancel()
+	}
+	if w.nextRefresh > 0 {
+		return w.nextRefresh
+	}
+	return time.Millisecond
+}
+
+func (w *recordingWatcher) OnUpdate(bundle *spiffebundle.Bundle) {
+	w.updates = append(w.updates, bundle)
+}
+
+func (w *recordingWatcher) OnError(err error) {
+	w.errs = append(w.errs, err)
+}
+
+func TestWatchBundle_PollIntervalAndBackoff(t *testing.T) {
+	var mtx sync.Mutex
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests++
+		// Fail every other request
+		if requests%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		data, err := test.NewCA(t, td).Bundle().Marshal()
+		assert.NoError(t, err)
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The watcher asks for an hour between refreshes, which the poll
+	// interval caps. Failures are retried with the backoff instead.
+	watcher := &recordingWatcher{cancelAfter: 3, cancel: cancel, nextRefresh: time.Hour}
+
+	err := federation.WatchBundle(ctx, td, server.URL, watcher,
+		federation.WithWebPKIRoots(x509util.NewCertPool([]*x509.Certificate{server.Certificate()})),
+		federation.WithPollInterval(10*time.Millisecond),
+		federation.WithJitter(0.5),
+		federation.WithBackoff(time.Millisecond, 10*time.Millisecond))
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, watcher.updates, 3)
+	assert.Len(t, watcher.errs, 2)
+	assert.Len(t, watcher.refreshHints, 3)
+}
+
+func TestWatchBundle_Lifecycle(t *testing.T) {
+	var mtx sync.Mutex
+	requests := 0
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		requests++
+		// Fail the second and third requests
+		if requests == 2 || requests == 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		data, err := test.NewCA(t, td).Bundle().Marshal()
+		assert.NoError(t, err)
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &lifecycleWatcher{recordingWatcher: recordingWatcher{cancelAfter: 4, cancel: cancel}}
+
+	err := federation.WatchBundle(ctx, td, server.URL, watcher,
+		federation.WithWebPKIRoots(x509util.NewCertPool([]*x509.Certificate{server.Certificate()})),
+		federation.WithDegradedThreshold(2))
+	assert.Equal(t, context.Canceled, err)
+	assert.Len(t, watcher.errs, 2)
+	assert.Equal(t, watcher.errs, watcher.fetchErrs)
+	assert.Equal(t, []time.Duration{time.Millisecond, time.Millisecond}, watcher.nextRetries)
+	assert.Equal(t, []federation.WatchState{federation.WatchStateHealthy, federation.WatchStateDegraded, federation.WatchStateHealthy}, watcher.states)
+}
+
+type lifecycleWatcher struct {
+	recordingWatcher
+	fetchErrs   []error
+	nextRetries []time.Duration
+	states      []federation.WatchState
+}
+
+func (w *lifecycleWatcher) OnFetchError(err error, nextRetry time.Duration) {
+	w.fetchErrs = append(w.fetchErrs, err)
+	w.nextRetries = append(w.nextRetries, nextRetry)
+}
+
+func (w *lifecycleWatcher) OnStateChange(state federation.WatchState) {
+	w.states = append(w.states, state)
+}
+
+func TestWatchBundle_BundleSetUpdates(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundleSet := spiffebundle.NewSet(ca.Bundle())
+
+	// The served bundle differs from the one in the set by its refresh hint.
+	servedBundle := ca.Bundle()
+	servedBundle.SetRefreshHint(time.Minute)
+
+	id := spiffeid.RequireFromPath(td, "/bundle-endpoint")
+	be := fakebundleendpoint.New(t,
+		fakebundleendpoint.WithTestBundles(servedBundle),
+		fakebundleendpoint.WithSPIFFEAuth(ca.Bundle(), ca.CreateX509SVID(id, test.WithIPAddresses(localhostIPs...))))
+	defer be.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &recordingWatcher{cancelAfter: 1, cancel: cancel}
+
+	err := federation.WatchBundle(ctx, td, be.FetchBundleURL(), watcher,
+		federation.WithSPIFFEAuth(bundleSet, id),
+		federation.WithBundleSetUpdates())
+	assert.Equal(t, context.Canceled, err)
+	require.Equal(t, []*spiffebundle.Bundle{servedBundle}, watcher.updates)
+
+	bundle, ok := bundleSet.Get(td)
+	require.True(t, ok)
+	assert.Equal(t, servedBundle, bundle)
+}
+
+func TestWatchBundle_BundleSetUpdatesWithoutBundleSet(t *testing.T) {
+	ca := test.NewCA(t, td)
+	for _, options := range [][]federation.FetchOption{
+		{federation.WithBundleSetUpdates()},
+		{federation.WithSPIFFEAuth(ca.Bundle(), spiffeid.RequireFromPath(td, "/bundle-endpoint")), federation.WithBundleSetUpdates()},
+	} {
+		err := federation.WatchBundle(context.Background(), td, "url not used", &recordingWatcher{}, options...)
+		assert.EqualError(t, err, "federation: bundle set updates require SPIFFE authentication with a bundle set")
+	}
+}
+
+func TestWatchBundle_Logging(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle := spiffebundle.FromX509Authorities(td, ca.X509Authorities())
+	for _, key := range ca.JWTAuthorities() {
+		require.NoError(t, bundle.AddJWTAuthority("KID", key))
+	}
+	serverLog := new(bytes.Buffer)
+	handler, err := federation.NewHandler(td, bundle, federation.WithLogger(logger.Writer(serverLog)))
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clientLog := new(bytes.Buffer)
+	watcher := &recordingWatcher{cancelAfter: 2, cancel: cancel}
+	err = federation.WatchBundle(ctx, td, server.URL, watcher, federation.WithLogger(logger.Writer(clientLog)))
+	assert.Equal(t, context.Canceled, err)
+	server.Close()
+
+	url := server.URL
+	assert.Equal(t, fmt.Sprintf(`[DEBUG] Fetching bundle for trust domain "domain.test" from %q using Web PKI authentication
+[DEBUG] Fetched bundle for trust domain "domain.test" with status 200
+[DEBUG] Bundle for trust domain "domain.test" changed: X.509 authorities added: 1, removed: 0; JWT authorities added: [KID], removed: []
+[DEBUG] Next bundle refresh for trust domain "domain.test" in 1ms
+[DEBUG] Fetching bundle for trust domain "domain.test" from %q using Web PKI authentication
+[DEBUG] Bundle for trust domain "domain.test" not modified
+[DEBUG] Next bundle refresh for trust domain "domain.test" in 1ms
+`, url, url), clientLog.String())
+
+	lines := strings.Split(strings.TrimSpace(serverLog.String()), "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^\[DEBUG\] served bundle for trust domain "domain.test" to 127.0.0.1:\d+$`, lines[0])
+	assert.Regexp(t, `^\[DEBUG\] bundle for trust domain "domain.test" not modified for 127.0.0.1:\d+$`, lines[1])
+}
+
+func TestWatchBundle_InvalidOptions(t *testing.T) {
+	watcher := &recordingWatcher{}
+	for _, tt := range []struct {
+		option federation.FetchOption
+		err    string
+	}{
+		{option: federation.WithPollInterval(0), err: "federation: poll interval must be positive"},
+		{option: federation.WithJitter(1.5), err: "federation: jitter must be between 0 and 1"},
+		{option: federation.WithBackoff(time.Second, time.Millisecond), err: "federation: backoff intervals must be positive and the maximum cannot be less than the initial"},
+		{option: federation.WithDegradedThreshold(0), err: "federation: degraded threshold must be positive"},
+	} {
+		err := federation.WatchBundle(context.Background(), td, "url not used", watcher, tt.option)
+		assert.EqualError(t, err, tt.err)
+	}
+}
+
+func TestWatchBundle_SequenceNumberRollback(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle1 := ca.Bundle()
+	bundle1.SetSequenceNumber(2)
+	bundle2 := ca.Bundle()
+	bundle2.SetSequenceNumber(1)
+	bundle3 := ca.Bundle()
+	bundle3.SetSequenceNumber(3)
+
+	be := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(bundle1, bundle2, bundle3))
+	defer be.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &recordingWatcher{cancelAfter: 3, cancel: cancel}
+
+	err := federation.WatchBundle(ctx, td, be.FetchBundleURL(), watcher, federation.WithWebPKIRoots(be.RootCAs()))
+	assert.Equal(t, context.Canceled, err)
+
+	assert.Equal(t, []*spiffebundle.Bundle{bundle1, bundle3}, watcher.updates)
+	require.Len(t, watcher.errs, 1)
+	var rollbackErr *federation.SequenceNumberRollbackError
+	require.True(t, errors.As(watcher.errs[0], &rollbackErr))
+	assert.Equal(t, &federation.SequenceNumberRollbackError{TrustDomain: td, Latest: 2, Received: 1}, rollbackErr)
+	assert.EqualError(t, rollbackErr, `federation: bundle sequence number for "domain.test" went backwards from 2 to 1`)
+}
+
+func TestWatchBundle_SequenceNumberMissing(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle1 := ca.Bundle()
+	bundle1.SetSequenceNumber(2)
+	bundle2 := ca.Bundle()
+	bundle3 := ca.Bundle()
+	bundle3.SetSequenceNumber(3)
+
+	be := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(bundle1, bundle2, bundle3))
+	defer be.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &recordingWatcher{cancelAfter: 3, cancel: cancel}
+
+	err := federation.WatchBundle(ctx, td, be.FetchBundleURL(), watcher, federation.WithWebPKIRoots(be.RootCAs()))
+	assert.Equal(t, context.Canceled, err)
+
+	// The bundle without a sequence number is rejected, and does not disable
+	// the checks of the following bundles.
+	assert.Equal(t, []*spiffebundle.Bundle{bundle1, bundle3}, watcher.updates)
+	require.Len(t, watcher.errs, 1)
+	var rollbackErr *federation.SequenceNumberRollbackError
+	require.True(t, errors.As(watcher.errs[0], &rollbackErr))
+	assert.Equal(t, &federation.SequenceNumberRollbackError{TrustDomain: td, Latest: 2, Missing: true}, rollbackErr)
+	assert.EqualError(t, rollbackErr, `federation: bundle sequence number for "domain.test" went backwards from 2 to none`)
+}
+
+func TestWatchBundle_Delta(t *testing.T) {
+	ca := test.NewCA(t, td)
+	bundle1 := ca.Bundle()
+	bundle1.SetSequenceNumber(1)
+	bundle2 := ca.Bundle()
+	bundle2.AddX509Authority(test.NewCA(t, td).X509Authorities()[0])
+	bundle2.SetSequenceNumber(2)
+
+	be := fakebundleendpoint.New(t, fakebundleendpoint.WithTestBundles(bundle1, bundle2))
+	defer be.Shutdown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher := &deltaWatcher{recordingWatcher: recordingWatcher{cancelAfter: 2, cancel: cancel}}
+
+	err := federation.WatchBundle(ctx, td, be.FetchBundleURL(), watcher, federation.WithWebPKIRoots(be.RootCAs()))
+	assert.Equal(t, context.Canceled, err)
+	require.Equal(t, []*spiffebundle.Bundle{bundle1, bundle2}, watcher.updates)
+	require.Len(t, watcher.deltas, 2)
+
+	assert.Nil(t, watcher.deltas[0].Previous)
+	assert.Equal(t, bundle1, watcher.deltas[0].Current)
+	assert.Equal(t, bundle1.X509Authorities(), watcher.deltas[0].AddedX509Authorities)
+	assert.Equal(t, bundle1.JWTAuthorities(), watcher.deltas[0].AddedJWTAuthorities)
+
+	assert.Equal(t, bundle1, watcher.deltas[1].Previous)
+	assert.Equal(t, bundle2, watcher.deltas[1].Current)
+	assert.Equal(t, bundle2.X509Authorities()[1:], watcher.deltas[1].AddedX509Authorities)
+	assert.Empty(t, watcher.deltas[1].RemovedX509Authorities)
+	assert.Empty(t, watcher.deltas[1].AddedJWTAuthorities)
+	assert.Empty(t, watcher.deltas[1].RemovedJWTAuthorities)
+	assert.True(t, watcher.deltas[1].SequenceNumberChanged())
+}
+
+type deltaWatcher struct {
+	recordingWatcher
+	deltas []federation.BundleDelta
+}
+
+func (w *deltaWatcher) OnDelta(delta federation.BundleDelta) {
+	w.deltas = append(w.deltas, delta)
+}
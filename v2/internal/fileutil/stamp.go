@@ -0,0 +1,28 @@
+package fileutil
+
+import (
+	"os"
+	"time"
+)
+
+// Stamp identifies the version of a file on disk by its size and
+// modification time. It is used to detect file changes without reading the
+// file contents.
+type Stamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// Equal returns true if both stamps identify the same version of a file.
+func (s Stamp) Equal(other Stamp) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// Stat returns the stamp of the file at the given path.
+func Stat(path string) (Stamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Stamp{}, err
+	}
+	return Stamp{size: info.Size(), modTime: info.ModTime()}, nil
+}
@@ -0,0 +1,322 @@
+package pemutil
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // hmacWithSHA1 is the PBKDF2 default PRF, only supported for decryption
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+)
+
+const encryptedKeyType string = "ENCRYPTED PRIVATE KEY"
+
+// KDF is the key derivation function used to derive the encryption key of an
+// encrypted private key from its password.
+type KDF int
+
+const (
+	// KDFScrypt derives the key with scrypt (RFC 7914).
+	KDFScrypt KDF = iota
+	// KDFPBKDF2 derives the key with PBKDF2 and HMAC-SHA256 (RFC 8018).
+	KDFPBKDF2
+)
+
+const (
+	saltLen = 16
+
+	// The scrypt cost parameters fit the default memory limit of OpenSSL.
+	scryptN = 1 << 14
+	scryptR = 8
+	scryptP = 1
+
+	// pbkdf2Iterations follows the OWASP recommendation for HMAC-SHA256.
+	pbkdf2Iterations = 600000
+
+	// The KDF parameters of parsed keys are bounded, so that crafted keys
+	// cannot exhaust the memory or the CPU. The bounds are well above the
+	// parameters used by EncodeEncryptedPKCS8PrivateKey and OpenSSL.
+	maxScryptN          = 1 << 20
+	maxScryptRP         = 64
+	maxScryptMemory     = 1 << 30
+	maxPBKDF2Iterations = 10000000
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidScrypt         = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11591, 4, 11}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+type scryptParams struct {
+	Salt                     []byte
+	CostParameter            int
+	BlockSize                int
+	ParallelizationParameter int
+	KeyLength                int `asn1:"optional"`
+}
+
+// EncodeEncryptedPKCS8PrivateKey returns the private key as an encrypted
+// PKCS#8 PEM block. The key is encrypted with AES-256-CBC under a key derived
+// from the password with the given KDF (PBES2, RFC 8018).
+func EncodeEncryptedPKCS8PrivateKey(privateKey interface{}, password []byte, kdf KDF) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("password cannot be empty")
+	}
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltLen)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	const keyLen = 32
+	var key []byte
+	var kdfAlgorithm pkix.AlgorithmIdentifier
+	switch kdf {
+	case KDFScrypt:
+		key, err = scrypt.Key(password, salt, scryptN, scryptR, scryptP, keyLen)
+		if err != nil {
+			return nil, err
+		}
+		kdfAlgorithm, err = newAlgorithmIdentifier(oidScrypt, scryptParams{
+			Salt:                     salt,
+			CostParameter:            scryptN,
+			BlockSize:                scryptR,
+			ParallelizationParameter: scryptP,
+			KeyLength:                keyLen,
+		})
+	case KDFPBKDF2:
+		key = pbkdf2.Key(password, salt, pbkdf2Iterations, keyLen, sha256.New)
+		kdfAlgorithm, err = newAlgorithmIdentifier(oidPBKDF2, pbkdf2Params{
+			Salt:           salt,
+			IterationCount: pbkdf2Iterations,
+			KeyLength:      keyLen,
+			PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+		})
+	default:
+		return nil, fmt.Errorf("unsupported KDF %d", kdf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(keyBytes)%aes.BlockSize
+	encrypted := append(keyBytes, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	encryptionScheme, err := newAlgorithmIdentifier(oidAES256CBC, iv)
+	if err != nil {
+		return nil, err
+	}
+	pbes2, err := newAlgorithmIdentifier(oidPBES2, pbes2Params{
+		KeyDerivationFunc: kdfAlgorithm,
+		EncryptionScheme:  encryptionScheme,
+	})
+	if err != nil {
+		return nil, err
+	}
+	der, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pbes2,
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  encryptedKeyType,
+		Bytes: der,
+	}), nil
+}
+
+// ParseEncryptedPrivateKey parses the first encrypted PKCS#8 PEM block,
+// decrypting it with the password. Keys encrypted with PBES2, using PBKDF2
+// (with HMAC-SHA1 or HMAC-SHA256) or scrypt and AES-CBC, are supported, which
+// includes the keys encrypted by EncodeEncryptedPKCS8PrivateKey and OpenSSL.
+func ParseEncryptedPrivateKey(keyBytes []byte, password []byte) (crypto.PrivateKey, error) {
+	var foundBlocks bool
+	for {
+		var block *pem.Block
+		block, keyBytes = pem.Decode(keyBytes)
+		switch {
+		case block == nil && !foundBlocks:
+			return nil, errors.New("no PEM blocks found")
+		case block == nil:
+			return nil, errors.New("no encrypted private key PEM block found")
+		case block.Type == encryptedKeyType:
+			return decryptPrivateKey(block.Bytes, password)
+		}
+		foundBlocks = true
+	}
+}
+
+// HasEncryptedPrivateKey returns true if there is an encrypted PKCS#8 PEM
+// block.
+func HasEncryptedPrivateKey(keyBytes []byte) bool {
+	for {
+		var block *pem.Block
+		block, keyBytes = pem.Decode(keyBytes)
+		switch {
+		case block == nil:
+			return false
+		case block.Type == encryptedKeyType:
+			return true
+		}
+	}
+}
+
+func decryptPrivateKey(der []byte, password []byte) (crypto.PrivateKey, error) {
+	var info encryptedPrivateKeyInfo
+	if err := unmarshalDER(der, &info); err != nil {
+		return nil, fmt.Errorf("cannot parse encrypted private key: %w", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported private key encryption algorithm %s", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if err := unmarshalDER(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("cannot parse PBES2 parameters: %w", err)
+	}
+
+	var keyLen int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAES128CBC):
+		keyLen = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAES192CBC):
+		keyLen = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAES256CBC):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported private key encryption scheme %s", params.EncryptionScheme.Algorithm)
+	}
+	var iv []byte
+	if err := unmarshalDER(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil || len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid private key encryption IV")
+	}
+
+	key, err := deriveKey(params.KeyDerivationFunc, password, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted private key length")
+	}
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	// A wrong password is detected by the padding or the PKCS#8 structure.
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("cannot decrypt private key: incorrect password")
+	}
+	privateKey, err := x509.ParsePKCS8PrivateKey(decrypted[:len(decrypted)-padding])
+	if err != nil {
+		return nil, errors.New("cannot decrypt private key: incorrect password")
+	}
+	return privateKey, nil
+}
+
+func deriveKey(kdf pkix.AlgorithmIdentifier, password []byte, keyLen int) ([]byte, error) {
+	switch {
+	case kdf.Algorithm.Equal(oidPBKDF2):
+		var params pbkdf2Params
+		if err := unmarshalDER(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("cannot parse PBKDF2 parameters: %w", err)
+		}
+		var prf func() hash.Hash
+		switch {
+		case len(params.PRF.Algorithm) == 0, params.PRF.Algorithm.Equal(oidHMACWithSHA1):
+			prf = sha1.New
+		case params.PRF.Algorithm.Equal(oidHMACWithSHA256):
+			prf = sha256.New
+		default:
+			return nil, fmt.Errorf("unsupported PBKDF2 PRF %s", params.PRF.Algorithm)
+		}
+		if params.IterationCount < 1 || params.IterationCount > maxPBKDF2Iterations {
+			return nil, fmt.Errorf("unsupported PBKDF2 iteration count %d", params.IterationCount)
+		}
+		return pbkdf2.Key(password, params.Salt, params.IterationCount, keyLen, prf), nil
+	case kdf.Algorithm.Equal(oidScrypt):
+		var params scryptParams
+		if err := unmarshalDER(kdf.Parameters.FullBytes, &params); err != nil {
+			return nil, fmt.Errorf("cannot parse scrypt parameters: %w", err)
+		}
+		n, r, p := params.CostParameter, params.BlockSize, params.ParallelizationParameter
+		if n < 2 || n > maxScryptN || r < 1 || p < 1 || r*p > maxScryptRP || 128*n*r > maxScryptMemory {
+			return nil, fmt.Errorf("unsupported scrypt parameters N=%d, r=%d, p=%d", n, r, p)
+		}
+		return scrypt.Key(password, params.Salt, n, r, p, keyLen)
+	default:
+		return nil, fmt.Errorf("unsupported private key KDF %s", kdf.Algorithm)
+	}
+}
+
+func newAlgorithmIdentifier(oid asn1.ObjectIdentifier, params interface{}) (pkix.AlgorithmIdentifier, error) {
+	der, err := asn1.Marshal(params)
+	if err != nil {
+		return pkix.AlgorithmIdentifier{}, err
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.RawValue{FullBytes: der}}, nil
+}
+
+func unmarshalDER(der []byte, out interface{}) error {
+	rest, err := asn1.Unmarshal(der, out)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 0 {
+		return errors.New("trailing data")
+	}
+	return nil
+}
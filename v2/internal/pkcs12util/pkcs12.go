@@ -0,0 +1,60 @@
+// Package pkcs12util encodes and decodes PKCS#12 (.p12/.pfx) keystores with a
+// private key and its certificate chain, as understood by Java, Windows and
+// OpenSSL.
+package pkcs12util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Encode returns a PKCS#12 keystore with the private key and certificates,
+// protected with the password. The private key and certificates are encrypted
+// with PBES2, using PBKDF2 with HMAC-SHA256 and AES-256-CBC, and the keystore
+// integrity is protected with an HMAC-SHA256, like OpenSSL 3 and Java 20 do
+// by default. Such keystores are understood by OpenSSL 1.1.1, Java 12,
+// Windows Server 2019 and later versions. The private key and the first
+// certificate share a local key ID so they are associated with each other by
+// consumers.
+func Encode(privateKey crypto.PrivateKey, certs []*x509.Certificate, password string) ([]byte, error) {
+	return encode(pkcs12.Modern, privateKey, certs, password)
+}
+
+// EncodeLegacy is like Encode, but encrypts the keystore with the legacy
+// pbeWithSHAAnd3-KeyTripleDES-CBC algorithm and protects its integrity with
+// an HMAC-SHA1, for consumers that do not support PBES2, e.g. older versions
+// of Java and Windows. The legacy encryption is weak, so the keystore should
+// be protected by other means.
+func EncodeLegacy(privateKey crypto.PrivateKey, certs []*x509.Certificate, password string) ([]byte, error) {
+	return encode(pkcs12.Legacy, privateKey, certs, password)
+}
+
+// Decode returns the private key and certificates in a PKCS#12 keystore
+// protected with the password. Keystores encrypted with PBES2 (e.g. AES) and
+// with the legacy PKCS#12 algorithms (e.g. 3DES or RC2) are supported. The
+// certificates are returned in the order they appear in the keystore.
+func Decode(data []byte, password string) (crypto.PrivateKey, []*x509.Certificate, error) {
+	privateKey, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, append([]*x509.Certificate{cert}, caCerts...), nil
+}
+
+func encode(encoder *pkcs12.Encoder, privateKey crypto.PrivateKey, certs []*x509.Certificate, password string) ([]byte, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates to encode")
+	}
+	switch privateKey.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+	return encoder.Encode(privateKey, certs[0], certs[1:], password)
+}
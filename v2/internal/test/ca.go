@@ -265,6 +265,15 @@ func WithKeyUsage(keyUsage x509.KeyUsage) SVIDOption {
 	}
 }
 
+func WithMaxPathLen(maxPathLen int) SVIDOption {
+	return SVIDOption{
+		certificateOption: func(c *x509.Certificate) {
+			c.MaxPathLen = maxPathLen
+			c.MaxPathLenZero = maxPathLen == 0
+		},
+	}
+}
+
 func WithLifetime(notBefore, notAfter time.Time) SVIDOption {
 	return SVIDOption{
 		certificateOption: func(c *x509.Certificate) {
@@ -282,6 +291,14 @@ func WithIPAddresses(ips ...net.IP) SVIDOption {
 	}
 }
 
+func WithDNSNames(dnsNames ...string) SVIDOption {
+	return SVIDOption{
+		certificateOption: func(c *x509.Certificate) {
+			c.DNSNames = dnsNames
+		},
+	}
+}
+
 func WithURIs(uris ...*url.URL) SVIDOption {
 	return SVIDOption{
 		certificateOption: func(c *x509.Certificate) {
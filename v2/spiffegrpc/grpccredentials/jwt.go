@@ -0,0 +1,52 @@
+package grpccredentials
+
+import (
+	"context"
+
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"google.golang.org/grpc/credentials"
+)
+
+// JWTPerRPCCredentials returns per-RPC credentials attaching a JWT-SVID
+// fetched from the source to each RPC as a bearer token. The audience of the
+// JWT-SVID is derived from the URI of the service being called, e.g.
+// "https://example.org/helloworld.Greeter", with the audience function. If
+// the audience function is nil, the URI normalized with
+// jwtsvid.NormalizeAudience is used as the audience. JWT-SVIDs
+// are cached and fetched again before they expire (see
+// jwtsvid.NewCachingSource). The credentials require transport security,
+// since the JWT-SVIDs are bearer tokens.
+func JWTPerRPCCredentials(source jwtsvid.Source, audience func(uri string) string) credentials.PerRPCCredentials {
+	if audience == nil {
+		audience = jwtsvid.NormalizeAudience
+	}
+	return jwtCredentials{
+		source:   jwtsvid.NewCachingSource(source),
+		audience: audience,
+	}
+}
+
+type jwtCredentials struct {
+	source   jwtsvid.Source
+	audience func(uri string) string
+}
+
+func (c jwtCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	var target string
+	if len(uri) > 0 {
+		target = uri[0]
+	}
+	svid, err := c.source.FetchJWTSVID(ctx, jwtsvid.Params{
+		Audience: c.audience(target),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"authorization": "Bearer " + svid.Marshal(),
+	}, nil
+}
+
+func (c jwtCredentials) RequireTransportSecurity() bool {
+	return true
+}
@@ -0,0 +1,60 @@
+package grpccredentials_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffegrpc/grpccredentials"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTPerRPCCredentials(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain.test")
+	ca := test.NewCA(t, td)
+	clientID := spiffeid.RequireFromPath(td, "/client")
+	uri := "https://example.org/helloworld.Greeter"
+
+	var audiences []string
+	source := jwtSourceFunc(func(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error) {
+		audiences = append(audiences, params.Audience)
+		if params.Audience == "fail" {
+			return nil, errors.New("oh no")
+		}
+		return ca.CreateJWTSVID(clientID, []string{params.Audience}), nil
+	})
+
+	creds := grpccredentials.JWTPerRPCCredentials(source, nil)
+	assert.True(t, creds.RequireTransportSecurity())
+	md, err := creds.GetRequestMetadata(context.Background(), uri)
+	require.NoError(t, err)
+	svid, err := jwtsvid.ParseAndValidate(md["authorization"][len("Bearer "):], ca.JWTBundle(), []string{uri})
+	require.NoError(t, err)
+	assert.Equal(t, clientID, svid.ID)
+
+	// The JWT-SVID is cached.
+	_, err = creds.GetRequestMetadata(context.Background(), uri)
+	require.NoError(t, err)
+	assert.Equal(t, []string{uri}, audiences)
+
+	creds = grpccredentials.JWTPerRPCCredentials(source, func(uri string) string {
+		return "spiffe://domain.test/server"
+	})
+	_, err = creds.GetRequestMetadata(context.Background(), uri)
+	require.NoError(t, err)
+	assert.Equal(t, []string{uri, "spiffe://domain.test/server"}, audiences)
+
+	creds = grpccredentials.JWTPerRPCCredentials(source, func(string) string { return "fail" })
+	_, err = creds.GetRequestMetadata(context.Background(), uri)
+	require.EqualError(t, err, "oh no")
+}
+
+type jwtSourceFunc func(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error)
+
+func (fn jwtSourceFunc) FetchJWTSVID(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error) {
+	return fn(ctx, params)
+}
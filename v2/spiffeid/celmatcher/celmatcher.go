@@ -0,0 +1,92 @@
+// Package celmatcher creates SPIFFE ID matchers from CEL expressions (see
+// https://github.com/google/cel-spec), so that authorization policies can be
+// part of the configuration of a service instead of its code.
+//
+// The expression must evaluate to a boolean and can refer to the following
+// variables, describing the SPIFFE ID being matched:
+//
+//	id            string        the SPIFFE ID, e.g. "spiffe://example.org/ns/prod/sa/db"
+//	trust_domain  string        the trust domain, e.g. "example.org"
+//	path          string        the path, e.g. "/ns/prod/sa/db"
+//	segments      list(string)  the path segments, e.g. ["ns", "prod", "sa", "db"]
+//
+// For example:
+//
+//	trust_domain == "example.org" && path.startsWith("/ns/prod/")
+//	trust_domain in ["example.org", "example.com"] && segments[0] == "ns"
+//
+// The package is a separate module, so that only its users depend on cel-go,
+// which requires Go 1.18 or later.
+package celmatcher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+// New returns a matcher matching the SPIFFE IDs for which the CEL expression
+// evaluates to true. An error is returned if the expression cannot be
+// compiled or does not evaluate to a boolean. The returned matcher is safe
+// for concurrent use.
+func New(expression string) (spiffeid.Matcher, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("id", cel.StringType),
+		cel.Variable("trust_domain", cel.StringType),
+		cel.Variable("path", cel.StringType),
+		cel.Variable("segments", cel.ListType(cel.StringType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid CEL expression: %w", issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType {
+		return nil, fmt.Errorf("CEL expression must evaluate to a bool, not %s", ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CEL expression: %w", err)
+	}
+
+	return func(actual spiffeid.ID) error {
+		out, _, err := program.Eval(map[string]interface{}{
+			"id":           actual.String(),
+			"trust_domain": actual.TrustDomain().String(),
+			"path":         actual.Path(),
+			"segments":     pathSegments(actual.Path()),
+		})
+		if err != nil {
+			return fmt.Errorf("cannot evaluate CEL expression for ID %q: %w", actual, err)
+		}
+		if out != types.True {
+			return fmt.Errorf("unexpected ID %q", actual)
+		}
+		return nil
+	}, nil
+}
+
+// RequireNew is similar to New except that instead of returning an error on
+// an invalid expression, it panics. It should only be used when the input is
+// statically verifiable.
+func RequireNew(expression string) spiffeid.Matcher {
+	matcher, err := New(expression)
+	if err != nil {
+		panic(err)
+	}
+	return matcher
+}
+
+// pathSegments returns the segments of a SPIFFE ID path. SPIFFE ID paths are
+// normalized, so they have no empty segments.
+func pathSegments(path string) []string {
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path[1:], "/")
+}
@@ -0,0 +1,89 @@
+package celmatcher_test
+
+import (
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid/celmatcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	prodDB := spiffeid.RequireFromString("spiffe://example.org/ns/prod/sa/db")
+	devDB := spiffeid.RequireFromString("spiffe://example.org/ns/dev/sa/db")
+	otherProdDB := spiffeid.RequireFromString("spiffe://example.com/ns/prod/sa/db")
+	trustDomain := spiffeid.RequireFromString("spiffe://example.org")
+
+	for _, tt := range []struct {
+		name       string
+		expression string
+		matches    []spiffeid.ID
+		mismatches []spiffeid.ID
+	}{
+		{
+			name:       "trust domain and path prefix",
+			expression: `trust_domain == "example.org" && path.startsWith("/ns/prod/")`,
+			matches:    []spiffeid.ID{prodDB},
+			mismatches: []spiffeid.ID{devDB, otherProdDB, trustDomain},
+		},
+		{
+			name:       "segments",
+			expression: `size(segments) == 4 && segments[1] in ["prod", "dev"] && segments[3] == "db"`,
+			matches:    []spiffeid.ID{prodDB, devDB, otherProdDB},
+			mismatches: []spiffeid.ID{trustDomain},
+		},
+		{
+			name:       "ID",
+			expression: `id == "spiffe://example.org"`,
+			matches:    []spiffeid.ID{trustDomain},
+			mismatches: []spiffeid.ID{prodDB},
+		},
+		{
+			name:       "regular expression",
+			expression: `id.matches("^spiffe://example\\.(org|com)/ns/prod/")`,
+			matches:    []spiffeid.ID{prodDB, otherProdDB},
+			mismatches: []spiffeid.ID{devDB},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			matcher, err := celmatcher.New(tt.expression)
+			require.NoError(t, err)
+			for _, id := range tt.matches {
+				assert.NoError(t, matcher(id), id.String())
+			}
+			for _, id := range tt.mismatches {
+				assert.EqualError(t, matcher(id), `unexpected ID "`+id.String()+`"`)
+			}
+		})
+	}
+}
+
+func TestNewFailures(t *testing.T) {
+	_, err := celmatcher.New(`trust_domain ==`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid CEL expression:")
+
+	_, err = celmatcher.New(`unknown == "example.org"`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "undeclared reference to 'unknown'")
+
+	_, err = celmatcher.New(`path`)
+	assert.EqualError(t, err, "CEL expression must evaluate to a bool, not string")
+
+	matcher, err := celmatcher.New(`segments[5] == "db"`)
+	require.NoError(t, err)
+	err = matcher(spiffeid.RequireFromString("spiffe://example.org/db"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `cannot evaluate CEL expression for ID "spiffe://example.org/db":`)
+}
+
+func TestRequireNew(t *testing.T) {
+	assert.NotPanics(t, func() {
+		celmatcher.RequireNew(`trust_domain == "example.org"`)
+	})
+	assert.Panics(t, func() {
+		celmatcher.RequireNew(`trust_domain ==`)
+	})
+}
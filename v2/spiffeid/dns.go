@@ -0,0 +1,51 @@
+package spiffeid
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// TrustDomainFromDNSName returns a trust domain derived from a DNS name,
+// e.g. the domain of a cluster or environment. The name is lowercased, a
+// trailing dot is removed and internationalized domain names are converted
+// to their ASCII (punycode) form, so that "Exämple.ORG." becomes
+// "xn--exmple-cua.org". Names with a port, IP addresses and names that are
+// not valid DNS names are rejected.
+func TrustDomainFromDNSName(name string) (TrustDomain, error) {
+	switch {
+	case name == "":
+		return TrustDomain{}, errMissingTrustDomain
+	case strings.Contains(name, ":"):
+		if net.ParseIP(strings.Trim(name, "[]")) != nil {
+			return TrustDomain{}, fmt.Errorf("DNS name %q cannot be an IP address", name)
+		}
+		return TrustDomain{}, fmt.Errorf("DNS name %q cannot have a port or scheme", name)
+	case net.ParseIP(name) != nil:
+		return TrustDomain{}, fmt.Errorf("DNS name %q cannot be an IP address", name)
+	}
+
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSuffix(name, "."))
+	if err != nil {
+		return TrustDomain{}, fmt.Errorf("invalid DNS name %q: %w", name, err)
+	}
+	for _, label := range strings.Split(ascii, ".") {
+		switch {
+		case label == "":
+			return TrustDomain{}, fmt.Errorf("invalid DNS name %q: empty label", name)
+		case len(label) > 63:
+			return TrustDomain{}, fmt.Errorf("invalid DNS name %q: label %q is longer than 63 characters", name, label)
+		}
+	}
+	if len(ascii) > 253 {
+		return TrustDomain{}, fmt.Errorf("invalid DNS name %q: longer than 253 characters", name)
+	}
+
+	td, err := TrustDomainFromString(ascii)
+	if err != nil {
+		return TrustDomain{}, fmt.Errorf("invalid DNS name %q: %w", name, err)
+	}
+	return td, nil
+}
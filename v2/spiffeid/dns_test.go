@@ -0,0 +1,45 @@
+package spiffeid_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustDomainFromDNSName(t *testing.T) {
+	for name, expectTD := range map[string]string{
+		"example.org":            "example.org",
+		"Cluster.Example.ORG":    "cluster.example.org",
+		"example.org.":           "example.org",
+		"exämple.org":            "xn--exmple-cua.org",
+		"xn--exmple-cua.org":     "xn--exmple-cua.org",
+		"prod-1.k8s.example.org": "prod-1.k8s.example.org",
+		"localhost":              "localhost",
+	} {
+		td, err := spiffeid.TrustDomainFromDNSName(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expectTD, td.String(), name)
+	}
+}
+
+func TestTrustDomainFromDNSNameErrors(t *testing.T) {
+	for name, expectErr := range map[string]string{
+		"":                      "trust domain is missing",
+		"example.org:8443":      `DNS name "example.org:8443" cannot have a port or scheme`,
+		"spiffe://example.org":  `DNS name "spiffe://example.org" cannot have a port or scheme`,
+		"10.0.0.1":              `DNS name "10.0.0.1" cannot be an IP address`,
+		"::1":                   `DNS name "::1" cannot be an IP address`,
+		"[2001:db8::1]":         `DNS name "[2001:db8::1]" cannot be an IP address`,
+		"example..org":          `invalid DNS name "example..org": empty label`,
+		"-example.org":          `invalid DNS name "-example.org": idna: invalid label "-example"`,
+		strings.Repeat("a", 64): `invalid DNS name "` + strings.Repeat("a", 64) + `": label "` + strings.Repeat("a", 64) + `" is longer than 63 characters`,
+		"example.org/path":      `invalid DNS name "example.org/path": idna: disallowed rune U+002F`,
+	} {
+		td, err := spiffeid.TrustDomainFromDNSName(name)
+		assert.EqualError(t, err, expectErr, name)
+		assert.Zero(t, td)
+	}
+}
@@ -9,7 +9,12 @@ var (
 	errNoLeadingSlash     = errors.New("path must have a leading slash")
 	errEmpty              = errors.New("cannot be empty")
 	errEmptySegment       = errors.New("path cannot contain empty segments")
+	errMissingMatcherKind = errors.New("matcher kind is missing")
 	errMissingTrustDomain = errors.New("trust domain is missing")
+	errNilRegexp          = errors.New("path regular expression cannot be nil")
+	errPartialWildcard    = errors.New("wildcards must span a whole path segment; escape literal asterisks with a backslash")
+	errPercentEncoded     = errors.New("path segment characters cannot be percent-encoded")
+	errTrailingEscape     = errors.New("escape character must be followed by the escaped character")
 	errTrailingSlash      = errors.New("path cannot have a trailing slash")
 	errWrongScheme        = errors.New("scheme is missing or invalid")
 )
@@ -1,6 +1,7 @@
 package spiffeid
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -37,7 +38,9 @@ func FromPathf(td TrustDomain, format string, args ...interface{}) (ID, error) {
 
 // FromSegments returns a new SPIFFE ID in the given trust domain with joined
 // path segments. The path segments must be valid according to the SPIFFE
-// specification and must not contain path separators.
+// specification and must not contain path separators. It is the counterpart
+// of ID.Segments, i.e., FromSegments(id.TrustDomain(), id.Segments()...)
+// returns id.
 // See https://github.com/spiffe/spiffe/blob/main/standards/SPIFFE-ID.md#22-path
 func FromSegments(td TrustDomain, segments ...string) (ID, error) {
 	path, err := JoinPathSegments(segments...)
@@ -47,13 +50,14 @@ func FromSegments(td TrustDomain, segments ...string) (ID, error) {
 	return makeID(td, path)
 }
 
-// FromString parses a SPIFFE ID from a string.
+// FromString parses a SPIFFE ID from a string. If the string is not a valid
+// SPIFFE ID, a *ParseError is returned.
 func FromString(id string) (ID, error) {
 	switch {
 	case id == "":
-		return ID{}, errEmpty
+		return ID{}, newParseError(id, 0, errEmpty)
 	case !strings.HasPrefix(id, schemePrefix):
-		return ID{}, errWrongScheme
+		return ID{}, newParseError(id, 0, errWrongScheme)
 	}
 
 	pathidx := schemePrefixLen
@@ -63,16 +67,16 @@ func FromString(id string) (ID, error) {
 			break
 		}
 		if !isValidTrustDomainChar(c) {
-			return ID{}, errBadTrustDomainChar
+			return ID{}, newParseError(id, pathidx, errBadTrustDomainChar)
 		}
 	}
 
 	if pathidx == schemePrefixLen {
-		return ID{}, errMissingTrustDomain
+		return ID{}, newParseError(id, pathidx, errMissingTrustDomain)
 	}
 
-	if err := ValidatePath(id[pathidx:]); err != nil {
-		return ID{}, err
+	if offset, err := validatePath(id[pathidx:]); err != nil {
+		return ID{}, newParseError(id, pathidx+offset, err)
 	}
 
 	return ID{
@@ -88,13 +92,39 @@ func FromStringf(format string, args ...interface{}) (ID, error) {
 
 // FromURI parses a SPIFFE ID from a URI.
 func FromURI(uri *url.URL) (ID, error) {
+	if isPlainSPIFFEURI(uri) {
+		return ID{
+			id:      schemePrefix + uri.Host + uri.Path,
+			pathidx: schemePrefixLen + len(uri.Host),
+		}, nil
+	}
 	return FromString(uri.String())
 }
 
+// isPlainSPIFFEURI returns true if the URI is a valid SPIFFE ID whose string
+// representation is exactly the scheme prefix followed by the host and path.
+// Such URIs, which are the vast majority, can be converted without
+// re-encoding the URI. Anything else, including invalid SPIFFE IDs, goes
+// through the string representation so that errors are reported against it.
+func isPlainSPIFFEURI(uri *url.URL) bool {
+	if uri.Scheme != "spiffe" || uri.Opaque != "" || uri.User != nil ||
+		uri.Host == "" || uri.RawPath != "" || uri.ForceQuery ||
+		uri.RawQuery != "" || uri.Fragment != "" {
+		return false
+	}
+	for i := 0; i < len(uri.Host); i++ {
+		if !isValidTrustDomainChar(uri.Host[i]) {
+			return false
+		}
+	}
+	_, err := validatePath(uri.Path)
+	return err == nil
+}
+
 // ID is a SPIFFE ID
 type ID struct {
 	id string
-	//subdomain string
+
 	// pathidx tracks the index to the beginning of the path inside of id. This
 	// is used when extracting the trust domain or path portions of the id.
 	pathidx int
@@ -110,10 +140,6 @@ func (id ID) TrustDomain() TrustDomain {
 
 // MemberOf returns true if the SPIFFE ID is a member of the given trust domain.
 func (id ID) MemberOf(td TrustDomain) bool {
-	if strings.Contains(id, td.String()) {
-		return true
-	}
-	//return id.TrustDomain() == td || id.SubDomain() == td
 	return id.TrustDomain() == td
 }
 
@@ -122,6 +148,35 @@ func (id ID) Path() string {
 	return id.id[id.pathidx:]
 }
 
+// Segments returns the segments of the SPIFFE ID path, e.g., ["foo", "bar"]
+// for "spiffe://example.org/foo/bar". Since SPIFFE ID paths cannot contain
+// percent-encoded characters, the segments are returned as they appear in the
+// path. If the SPIFFE ID has no path, nil is returned.
+func (id ID) Segments() []string {
+	path := id.Path()
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path[1:], "/")
+}
+
+// HasSegmentPrefix returns true if the SPIFFE ID path starts with the given
+// path segments. Segments are compared whole, so an ID with the path
+// "/foo/bar" has the prefix "foo" but not "fo". Any ID has the empty prefix.
+func (id ID) HasSegmentPrefix(segments ...string) bool {
+	path := id.Path()
+	for _, segment := range segments {
+		if strings.Contains(segment, "/") || !strings.HasPrefix(path, "/"+segment) {
+			return false
+		}
+		path = path[len(segment)+1:]
+		if path != "" && path[0] != '/' {
+			return false
+		}
+	}
+	return true
+}
+
 // String returns the string representation of the SPIFFE ID, e.g.,
 // "spiffe://example.org/foo/bar".
 func (id ID) String() string {
@@ -251,6 +306,27 @@ func (id *ID) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// MarshalJSON returns a JSON string with the text representation of the
+// ID. If the ID is the zero value, an empty string is returned.
+func (id ID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.String())
+}
+
+// UnmarshalJSON decodes a JSON string with the text representation of the
+// ID. If the string is empty or the JSON value is null, the ID
+// is set to the zero value.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	var text *string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	if text == nil {
+		*id = ID{}
+		return nil
+	}
+	return id.UnmarshalText([]byte(*text))
+}
+
 func makeID(td TrustDomain, path string) (ID, error) {
 	if td.IsZero() {
 		return ID{}, errors.New("trust domain is empty")
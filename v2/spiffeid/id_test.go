@@ -168,11 +168,23 @@ func TestFromURI(t *testing.T) {
 
 	assertOK("spiffe://trustdomain")
 	assertOK("spiffe://trustdomain/path")
+	assertOK("spiffe://trustdomain/path/element")
+
+	// The path is made absolute by the string representation of the URI.
+	id, err := spiffeid.FromURI(&url.URL{Scheme: "spiffe", Host: "trustdomain", Path: "path"})
+	assert.NoError(t, err)
+	assert.Equal(t, "spiffe://trustdomain/path", id.String())
 
 	assertFail(&url.URL{}, `cannot be empty`)
 	assertFail(&url.URL{Scheme: "SPIFFE", Host: "trustdomain"}, `scheme is missing or invalid`)
 	assertFail(parseURI("spiffe://trust$domain"), `trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`)
 	assertFail(parseURI("spiffe://trustdomain/path$"), `path segment characters are limited to letters, numbers, dots, dashes, and underscores`)
+	assertFail(parseURI("spiffe://trustdomain:8080/path"), `trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`)
+	assertFail(parseURI("spiffe://user@trustdomain/path"), `trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`)
+	assertFail(parseURI("spiffe://trustdomain/path?query"), `path segment characters are limited to letters, numbers, dots, dashes, and underscores`)
+	assertFail(parseURI("spiffe://trustdomain/path#fragment"), `path segment characters are limited to letters, numbers, dots, dashes, and underscores`)
+	assertFail(parseURI("spiffe://trustdomain/path/"), `path cannot have a trailing slash`)
+	assertFail(parseURI("spiffe://trustdomain/%41"), `path segment characters are limited to letters, numbers, dots, dashes, and underscores`)
 }
 
 func TestFromSegments(t *testing.T) {
@@ -196,6 +208,15 @@ func TestFromSegments(t *testing.T) {
 	assertFail([]string{"/"}, "path segment characters are limited to letters, numbers, dots, dashes, and underscores")
 	assertFail([]string{"/foo"}, "path segment characters are limited to letters, numbers, dots, dashes, and underscores")
 	assertFail([]string{"$"}, "path segment characters are limited to letters, numbers, dots, dashes, and underscores")
+	assertFail([]string{"foo", ".."}, "path cannot contain dot segments")
+
+	// FromSegments round-trips with Segments.
+	for _, s := range []string{"spiffe://trustdomain", "spiffe://trustdomain/foo", "spiffe://trustdomain/foo/bar"} {
+		id := spiffeid.RequireFromString(s)
+		roundTripped, err := spiffeid.FromSegments(id.TrustDomain(), id.Segments()...)
+		assert.NoError(t, err)
+		assert.Equal(t, id, roundTripped)
+	}
 }
 
 func TestFromPathf(t *testing.T) {
@@ -233,6 +254,46 @@ func TestIDMemberOf(t *testing.T) {
 	td2 := spiffeid.RequireTrustDomainFromString("domain2.test")
 	id = spiffeid.RequireFromSegments(td2, "path", "element")
 	assert.False(t, id.MemberOf(td))
+
+	// Trust domain is a substring of the ID, but not its trust domain
+	example := spiffeid.RequireTrustDomainFromString("example.org")
+	assert.False(t, spiffeid.RequireFromString("spiffe://evil.com/example.org").MemberOf(example))
+	assert.False(t, spiffeid.RequireFromString("spiffe://example.org.evil.com").MemberOf(example))
+	assert.False(t, spiffeid.RequireFromString("spiffe://org/x").MemberOf(example))
+	assert.False(t, spiffeid.RequireFromString("spiffe://example.org/x").MemberOf(spiffeid.RequireTrustDomainFromString("org")))
+
+	// Zero trust domain
+	assert.False(t, id.MemberOf(spiffeid.TrustDomain{}))
+}
+
+func TestIDSegments(t *testing.T) {
+	assert.Nil(t, spiffeid.ID{}.Segments())
+	assert.Nil(t, spiffeid.RequireFromString("spiffe://trustdomain").Segments())
+	assert.Equal(t, []string{"foo"}, spiffeid.RequireFromString("spiffe://trustdomain/foo").Segments())
+	assert.Equal(t, []string{"foo", "bar", "baz"}, spiffeid.RequireFromString("spiffe://trustdomain/foo/bar/baz").Segments())
+
+	segments := []string{"ns", "default", "sa", "frontend"}
+	id := spiffeid.RequireFromSegments(td, segments...)
+	assert.Equal(t, segments, id.Segments())
+}
+
+func TestIDHasSegmentPrefix(t *testing.T) {
+	id := spiffeid.RequireFromString("spiffe://trustdomain/foo/bar")
+	assert.True(t, id.HasSegmentPrefix())
+	assert.True(t, id.HasSegmentPrefix("foo"))
+	assert.True(t, id.HasSegmentPrefix("foo", "bar"))
+	assert.False(t, id.HasSegmentPrefix("fo"))
+	assert.False(t, id.HasSegmentPrefix("foo", "ba"))
+	assert.False(t, id.HasSegmentPrefix("foo/bar"))
+	assert.False(t, id.HasSegmentPrefix("bar"))
+	assert.False(t, id.HasSegmentPrefix("foo", "bar", "baz"))
+	assert.False(t, id.HasSegmentPrefix(""))
+
+	noPath := spiffeid.RequireFromString("spiffe://trustdomain")
+	assert.True(t, noPath.HasSegmentPrefix())
+	assert.False(t, noPath.HasSegmentPrefix("foo"))
+	assert.True(t, spiffeid.ID{}.HasSegmentPrefix())
+	assert.False(t, spiffeid.ID{}.HasSegmentPrefix("foo"))
 }
 
 func TestIDString(t *testing.T) {
@@ -459,15 +520,37 @@ func TestIDTextUnmarshaler(t *testing.T) {
 	err = json.Unmarshal([]byte(`{"id": "spiffe://trustdomain/path"}`), &s)
 	require.NoError(t, err)
 	require.Equal(t, "spiffe://trustdomain/path", s.ID.String())
+
+	err = json.Unmarshal([]byte(`{"id": null}`), &s)
+	require.NoError(t, err)
+	require.Zero(t, s.ID)
+
+	err = json.Unmarshal([]byte(`{"id": 42}`), &s)
+	require.Error(t, err)
+	require.Zero(t, s.ID)
 }
 
 func BenchmarkIDFromString(b *testing.B) {
 	s := "spiffe://trustdomain/path"
+	b.ReportAllocs()
 	for n := 0; n < b.N; n++ {
 		escapes(spiffeid.RequireFromString(s).String())
 	}
 }
 
+func BenchmarkIDFromURI(b *testing.B) {
+	u, err := url.Parse("spiffe://trustdomain/path")
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		id, err := spiffeid.FromURI(u)
+		if err != nil {
+			b.Fatal(err)
+		}
+		escapes(id.String())
+	}
+}
+
 func BenchmarkIDFromPath(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		escapes(spiffeid.RequireFromPath(td, "/path").String())
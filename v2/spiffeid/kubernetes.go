@@ -0,0 +1,41 @@
+package spiffeid
+
+import "fmt"
+
+// KubernetesIdentity is a Kubernetes service account identified by a SPIFFE
+// ID with the conventional /ns/<namespace>/sa/<service account> path.
+type KubernetesIdentity struct {
+	// Namespace is the namespace of the service account.
+	Namespace string
+
+	// ServiceAccount is the name of the service account.
+	ServiceAccount string
+}
+
+// ParseKubernetesIdentity parses the Kubernetes service account from the
+// path of the SPIFFE ID, which must be exactly of the form
+// /ns/<namespace>/sa/<service account>.
+func ParseKubernetesIdentity(id ID) (KubernetesIdentity, error) {
+	segments := id.Segments()
+	if len(segments) != 4 || segments[0] != "ns" || segments[2] != "sa" {
+		return KubernetesIdentity{}, fmt.Errorf("path of %q is not of the form /ns/<namespace>/sa/<service account>", id)
+	}
+	return KubernetesIdentity{
+		Namespace:      segments[1],
+		ServiceAccount: segments[3],
+	}, nil
+}
+
+// Path returns the /ns/<namespace>/sa/<service account> path for the service
+// account. An error is returned if the namespace or service account name is
+// not a valid path segment.
+func (k KubernetesIdentity) Path() (string, error) {
+	return new(PathBuilder).Append("ns", k.Namespace, "sa", k.ServiceAccount).Path()
+}
+
+// ID returns the SPIFFE ID of the service account in the given trust domain.
+// An error is returned if the namespace or service account name is not a
+// valid path segment.
+func (k KubernetesIdentity) ID(td TrustDomain) (ID, error) {
+	return new(PathBuilder).Append("ns", k.Namespace, "sa", k.ServiceAccount).ID(td)
+}
@@ -0,0 +1,50 @@
+package spiffeid_test
+
+import (
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKubernetesIdentity(t *testing.T) {
+	k8sID, err := spiffeid.ParseKubernetesIdentity(spiffeid.RequireFromString("spiffe://foo.test/ns/default/sa/frontend"))
+	require.NoError(t, err)
+	assert.Equal(t, spiffeid.KubernetesIdentity{Namespace: "default", ServiceAccount: "frontend"}, k8sID)
+
+	for _, id := range []spiffeid.ID{
+		{},
+		foo,
+		fooA,
+		spiffeid.RequireFromString("spiffe://foo.test/ns/default/sa"),
+		spiffeid.RequireFromString("spiffe://foo.test/ns/default/pod/frontend"),
+		spiffeid.RequireFromString("spiffe://foo.test/namespace/default/sa/frontend"),
+		spiffeid.RequireFromString("spiffe://foo.test/ns/default/sa/frontend/v1"),
+	} {
+		k8sID, err := spiffeid.ParseKubernetesIdentity(id)
+		assert.EqualError(t, err, `path of "`+id.String()+`" is not of the form /ns/<namespace>/sa/<service account>`)
+		assert.Zero(t, k8sID)
+	}
+}
+
+func TestKubernetesIdentityID(t *testing.T) {
+	k8sID := spiffeid.KubernetesIdentity{Namespace: "default", ServiceAccount: "frontend"}
+	path, err := k8sID.Path()
+	require.NoError(t, err)
+	assert.Equal(t, "/ns/default/sa/frontend", path)
+	id, err := k8sID.ID(foo.TrustDomain())
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://foo.test/ns/default/sa/frontend", id.String())
+
+	parsed, err := spiffeid.ParseKubernetesIdentity(id)
+	require.NoError(t, err)
+	assert.Equal(t, k8sID, parsed)
+
+	k8sID = spiffeid.KubernetesIdentity{Namespace: "default"}
+	_, err = k8sID.Path()
+	assert.EqualError(t, err, `invalid path segment 3 (""): path cannot contain empty segments`)
+	id, err = k8sID.ID(foo.TrustDomain())
+	assert.EqualError(t, err, `invalid path segment 3 (""): path cannot contain empty segments`)
+	assert.Zero(t, id)
+}
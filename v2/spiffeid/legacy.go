@@ -0,0 +1,114 @@
+package spiffeid
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Normalization is a change made by FromStringLegacy to turn a historically
+// tolerated form of a SPIFFE ID into its canonical form.
+type Normalization int
+
+const (
+	// NormalizedScheme means that the scheme was lowercased.
+	NormalizedScheme Normalization = iota + 1
+
+	// NormalizedTrustDomain means that the trust domain was lowercased.
+	NormalizedTrustDomain
+
+	// DecodedPath means that percent-encoded characters in the path were
+	// decoded.
+	DecodedPath
+
+	// RemovedTrailingSlash means that a trailing slash was removed.
+	RemovedTrailingSlash
+)
+
+// String returns a description of the normalization.
+func (n Normalization) String() string {
+	switch n {
+	case NormalizedScheme:
+		return "lowercased scheme"
+	case NormalizedTrustDomain:
+		return "lowercased trust domain"
+	case DecodedPath:
+		return "decoded percent-encoded path characters"
+	case RemovedTrailingSlash:
+		return "removed trailing slash"
+	default:
+		return "unknown"
+	}
+}
+
+// FromStringLegacy parses a SPIFFE ID from a string like FromString, but
+// first normalizes forms that were historically tolerated: an uppercase
+// scheme or trust domain, percent-encoded path characters and trailing
+// slashes. It returns the canonical ID along with the normalizations that
+// were needed, which are empty if the string was already canonical. It is
+// intended to ease migrations, so canonical IDs should be stored in its
+// place. The normalized string must be a valid SPIFFE ID or a *ParseError is
+// returned.
+func FromStringLegacy(s string) (ID, []Normalization, error) {
+	var normalizations []Normalization
+
+	if len(s) >= schemePrefixLen && strings.EqualFold(s[:schemePrefixLen], schemePrefix) && s[:schemePrefixLen] != schemePrefix {
+		s = schemePrefix + s[schemePrefixLen:]
+		normalizations = append(normalizations, NormalizedScheme)
+	}
+
+	if trimmed := strings.TrimRight(s, "/"); len(trimmed) > schemePrefixLen && trimmed != s {
+		s = trimmed
+		normalizations = append(normalizations, RemovedTrailingSlash)
+	}
+
+	if strings.HasPrefix(s, schemePrefix) {
+		pathidx := strings.IndexByte(s[schemePrefixLen:], '/')
+		if pathidx < 0 {
+			pathidx = len(s)
+		} else {
+			pathidx += schemePrefixLen
+		}
+
+		td := s[schemePrefixLen:pathidx]
+		if lower := strings.ToLower(td); lower != td {
+			td = lower
+			normalizations = append(normalizations, NormalizedTrustDomain)
+		}
+
+		path := s[pathidx:]
+		if strings.IndexByte(path, '%') >= 0 {
+			decoded, err := decodePath(path)
+			if err != nil {
+				return ID{}, nil, err
+			}
+			path = decoded
+			normalizations = append(normalizations, DecodedPath)
+		}
+		s = schemePrefix + td + path
+	}
+
+	id, err := FromString(s)
+	if err != nil {
+		return ID{}, nil, err
+	}
+	return id, normalizations, nil
+}
+
+// decodePath decodes the percent-encoded characters of each path segment.
+// Encoded slashes are rejected since decoding them would change the
+// segments of the path.
+func decodePath(path string) (string, error) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", fmt.Errorf("cannot decode path segment %q: %w", segment, err)
+		}
+		if strings.IndexByte(decoded, '/') >= 0 {
+			return "", fmt.Errorf("cannot decode path segment %q: decoded segment contains a slash", segment)
+		}
+		segments[i] = decoded
+	}
+	return strings.Join(segments, "/"), nil
+}
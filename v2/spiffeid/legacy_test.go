@@ -0,0 +1,63 @@
+package spiffeid_test
+
+import (
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromStringLegacy(t *testing.T) {
+	for _, tt := range []struct {
+		input          string
+		expectID       string
+		normalizations []spiffeid.Normalization
+	}{
+		{input: "spiffe://foo.test/A", expectID: "spiffe://foo.test/A"},
+		{input: "SPIFFE://foo.test/A", expectID: "spiffe://foo.test/A", normalizations: []spiffeid.Normalization{spiffeid.NormalizedScheme}},
+		{input: "spiffe://Foo.TEST/A", expectID: "spiffe://foo.test/A", normalizations: []spiffeid.Normalization{spiffeid.NormalizedTrustDomain}},
+		{input: "spiffe://foo.test/", expectID: "spiffe://foo.test", normalizations: []spiffeid.Normalization{spiffeid.RemovedTrailingSlash}},
+		{input: "spiffe://foo.test/ns/default/", expectID: "spiffe://foo.test/ns/default", normalizations: []spiffeid.Normalization{spiffeid.RemovedTrailingSlash}},
+		{input: "spiffe://foo.test/my%2Dservice/v%31", expectID: "spiffe://foo.test/my-service/v1", normalizations: []spiffeid.Normalization{spiffeid.DecodedPath}},
+		{
+			input:    "Spiffe://FOO.test/my%5Fservice//",
+			expectID: "spiffe://foo.test/my_service",
+			normalizations: []spiffeid.Normalization{
+				spiffeid.NormalizedScheme,
+				spiffeid.RemovedTrailingSlash,
+				spiffeid.NormalizedTrustDomain,
+				spiffeid.DecodedPath,
+			},
+		},
+	} {
+		id, normalizations, err := spiffeid.FromStringLegacy(tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.expectID, id.String(), tt.input)
+		assert.Equal(t, tt.normalizations, normalizations, tt.input)
+	}
+}
+
+func TestFromStringLegacyErrors(t *testing.T) {
+	for input, expectErr := range map[string]string{
+		"":                           "cannot be empty",
+		"spiffe://":                  "trust domain is missing",
+		"spiffe:///":                 "trust domain is missing",
+		"http://foo.test/A":          "scheme is missing or invalid",
+		"spiffe://foo.test/a%2Fb":    `cannot decode path segment "a%2Fb": decoded segment contains a slash`,
+		"spiffe://foo.test/a%zz":     `cannot decode path segment "a%zz": invalid URL escape "%zz"`,
+		"spiffe://foo.test/a%20b":    "path segment characters are limited to letters, numbers, dots, dashes, and underscores",
+		"spiffe://foo.test/a//b":     "path cannot contain empty segments",
+		"spiffe://foo.test/a/%2E%2E": "path cannot contain dot segments",
+	} {
+		id, normalizations, err := spiffeid.FromStringLegacy(input)
+		assert.EqualError(t, err, expectErr, input)
+		assert.Zero(t, id)
+		assert.Nil(t, normalizations)
+	}
+}
+
+func TestNormalizationString(t *testing.T) {
+	assert.Equal(t, "decoded percent-encoded path characters", spiffeid.DecodedPath.String())
+	assert.Equal(t, "unknown", spiffeid.Normalization(0).String())
+}
@@ -1,6 +1,11 @@
 package spiffeid
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 // Matcher is used to match a SPIFFE ID.
 type Matcher func(ID) error
@@ -24,29 +29,151 @@ func MatchID(expected ID) Matcher {
 
 // MatchOneOf matches any SPIFFE ID in the given list of IDs.
 func MatchOneOf(expected ...ID) Matcher {
-	set := make(map[ID]struct{})
-	for _, id := range expected {
-		set[id] = struct{}{}
-	}
+	return MatchSet(NewSet(expected...))
+}
+
+// MatchSet matches any SPIFFE ID in the given set.
+func MatchSet(expected Set) Matcher {
 	return Matcher(func(actual ID) error {
-		if _, ok := set[actual]; !ok {
+		if !expected.Contains(actual) {
 			return fmt.Errorf("unexpected ID %q", actual)
 		}
 		return nil
 	})
 }
 
+// MatchMemberOfSet matches any SPIFFE ID in one of the trust domains in the
+// given set.
+func MatchMemberOfSet(expected TrustDomainSet) Matcher {
+	return Matcher(func(actual ID) error {
+		if !expected.Contains(actual.TrustDomain()) {
+			return fmt.Errorf("unexpected trust domain %q", actual.TrustDomain())
+		}
+		return nil
+	})
+}
+
 // MatchMemberOf matches any SPIFFE ID in the given trust domain.
-//func MatchMemberOf(expected TrustDomain, expectedSub SubDomain) Matcher {
 func MatchMemberOf(expected TrustDomain) Matcher {
 	return Matcher(func(actual ID) error {
-		//if !actual.MemberOf(expected)  {
-		if !expected.ID().MemberOf(actual.TrustDomain()) {
+		if actual.TrustDomain() != expected {
+			return fmt.Errorf("unexpected trust domain %q", actual.TrustDomain())
+		}
+		return nil
+	})
+}
+
+// MatchMemberOfSubtree matches any SPIFFE ID in the given trust domain with
+// the given path, or with a path below it. Path segments are compared whole,
+// so "/team-a" matches "/team-a" and "/team-a/db", but not "/team-ab". A
+// trailing slash in the path is ignored, and an empty path or "/" matches the
+// whole trust domain. An error is returned if the path is invalid.
+func MatchMemberOfSubtree(td TrustDomain, path string) (Matcher, error) {
+	path = strings.TrimSuffix(path, "/")
+	if err := ValidatePath(path); err != nil {
+		return nil, err
+	}
+	return Matcher(func(actual ID) error {
+		if actual.TrustDomain() != td {
 			return fmt.Errorf("unexpected trust domain %q", actual.TrustDomain())
-		} 
-		//else if !actual.MemberOf(expectedSub) {
-		//	return fmt.Errorf("unexpected trust domain %q", actual.SubDomain())
-		//}
+		}
+		actualPath := actual.Path()
+		if actualPath != path && !strings.HasPrefix(actualPath, path+"/") {
+			return fmt.Errorf("unexpected ID %q", actual)
+		}
+		return nil
+	}), nil
+}
+
+// MatchAll matches a SPIFFE ID if all of the given matchers match it. The
+// error of the first matcher that does not match is returned. If no matchers
+// are given, any SPIFFE ID is matched.
+func MatchAll(matchers ...Matcher) Matcher {
+	return Matcher(func(actual ID) error {
+		for _, matcher := range matchers {
+			if err := matcher(actual); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 }
+
+// MatchAnyOf matches a SPIFFE ID if any of the given matchers matches it. If
+// none does, the errors of all the matchers are returned. If no matchers are
+// given, no SPIFFE ID is matched.
+func MatchAnyOf(matchers ...Matcher) Matcher {
+	return Matcher(func(actual ID) error {
+		if len(matchers) == 0 {
+			return fmt.Errorf("unexpected ID %q", actual)
+		}
+		errs := make([]string, 0, len(matchers))
+		for _, matcher := range matchers {
+			err := matcher(actual)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return errors.New(strings.Join(errs, "; "))
+	})
+}
+
+// MatchNot matches a SPIFFE ID if the given matcher does not match it.
+func MatchNot(matcher Matcher) Matcher {
+	return Matcher(func(actual ID) error {
+		if err := matcher(actual); err == nil {
+			return fmt.Errorf("unexpected ID %q", actual)
+		}
+		return nil
+	})
+}
+
+// MatchPathPattern matches any SPIFFE ID in the given trust domain with a
+// path matching the pattern. Each segment of the pattern is either a literal
+// segment, "*", matching exactly one segment, or "**", matching zero or more
+// segments. For example, "/ns/*/sa/*" matches "/ns/default/sa/frontend" and
+// "/ns/**" matches "/ns" and any path below it. Wildcards must span a whole
+// segment. A backslash escapes the next character of a literal segment,
+// which is needed to match literal asterisks (e.g. "\*"), since asterisks
+// are valid path characters when the spiffeid_charset_backcompat build tag
+// is used. An error is returned if the pattern is invalid.
+func MatchPathPattern(td TrustDomain, pattern string) (Matcher, error) {
+	parsed, err := parsePathPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return Matcher(func(actual ID) error {
+		if actual.TrustDomain() != td {
+			return fmt.Errorf("unexpected trust domain %q", actual.TrustDomain())
+		}
+		if !parsed.matches(actual.Path()) {
+			return fmt.Errorf("unexpected ID %q", actual)
+		}
+		return nil
+	}), nil
+}
+
+// MatchPathRegex matches any SPIFFE ID in the given trust domain with a path
+// matching the regular expression. The regular expression must match the
+// whole path, as if it was anchored with ^ and $, so that e.g. "/v[0-9]+"
+// does not match "/v1/admin". An error is returned if the regular expression
+// is nil.
+func MatchPathRegex(td TrustDomain, re *regexp.Regexp) (Matcher, error) {
+	if re == nil {
+		return nil, errNilRegexp
+	}
+	anchored, err := regexp.Compile(`^(?:` + re.String() + `)$`)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path regular expression: %w", err)
+	}
+	return Matcher(func(actual ID) error {
+		if actual.TrustDomain() != td {
+			return fmt.Errorf("unexpected trust domain %q", actual.TrustDomain())
+		}
+		if !anchored.MatchString(actual.Path()) {
+			return fmt.Errorf("unexpected ID %q", actual)
+		}
+		return nil
+	}), nil
+}
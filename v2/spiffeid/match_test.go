@@ -1,10 +1,13 @@
 package spiffeid_test
 
 import (
+	"fmt"
+	"regexp"
 	"testing"
 
 	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -93,6 +96,133 @@ func TestMatchMemberOf_AgainstEmptyTrustDomain(t *testing.T) {
 	)
 }
 
+func TestMatchMemberOf_RejectsPartialTrustDomains(t *testing.T) {
+	matcher := spiffeid.MatchMemberOf(spiffeid.RequireTrustDomainFromString("example.org"))
+	for _, id := range []string{
+		"spiffe://org/x",
+		"spiffe://e/x",
+		"spiffe://evil.com/example.org",
+		"spiffe://example.org.evil.com/x",
+		"spiffe://sub.example.org/x",
+	} {
+		assert.Error(t, matcher(spiffeid.RequireFromString(id)), id)
+	}
+	assert.NoError(t, matcher(spiffeid.RequireFromString("spiffe://example.org/x")))
+}
+
+func TestMatchSet(t *testing.T) {
+	testMatch(t, spiffeid.MatchSet(spiffeid.NewSet(fooA, barA)),
+		`unexpected ID ""`,
+		`unexpected ID "spiffe://foo.test"`,
+		``,
+		`unexpected ID "spiffe://foo.test/B"`,
+		`unexpected ID "spiffe://foo.test/sub/C"`,
+		``,
+	)
+}
+
+func TestMatchMemberOfSet(t *testing.T) {
+	testMatch(t, spiffeid.MatchMemberOfSet(spiffeid.NewTrustDomainSet(barA.TrustDomain())),
+		`unexpected trust domain ""`,
+		`unexpected trust domain "foo.test"`,
+		`unexpected trust domain "foo.test"`,
+		`unexpected trust domain "foo.test"`,
+		`unexpected trust domain "foo.test"`,
+		``,
+	)
+}
+
+func TestMatchMemberOfSubtree(t *testing.T) {
+	testMatch(t, spiffeid.RequireMatchMemberOfSubtree(foo.TrustDomain(), "/sub/"),
+		`unexpected trust domain ""`,
+		`unexpected ID "spiffe://foo.test"`,
+		`unexpected ID "spiffe://foo.test/A"`,
+		`unexpected ID "spiffe://foo.test/B"`,
+		``,
+		`unexpected trust domain "bar.test"`,
+	)
+
+	td := foo.TrustDomain()
+	matcher, err := spiffeid.MatchMemberOfSubtree(td, "/team-a")
+	require.NoError(t, err)
+	assert.NoError(t, matcher(spiffeid.RequireFromPath(td, "/team-a")))
+	assert.NoError(t, matcher(spiffeid.RequireFromPath(td, "/team-a/db/primary")))
+	assert.EqualError(t, matcher(spiffeid.RequireFromPath(td, "/team-ab")), `unexpected ID "spiffe://foo.test/team-ab"`)
+
+	for _, path := range []string{"", "/"} {
+		matcher, err := spiffeid.MatchMemberOfSubtree(td, path)
+		require.NoError(t, err)
+		assert.NoError(t, matcher(foo))
+		assert.NoError(t, matcher(fooC))
+		assert.EqualError(t, matcher(barA), `unexpected trust domain "bar.test"`)
+	}
+
+	for path, expectErr := range map[string]string{
+		"team-a":     "path must have a leading slash",
+		"/team-a//b": "path cannot contain empty segments",
+		"/../a":      "path cannot contain dot segments",
+	} {
+		matcher, err := spiffeid.MatchMemberOfSubtree(td, path)
+		assert.EqualError(t, err, expectErr, path)
+		assert.Nil(t, matcher)
+	}
+}
+
+func TestMatchAll(t *testing.T) {
+	testMatch(t, spiffeid.MatchAll(spiffeid.MatchMemberOf(foo.TrustDomain()), spiffeid.MatchNot(spiffeid.MatchID(fooB))),
+		`unexpected trust domain ""`,
+		``,
+		``,
+		`unexpected ID "spiffe://foo.test/B"`,
+		``,
+		`unexpected trust domain "bar.test"`,
+	)
+}
+
+func TestMatchAll_OnAnEmptyListOfMatchers(t *testing.T) {
+	testMatch(t, spiffeid.MatchAll(),
+		``,
+		``,
+		``,
+		``,
+		``,
+		``,
+	)
+}
+
+func TestMatchAnyOf(t *testing.T) {
+	testMatch(t, spiffeid.MatchAnyOf(spiffeid.MatchID(fooA), spiffeid.MatchMemberOf(barA.TrustDomain())),
+		`unexpected ID ""; unexpected trust domain ""`,
+		`unexpected ID "spiffe://foo.test"; unexpected trust domain "foo.test"`,
+		``,
+		`unexpected ID "spiffe://foo.test/B"; unexpected trust domain "foo.test"`,
+		`unexpected ID "spiffe://foo.test/sub/C"; unexpected trust domain "foo.test"`,
+		``,
+	)
+}
+
+func TestMatchAnyOf_OnAnEmptyListOfMatchers(t *testing.T) {
+	testMatch(t, spiffeid.MatchAnyOf(),
+		`unexpected ID ""`,
+		`unexpected ID "spiffe://foo.test"`,
+		`unexpected ID "spiffe://foo.test/A"`,
+		`unexpected ID "spiffe://foo.test/B"`,
+		`unexpected ID "spiffe://foo.test/sub/C"`,
+		`unexpected ID "spiffe://bar.test/A"`,
+	)
+}
+
+func TestMatchNot(t *testing.T) {
+	testMatch(t, spiffeid.MatchNot(spiffeid.MatchOneOf(fooA, barA)),
+		``,
+		``,
+		`unexpected ID "spiffe://foo.test/A"`,
+		``,
+		``,
+		`unexpected ID "spiffe://bar.test/A"`,
+	)
+}
+
 func testMatch(t *testing.T, matcher spiffeid.Matcher, zeroErr, fooErr, fooAErr, fooBErr, fooCErr, barAErr string) {
 	test := func(id spiffeid.ID, expectErr string, msgAndArgs ...interface{}) {
 		err := matcher(id)
@@ -110,3 +240,86 @@ func testMatch(t *testing.T, matcher spiffeid.Matcher, zeroErr, fooErr, fooAErr,
 	test(fooC, fooCErr, "unexpected result for fooC ID")
 	test(barA, barAErr, "unexpected result for fooD ID")
 }
+
+func TestMatchPathPattern(t *testing.T) {
+	td := foo.TrustDomain()
+	for _, tt := range []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{pattern: "", matches: []string{""}, misses: []string{"/A"}},
+		{pattern: "/A", matches: []string{"/A"}, misses: []string{"", "/B", "/A/B"}},
+		{pattern: "/*", matches: []string{"/A", "/B"}, misses: []string{"", "/sub/C"}},
+		{pattern: "/ns/*/sa/*", matches: []string{"/ns/default/sa/frontend"}, misses: []string{"/ns/default/sa", "/ns/default/sa/frontend/v1", "/ns/default/pod/frontend"}},
+		{pattern: "/**", matches: []string{"", "/A", "/sub/C"}},
+		{pattern: "/sub/**", matches: []string{"/sub", "/sub/C", "/sub/C/D"}, misses: []string{"", "/A"}},
+		{pattern: "/**/C", matches: []string{"/C", "/sub/C", "/sub/sub/C"}, misses: []string{"/sub", "/sub/C/D"}},
+		{pattern: "/*/**/C", matches: []string{"/sub/C", "/sub/sub/C"}, misses: []string{"/C"}},
+		{pattern: `/\A`, matches: []string{"/A"}},
+	} {
+		matcher, err := spiffeid.MatchPathPattern(td, tt.pattern)
+		require.NoError(t, err, tt.pattern)
+		for _, path := range tt.matches {
+			assert.NoError(t, matcher(spiffeid.RequireFromPath(td, path)), "pattern %q should match %q", tt.pattern, path)
+		}
+		for _, path := range tt.misses {
+			assert.EqualError(t, matcher(spiffeid.RequireFromPath(td, path)), fmt.Sprintf("unexpected ID %q", "spiffe://foo.test"+path), "pattern %q should not match %q", tt.pattern, path)
+		}
+	}
+
+	testMatch(t, spiffeid.RequireMatchPathPattern(td, "/*"),
+		`unexpected trust domain ""`,
+		`unexpected ID "spiffe://foo.test"`,
+		``,
+		``,
+		`unexpected ID "spiffe://foo.test/sub/C"`,
+		`unexpected trust domain "bar.test"`,
+	)
+}
+
+func TestMatchPathPattern_Invalid(t *testing.T) {
+	for pattern, expectErr := range map[string]string{
+		"A":     "path must have a leading slash",
+		"/":     `invalid path pattern segment "": path cannot contain empty segments`,
+		"/A//B": `invalid path pattern segment "": path cannot contain empty segments`,
+		"/A/":   `invalid path pattern segment "": path cannot contain empty segments`,
+		"/..":   `invalid path pattern segment "..": path cannot contain dot segments`,
+		"/A*":   `invalid path pattern segment "A*": wildcards must span a whole path segment; escape literal asterisks with a backslash`,
+		"/***":  `invalid path pattern segment "***": wildcards must span a whole path segment; escape literal asterisks with a backslash`,
+		`/A\`:   `invalid path pattern segment "A\\": escape character must be followed by the escaped character`,
+		"/A/%":  `invalid path pattern segment "%": path segment characters are limited to letters, numbers, dots, dashes, and underscores`,
+	} {
+		matcher, err := spiffeid.MatchPathPattern(foo.TrustDomain(), pattern)
+		assert.EqualError(t, err, expectErr, pattern)
+		assert.Nil(t, matcher)
+	}
+}
+
+func TestMatchPathRegex(t *testing.T) {
+	matcher, err := spiffeid.MatchPathRegex(foo.TrustDomain(), regexp.MustCompile(`/[AB]`))
+	require.NoError(t, err)
+	testMatch(t, matcher,
+		`unexpected trust domain ""`,
+		`unexpected ID "spiffe://foo.test"`,
+		``,
+		``,
+		`unexpected ID "spiffe://foo.test/sub/C"`,
+		`unexpected trust domain "bar.test"`,
+	)
+
+	// The regular expression must match the whole path.
+	td := foo.TrustDomain()
+	matcher, err = spiffeid.MatchPathRegex(td, regexp.MustCompile(`/v[0-9]+|/shard-[0-9]+/v[0-9]+`))
+	require.NoError(t, err)
+	assert.NoError(t, matcher(spiffeid.RequireFromPath(td, "/v1")))
+	assert.NoError(t, matcher(spiffeid.RequireFromPath(td, "/shard-2/v10")))
+	assert.EqualError(t, matcher(spiffeid.RequireFromPath(td, "/v1/admin")), `unexpected ID "spiffe://foo.test/v1/admin"`)
+	assert.EqualError(t, matcher(spiffeid.RequireFromPath(td, "/admin/v1")), `unexpected ID "spiffe://foo.test/admin/v1"`)
+}
+
+func TestMatchPathRegex_Nil(t *testing.T) {
+	matcher, err := spiffeid.MatchPathRegex(foo.TrustDomain(), nil)
+	assert.EqualError(t, err, "path regular expression cannot be nil")
+	assert.Nil(t, matcher)
+}
@@ -0,0 +1,188 @@
+package spiffeid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// MatcherKind is the kind of matcher described by a MatcherSpec.
+type MatcherKind string
+
+const (
+	// MatcherKindAny matches any SPIFFE ID. See MatchAny.
+	MatcherKindAny MatcherKind = "any"
+
+	// MatcherKindID matches the SPIFFE ID in IDs, which must have exactly
+	// one element. See MatchID.
+	MatcherKindID MatcherKind = "id"
+
+	// MatcherKindOneOf matches any SPIFFE ID in IDs. See MatchOneOf.
+	MatcherKindOneOf MatcherKind = "one_of"
+
+	// MatcherKindMemberOf matches any SPIFFE ID in the trust domains in
+	// TrustDomains. See MatchMemberOfSet.
+	MatcherKindMemberOf MatcherKind = "member_of"
+
+	// MatcherKindSubtree matches any SPIFFE ID in the trust domain in
+	// TrustDomains, which must have exactly one element, with Path or a path
+	// below it. See MatchMemberOfSubtree.
+	MatcherKindSubtree MatcherKind = "subtree"
+
+	// MatcherKindPathPattern matches any SPIFFE ID in the trust domain in
+	// TrustDomains, which must have exactly one element, with a path
+	// matching Path as a pattern. See MatchPathPattern.
+	MatcherKindPathPattern MatcherKind = "path_pattern"
+
+	// MatcherKindPathRegex matches any SPIFFE ID in the trust domain in
+	// TrustDomains, which must have exactly one element, with a path
+	// matching Path as a regular expression. See MatchPathRegex.
+	MatcherKindPathRegex MatcherKind = "path_regex"
+
+	// MatcherKindAll matches a SPIFFE ID matched by all the Matchers. See
+	// MatchAll.
+	MatcherKindAll MatcherKind = "all"
+
+	// MatcherKindAnyOf matches a SPIFFE ID matched by any of the Matchers.
+	// See MatchAnyOf.
+	MatcherKindAnyOf MatcherKind = "any_of"
+
+	// MatcherKindNot matches a SPIFFE ID not matched by the Matchers, which
+	// must have exactly one element. See MatchNot.
+	MatcherKindNot MatcherKind = "not"
+)
+
+// MatcherSpec is a serializable specification of a Matcher, so that
+// authorization policies can be loaded from configuration files. The fields
+// used depend on the kind of matcher. For example, in JSON:
+//
+//	{
+//	  "kind": "any_of",
+//	  "matchers": [
+//	    {"kind": "id", "ids": ["spiffe://example.org/admin"]},
+//	    {"kind": "path_pattern", "trust_domains": ["example.org"], "path": "/ns/*/sa/frontend"}
+//	  ]
+//	}
+//
+// Specs are validated when unmarshaled from JSON or YAML, so that invalid
+// policies are rejected when loaded.
+type MatcherSpec struct {
+	// Kind is the kind of matcher.
+	Kind MatcherKind `json:"kind" yaml:"kind"`
+
+	// IDs are the SPIFFE IDs used by the id and one_of matchers.
+	IDs []ID `json:"ids,omitempty" yaml:"ids,omitempty"`
+
+	// TrustDomains are the trust domains used by the member_of, subtree,
+	// path_pattern and path_regex matchers.
+	TrustDomains []TrustDomain `json:"trust_domains,omitempty" yaml:"trust_domains,omitempty"`
+
+	// Path is the path, path pattern or path regular expression used by the
+	// subtree, path_pattern and path_regex matchers.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// Matchers are the nested matchers used by the all, any_of and not
+	// matchers.
+	Matchers []MatcherSpec `json:"matchers,omitempty" yaml:"matchers,omitempty"`
+}
+
+// Matcher returns the Matcher described by the spec, or an error if the spec
+// is invalid.
+func (s MatcherSpec) Matcher() (Matcher, error) {
+	switch s.Kind {
+	case MatcherKindAny:
+		return MatchAny(), nil
+	case MatcherKindID:
+		if len(s.IDs) != 1 {
+			return nil, fmt.Errorf("%s matcher requires exactly one ID", s.Kind)
+		}
+		return MatchID(s.IDs[0]), nil
+	case MatcherKindOneOf:
+		return MatchOneOf(s.IDs...), nil
+	case MatcherKindMemberOf:
+		return MatchMemberOfSet(NewTrustDomainSet(s.TrustDomains...)), nil
+	case MatcherKindSubtree, MatcherKindPathPattern, MatcherKindPathRegex:
+		if len(s.TrustDomains) != 1 {
+			return nil, fmt.Errorf("%s matcher requires exactly one trust domain", s.Kind)
+		}
+		return s.pathMatcher(s.TrustDomains[0])
+	case MatcherKindAll, MatcherKindAnyOf, MatcherKindNot:
+		matchers := make([]Matcher, 0, len(s.Matchers))
+		for i, spec := range s.Matchers {
+			matcher, err := spec.Matcher()
+			if err != nil {
+				return nil, fmt.Errorf("%s matcher %d: %w", s.Kind, i, err)
+			}
+			matchers = append(matchers, matcher)
+		}
+		switch s.Kind {
+		case MatcherKindAll:
+			return MatchAll(matchers...), nil
+		case MatcherKindAnyOf:
+			return MatchAnyOf(matchers...), nil
+		}
+		if len(matchers) != 1 {
+			return nil, fmt.Errorf("%s matcher requires exactly one nested matcher", s.Kind)
+		}
+		return MatchNot(matchers[0]), nil
+	case "":
+		return nil, errMissingMatcherKind
+	default:
+		return nil, fmt.Errorf("unknown matcher kind %q", s.Kind)
+	}
+}
+
+func (s MatcherSpec) pathMatcher(td TrustDomain) (Matcher, error) {
+	var matcher Matcher
+	var err error
+	switch s.Kind {
+	case MatcherKindSubtree:
+		matcher, err = MatchMemberOfSubtree(td, s.Path)
+	case MatcherKindPathPattern:
+		matcher, err = MatchPathPattern(td, s.Path)
+	default:
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(s.Path); err == nil {
+			matcher, err = MatchPathRegex(td, re)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s matcher: %w", s.Kind, err)
+	}
+	return matcher, nil
+}
+
+// UnmarshalJSON decodes and validates a JSON matcher spec. Unknown fields are
+// rejected, so that mistyped policies are not silently ignored.
+func (s *MatcherSpec) UnmarshalJSON(data []byte) error {
+	// The alias type does not have the UnmarshalJSON method, preventing an
+	// infinite recursion.
+	type matcherSpec MatcherSpec
+	var spec matcherSpec
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		return err
+	}
+	return s.validate(MatcherSpec(spec))
+}
+
+// UnmarshalYAML decodes and validates a YAML matcher spec. It is compatible
+// with the gopkg.in/yaml.v2 and gopkg.in/yaml.v3 packages.
+func (s *MatcherSpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type matcherSpec MatcherSpec
+	var spec matcherSpec
+	if err := unmarshal(&spec); err != nil {
+		return err
+	}
+	return s.validate(MatcherSpec(spec))
+}
+
+func (s *MatcherSpec) validate(spec MatcherSpec) error {
+	if _, err := spec.Matcher(); err != nil {
+		return fmt.Errorf("invalid matcher spec: %w", err)
+	}
+	*s = spec
+	return nil
+}
@@ -0,0 +1,89 @@
+package spiffeid_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMatcherSpecJSON(t *testing.T) {
+	var spec spiffeid.MatcherSpec
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"kind": "any_of",
+		"matchers": [
+			{"kind": "id", "ids": ["spiffe://foo.test/A"]},
+			{"kind": "all", "matchers": [
+				{"kind": "member_of", "trust_domains": ["bar.test"]},
+				{"kind": "not", "matchers": [{"kind": "one_of", "ids": ["spiffe://bar.test/B"]}]}
+			]}
+		]
+	}`), &spec))
+
+	matcher, err := spec.Matcher()
+	require.NoError(t, err)
+	testMatch(t, matcher,
+		`unexpected ID ""; unexpected trust domain ""`,
+		`unexpected ID "spiffe://foo.test"; unexpected trust domain "foo.test"`,
+		``,
+		`unexpected ID "spiffe://foo.test/B"; unexpected trust domain "foo.test"`,
+		`unexpected ID "spiffe://foo.test/sub/C"; unexpected trust domain "foo.test"`,
+		``,
+	)
+}
+
+func TestMatcherSpecYAML(t *testing.T) {
+	var spec spiffeid.MatcherSpec
+	require.NoError(t, yaml.Unmarshal([]byte(`
+kind: any_of
+matchers:
+- kind: subtree
+  trust_domains: [foo.test]
+  path: /sub
+- kind: path_regex
+  trust_domains: [bar.test]
+  path: /[A-Z]
+`), &spec))
+
+	matcher, err := spec.Matcher()
+	require.NoError(t, err)
+	testMatch(t, matcher,
+		`unexpected trust domain ""; unexpected trust domain ""`,
+		`unexpected ID "spiffe://foo.test"; unexpected trust domain "foo.test"`,
+		`unexpected ID "spiffe://foo.test/A"; unexpected trust domain "foo.test"`,
+		`unexpected ID "spiffe://foo.test/B"; unexpected trust domain "foo.test"`,
+		``,
+		``,
+	)
+
+	err = yaml.Unmarshal([]byte("kind: path_pattern\ntrust_domains: [foo.test]\npath: /A*\n"), &spec)
+	assert.EqualError(t, err, `invalid matcher spec: path_pattern matcher: invalid path pattern segment "A*": wildcards must span a whole path segment; escape literal asterisks with a backslash`)
+}
+
+func TestMatcherSpecInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		spec      string
+		expectErr string
+	}{
+		{spec: `{}`, expectErr: "invalid matcher spec: matcher kind is missing"},
+		{spec: `{"kind": "unknown"}`, expectErr: `invalid matcher spec: unknown matcher kind "unknown"`},
+		{spec: `{"kind": "any", "typo": true}`, expectErr: `json: unknown field "typo"`},
+		{spec: `{"kind": "id"}`, expectErr: "invalid matcher spec: id matcher requires exactly one ID"},
+		{spec: `{"kind": "id", "ids": ["foo.test"]}`, expectErr: "scheme is missing or invalid"},
+		{spec: `{"kind": "subtree", "path": "/A"}`, expectErr: "invalid matcher spec: subtree matcher requires exactly one trust domain"},
+		{spec: `{"kind": "subtree", "trust_domains": ["foo.test"], "path": "A"}`, expectErr: "invalid matcher spec: subtree matcher: path must have a leading slash"},
+		{spec: `{"kind": "path_regex", "trust_domains": ["foo.test"], "path": "("}`, expectErr: "invalid matcher spec: path_regex matcher: error parsing regexp: missing closing ): `(`"},
+		{spec: `{"kind": "not", "matchers": [{"kind": "any"}, {"kind": "any"}]}`, expectErr: "invalid matcher spec: not matcher requires exactly one nested matcher"},
+		{spec: `{"kind": "all", "matchers": [{"kind": "any"}, {"kind": "bad"}]}`, expectErr: `invalid matcher spec: unknown matcher kind "bad"`},
+	} {
+		var spec spiffeid.MatcherSpec
+		err := json.Unmarshal([]byte(tt.spec), &spec)
+		assert.EqualError(t, err, tt.expectErr, tt.spec)
+	}
+
+	_, err := spiffeid.MatcherSpec{Kind: spiffeid.MatcherKindAll, Matchers: []spiffeid.MatcherSpec{{Kind: "bad"}}}.Matcher()
+	assert.EqualError(t, err, `all matcher 0: unknown matcher kind "bad"`)
+}
@@ -0,0 +1,125 @@
+package spiffeid
+
+// ParseErrorKind classifies why a SPIFFE ID or trust domain could not be
+// parsed.
+type ParseErrorKind int
+
+const (
+	// ParseErrorEmpty means that the input is empty.
+	ParseErrorEmpty ParseErrorKind = iota + 1
+
+	// ParseErrorWrongScheme means that the input does not start with the
+	// spiffe:// scheme.
+	ParseErrorWrongScheme
+
+	// ParseErrorMissingTrustDomain means that the trust domain is empty.
+	ParseErrorMissingTrustDomain
+
+	// ParseErrorBadTrustDomainChar means that the trust domain contains a
+	// character that is not allowed.
+	ParseErrorBadTrustDomainChar
+
+	// ParseErrorBadPathSegmentChar means that the path contains a character
+	// that is not allowed.
+	ParseErrorBadPathSegmentChar
+
+	// ParseErrorEmptySegment means that the path contains an empty segment,
+	// i.e. two consecutive slashes.
+	ParseErrorEmptySegment
+
+	// ParseErrorDotSegment means that the path contains a . or .. segment.
+	ParseErrorDotSegment
+
+	// ParseErrorTrailingSlash means that the path ends with a slash.
+	ParseErrorTrailingSlash
+)
+
+// String returns the name of the kind.
+func (k ParseErrorKind) String() string {
+	switch k {
+	case ParseErrorEmpty:
+		return "empty"
+	case ParseErrorWrongScheme:
+		return "wrong scheme"
+	case ParseErrorMissingTrustDomain:
+		return "missing trust domain"
+	case ParseErrorBadTrustDomainChar:
+		return "bad trust domain character"
+	case ParseErrorBadPathSegmentChar:
+		return "bad path segment character"
+	case ParseErrorEmptySegment:
+		return "empty segment"
+	case ParseErrorDotSegment:
+		return "dot segment"
+	case ParseErrorTrailingSlash:
+		return "trailing slash"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseError is returned by FromString and TrustDomainFromString, and the
+// functions built on them, when the input cannot be parsed. It tells where
+// the input is invalid so that users can be given actionable feedback. Its
+// message is the one of the underlying error, so it does not include the
+// input, which may be sensitive.
+type ParseError struct {
+	// Kind is the reason why the input is invalid.
+	Kind ParseErrorKind
+
+	// Input is the input that could not be parsed.
+	Input string
+
+	// Offset is the byte offset in Input where the input is invalid, e.g.
+	// the offset of the invalid character or of the start of the invalid
+	// path segment.
+	Offset int
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *ParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Char returns the invalid character for the ParseErrorBadTrustDomainChar and
+// ParseErrorBadPathSegmentChar kinds.
+func (e *ParseError) Char() (byte, bool) {
+	switch e.Kind {
+	case ParseErrorBadTrustDomainChar, ParseErrorBadPathSegmentChar:
+		if e.Offset < len(e.Input) {
+			return e.Input[e.Offset], true
+		}
+	}
+	return 0, false
+}
+
+func newParseError(input string, offset int, err error) *ParseError {
+	var kind ParseErrorKind
+	switch err {
+	case errEmpty:
+		kind = ParseErrorEmpty
+	case errWrongScheme:
+		kind = ParseErrorWrongScheme
+	case errMissingTrustDomain:
+		kind = ParseErrorMissingTrustDomain
+	case errBadTrustDomainChar:
+		kind = ParseErrorBadTrustDomainChar
+	case errBadPathSegmentChar:
+		kind = ParseErrorBadPathSegmentChar
+	case errEmptySegment:
+		kind = ParseErrorEmptySegment
+	case errDotSegment:
+		kind = ParseErrorDotSegment
+	case errTrailingSlash:
+		kind = ParseErrorTrailingSlash
+	}
+	return &ParseError{Kind: kind, Input: input, Offset: offset, Err: err}
+}
@@ -0,0 +1,60 @@
+package spiffeid_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromStringParseError(t *testing.T) {
+	for _, tt := range []struct {
+		input  string
+		kind   spiffeid.ParseErrorKind
+		offset int
+		char   byte
+	}{
+		{input: "", kind: spiffeid.ParseErrorEmpty},
+		{input: "http://foo.test", kind: spiffeid.ParseErrorWrongScheme},
+		{input: "spiffe://", kind: spiffeid.ParseErrorMissingTrustDomain, offset: 9},
+		{input: "spiffe:///path", kind: spiffeid.ParseErrorMissingTrustDomain, offset: 9},
+		{input: "spiffe://Foo.test", kind: spiffeid.ParseErrorBadTrustDomainChar, offset: 9, char: 'F'},
+		{input: "spiffe://foo.test/a/b%c", kind: spiffeid.ParseErrorBadPathSegmentChar, offset: 21, char: '%'},
+		{input: "spiffe://foo.test/a//b", kind: spiffeid.ParseErrorEmptySegment, offset: 20},
+		{input: "spiffe://foo.test/a/../b", kind: spiffeid.ParseErrorDotSegment, offset: 20},
+		{input: "spiffe://foo.test/a/.", kind: spiffeid.ParseErrorDotSegment, offset: 20},
+		{input: "spiffe://foo.test/a/", kind: spiffeid.ParseErrorTrailingSlash, offset: 19},
+	} {
+		_, err := spiffeid.FromString(tt.input)
+		var parseErr *spiffeid.ParseError
+		require.True(t, errors.As(err, &parseErr), tt.input)
+		assert.Equal(t, tt.kind, parseErr.Kind, tt.input)
+		assert.Equal(t, tt.input, parseErr.Input)
+		assert.Equal(t, tt.offset, parseErr.Offset, tt.input)
+		char, ok := parseErr.Char()
+		assert.Equal(t, tt.char != 0, ok, tt.input)
+		assert.Equal(t, tt.char, char, tt.input)
+		assert.Equal(t, parseErr.Err.Error(), err.Error())
+	}
+}
+
+func TestTrustDomainFromStringParseError(t *testing.T) {
+	_, err := spiffeid.TrustDomainFromString("foo.te%t")
+	var parseErr *spiffeid.ParseError
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, spiffeid.ParseErrorBadTrustDomainChar, parseErr.Kind)
+	assert.Equal(t, 6, parseErr.Offset)
+	assert.EqualError(t, err, "trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores")
+
+	_, err = spiffeid.TrustDomainFromString("")
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, spiffeid.ParseErrorMissingTrustDomain, parseErr.Kind)
+	assert.Equal(t, "missing trust domain", parseErr.Kind.String())
+
+	_, err = spiffeid.TrustDomainFromString("spiffe://foo.test/a//b")
+	require.True(t, errors.As(err, &parseErr))
+	assert.Equal(t, spiffeid.ParseErrorEmptySegment, parseErr.Kind)
+	assert.Equal(t, 20, parseErr.Offset)
+}
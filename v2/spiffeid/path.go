@@ -32,15 +32,104 @@ func JoinPathSegments(segments ...string) (string, error) {
 	return builder.String(), nil
 }
 
+// PathBuilder builds a path one segment at a time. Each segment is validated
+// as it is appended, and the first invalid segment is reported by Path or ID
+// as a *PathSegmentError. Since the characters allowed in path segments do
+// not need to be percent-encoded, segments containing percent-encoded
+// characters are rejected and must be decoded first. The zero value is an
+// empty path ready to use.
+type PathBuilder struct {
+	path     string
+	segments int
+	err      error
+}
+
+// Append appends the segments to the path.
+func (b *PathBuilder) Append(segments ...string) *PathBuilder {
+	for _, segment := range segments {
+		if b.err != nil {
+			break
+		}
+		if err := validateBuilderSegment(segment); err != nil {
+			b.err = &PathSegmentError{Index: b.segments, Segment: segment, Err: err}
+			break
+		}
+		b.path += "/" + segment
+		b.segments++
+	}
+	return b
+}
+
+// Appendf appends a segment built by formatting the given formatting string
+// with the given args (i.e. fmt.Sprintf) to the path.
+func (b *PathBuilder) Appendf(format string, args ...interface{}) *PathBuilder {
+	return b.Append(fmt.Sprintf(format, args...))
+}
+
+// Path returns the built path, or the error for the first invalid segment.
+func (b *PathBuilder) Path() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	return b.path, nil
+}
+
+// ID returns a SPIFFE ID in the given trust domain with the built path, or
+// the error for the first invalid segment.
+func (b *PathBuilder) ID(td TrustDomain) (ID, error) {
+	path, err := b.Path()
+	if err != nil {
+		return ID{}, err
+	}
+	return makeID(td, path)
+}
+
+// PathSegmentError is returned by PathBuilder when a path segment is
+// invalid.
+type PathSegmentError struct {
+	// Index is the zero-based index of the segment in the path.
+	Index int
+
+	// Segment is the invalid segment.
+	Segment string
+
+	// Err is the reason why the segment is invalid.
+	Err error
+}
+
+// Error returns a message naming the invalid segment.
+func (e *PathSegmentError) Error() string {
+	return fmt.Sprintf("invalid path segment %d (%q): %v", e.Index, e.Segment, e.Err)
+}
+
+// Unwrap returns the reason why the segment is invalid.
+func (e *PathSegmentError) Unwrap() error {
+	return e.Err
+}
+
+func validateBuilderSegment(segment string) error {
+	if strings.IndexByte(segment, '%') >= 0 {
+		return errPercentEncoded
+	}
+	return ValidatePathSegment(segment)
+}
+
 // ValidatePath validates that a path string is a conformant path for a SPIFFE
 // ID.
 // See https://github.com/spiffe/spiffe/blob/main/standards/SPIFFE-ID.md#22-path
 func ValidatePath(path string) error {
+	_, err := validatePath(path)
+	return err
+}
+
+// validatePath validates the path, returning the offset in the path where it
+// is invalid along with the error.
+func validatePath(path string) (int, error) {
 	switch {
 	case path == "":
-		return nil
+		return 0, nil
 	case path[0] != '/':
-		return errNoLeadingSlash
+		return 0, errNoLeadingSlash
 	}
 
 	segmentStart := 0
@@ -50,25 +139,25 @@ func ValidatePath(path string) error {
 		if c == '/' {
 			switch path[segmentStart:segmentEnd] {
 			case "/":
-				return errEmptySegment
+				return segmentEnd, errEmptySegment
 			case "/.", "/..":
-				return errDotSegment
+				return segmentStart + 1, errDotSegment
 			}
 			segmentStart = segmentEnd
 			continue
 		}
 		if !isValidPathSegmentChar(c) {
-			return errBadPathSegmentChar
+			return segmentEnd, errBadPathSegmentChar
 		}
 	}
 
 	switch path[segmentStart:segmentEnd] {
 	case "/":
-		return errTrailingSlash
+		return segmentStart, errTrailingSlash
 	case "/.", "/..":
-		return errDotSegment
+		return segmentStart + 1, errDotSegment
 	}
-	return nil
+	return 0, nil
 }
 
 // ValidatePathSegment validates that a string is a conformant segment for
@@ -56,3 +56,46 @@ func TestValidatePathSegment(t *testing.T) {
 		require.NoError(t, ValidatePathSegment("a"))
 	})
 }
+
+func TestPathBuilder(t *testing.T) {
+	td := RequireTrustDomainFromString("example.org")
+
+	var empty PathBuilder
+	path, err := empty.Path()
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+
+	b := new(PathBuilder).Append("ns", "default").Appendf("sa-%d", 1)
+	path, err = b.Path()
+	assert.NoError(t, err)
+	assert.Equal(t, "/ns/default/sa-1", path)
+	id, err := b.ID(td)
+	assert.NoError(t, err)
+	assert.Equal(t, "spiffe://example.org/ns/default/sa-1", id.String())
+
+	for _, tt := range []struct {
+		segments  []string
+		expectErr error
+		index     int
+	}{
+		{segments: []string{"a", ""}, expectErr: errEmptySegment, index: 1},
+		{segments: []string{"a", "b", ".."}, expectErr: errDotSegment, index: 2},
+		{segments: []string{"a/b"}, expectErr: errBadPathSegmentChar, index: 0},
+		{segments: []string{"a", "b%2Fc"}, expectErr: errPercentEncoded, index: 1},
+	} {
+		b := new(PathBuilder).Append(tt.segments...).Append("ignored")
+		_, err := b.Path()
+		assert.ErrorIs(t, err, tt.expectErr)
+		var segmentErr *PathSegmentError
+		if assert.ErrorAs(t, err, &segmentErr) {
+			assert.Equal(t, tt.index, segmentErr.Index)
+			assert.Equal(t, tt.segments[tt.index], segmentErr.Segment)
+		}
+		id, err := b.ID(td)
+		assert.Error(t, err)
+		assert.Zero(t, id)
+	}
+
+	_, err = new(PathBuilder).Append("ns", "b%2Fc").Path()
+	assert.EqualError(t, err, `invalid path segment 1 ("b%2Fc"): path segment characters cannot be percent-encoded`)
+}
@@ -0,0 +1,106 @@
+package spiffeid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// pathPattern is a parsed path pattern. Each element is either a literal
+// segment or one of the wildcards.
+type pathPattern []string
+
+const (
+	// segmentWildcard matches exactly one path segment.
+	segmentWildcard = "*"
+
+	// multiSegmentWildcard matches zero or more path segments.
+	multiSegmentWildcard = "**"
+)
+
+// parsePathPattern parses a path pattern. Literal segments are unescaped,
+// so that they can be compared with the segments of a path, and validated.
+func parsePathPattern(pattern string) (pathPattern, error) {
+	if pattern == "" {
+		return pathPattern{}, nil
+	}
+	if pattern[0] != '/' {
+		return nil, errNoLeadingSlash
+	}
+
+	var parsed pathPattern
+	for _, segment := range strings.Split(pattern[1:], "/") {
+		switch segment {
+		case segmentWildcard, multiSegmentWildcard:
+			parsed = append(parsed, segment)
+			continue
+		}
+		literal, err := unescapePatternSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		if err := ValidatePathSegment(literal); err != nil {
+			return nil, fmt.Errorf("invalid path pattern segment %q: %w", segment, err)
+		}
+		// Wildcards are stored as-is, so an unescaped literal segment
+		// that looks like one is kept escaped to tell them apart.
+		if literal == segmentWildcard || literal == multiSegmentWildcard {
+			literal = `\` + literal
+		}
+		parsed = append(parsed, literal)
+	}
+	return parsed, nil
+}
+
+// unescapePatternSegment removes the backslashes escaping the characters of
+// a literal pattern segment. Asterisks must be escaped.
+func unescapePatternSegment(segment string) (string, error) {
+	var builder strings.Builder
+	for i := 0; i < len(segment); i++ {
+		switch c := segment[i]; c {
+		case '\\':
+			i++
+			if i == len(segment) {
+				return "", fmt.Errorf("invalid path pattern segment %q: %w", segment, errTrailingEscape)
+			}
+			builder.WriteByte(segment[i])
+		case '*':
+			return "", fmt.Errorf("invalid path pattern segment %q: %w", segment, errPartialWildcard)
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	return builder.String(), nil
+}
+
+// matches returns true if the path matches the pattern.
+func (p pathPattern) matches(path string) bool {
+	var segments []string
+	if path != "" {
+		segments = strings.Split(path[1:], "/")
+	}
+	return matchSegments(p, segments)
+}
+
+func matchSegments(pattern pathPattern, segments []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case multiSegmentWildcard:
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		case segmentWildcard:
+			if len(segments) == 0 {
+				return false
+			}
+		default:
+			if len(segments) == 0 || strings.TrimPrefix(pattern[0], `\`) != segments[0] {
+				return false
+			}
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
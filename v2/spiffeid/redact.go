@@ -0,0 +1,43 @@
+package spiffeid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// redactedPrefix prefixes the digest standing in for the redacted segments.
+// Since "~" is not a valid path segment character (unless the charset
+// backcompat build tag is set), redacted IDs are not mistaken for real ones.
+const redactedPrefix = "~"
+
+// Redacted returns a representation of the SPIFFE ID suitable for logging
+// when full workload paths are considered sensitive. The trust domain and the
+// first keepSegments path segments are kept as is while the remaining
+// segments are replaced by a single truncated SHA-256 digest of them, e.g.
+// "spiffe://example.org/ns/prod/~3f2c6a6f0a1b9e4d". Equal IDs redact to equal
+// strings so redacted IDs can still be correlated. If there are no segments
+// to redact, the string representation of the ID is returned. The zero value
+// redacts to an empty string.
+func (id ID) Redacted(keepSegments int) string {
+	if keepSegments < 0 {
+		keepSegments = 0
+	}
+	path := id.Path()
+	if path == "" {
+		return id.String()
+	}
+
+	// Find where the segments to redact start, including their leading slash.
+	start := 0
+	for i := 0; i < keepSegments; i++ {
+		next := strings.IndexByte(path[start+1:], '/')
+		if next < 0 {
+			return id.String()
+		}
+		start += next + 1
+	}
+
+	sum := sha256.Sum256([]byte(path[start:]))
+	return id.id[:id.pathidx] + path[:start] + "/" + redactedPrefix + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,37 @@
+package spiffeid_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIDRedacted(t *testing.T) {
+	id := spiffeid.RequireFromString("spiffe://example.org/ns/prod/sa/billing")
+
+	assertRedacted := func(t *testing.T, keepSegments int, expectPrefix string) {
+		redacted := id.Redacted(keepSegments)
+		assert.Regexp(t, "^"+regexp.QuoteMeta(expectPrefix)+"~[0-9a-f]{16}$", redacted)
+		assert.NotContains(t, redacted[len(expectPrefix):], "billing")
+	}
+
+	assertRedacted(t, -1, "spiffe://example.org/")
+	assertRedacted(t, 0, "spiffe://example.org/")
+	assertRedacted(t, 1, "spiffe://example.org/ns/")
+	assertRedacted(t, 2, "spiffe://example.org/ns/prod/")
+	assertRedacted(t, 3, "spiffe://example.org/ns/prod/sa/")
+	assert.Equal(t, id.String(), id.Redacted(4))
+	assert.Equal(t, id.String(), id.Redacted(5))
+
+	// Equal IDs redact equally, different ones don't.
+	assert.Equal(t, id.Redacted(2), spiffeid.RequireFromString("spiffe://example.org/ns/prod/sa/billing").Redacted(2))
+	assert.NotEqual(t, id.Redacted(2), spiffeid.RequireFromString("spiffe://example.org/ns/prod/sa/payments").Redacted(2))
+
+	// Redacting everything hides the segment count.
+	assert.Len(t, id.Redacted(0), len(spiffeid.RequireFromString("spiffe://example.org/a").Redacted(0)))
+
+	assert.Equal(t, "spiffe://example.org", spiffeid.RequireFromString("spiffe://example.org").Redacted(0))
+	assert.Equal(t, "", spiffeid.ID{}.Redacted(0))
+}
@@ -2,6 +2,7 @@ package spiffeid
 
 import (
 	"net/url"
+	"regexp"
 )
 
 // RequireFromPath is similar to FromPath except that instead of returning an
@@ -96,6 +97,42 @@ func RequireJoinPathSegments(segments ...string) string {
 	return path
 }
 
+// RequireMatchPathPattern is similar to MatchPathPattern except that instead
+// of returning an error on a malformed pattern, it panics. It should only be
+// used when the input is statically verifiable.
+func RequireMatchPathPattern(td TrustDomain, pattern string) Matcher {
+	matcher, err := MatchPathPattern(td, pattern)
+	panicOnErr(err)
+	return matcher
+}
+
+// RequireMatchPathRegex is similar to MatchPathRegex except that instead of
+// returning an error on a nil regular expression, it panics. It should only
+// be used when the input is statically verifiable.
+func RequireMatchPathRegex(td TrustDomain, re *regexp.Regexp) Matcher {
+	matcher, err := MatchPathRegex(td, re)
+	panicOnErr(err)
+	return matcher
+}
+
+// RequireMatchMemberOfSubtree is similar to MatchMemberOfSubtree except that
+// instead of returning an error on a malformed path, it panics. It should
+// only be used when the input is statically verifiable.
+func RequireMatchMemberOfSubtree(td TrustDomain, path string) Matcher {
+	matcher, err := MatchMemberOfSubtree(td, path)
+	panicOnErr(err)
+	return matcher
+}
+
+// RequireParseTemplate is similar to ParseTemplate except that instead of
+// returning an error on malformed input, it panics. It should only be used
+// when the input is statically verifiable.
+func RequireParseTemplate(text string) Template {
+	t, err := ParseTemplate(text)
+	panicOnErr(err)
+	return t
+}
+
 func panicOnErr(err error) {
 	if err != nil {
 		panic(err)
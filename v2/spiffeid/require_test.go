@@ -2,6 +2,7 @@ package spiffeid_test
 
 import (
 	"net/url"
+	"regexp"
 	"testing"
 
 	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
@@ -107,3 +108,43 @@ func TestRequireJoinPathSegments(t *testing.T) {
 		spiffeid.RequireJoinPathSegments("/absolute")
 	})
 }
+
+func TestRequireMatchPathPattern(t *testing.T) {
+	assert.NotPanics(t, func() {
+		matcher := spiffeid.RequireMatchPathPattern(td, "/*")
+		assert.NoError(t, matcher(spiffeid.RequireFromPath(td, "/path")))
+	})
+	assert.Panics(t, func() {
+		spiffeid.RequireMatchPathPattern(td, "/path*")
+	})
+}
+
+func TestRequireMatchPathRegex(t *testing.T) {
+	assert.NotPanics(t, func() {
+		matcher := spiffeid.RequireMatchPathRegex(td, regexp.MustCompile(`/path`))
+		assert.NoError(t, matcher(spiffeid.RequireFromPath(td, "/path")))
+	})
+	assert.Panics(t, func() {
+		spiffeid.RequireMatchPathRegex(td, nil)
+	})
+}
+
+func TestRequireMatchMemberOfSubtree(t *testing.T) {
+	assert.NotPanics(t, func() {
+		matcher := spiffeid.RequireMatchMemberOfSubtree(td, "/path")
+		assert.NoError(t, matcher(spiffeid.RequireFromPath(td, "/path/sub")))
+	})
+	assert.Panics(t, func() {
+		spiffeid.RequireMatchMemberOfSubtree(td, "path")
+	})
+}
+
+func TestRequireParseTemplate(t *testing.T) {
+	assert.NotPanics(t, func() {
+		template := spiffeid.RequireParseTemplate("spiffe://trustdomain/{{.Name}}")
+		assert.Equal(t, "spiffe://trustdomain/{{.Name}}", template.String())
+	})
+	assert.Panics(t, func() {
+		spiffeid.RequireParseTemplate("spiffe://trustdomain/{{.Name}")
+	})
+}
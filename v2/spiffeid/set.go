@@ -0,0 +1,128 @@
+package spiffeid
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Set is a set of SPIFFE IDs. It is immutable once created and safe for
+// concurrent use. It is marshaled to JSON as a sorted array of SPIFFE ID
+// strings. The zero value is an empty set.
+type Set struct {
+	ids map[ID]struct{}
+}
+
+// NewSet returns a set with the given SPIFFE IDs. Duplicates are ignored.
+func NewSet(ids ...ID) Set {
+	set := Set{ids: make(map[ID]struct{}, len(ids))}
+	for _, id := range ids {
+		set.ids[id] = struct{}{}
+	}
+	return set
+}
+
+// Contains returns true if the SPIFFE ID is in the set.
+func (s Set) Contains(id ID) bool {
+	_, ok := s.ids[id]
+	return ok
+}
+
+// Len returns the number of SPIFFE IDs in the set.
+func (s Set) Len() int {
+	return len(s.ids)
+}
+
+// IDs returns the SPIFFE IDs in the set, sorted according to Compare.
+func (s Set) IDs() []ID {
+	ids := make([]ID, 0, len(s.ids))
+	for id := range s.ids {
+		ids = append(ids, id)
+	}
+	SortIDs(ids)
+	return ids
+}
+
+// MarshalJSON returns the JSON array of SPIFFE ID strings in the set.
+func (s Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.IDs())
+}
+
+// UnmarshalJSON decodes a JSON array of SPIFFE ID strings into the set. It
+// fails if any of the SPIFFE IDs is invalid.
+func (s *Set) UnmarshalJSON(data []byte) error {
+	var ids []ID
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return err
+	}
+	*s = NewSet(ids...)
+	return nil
+}
+
+// TrustDomainSet is a set of trust domains. It is immutable once created and
+// safe for concurrent use. It is marshaled to JSON as a sorted array of trust
+// domain names. The zero value is an empty set.
+type TrustDomainSet struct {
+	tds map[TrustDomain]struct{}
+}
+
+// NewTrustDomainSet returns a set with the given trust domains. Duplicates
+// are ignored.
+func NewTrustDomainSet(tds ...TrustDomain) TrustDomainSet {
+	set := TrustDomainSet{tds: make(map[TrustDomain]struct{}, len(tds))}
+	for _, td := range tds {
+		set.tds[td] = struct{}{}
+	}
+	return set
+}
+
+// TrustDomainSetFromStrings returns a set with the trust domains parsed from
+// the given names, e.g. the allowlist of a configuration file. An error naming
+// the first invalid trust domain name is returned.
+func TrustDomainSetFromStrings(names ...string) (TrustDomainSet, error) {
+	set := TrustDomainSet{tds: make(map[TrustDomain]struct{}, len(names))}
+	for _, name := range names {
+		td, err := TrustDomainFromString(name)
+		if err != nil {
+			return TrustDomainSet{}, fmt.Errorf("invalid trust domain %q: %w", name, err)
+		}
+		set.tds[td] = struct{}{}
+	}
+	return set, nil
+}
+
+// Contains returns true if the trust domain is in the set.
+func (s TrustDomainSet) Contains(td TrustDomain) bool {
+	_, ok := s.tds[td]
+	return ok
+}
+
+// Len returns the number of trust domains in the set.
+func (s TrustDomainSet) Len() int {
+	return len(s.tds)
+}
+
+// TrustDomains returns the trust domains in the set, sorted by name.
+func (s TrustDomainSet) TrustDomains() []TrustDomain {
+	tds := make([]TrustDomain, 0, len(s.tds))
+	for td := range s.tds {
+		tds = append(tds, td)
+	}
+	SortTrustDomains(tds)
+	return tds
+}
+
+// MarshalJSON returns the JSON array of trust domain names in the set.
+func (s TrustDomainSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.TrustDomains())
+}
+
+// UnmarshalJSON decodes a JSON array of trust domain names into the set. It
+// fails if any of the trust domain names is invalid.
+func (s *TrustDomainSet) UnmarshalJSON(data []byte) error {
+	var tds []TrustDomain
+	if err := json.Unmarshal(data, &tds); err != nil {
+		return err
+	}
+	*s = NewTrustDomainSet(tds...)
+	return nil
+}
@@ -0,0 +1,77 @@
+package spiffeid_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet(t *testing.T) {
+	var empty spiffeid.Set
+	assert.False(t, empty.Contains(fooA))
+	assert.Zero(t, empty.Len())
+	assert.Empty(t, empty.IDs())
+
+	set := spiffeid.NewSet(fooB, barA, fooA, fooB)
+	assert.True(t, set.Contains(fooA))
+	assert.True(t, set.Contains(barA))
+	assert.False(t, set.Contains(fooC))
+	assert.Equal(t, 3, set.Len())
+	assert.Equal(t, []spiffeid.ID{barA, fooA, fooB}, set.IDs())
+}
+
+func TestSetJSON(t *testing.T) {
+	data, err := json.Marshal(spiffeid.NewSet(fooB, barA, fooA))
+	require.NoError(t, err)
+	assert.JSONEq(t, `["spiffe://bar.test/A", "spiffe://foo.test/A", "spiffe://foo.test/B"]`, string(data))
+
+	data, err = json.Marshal(spiffeid.Set{})
+	require.NoError(t, err)
+	assert.Equal(t, `[]`, string(data))
+
+	var set spiffeid.Set
+	require.NoError(t, json.Unmarshal([]byte(`["spiffe://foo.test/A", "spiffe://bar.test/A"]`), &set))
+	assert.Equal(t, spiffeid.NewSet(fooA, barA), set)
+
+	err = json.Unmarshal([]byte(`["foo.test/A"]`), &set)
+	assert.EqualError(t, err, "scheme is missing or invalid")
+}
+
+func TestTrustDomainSet(t *testing.T) {
+	var empty spiffeid.TrustDomainSet
+	assert.False(t, empty.Contains(foo.TrustDomain()))
+	assert.Zero(t, empty.Len())
+	assert.Empty(t, empty.TrustDomains())
+
+	set := spiffeid.NewTrustDomainSet(foo.TrustDomain(), barA.TrustDomain(), fooA.TrustDomain())
+	assert.True(t, set.Contains(foo.TrustDomain()))
+	assert.False(t, set.Contains(spiffeid.RequireTrustDomainFromString("baz.test")))
+	assert.Equal(t, 2, set.Len())
+	assert.Equal(t, []spiffeid.TrustDomain{barA.TrustDomain(), foo.TrustDomain()}, set.TrustDomains())
+}
+
+func TestTrustDomainSetFromStrings(t *testing.T) {
+	set, err := spiffeid.TrustDomainSetFromStrings("foo.test", "bar.test", "spiffe://foo.test")
+	require.NoError(t, err)
+	assert.Equal(t, spiffeid.NewTrustDomainSet(foo.TrustDomain(), barA.TrustDomain()), set)
+
+	set, err = spiffeid.TrustDomainSetFromStrings("foo.test", "Bar.test")
+	assert.EqualError(t, err, `invalid trust domain "Bar.test": trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`)
+	assert.Zero(t, set.Len())
+}
+
+func TestTrustDomainSetJSON(t *testing.T) {
+	data, err := json.Marshal(spiffeid.NewTrustDomainSet(foo.TrustDomain(), barA.TrustDomain()))
+	require.NoError(t, err)
+	assert.JSONEq(t, `["bar.test", "foo.test"]`, string(data))
+
+	var set spiffeid.TrustDomainSet
+	require.NoError(t, json.Unmarshal([]byte(`["foo.test"]`), &set))
+	assert.Equal(t, spiffeid.NewTrustDomainSet(foo.TrustDomain()), set)
+
+	err = json.Unmarshal([]byte(`["FOO.test"]`), &set)
+	assert.EqualError(t, err, "trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores")
+}
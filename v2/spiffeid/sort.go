@@ -0,0 +1,36 @@
+package spiffeid
+
+import (
+	"sort"
+	"strings"
+)
+
+// Compare returns an integer comparing two SPIFFE IDs, first by trust domain
+// and then by path, lexicographically. The result will be 0 if a==b, -1 if
+// a < b, and +1 if a > b. The zero ID sorts first.
+func Compare(a, b ID) int {
+	if c := a.TrustDomain().Compare(b.TrustDomain()); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Path(), b.Path())
+}
+
+// SortIDs sorts the SPIFFE IDs in place according to Compare.
+func SortIDs(ids []ID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return Compare(ids[i], ids[j]) < 0
+	})
+}
+
+// CompareTrustDomains returns an integer comparing two trust domains
+// lexicographically. It is equivalent to a.Compare(b).
+func CompareTrustDomains(a, b TrustDomain) int {
+	return a.Compare(b)
+}
+
+// SortTrustDomains sorts the trust domains in place by name.
+func SortTrustDomains(tds []TrustDomain) {
+	sort.Slice(tds, func(i, j int) bool {
+		return tds[i].Compare(tds[j]) < 0
+	})
+}
@@ -0,0 +1,36 @@
+package spiffeid_test
+
+import (
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	// By string, spiffe://foo.test.example sorts before spiffe://foo.test/A
+	// since '.' < '/', but IDs are compared by trust domain first.
+	fooExample := spiffeid.RequireFromString("spiffe://foo.test.example")
+
+	assert.Equal(t, 0, spiffeid.Compare(fooA, fooA))
+	assert.Equal(t, -1, spiffeid.Compare(fooA, fooB))
+	assert.Equal(t, 1, spiffeid.Compare(fooB, fooA))
+	assert.Equal(t, -1, spiffeid.Compare(foo, fooA))
+	assert.Equal(t, -1, spiffeid.Compare(barA, foo))
+	assert.Equal(t, -1, spiffeid.Compare(zero, barA))
+	assert.Equal(t, -1, spiffeid.Compare(fooC, fooExample))
+}
+
+func TestSortIDs(t *testing.T) {
+	fooExample := spiffeid.RequireFromString("spiffe://foo.test.example")
+	ids := []spiffeid.ID{fooExample, fooC, barA, fooB, zero, foo, fooA}
+	spiffeid.SortIDs(ids)
+	assert.Equal(t, []spiffeid.ID{zero, barA, foo, fooA, fooB, fooC, fooExample}, ids)
+}
+
+func TestSortTrustDomains(t *testing.T) {
+	tds := []spiffeid.TrustDomain{foo.TrustDomain(), {}, barA.TrustDomain()}
+	spiffeid.SortTrustDomains(tds)
+	assert.Equal(t, []spiffeid.TrustDomain{{}, barA.TrustDomain(), foo.TrustDomain()}, tds)
+	assert.Equal(t, -1, spiffeid.CompareTrustDomains(barA.TrustDomain(), foo.TrustDomain()))
+}
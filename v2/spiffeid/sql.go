@@ -0,0 +1,82 @@
+package spiffeid
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Value implements the driver.Valuer interface. The ID is stored as its
+// string representation. The zero value is stored as NULL.
+func (id ID) Value() (driver.Value, error) {
+	if id.IsZero() {
+		return nil, nil
+	}
+	return id.String(), nil
+}
+
+// Scan implements the sql.Scanner interface. The ID is parsed from a string
+// or a byte slice. NULL and empty values scan to the zero value.
+func (id *ID) Scan(src interface{}) error {
+	text, err := scanText(src, "SPIFFE ID")
+	if err != nil {
+		return err
+	}
+	return id.UnmarshalText(text)
+}
+
+// Value implements the driver.Valuer interface. The trust domain is stored as
+// its name. The zero value is stored as NULL.
+func (td TrustDomain) Value() (driver.Value, error) {
+	if td.IsZero() {
+		return nil, nil
+	}
+	return td.String(), nil
+}
+
+// Scan implements the sql.Scanner interface. The trust domain is parsed from
+// a string or a byte slice. NULL and empty values scan to the zero value.
+func (td *TrustDomain) Scan(src interface{}) error {
+	text, err := scanText(src, "trust domain")
+	if err != nil {
+		return err
+	}
+	return td.UnmarshalText(text)
+}
+
+// Value implements the driver.Valuer interface. The spec is stored as JSON.
+func (s MatcherSpec) Value() (driver.Value, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements the sql.Scanner interface. The spec is decoded and
+// validated from JSON stored as a string or a byte slice. NULL scans to the
+// zero value.
+func (s *MatcherSpec) Scan(src interface{}) error {
+	text, err := scanText(src, "matcher spec")
+	if err != nil {
+		return err
+	}
+	if text == nil {
+		*s = MatcherSpec{}
+		return nil
+	}
+	return json.Unmarshal(text, s)
+}
+
+func scanText(src interface{}, what string) ([]byte, error) {
+	switch src := src.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(src), nil
+	case []byte:
+		return src, nil
+	default:
+		return nil, fmt.Errorf("cannot scan %T into %s", src, what)
+	}
+}
@@ -0,0 +1,94 @@
+package spiffeid_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ driver.Valuer = spiffeid.ID{}
+	_ sql.Scanner   = (*spiffeid.ID)(nil)
+	_ driver.Valuer = spiffeid.TrustDomain{}
+	_ sql.Scanner   = (*spiffeid.TrustDomain)(nil)
+	_ driver.Valuer = spiffeid.MatcherSpec{}
+	_ sql.Scanner   = (*spiffeid.MatcherSpec)(nil)
+)
+
+func TestIDValueScan(t *testing.T) {
+	value, err := fooA.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://foo.test/A", value)
+
+	value, err = spiffeid.ID{}.Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	for _, src := range []interface{}{"spiffe://foo.test/A", []byte("spiffe://foo.test/A")} {
+		var id spiffeid.ID
+		require.NoError(t, id.Scan(src))
+		assert.Equal(t, fooA, id)
+	}
+
+	for _, src := range []interface{}{nil, "", []byte{}} {
+		id := fooA
+		require.NoError(t, id.Scan(src))
+		assert.Zero(t, id)
+	}
+
+	var id spiffeid.ID
+	assert.EqualError(t, id.Scan("BAD"), "scheme is missing or invalid")
+	assert.EqualError(t, id.Scan(42), "cannot scan int into SPIFFE ID")
+	assert.Zero(t, id)
+}
+
+func TestTrustDomainValueScan(t *testing.T) {
+	value, err := td.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "trustdomain", value)
+
+	value, err = spiffeid.TrustDomain{}.Value()
+	require.NoError(t, err)
+	assert.Nil(t, value)
+
+	for _, src := range []interface{}{"trustdomain", []byte("trustdomain"), "spiffe://trustdomain/path"} {
+		var scanned spiffeid.TrustDomain
+		require.NoError(t, scanned.Scan(src))
+		assert.Equal(t, td, scanned)
+	}
+
+	for _, src := range []interface{}{nil, "", []byte{}} {
+		scanned := td
+		require.NoError(t, scanned.Scan(src))
+		assert.Zero(t, scanned)
+	}
+
+	var scanned spiffeid.TrustDomain
+	assert.EqualError(t, scanned.Scan("BAD"), "trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores")
+	assert.EqualError(t, scanned.Scan(42), "cannot scan int into trust domain")
+	assert.Zero(t, scanned)
+}
+
+func TestMatcherSpecValueScan(t *testing.T) {
+	spec := spiffeid.MatcherSpec{Kind: spiffeid.MatcherKindID, IDs: []spiffeid.ID{fooA}}
+	value, err := spec.Value()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"kind": "id", "ids": ["spiffe://foo.test/A"]}`, value.(string))
+
+	for _, src := range []interface{}{value, []byte(value.(string))} {
+		var scanned spiffeid.MatcherSpec
+		require.NoError(t, scanned.Scan(src))
+		assert.Equal(t, spec, scanned)
+	}
+
+	scanned := spec
+	require.NoError(t, scanned.Scan(nil))
+	assert.Zero(t, scanned)
+
+	assert.EqualError(t, scanned.Scan(`{"kind": "id"}`), "invalid matcher spec: id matcher requires exactly one ID")
+	assert.EqualError(t, scanned.Scan(42), "cannot scan int into matcher spec")
+}
@@ -0,0 +1,213 @@
+package spiffeid
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Template is a SPIFFE ID template with placeholders that are substituted
+// with values when rendered, e.g.,
+// "spiffe://{{.TrustDomain}}/ns/{{.Namespace}}/sa/{{.ServiceAccount}}".
+// Placeholders can make up a whole trust domain or path segment or only part
+// of it, but never span a path separator. Templates are validated when
+// parsed, and the values substituted when rendered are validated so that a
+// value can never add path segments to the rendered ID.
+type Template struct {
+	text  string
+	parts []templatePart
+}
+
+type templatePart struct {
+	// literal is the literal text of the part, if it isn't a placeholder.
+	literal string
+	// name is the name of the placeholder, if the part is one.
+	name string
+	// inPath is true if the placeholder is in the path of the ID.
+	inPath bool
+}
+
+// ParseTemplate parses a SPIFFE ID template. Placeholders are written as
+// {{.Name}}, where Name is made up of letters, numbers and underscores. An
+// error is returned if a placeholder is malformed or if the template cannot
+// render a valid SPIFFE ID.
+func ParseTemplate(text string) (Template, error) {
+	parts, err := parseTemplateParts(text)
+	if err != nil {
+		return Template{}, fmt.Errorf("invalid ID template %q: %w", text, err)
+	}
+	t := Template{text: text, parts: parts}
+
+	// Render the template with a valid value for every placeholder to make
+	// sure the literal text around the placeholders is valid.
+	if _, err := t.render(func(string) (string, bool) { return "x", true }); err != nil {
+		return Template{}, fmt.Errorf("invalid ID template %q: %w", text, err)
+	}
+	return t, nil
+}
+
+// Placeholders returns the sorted names of the placeholders in the template.
+func (t Template) Placeholders() []string {
+	var names []string
+	seen := make(map[string]struct{})
+	for _, part := range t.parts {
+		if part.name == "" {
+			continue
+		}
+		if _, ok := seen[part.name]; !ok {
+			seen[part.name] = struct{}{}
+			names = append(names, part.name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render returns the SPIFFE ID obtained by substituting the placeholders
+// with the given values. An error is returned if a value is missing for a
+// placeholder, if a value is empty or has characters that are not allowed
+// where the placeholder is, or if the rendered SPIFFE ID is invalid. Values
+// for unknown placeholders are ignored.
+func (t Template) Render(values map[string]string) (ID, error) {
+	if t.IsZero() {
+		return ID{}, errors.New("cannot render a zero ID template value")
+	}
+	return t.render(func(name string) (string, bool) {
+		value, ok := values[name]
+		return value, ok
+	})
+}
+
+// String returns the text of the template.
+func (t Template) String() string {
+	return t.text
+}
+
+// IsZero returns true if the template is the zero value.
+func (t Template) IsZero() bool {
+	return t.text == ""
+}
+
+// MarshalText returns the text of the template. If the template is the zero
+// value, nil is returned.
+func (t Template) MarshalText() ([]byte, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+	return []byte(t.text), nil
+}
+
+// UnmarshalText parses the template from its text. If the text is empty, the
+// template is set to the zero value.
+func (t *Template) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*t = Template{}
+		return nil
+	}
+	parsed, err := ParseTemplate(string(text))
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+func (t Template) render(lookup func(name string) (string, bool)) (ID, error) {
+	var builder strings.Builder
+	for _, part := range t.parts {
+		if part.name == "" {
+			builder.WriteString(part.literal)
+			continue
+		}
+		value, ok := lookup(part.name)
+		if !ok {
+			return ID{}, fmt.Errorf("missing value for placeholder %q", part.name)
+		}
+		if err := validateTemplateValue(value, part.inPath); err != nil {
+			return ID{}, fmt.Errorf("invalid value %q for placeholder %q: %w", value, part.name, err)
+		}
+		builder.WriteString(value)
+	}
+
+	id, err := FromString(builder.String())
+	if err != nil {
+		return ID{}, fmt.Errorf("rendered ID %q is invalid: %w", builder.String(), err)
+	}
+	return id, nil
+}
+
+func parseTemplateParts(text string) ([]templatePart, error) {
+	var parts []templatePart
+	// probe is the template rendered so far, with placeholders standing in
+	// for a single character, used to tell whether placeholders are part of
+	// the trust domain or of the path.
+	var probe strings.Builder
+	for text != "" {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			start = len(text)
+		}
+		if literal := text[:start]; literal != "" {
+			if strings.Contains(literal, "}}") {
+				return nil, errors.New("unexpected placeholder end")
+			}
+			parts = append(parts, templatePart{literal: literal})
+			probe.WriteString(literal)
+		}
+		if start == len(text) {
+			break
+		}
+
+		end := strings.Index(text[start:], "}}")
+		if end < 0 {
+			return nil, errors.New("unterminated placeholder")
+		}
+		name, err := parsePlaceholderName(text[start+2 : start+end])
+		if err != nil {
+			return nil, err
+		}
+		rendered := probe.String()
+		inPath := len(rendered) > schemePrefixLen && strings.Contains(rendered[schemePrefixLen:], "/")
+		parts = append(parts, templatePart{name: name, inPath: inPath})
+		probe.WriteByte('x')
+		text = text[start+end+2:]
+	}
+	return parts, nil
+}
+
+func parsePlaceholderName(placeholder string) (string, error) {
+	name := strings.TrimSpace(placeholder)
+	if !strings.HasPrefix(name, ".") || len(name) == 1 {
+		return "", fmt.Errorf("placeholder %q must be of the form {{.Name}}", "{{"+placeholder+"}}")
+	}
+	name = name[1:]
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		default:
+			return "", fmt.Errorf("placeholder %q must be of the form {{.Name}}", "{{"+placeholder+"}}")
+		}
+	}
+	return name, nil
+}
+
+func validateTemplateValue(value string, inPath bool) error {
+	if value == "" {
+		return errEmpty
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if inPath {
+			// Percent-encoded characters are rejected since they would be
+			// decoded into something other than the value by consumers.
+			if c == '%' || !isValidPathSegmentChar(c) {
+				return errBadPathSegmentChar
+			}
+		} else if !isValidTrustDomainChar(c) {
+			return errBadTrustDomainChar
+		}
+	}
+	return nil
+}
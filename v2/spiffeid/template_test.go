@@ -0,0 +1,99 @@
+package spiffeid_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTemplate(t *testing.T) {
+	for text, expectPlaceholders := range map[string][]string{
+		"spiffe://example.org/static":                                        nil,
+		"spiffe://{{.TrustDomain}}/ns/{{.Namespace}}/sa/{{.ServiceAccount}}": {"Namespace", "ServiceAccount", "TrustDomain"},
+		"spiffe://{{ .Cluster }}.example.org/node/{{.Node}}-{{.Node}}":       {"Cluster", "Node"},
+		"spiffe://example.org/{{.A}}/{{.B}}":                                 {"A", "B"},
+	} {
+		template, err := spiffeid.ParseTemplate(text)
+		require.NoError(t, err, text)
+		assert.Equal(t, text, template.String())
+		assert.Equal(t, expectPlaceholders, template.Placeholders())
+	}
+
+	for text, expectErr := range map[string]string{
+		"":                                  `invalid ID template "": rendered ID "" is invalid: cannot be empty`,
+		"example.org/{{.Name}}":             `invalid ID template "example.org/{{.Name}}": rendered ID "example.org/x" is invalid: scheme is missing or invalid`,
+		"spiffe://example.org/{{.Name}":     `invalid ID template "spiffe://example.org/{{.Name}": unterminated placeholder`,
+		"spiffe://example.org/{{.Name}}}}":  `invalid ID template "spiffe://example.org/{{.Name}}}}": unexpected placeholder end`,
+		"spiffe://example.org/{{Name}}":     `invalid ID template "spiffe://example.org/{{Name}}": placeholder "{{Name}}" must be of the form {{.Name}}`,
+		"spiffe://example.org/{{.}}":        `invalid ID template "spiffe://example.org/{{.}}": placeholder "{{.}}" must be of the form {{.Name}}`,
+		"spiffe://example.org/{{.A.B}}":     `invalid ID template "spiffe://example.org/{{.A.B}}": placeholder "{{.A.B}}" must be of the form {{.Name}}`,
+		"spiffe://example.org//{{.Name}}":   `invalid ID template "spiffe://example.org//{{.Name}}": rendered ID "spiffe://example.org//x" is invalid: path cannot contain empty segments`,
+		"spiffe://example.org/{{.Name}}/":   `invalid ID template "spiffe://example.org/{{.Name}}/": rendered ID "spiffe://example.org/x/" is invalid: path cannot have a trailing slash`,
+		"spiffe://Example.org/{{.Name}}":    `invalid ID template "spiffe://Example.org/{{.Name}}": rendered ID "spiffe://Example.org/x" is invalid: trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`,
+		"spiffe://example.org/../{{.Name}}": `invalid ID template "spiffe://example.org/../{{.Name}}": rendered ID "spiffe://example.org/../x" is invalid: path cannot contain dot segments`,
+	} {
+		template, err := spiffeid.ParseTemplate(text)
+		assert.EqualError(t, err, expectErr, text)
+		assert.Zero(t, template)
+	}
+}
+
+func TestTemplateRender(t *testing.T) {
+	template := spiffeid.RequireParseTemplate("spiffe://{{.TrustDomain}}/ns/{{.Namespace}}/sa/{{.ServiceAccount}}")
+
+	id, err := template.Render(map[string]string{
+		"TrustDomain":    "example.org",
+		"Namespace":      "default",
+		"ServiceAccount": "frontend",
+		"Unused":         "ignored",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "spiffe://example.org/ns/default/sa/frontend", id.String())
+
+	assertFail := func(values map[string]string, expectErr string) {
+		id, err := template.Render(values)
+		assert.EqualError(t, err, expectErr)
+		assert.Zero(t, id)
+	}
+	values := func(td, ns, sa string) map[string]string {
+		return map[string]string{"TrustDomain": td, "Namespace": ns, "ServiceAccount": sa}
+	}
+
+	assertFail(map[string]string{"TrustDomain": "example.org", "Namespace": "default"}, `missing value for placeholder "ServiceAccount"`)
+	assertFail(values("example.org", "", "frontend"), `invalid value "" for placeholder "Namespace": cannot be empty`)
+	assertFail(values("example.org", "default/sa/admin", "frontend"), `invalid value "default/sa/admin" for placeholder "Namespace": path segment characters are limited to letters, numbers, dots, dashes, and underscores`)
+	assertFail(values("example.org", "default", "front%65nd"), `invalid value "front%65nd" for placeholder "ServiceAccount": path segment characters are limited to letters, numbers, dots, dashes, and underscores`)
+	assertFail(values("Example.org", "default", "frontend"), `invalid value "Example.org" for placeholder "TrustDomain": trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`)
+	assertFail(values("example.org/admin", "default", "frontend"), `invalid value "example.org/admin" for placeholder "TrustDomain": trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`)
+	assertFail(values("example.org", "..", "frontend"), `rendered ID "spiffe://example.org/ns/../sa/frontend" is invalid: path cannot contain dot segments`)
+
+	id, err = spiffeid.Template{}.Render(nil)
+	assert.EqualError(t, err, "cannot render a zero ID template value")
+	assert.Zero(t, id)
+}
+
+func TestTemplateTextMarshaling(t *testing.T) {
+	var s struct {
+		Template spiffeid.Template `json:"template"`
+	}
+
+	marshaled, err := json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"template": ""}`, string(marshaled))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"template": "spiffe://example.org/{{.Name}}"}`), &s))
+	assert.Equal(t, spiffeid.RequireParseTemplate("spiffe://example.org/{{.Name}}"), s.Template)
+
+	marshaled, err = json.Marshal(s)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"template": "spiffe://example.org/{{.Name}}"}`, string(marshaled))
+
+	require.NoError(t, json.Unmarshal([]byte(`{"template": ""}`), &s))
+	assert.Zero(t, s.Template)
+
+	err = json.Unmarshal([]byte(`{"template": "spiffe://example.org/{{.Name"}`), &s)
+	assert.EqualError(t, err, `invalid ID template "spiffe://example.org/{{.Name": unterminated placeholder`)
+}
@@ -1,6 +1,7 @@
 package spiffeid
 
 import (
+	"encoding/json"
 	"net/url"
 	"strings"
 )
@@ -13,12 +14,12 @@ type TrustDomain struct {
 
 // TrustDomainFromString returns a new TrustDomain from a string. The string
 // can either be a trust domain name (e.g. example.org), or a valid SPIFFE ID
-// URI (e.g. spiffe://example.org), otherwise an error is returned.
+// URI (e.g. spiffe://example.org), otherwise a *ParseError is returned.
 // See https://github.com/spiffe/spiffe/blob/main/standards/SPIFFE-ID.md#21-trust-domain.
 func TrustDomainFromString(idOrName string) (TrustDomain, error) {
 	switch {
 	case idOrName == "":
-		return TrustDomain{}, errMissingTrustDomain
+		return TrustDomain{}, newParseError(idOrName, 0, errMissingTrustDomain)
 	case strings.Contains(idOrName, ":/"):
 		// The ID looks like it has something like a scheme separator, let's
 		// try to parse as an ID. We use :/ instead of :// since the
@@ -31,7 +32,7 @@ func TrustDomainFromString(idOrName string) (TrustDomain, error) {
 	default:
 		for i := 0; i < len(idOrName); i++ {
 			if !isValidTrustDomainChar(idOrName[i]) {
-				return TrustDomain{}, errBadTrustDomainChar
+				return TrustDomain{}, newParseError(idOrName, i, errBadTrustDomainChar)
 			}
 		}
 		return TrustDomain{name: idOrName}, nil
@@ -42,6 +43,10 @@ func TrustDomainFromString(idOrName string) (TrustDomain, error) {
 // valid SPIFFE ID (see FromURI) or an error is returned. The trust domain is
 // extracted from the host field.
 func TrustDomainFromURI(uri *url.URL) (TrustDomain, error) {
+	if isPlainSPIFFEURI(uri) {
+		return TrustDomain{name: uri.Host}, nil
+	}
+
 	id, err := FromURI(uri)
 	if err != nil {
 		return TrustDomain{}, err
@@ -111,6 +116,27 @@ func (td *TrustDomain) UnmarshalText(text []byte) error {
 	return nil
 }
 
+// MarshalJSON returns a JSON string with the text representation of the
+// trust domain. If the trust domain is the zero value, an empty string is returned.
+func (td TrustDomain) MarshalJSON() ([]byte, error) {
+	return json.Marshal(td.String())
+}
+
+// UnmarshalJSON decodes a JSON string with the text representation of the
+// trust domain. If the string is empty or the JSON value is null, the trust domain
+// is set to the zero value.
+func (td *TrustDomain) UnmarshalJSON(data []byte) error {
+	var text *string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	if text == nil {
+		*td = TrustDomain{}
+		return nil
+	}
+	return td.UnmarshalText([]byte(*text))
+}
+
 func isValidTrustDomainChar(c uint8) bool {
 	switch {
 	case c >= 'a' && c <= 'z':
@@ -96,6 +96,8 @@ func TestTrustDomainFromURI(t *testing.T) {
 	assertFail(&url.URL{Scheme: "SPIFFE", Host: "trustdomain"}, `scheme is missing or invalid`)
 	assertFail(parseURI("spiffe://trust$domain"), `trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`)
 	assertFail(parseURI("spiffe://trustdomain/path$"), `path segment characters are limited to letters, numbers, dots, dashes, and underscores`)
+	assertFail(parseURI("spiffe://trustdomain:8080"), `trust domain characters are limited to lowercase letters, numbers, dots, dashes, and underscores`)
+	assertFail(parseURI("spiffe://trustdomain/path?query"), `path segment characters are limited to letters, numbers, dots, dashes, and underscores`)
 }
 
 func TestTrustDomainID(t *testing.T) {
@@ -165,4 +167,32 @@ func TestTrustDomainTextUnmarshaler(t *testing.T) {
 	err = json.Unmarshal([]byte(`{"trustDomain": "trustdomain"}`), &s)
 	require.NoError(t, err)
 	require.Equal(t, "trustdomain", s.TrustDomain.String())
+
+	err = json.Unmarshal([]byte(`{"trustDomain": null}`), &s)
+	require.NoError(t, err)
+	require.Zero(t, s.TrustDomain)
+
+	err = json.Unmarshal([]byte(`{"trustDomain": 42}`), &s)
+	require.Error(t, err)
+	require.Zero(t, s.TrustDomain)
+}
+
+func BenchmarkTrustDomainFromString(b *testing.B) {
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		escapes(spiffeid.RequireTrustDomainFromString("spiffe://trustdomain/path").String())
+	}
+}
+
+func BenchmarkTrustDomainFromURI(b *testing.B) {
+	u, err := url.Parse("spiffe://trustdomain/path")
+	require.NoError(b, err)
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		td, err := spiffeid.TrustDomainFromURI(u)
+		if err != nil {
+			b.Fatal(err)
+		}
+		escapes(td.String())
+	}
 }
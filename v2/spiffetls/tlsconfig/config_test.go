@@ -2,6 +2,7 @@ package tlsconfig_test
 
 import (
 	"bytes"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
@@ -597,6 +598,12 @@ func TestMTLSHandshake(t *testing.T) {
 	svid3ID := spiffeid.RequireFromPath(td, "/client")
 	client3SVID := ca3.CreateX509SVID(svid3ID)
 
+	// SVIDs with signers that are not in-memory private keys.
+	opaqueServerSVID, err := x509svid.FromSigner(serverSVID.Certificates, opaqueSigner{serverSVID.PrivateKey})
+	require.NoError(t, err)
+	opaqueClientSVID, err := x509svid.FromSigner(clientSVID.Certificates, opaqueSigner{clientSVID.PrivateKey})
+	require.NoError(t, err)
+
 	testCases := []struct {
 		name         string
 		serverConfig *tls.Config
@@ -609,6 +616,11 @@ func TestMTLSHandshake(t *testing.T) {
 			serverConfig: tlsconfig.MTLSServerConfig(serverSVID, bundle1, tlsconfig.AuthorizeAny()),
 			clientConfig: tlsconfig.MTLSClientConfig(clientSVID, bundle1, tlsconfig.AuthorizeAny()),
 		},
+		{
+			name:         "success with opaque signers",
+			serverConfig: tlsconfig.MTLSServerConfig(opaqueServerSVID, bundle1, tlsconfig.AuthorizeAny()),
+			clientConfig: tlsconfig.MTLSClientConfig(opaqueClientSVID, bundle1, tlsconfig.AuthorizeAny()),
+		},
 		{
 			name:         "client authentication fails",
 			serverConfig: tlsconfig.MTLSServerConfig(serverSVID, bundle1, tlsconfig.AuthorizeAny()),
@@ -885,3 +897,9 @@ func (f *fakeSource) GetX509SVID() (*x509svid.SVID, error) {
 	}
 	return f.svid, nil
 }
+
+// opaqueSigner hides the concrete type of the wrapped signer, like signers
+// backed by an HSM, a TPM or a cloud KMS.
+type opaqueSigner struct {
+	crypto.Signer
+}
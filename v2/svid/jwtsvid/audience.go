@@ -0,0 +1,73 @@
+package jwtsvid
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// AudienceFromURL returns the audience identifying the service at the URL,
+// normalized as NormalizeAudience does: the scheme and host are lowercased,
+// the default port of the scheme is dropped, and so are the trailing slashes
+// of the path, the query and the fragment. For example,
+// "HTTPS://Example.org:443/api/" results in "https://example.org/api".
+func AudienceFromURL(u *url.URL) string {
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		// IPv6 addresses must be enclosed in brackets.
+		host = "[" + host + "]"
+	}
+	return scheme + "://" + host + strings.TrimRight(u.EscapedPath(), "/")
+}
+
+// AudienceFromID returns the audience identifying the workload with the
+// SPIFFE ID.
+func AudienceFromID(id spiffeid.ID) string {
+	return id.String()
+}
+
+// NormalizeAudience returns the audience normalized, so that audiences that
+// differ only in formatting compare equal. SPIFFE IDs are returned as is, and
+// absolute URLs are normalized as AudienceFromURL does. Other audiences are
+// returned unchanged.
+func NormalizeAudience(audience string) string {
+	if id, err := spiffeid.FromString(audience); err == nil {
+		return AudienceFromID(id)
+	}
+	u, err := url.Parse(audience)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		return audience
+	}
+	return AudienceFromURL(u)
+}
+
+// WithAudienceNormalization normalizes both the expected audience and the
+// 'aud' claim of JWT-SVIDs with NormalizeAudience before comparing them, so
+// that e.g. "https://example.org:443/" matches "https://example.org". The
+// audience of the returned JWT-SVID is not normalized.
+func WithAudienceNormalization() ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.normalizeAudience = true
+	})
+}
+
+func normalizeAudiences(audiences []string) []string {
+	if audiences == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(audiences))
+	for _, audience := range audiences {
+		normalized = append(normalized, NormalizeAudience(audience))
+	}
+	return normalized
+}
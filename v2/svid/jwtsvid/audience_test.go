@@ -0,0 +1,72 @@
+package jwtsvid_test
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeAudience(t *testing.T) {
+	testCases := []struct {
+		audience string
+		expected string
+	}{
+		{audience: "https://example.org", expected: "https://example.org"},
+		{audience: "HTTPS://Example.ORG", expected: "https://example.org"},
+		{audience: "https://example.org/", expected: "https://example.org"},
+		{audience: "https://example.org:443", expected: "https://example.org"},
+		{audience: "http://example.org:80/", expected: "http://example.org"},
+		{audience: "http://example.org:443", expected: "http://example.org:443"},
+		{audience: "https://example.org:8443/api//", expected: "https://example.org:8443/api"},
+		{audience: "https://example.org/API/?q=1#frag", expected: "https://example.org/API"},
+		{audience: "https://[::1]:443/", expected: "https://[::1]"},
+		{audience: "https://[::1]:8443", expected: "https://[::1]:8443"},
+		{audience: "spiffe://example.org/service", expected: "spiffe://example.org/service"},
+		{audience: "spiffe://example.org/service/", expected: "spiffe://example.org/service"},
+		{audience: "audience", expected: "audience"},
+		{audience: "/path/", expected: "/path/"},
+		{audience: "", expected: ""},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.audience, func(t *testing.T) {
+			assert.Equal(t, testCase.expected, jwtsvid.NormalizeAudience(testCase.audience))
+		})
+	}
+}
+
+func TestAudienceFromURL(t *testing.T) {
+	u, err := url.Parse("HTTPS://Example.org:443/api/?q=1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/api", jwtsvid.AudienceFromURL(u))
+}
+
+func TestAudienceFromID(t *testing.T) {
+	id := spiffeid.RequireFromString("spiffe://example.org/service")
+	assert.Equal(t, "spiffe://example.org/service", jwtsvid.AudienceFromID(id))
+}
+
+func TestWithAudienceNormalization(t *testing.T) {
+	token := generateToken(t, jwt.Claims{
+		Subject:  "spiffe://trustdomain/host",
+		Audience: []string{"HTTPS://Example.org:443/"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}, key1, "authority1")
+
+	_, err := jwtsvid.ParseInsecure(token, []string{"https://example.org"})
+	require.True(t, errors.Is(err, jwtsvid.ErrInvalidAudience))
+
+	svid, err := jwtsvid.ParseInsecure(token, []string{"https://example.org"}, jwtsvid.WithAudienceNormalization())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HTTPS://Example.org:443/"}, svid.Audience)
+
+	_, err = jwtsvid.ParseInsecure(token, []string{"https://example.org:8443"}, jwtsvid.WithAudienceNormalization())
+	require.True(t, errors.Is(err, jwtsvid.ErrInvalidAudience))
+}
@@ -0,0 +1,45 @@
+package jwtsvid
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+)
+
+// CertificateThumbprint returns the SHA-256 thumbprint of the certificate as
+// used in the 'x5t#S256' member of the 'cnf' claim of tokens bound to the
+// certificate (RFC 8705), i.e. the base64url encoded SHA-256 digest of its
+// DER encoding.
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// WithCertificateBinding requires JWT-SVIDs to be bound to the certificate,
+// e.g. the client certificate of the TLS connection the token was received
+// on, with a 'cnf' claim holding the thumbprint of the certificate in its
+// 'x5t#S256' member (RFC 8705). This prevents tokens from being replayed off
+// the channel they were issued for. An error classified as
+// ErrInvalidConfirmation is returned if the token is not bound to the
+// certificate.
+func WithCertificateBinding(cert *x509.Certificate) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.boundCert = cert
+	})
+}
+
+func checkBinding(claims map[string]interface{}, cert *x509.Certificate) error {
+	cnf, ok := claims["cnf"].(map[string]interface{})
+	if !ok {
+		return validationError(ErrInvalidConfirmation, "token missing cnf claim")
+	}
+	thumbprint, ok := cnf["x5t#S256"].(string)
+	if !ok {
+		return validationError(ErrInvalidConfirmation, "token cnf claim missing x5t#S256 member")
+	}
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(CertificateThumbprint(cert))) != 1 {
+		return validationError(ErrInvalidConfirmation, "token is not bound to the certificate")
+	}
+	return nil
+}
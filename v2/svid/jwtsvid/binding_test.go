@@ -0,0 +1,111 @@
+package jwtsvid_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCertificateBinding(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	ca := test.NewCA(t, trustDomain1)
+	bound := ca.CreateX509SVID(spiffeid.RequireFromPath(trustDomain1, "/bound")).Certificates[0]
+	other := ca.CreateX509SVID(spiffeid.RequireFromPath(trustDomain1, "/other")).Certificates[0]
+	audience := []string{"audience"}
+
+	newToken := func(cnf interface{}) string {
+		claims := map[string]interface{}{
+			"sub": spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+			"aud": audience,
+			"exp": jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}
+		if cnf != nil {
+			claims["cnf"] = cnf
+		}
+		return generateToken(t, claims, key1, "authority1")
+	}
+	boundToken := newToken(map[string]string{"x5t#S256": jwtsvid.CertificateThumbprint(bound)})
+
+	_, err := jwtsvid.ParseInsecure(boundToken, audience, jwtsvid.WithCertificateBinding(bound))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name  string
+		token string
+		cert  *x509.Certificate
+		err   string
+	}{
+		{
+			name:  "other certificate",
+			token: boundToken,
+			cert:  other,
+			err:   "jwtsvid: token is not bound to the certificate",
+		},
+		{
+			name:  "missing cnf",
+			token: newToken(nil),
+			cert:  bound,
+			err:   "jwtsvid: token missing cnf claim",
+		},
+		{
+			name:  "missing thumbprint",
+			token: newToken(map[string]string{"jkt": "thumbprint"}),
+			cert:  bound,
+			err:   "jwtsvid: token cnf claim missing x5t#S256 member",
+		},
+	}
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := jwtsvid.ParseInsecure(testCase.token, audience, jwtsvid.WithCertificateBinding(testCase.cert))
+			require.EqualError(t, err, testCase.err)
+			assert.True(t, errors.Is(err, jwtsvid.ErrInvalidConfirmation))
+		})
+	}
+}
+
+func TestMiddlewareWithTLSBinding(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	bundle1 := jwtbundle.New(trustDomain1)
+	require.NoError(t, bundle1.AddJWTAuthority("authority1", key1.Public()))
+	ca := test.NewCA(t, trustDomain1)
+	clientCert := ca.CreateX509SVID(spiffeid.RequireFromPath(trustDomain1, "/client")).Certificates[0]
+
+	token := generateToken(t, map[string]interface{}{
+		"sub": spiffeid.RequireFromPath(trustDomain1, "/client").String(),
+		"aud": []string{"audience"},
+		"exp": jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		"cnf": map[string]string{"x5t#S256": jwtsvid.CertificateThumbprint(clientCert)},
+	}, key1, "authority1")
+
+	handler := jwtsvid.Middleware(bundle1, []string{"audience"}, spiffeid.MatchAny(), jwtsvid.WithTLSBinding())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{clientCert}}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req.TLS = nil
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{ca.X509Authorities()[0]}}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
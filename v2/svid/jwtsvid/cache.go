@@ -0,0 +1,134 @@
+package jwtsvid
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRefreshMargin = 30 * time.Second
+
+// CachingSourceOption is an option used when creating a CachingSource.
+type CachingSourceOption interface {
+	applyCachingSource(config *cachingSourceConfig)
+}
+
+// WithRefreshMargin sets how long before their expiration cached JWT-SVIDs
+// are fetched again, so that callers are not handed tokens about to expire.
+// If not used, JWT-SVIDs are fetched again 30 seconds before they expire.
+func WithRefreshMargin(margin time.Duration) CachingSourceOption {
+	return cachingSourceOption(func(config *cachingSourceConfig) {
+		config.refreshMargin = margin
+	})
+}
+
+// CachingSource is a Source decorator caching the JWT-SVIDs fetched from the
+// underlying source, e.g. a workloadapi.JWTSource, per subject and audience
+// until they are about to expire. Concurrent fetches with the same
+// parameters are coalesced into a single fetch from the underlying source,
+// made with the context of the first caller, whose result, or error, is
+// shared by all the callers. Errors are not cached. It is safe for concurrent
+// use.
+type CachingSource struct {
+	source        Source
+	refreshMargin time.Duration
+
+	mtx     sync.Mutex
+	entries map[string]*SVID
+	calls   map[string]*fetchCall
+}
+
+type fetchCall struct {
+	done chan struct{}
+	svid *SVID
+	err  error
+}
+
+// NewCachingSource creates a new CachingSource fetching JWT-SVIDs from the
+// given source.
+func NewCachingSource(source Source, opts ...CachingSourceOption) *CachingSource {
+	config := &cachingSourceConfig{
+		refreshMargin: defaultRefreshMargin,
+	}
+	for _, opt := range opts {
+		opt.applyCachingSource(config)
+	}
+
+	return &CachingSource{
+		source:        source,
+		refreshMargin: config.refreshMargin,
+		entries:       make(map[string]*SVID),
+		calls:         make(map[string]*fetchCall),
+	}
+}
+
+// FetchJWTSVID returns a cached JWT-SVID for the given parameters, fetching
+// it from the underlying source if there is none or if it is about to
+// expire. It implements the Source interface.
+func (s *CachingSource) FetchJWTSVID(ctx context.Context, params Params) (*SVID, error) {
+	key := cacheKey(params)
+
+	s.mtx.Lock()
+	if svid, ok := s.entries[key]; ok && time.Until(svid.Expiry) > s.refreshMargin {
+		s.mtx.Unlock()
+		return svid, nil
+	}
+	call, ok := s.calls[key]
+	if !ok {
+		call = &fetchCall{done: make(chan struct{})}
+		s.calls[key] = call
+		go s.fetch(ctx, key, params, call)
+	}
+	s.mtx.Unlock()
+
+	select {
+	case <-call.done:
+		return call.svid, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (s *CachingSource) fetch(ctx context.Context, key string, params Params, call *fetchCall) {
+	svid, err := s.source.FetchJWTSVID(ctx, params)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.calls, key)
+	call.svid, call.err = svid, err
+	close(call.done)
+	if err != nil {
+		return
+	}
+
+	// Drop the expired JWT-SVIDs, so that the cache does not grow with
+	// parameters that are no longer used.
+	now := time.Now()
+	for k, cached := range s.entries {
+		if !cached.Expiry.After(now) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = svid
+}
+
+// cacheKey returns the key of the JWT-SVIDs fetched with the parameters.
+// Extra audiences are sorted, since their order does not matter.
+func cacheKey(params Params) string {
+	extraAudiences := append([]string(nil), params.ExtraAudiences...)
+	sort.Strings(extraAudiences)
+	return strings.Join(append([]string{params.Subject.String(), params.Audience}, extraAudiences...), "\x00")
+}
+
+type cachingSourceConfig struct {
+	refreshMargin time.Duration
+}
+
+type cachingSourceOption func(config *cachingSourceConfig)
+
+func (fn cachingSourceOption) applyCachingSource(config *cachingSourceConfig) {
+	fn(config)
+}
@@ -0,0 +1,149 @@
+package jwtsvid_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingSource(t *testing.T) {
+	source := newFakeSource(t, time.Hour)
+	cache := jwtsvid.NewCachingSource(source)
+	ctx := context.Background()
+	td := spiffeid.RequireTrustDomainFromString("trustdomain")
+
+	params := jwtsvid.Params{Audience: "audience1", ExtraAudiences: []string{"b", "a"}}
+	svid1, err := cache.FetchJWTSVID(ctx, params)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"audience1", "b", "a"}, svid1.Audience)
+
+	// The order of the extra audiences does not matter.
+	svid2, err := cache.FetchJWTSVID(ctx, jwtsvid.Params{Audience: "audience1", ExtraAudiences: []string{"a", "b"}})
+	require.NoError(t, err)
+	assert.Same(t, svid1, svid2)
+	assert.Equal(t, int32(1), source.calls())
+
+	// Other audiences and subjects are cached separately.
+	_, err = cache.FetchJWTSVID(ctx, jwtsvid.Params{Audience: "audience2"})
+	require.NoError(t, err)
+	_, err = cache.FetchJWTSVID(ctx, jwtsvid.Params{Audience: "audience1", Subject: spiffeid.RequireFromPath(td, "/other")})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), source.calls())
+
+	// Errors are not cached.
+	source.setErr(errors.New("oh no"))
+	_, err = cache.FetchJWTSVID(ctx, jwtsvid.Params{Audience: "audience3"})
+	require.EqualError(t, err, "oh no")
+	source.setErr(nil)
+	_, err = cache.FetchJWTSVID(ctx, jwtsvid.Params{Audience: "audience3"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(5), source.calls())
+}
+
+func TestCachingSourceRefreshMargin(t *testing.T) {
+	source := newFakeSource(t, time.Hour)
+	cache := jwtsvid.NewCachingSource(source, jwtsvid.WithRefreshMargin(2*time.Hour))
+	params := jwtsvid.Params{Audience: "audience"}
+
+	svid1, err := cache.FetchJWTSVID(context.Background(), params)
+	require.NoError(t, err)
+	svid2, err := cache.FetchJWTSVID(context.Background(), params)
+	require.NoError(t, err)
+	assert.NotSame(t, svid1, svid2)
+	assert.Equal(t, int32(2), source.calls())
+}
+
+func TestCachingSourceCoalescesFetches(t *testing.T) {
+	source := newFakeSource(t, time.Hour)
+	source.release = make(chan struct{})
+	cache := jwtsvid.NewCachingSource(source)
+	params := jwtsvid.Params{Audience: "audience"}
+
+	var wg sync.WaitGroup
+	svids := make([]*jwtsvid.SVID, 5)
+	for i := range svids {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svid, err := cache.FetchJWTSVID(context.Background(), params)
+			assert.NoError(t, err)
+			svids[i] = svid
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(source.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), source.calls())
+	for _, svid := range svids {
+		assert.Same(t, svids[0], svid)
+	}
+
+	// Callers stop waiting when their context is done.
+	source.release = make(chan struct{})
+	defer close(source.release)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cache.FetchJWTSVID(ctx, jwtsvid.Params{Audience: "other"})
+	require.Equal(t, context.Canceled, err)
+}
+
+type fakeSource struct {
+	tb      testing.TB
+	ttl     time.Duration
+	release chan struct{}
+	n       int32
+
+	mtx sync.Mutex
+	err error
+}
+
+func newFakeSource(tb testing.TB, ttl time.Duration) *fakeSource {
+	return &fakeSource{tb: tb, ttl: ttl}
+}
+
+func (s *fakeSource) FetchJWTSVID(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error) {
+	atomic.AddInt32(&s.n, 1)
+	if s.release != nil {
+		<-s.release
+	}
+
+	s.mtx.Lock()
+	err := s.err
+	s.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	subject := params.Subject
+	if subject.IsZero() {
+		subject = spiffeid.RequireFromString("spiffe://trustdomain/workload")
+	}
+	audience := append([]string{params.Audience}, params.ExtraAudiences...)
+	token := generateToken(s.tb, jwt.Claims{
+		Subject:  subject.String(),
+		Audience: audience,
+		Expiry:   jwt.NewNumericDate(time.Now().Add(s.ttl)),
+	}, key1, "authority1")
+	return jwtsvid.ParseInsecure(token, audience)
+}
+
+func (s *fakeSource) calls() int32 {
+	return atomic.LoadInt32(&s.n)
+}
+
+func (s *fakeSource) setErr(err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.err = err
+}
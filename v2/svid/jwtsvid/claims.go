@@ -0,0 +1,52 @@
+package jwtsvid
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// registeredClaims are the claims registered by RFC 7519, which are ignored
+// by DecodeClaimsStrict when looking for unknown claims.
+var registeredClaims = []string{"iss", "sub", "aud", "exp", "nbf", "iat", "jti"}
+
+// DecodeClaims decodes the claims of the JWT-SVID into v, which must be a
+// pointer to a value JSON claims can be decoded into, e.g. a struct with
+// json field tags, so that applications can consume claims beyond the ones
+// of the SVID struct. Claims without a matching field are ignored.
+func (svid *SVID) DecodeClaims(v interface{}) error {
+	return svid.decodeClaims(v, false)
+}
+
+// DecodeClaimsStrict decodes the claims of the JWT-SVID into v like
+// DecodeClaims does, but fails if a claim has no matching field. The claims
+// registered by RFC 7519 (iss, sub, aud, exp, nbf, iat and jti) are exempt,
+// since they are already validated and exposed by the SVID.
+func (svid *SVID) DecodeClaimsStrict(v interface{}) error {
+	return svid.decodeClaims(v, true)
+}
+
+func (svid *SVID) decodeClaims(v interface{}, strict bool) error {
+	claims := svid.Claims
+	if strict {
+		claims = make(map[string]interface{}, len(svid.Claims))
+		for name, value := range svid.Claims {
+			claims[name] = value
+		}
+		for _, name := range registeredClaims {
+			delete(claims, name)
+		}
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return jwtsvidErr.New("unable to encode claims: %v", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return jwtsvidErr.New("unable to decode claims: %v", err)
+	}
+	return nil
+}
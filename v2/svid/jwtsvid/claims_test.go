@@ -0,0 +1,55 @@
+package jwtsvid_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeClaims(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	claims := map[string]interface{}{
+		"sub":    spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+		"aud":    []string{"audience"},
+		"exp":    jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		"tenant": "acme",
+		"roles":  []string{"reader", "writer"},
+		"level":  3,
+	}
+	token := generateToken(t, claims, key1, "authority1")
+	svid, err := jwtsvid.ParseInsecure(token, []string{"audience"})
+	require.NoError(t, err)
+
+	type partialClaims struct {
+		Tenant string `json:"tenant"`
+	}
+	type customClaims struct {
+		Tenant string   `json:"tenant"`
+		Roles  []string `json:"roles"`
+		Level  int      `json:"level"`
+	}
+
+	var partial partialClaims
+	require.NoError(t, svid.DecodeClaims(&partial))
+	assert.Equal(t, partialClaims{Tenant: "acme"}, partial)
+
+	err = svid.DecodeClaimsStrict(&partial)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "jwtsvid: unable to decode claims: json: unknown field")
+
+	var custom customClaims
+	require.NoError(t, svid.DecodeClaimsStrict(&custom))
+	assert.Equal(t, customClaims{Tenant: "acme", Roles: []string{"reader", "writer"}, Level: 3}, custom)
+
+	var mismatched struct {
+		Tenant int `json:"tenant"`
+	}
+	err = svid.DecodeClaims(&mismatched)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "jwtsvid: unable to decode claims: json: cannot unmarshal string")
+}
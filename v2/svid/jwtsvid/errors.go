@@ -0,0 +1,86 @@
+package jwtsvid
+
+import (
+	"errors"
+)
+
+var (
+	// ErrMalformed classifies errors caused by a token that is not a well
+	// formed JWT-SVID, e.g. because it cannot be parsed or lacks a required
+	// header or claim.
+	ErrMalformed = errors.New("token is malformed")
+
+	// ErrUnsupportedAlgorithm classifies errors caused by a token signed with
+	// an algorithm that is not supported by JWT-SVIDs or not allowed by
+	// WithAllowedAlgorithms.
+	ErrUnsupportedAlgorithm = errors.New("token signature algorithm is not supported")
+
+	// ErrUntrustedTrustDomain classifies errors caused by a token for a trust
+	// domain without a bundle in the bundle source.
+	ErrUntrustedTrustDomain = errors.New("token trust domain is not trusted")
+
+	// ErrUnknownKeyID classifies errors caused by a token signed with a key
+	// that is not a JWT authority of the bundle of its trust domain.
+	ErrUnknownKeyID = errors.New("token key id is unknown")
+
+	// ErrInvalidSignature classifies errors caused by a token whose signature
+	// cannot be verified with the JWT authority matching its key id.
+	ErrInvalidSignature = errors.New("token signature is invalid")
+
+	// ErrExpired classifies errors caused by an expired token.
+	ErrExpired = errors.New("token has expired")
+
+	// ErrNotValidYet classifies errors caused by a token that is not valid
+	// yet, or that was issued in the future.
+	ErrNotValidYet = errors.New("token is not valid yet")
+
+	// ErrInvalidAudience classifies errors caused by a token that is not
+	// intended for the expected audience.
+	ErrInvalidAudience = errors.New("token audience is invalid")
+
+	// ErrPolicyViolation classifies errors caused by a token that does not
+	// meet the requirements set with WithMaxTTL, WithStrictProfile or
+	// WithRequiredType.
+	ErrPolicyViolation = errors.New("token violates the validation policy")
+
+	// ErrUnauthorized classifies errors caused by a valid token whose SPIFFE
+	// ID is rejected by the matcher set with WithSubjectMatcher.
+	ErrUnauthorized = errors.New("token subject is not authorized")
+
+	// ErrInvalidConfirmation classifies errors caused by a token that is not
+	// bound to the certificate set with WithCertificateBinding.
+	ErrInvalidConfirmation = errors.New("token confirmation is invalid")
+)
+
+// ValidationError is returned from ParseAndValidate and ParseInsecure when a
+// token is rejected. It can be matched against ErrMalformed,
+// ErrUnsupportedAlgorithm, ErrUntrustedTrustDomain, ErrUnknownKeyID,
+// ErrInvalidSignature, ErrExpired, ErrNotValidYet, ErrInvalidAudience,
+// ErrPolicyViolation, ErrUnauthorized or ErrInvalidConfirmation using
+// errors.Is, and it still wraps the underlying error.
+type ValidationError struct {
+	// Class is the classification of the error.
+	Class error
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the target is the classification of the error.
+func (e *ValidationError) Is(target error) bool {
+	return e.Class == target
+}
+
+func validationError(class error, format string, args ...interface{}) error {
+	return &ValidationError{Class: class, Err: jwtsvidErr.New(format, args...)}
+}
@@ -0,0 +1,94 @@
+package jwtsvid_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationErrors(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	bundle1 := jwtbundle.New(trustDomain1)
+	require.NoError(t, bundle1.AddJWTAuthority("authority1", key1.Public()))
+	now := time.Now()
+
+	newToken := func(tb testing.TB, subject string, notBefore, expiry time.Time, keyID string) string {
+		claims := jwt.Claims{
+			Subject:   subject,
+			Expiry:    jwt.NewNumericDate(expiry),
+			NotBefore: jwt.NewNumericDate(notBefore),
+			Audience:  []string{"audience"},
+		}
+		return generateToken(tb, claims, key1, keyID)
+	}
+	subject := spiffeid.RequireFromPath(trustDomain1, "/host").String()
+
+	testCases := []struct {
+		name     string
+		token    string
+		audience []string
+		class    error
+	}{
+		{
+			name:  "malformed",
+			token: "invalid token",
+			class: jwtsvid.ErrMalformed,
+		},
+		{
+			name:  "unsupported algorithm",
+			token: hs256Token,
+			class: jwtsvid.ErrUnsupportedAlgorithm,
+		},
+		{
+			name:  "untrusted trust domain",
+			token: newToken(t, "spiffe://other/host", now, now.Add(time.Hour), "authority1"),
+			class: jwtsvid.ErrUntrustedTrustDomain,
+		},
+		{
+			name:  "unknown key id",
+			token: newToken(t, subject, now, now.Add(time.Hour), "unknown"),
+			class: jwtsvid.ErrUnknownKeyID,
+		},
+		{
+			name:  "invalid signature",
+			token: generateToken(t, jwt.Claims{Subject: subject, Expiry: jwt.NewNumericDate(now.Add(time.Hour))}, key2, "authority1"),
+			class: jwtsvid.ErrInvalidSignature,
+		},
+		{
+			name:  "expired",
+			token: newToken(t, subject, now.Add(-time.Hour), now.Add(-10*time.Minute), "authority1"),
+			class: jwtsvid.ErrExpired,
+		},
+		{
+			name:  "not valid yet",
+			token: newToken(t, subject, now.Add(10*time.Minute), now.Add(time.Hour), "authority1"),
+			class: jwtsvid.ErrNotValidYet,
+		},
+		{
+			name:     "invalid audience",
+			token:    newToken(t, subject, now, now.Add(time.Hour), "authority1"),
+			audience: []string{"other"},
+			class:    jwtsvid.ErrInvalidAudience,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			_, err := jwtsvid.ParseAndValidate(testCase.token, bundle1, testCase.audience)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, testCase.class), "unexpected error class: %v", err)
+
+			var validationErr *jwtsvid.ValidationError
+			require.True(t, errors.As(err, &validationErr))
+			assert.Equal(t, testCase.class, validationErr.Class)
+		})
+	}
+}
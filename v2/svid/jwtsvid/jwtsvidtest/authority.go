@@ -0,0 +1,240 @@
+// Package jwtsvidtest provides utilities to mint JWT-SVIDs in tests, so that
+// services can test their token handling without a SPIFFE implementation.
+package jwtsvidtest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/cryptosigner"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+const defaultTTL = time.Hour
+
+// AuthorityOption is an option used when creating an Authority.
+type AuthorityOption func(*Authority)
+
+// WithKeyID sets the key ID of the authority. If not used, a random key ID is
+// generated.
+func WithKeyID(keyID string) AuthorityOption {
+	return func(a *Authority) {
+		a.keyID = keyID
+	}
+}
+
+// WithSigner sets the key of the authority, which must be an ECDSA (P-256,
+// P-384 or P-521) or RSA key. If not used, a P-256 key is generated.
+func WithSigner(signer crypto.Signer) AuthorityOption {
+	return func(a *Authority) {
+		a.signer = signer
+	}
+}
+
+// MintOption is an option used when minting JWT-SVIDs.
+type MintOption func(*mintConfig)
+
+// WithTTL sets the lifetime of minted JWT-SVIDs, from the time they are
+// minted. It can be negative to mint expired JWT-SVIDs. If not used, minted
+// JWT-SVIDs expire after an hour.
+func WithTTL(ttl time.Duration) MintOption {
+	return func(c *mintConfig) {
+		c.ttl = ttl
+	}
+}
+
+// WithClaims adds claims to minted JWT-SVIDs, overriding the ones set by
+// default (sub, aud, iat and exp) if they have the same name.
+func WithClaims(claims map[string]interface{}) MintOption {
+	return func(c *mintConfig) {
+		for name, value := range claims {
+			c.claims[name] = value
+		}
+	}
+}
+
+// WithHeader adds a header parameter to minted JWT-SVIDs, e.g. "typ".
+func WithHeader(name string, value interface{}) MintOption {
+	return func(c *mintConfig) {
+		c.headers[jose.HeaderKey(name)] = value
+	}
+}
+
+type mintConfig struct {
+	ttl     time.Duration
+	claims  map[string]interface{}
+	headers map[jose.HeaderKey]interface{}
+}
+
+// Authority is a JWT authority of a trust domain minting JWT-SVIDs in tests.
+type Authority struct {
+	tb          testing.TB
+	trustDomain spiffeid.TrustDomain
+	keyID       string
+	signer      crypto.Signer
+	algorithm   jose.SignatureAlgorithm
+}
+
+// NewAuthority creates a new authority for the trust domain. The test fails
+// if the authority cannot be created.
+func NewAuthority(tb testing.TB, trustDomain spiffeid.TrustDomain, opts ...AuthorityOption) *Authority {
+	tb.Helper()
+
+	a := &Authority{
+		tb:          tb,
+		trustDomain: trustDomain,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.keyID == "" {
+		a.keyID = randomKeyID(tb)
+	}
+	if a.signer == nil {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			tb.Fatalf("failed to generate key: %v", err)
+		}
+		a.signer = key
+	}
+
+	algorithm, err := signatureAlgorithm(a.signer.Public())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	a.algorithm = algorithm
+	return a
+}
+
+// TrustDomain returns the trust domain of the authority.
+func (a *Authority) TrustDomain() spiffeid.TrustDomain {
+	return a.trustDomain
+}
+
+// KeyID returns the key ID of the authority.
+func (a *Authority) KeyID() string {
+	return a.keyID
+}
+
+// Bundle returns a JWT bundle of the trust domain holding the authority, to
+// validate the JWT-SVIDs it mints.
+func (a *Authority) Bundle() *jwtbundle.Bundle {
+	return jwtbundle.FromJWTAuthorities(a.trustDomain, map[string]crypto.PublicKey{
+		a.keyID: a.signer.Public(),
+	})
+}
+
+// Sign signs arbitrary claims, e.g. a map or a struct with JSON field tags,
+// and returns the token. Unlike Mint, no claim is set by default, so that
+// tokens that are not valid JWT-SVIDs can be minted. The test fails if the
+// claims cannot be signed.
+func (a *Authority) Sign(claims interface{}, opts ...MintOption) string {
+	a.tb.Helper()
+
+	config := newMintConfig(opts)
+	signerOpts := new(jose.SignerOptions).WithType("JWT")
+	for name, value := range config.headers {
+		signerOpts.WithHeader(name, value)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: a.algorithm,
+		Key: jose.JSONWebKey{
+			Key:   cryptosigner.Opaque(a.signer),
+			KeyID: a.keyID,
+		},
+	}, signerOpts)
+	if err != nil {
+		a.tb.Fatalf("failed to create signer: %v", err)
+	}
+
+	builder := jwt.Signed(signer).Claims(claims)
+	if len(config.claims) > 0 {
+		builder = builder.Claims(config.claims)
+	}
+	token, err := builder.CompactSerialize()
+	if err != nil {
+		a.tb.Fatalf("failed to sign claims: %v", err)
+	}
+	return token
+}
+
+// Mint mints a JWT-SVID for the SPIFFE ID and the audience, and returns the
+// token. The SPIFFE ID does not need to be a member of the trust domain of
+// the authority, so that JWT-SVIDs signed by the wrong authority can be
+// minted. The test fails if the JWT-SVID cannot be minted.
+func (a *Authority) Mint(id spiffeid.ID, audience []string, opts ...MintOption) string {
+	a.tb.Helper()
+
+	config := newMintConfig(opts)
+	now := time.Now()
+	claims := map[string]interface{}{
+		"sub": id.String(),
+		"aud": audience,
+		"iat": jwt.NewNumericDate(now),
+		"exp": jwt.NewNumericDate(now.Add(config.ttl)),
+	}
+	return a.Sign(claims, opts...)
+}
+
+// MintSVID mints a JWT-SVID like Mint does, and returns it parsed. The test
+// fails if the JWT-SVID cannot be minted or parsed, e.g. because it has
+// expired.
+func (a *Authority) MintSVID(id spiffeid.ID, audience []string, opts ...MintOption) *jwtsvid.SVID {
+	a.tb.Helper()
+
+	svid, err := jwtsvid.ParseInsecure(a.Mint(id, audience, opts...), audience)
+	if err != nil {
+		a.tb.Fatalf("failed to parse minted JWT-SVID: %v", err)
+	}
+	return svid
+}
+
+func newMintConfig(opts []MintOption) *mintConfig {
+	config := &mintConfig{
+		ttl:     defaultTTL,
+		claims:  make(map[string]interface{}),
+		headers: make(map[jose.HeaderKey]interface{}),
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+func signatureAlgorithm(publicKey crypto.PublicKey) (jose.SignatureAlgorithm, error) {
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		return jose.RS256, nil
+	case *ecdsa.PublicKey:
+		switch publicKey.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+		return "", fmt.Errorf("unsupported curve %s", publicKey.Curve.Params().Name)
+	default:
+		return "", fmt.Errorf("unsupported key type %T", publicKey)
+	}
+}
+
+func randomKeyID(tb testing.TB) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		tb.Fatalf("failed to generate key ID: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
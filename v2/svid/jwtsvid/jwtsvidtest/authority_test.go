@@ -0,0 +1,60 @@
+package jwtsvidtest_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid/jwtsvidtest"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthority(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain.test")
+	id := spiffeid.RequireFromPath(td, "/workload")
+	authority := jwtsvidtest.NewAuthority(t, td)
+	assert.Equal(t, td, authority.TrustDomain())
+	assert.Len(t, authority.KeyID(), 32)
+
+	bundle := authority.Bundle()
+	assert.Equal(t, td, bundle.TrustDomain())
+	assert.True(t, bundle.HasJWTAuthority(authority.KeyID()))
+
+	token := authority.Mint(id, []string{"audience"}, jwtsvidtest.WithClaims(map[string]interface{}{"tenant": "acme"}))
+	svid, err := jwtsvid.ParseAndValidate(token, bundle, []string{"audience"}, jwtsvid.WithAllowedAlgorithms("ES256"))
+	require.NoError(t, err)
+	assert.Equal(t, id, svid.ID)
+	assert.Equal(t, "acme", svid.Claims["tenant"])
+	assert.WithinDuration(t, time.Now().Add(time.Hour), svid.Expiry, 5*time.Second)
+
+	expired := authority.Mint(id, []string{"audience"}, jwtsvidtest.WithTTL(-time.Hour))
+	_, err = jwtsvid.ParseAndValidate(expired, bundle, []string{"audience"})
+	assert.True(t, errors.Is(err, jwtsvid.ErrExpired))
+
+	svid = authority.MintSVID(id, []string{"audience"}, jwtsvidtest.WithTTL(time.Minute))
+	assert.WithinDuration(t, time.Now().Add(time.Minute), svid.Expiry, 5*time.Second)
+}
+
+func TestAuthoritySign(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain.test")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	authority := jwtsvidtest.NewAuthority(t, td, jwtsvidtest.WithKeyID("rsa"), jwtsvidtest.WithSigner(key))
+	assert.Equal(t, "rsa", authority.KeyID())
+
+	token := authority.Sign(jwt.Claims{Subject: "not a SPIFFE ID"}, jwtsvidtest.WithHeader("typ", "at+jwt"))
+	tok, err := jwt.ParseSigned(token)
+	require.NoError(t, err)
+	assert.Equal(t, "RS256", tok.Headers[0].Algorithm)
+	assert.Equal(t, "rsa", tok.Headers[0].KeyID)
+	assert.Equal(t, "at+jwt", tok.Headers[0].ExtraHeaders["typ"])
+
+	_, err = jwtsvid.ParseAndValidate(token, authority.Bundle(), nil)
+	assert.True(t, errors.Is(err, jwtsvid.ErrMalformed))
+}
@@ -0,0 +1,58 @@
+package jwtsvid
+
+import (
+	"errors"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// ValidationMetrics describes the validation of a JWT-SVID.
+type ValidationMetrics struct {
+	// TrustDomain is the trust domain whose bundle is used to verify the
+	// token, i.e. the trust domain of its SPIFFE ID unless mapped otherwise
+	// with WithIssuerTrustDomains or WithKeyIDTrustDomains. It is zero if the
+	// token was rejected before the trust domain was determined.
+	TrustDomain spiffeid.TrustDomain
+
+	// Duration is how long the validation took.
+	Duration time.Duration
+
+	// Class is the classification of the error, e.g. ErrExpired, if the
+	// token was rejected with a ValidationError. It is nil if the token was
+	// accepted.
+	Class error
+
+	// Err is the error rejecting the token, if any.
+	Err error
+}
+
+// Metrics receives measurements from JWT-SVID validation. The methods are
+// called synchronously and should return quickly.
+type Metrics interface {
+	// TokenValidated is called after each validation, whether the token was
+	// accepted or not.
+	TokenValidated(ValidationMetrics)
+}
+
+// WithMetrics provides the Metrics that receive measurements from the
+// validation.
+func WithMetrics(metrics Metrics) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.metrics = metrics
+	})
+}
+
+func reportValidation(metrics Metrics, trustDomain spiffeid.TrustDomain, start time.Time, err error) {
+	var class error
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		class = validationErr.Class
+	}
+	metrics.TokenValidated(ValidationMetrics{
+		TrustDomain: trustDomain,
+		Duration:    time.Since(start),
+		Class:       class,
+		Err:         err,
+	})
+}
@@ -0,0 +1,58 @@
+package jwtsvid_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMetrics(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	bundle1 := jwtbundle.New(trustDomain1)
+	require.NoError(t, bundle1.AddJWTAuthority("authority1", key1.Public()))
+	token := generateToken(t, jwt.Claims{
+		Subject:  spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+		Audience: []string{"audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}, key1, "authority1")
+
+	metrics := &fakeMetrics{}
+	_, err := jwtsvid.ParseAndValidate(token, bundle1, []string{"audience"}, jwtsvid.WithMetrics(metrics))
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseAndValidate(token, bundle1, []string{"other"}, jwtsvid.WithMetrics(metrics))
+	require.Error(t, err)
+	_, err = jwtsvid.ParseInsecure("invalid token", nil, jwtsvid.WithMetrics(metrics))
+	require.Error(t, err)
+
+	require.Len(t, metrics.validations, 3)
+
+	accepted := metrics.validations[0]
+	assert.Equal(t, trustDomain1, accepted.TrustDomain)
+	assert.NoError(t, accepted.Err)
+	assert.Nil(t, accepted.Class)
+	assert.Greater(t, int64(accepted.Duration), int64(0))
+
+	rejected := metrics.validations[1]
+	assert.Equal(t, trustDomain1, rejected.TrustDomain)
+	assert.Equal(t, jwtsvid.ErrInvalidAudience, rejected.Class)
+	assert.Error(t, rejected.Err)
+
+	malformed := metrics.validations[2]
+	assert.True(t, malformed.TrustDomain.IsZero())
+	assert.Equal(t, jwtsvid.ErrMalformed, malformed.Class)
+	assert.EqualError(t, malformed.Err, "jwtsvid: unable to parse JWT token")
+}
+
+type fakeMetrics struct {
+	validations []jwtsvid.ValidationMetrics
+}
+
+func (m *fakeMetrics) TokenValidated(validation jwtsvid.ValidationMetrics) {
+	m.validations = append(m.validations, validation)
+}
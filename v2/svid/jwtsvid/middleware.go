@@ -0,0 +1,142 @@
+package jwtsvid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// MiddlewareOption is an option used when creating HTTP middleware with
+// Middleware.
+type MiddlewareOption interface {
+	applyMiddleware(config *middlewareConfig)
+}
+
+// WithValidateOptions sets the options used to validate the JWT-SVIDs of the
+// requests.
+func WithValidateOptions(opts ...ValidateOption) MiddlewareOption {
+	return middlewareOption(func(config *middlewareConfig) {
+		config.validateOpts = opts
+	})
+}
+
+// WithErrorHandler sets the function called to respond to requests that
+// fail authentication or authorization, instead of the default responses.
+// The status code is the one the default responses use, i.e. 401 when the
+// request has no valid JWT-SVID and 403 when the SPIFFE ID of the JWT-SVID
+// is not authorized.
+func WithErrorHandler(handler func(w http.ResponseWriter, r *http.Request, statusCode int, err error)) MiddlewareOption {
+	return middlewareOption(func(config *middlewareConfig) {
+		config.errorHandler = handler
+	})
+}
+
+// WithTLSBinding requires the JWT-SVIDs of the requests to be bound to the
+// client certificate of the TLS connection they are received on (see
+// WithCertificateBinding). Requests without a client certificate are
+// rejected.
+func WithTLSBinding() MiddlewareOption {
+	return middlewareOption(func(config *middlewareConfig) {
+		config.tlsBinding = true
+	})
+}
+
+// Middleware returns HTTP middleware authenticating requests with JWT-SVIDs
+// sent as bearer tokens in the Authorization header. The JWT-SVIDs are
+// validated against the bundle source and the audience as ParseAndValidate
+// does, and their SPIFFE IDs must be authorized by the matcher. The matcher
+// is required; use spiffeid.MatchAny to authorize any SPIFFE ID. Middleware
+// panics if the matcher is nil. The JWT-SVID of authorized
+// requests is added to the request context and can be retrieved with
+// SVIDFromContext. Other requests are answered with a 401 or 403 status code
+// without calling the next handler.
+func Middleware(bundles jwtbundle.Source, audience []string, matcher spiffeid.Matcher, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	if matcher == nil {
+		panic(jwtsvidErr.New("middleware matcher is required"))
+	}
+
+	config := &middlewareConfig{
+		errorHandler: defaultErrorHandler,
+	}
+	for _, opt := range opts {
+		opt.applyMiddleware(config)
+	}
+	validateOpts := append(config.validateOpts[:len(config.validateOpts):len(config.validateOpts)], WithSubjectMatcher(matcher))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := BearerToken(r)
+			if !ok {
+				config.errorHandler(w, r, http.StatusUnauthorized, jwtsvidErr.New("missing bearer token"))
+				return
+			}
+			requestOpts := validateOpts
+			if config.tlsBinding {
+				if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+					config.errorHandler(w, r, http.StatusUnauthorized, jwtsvidErr.New("missing client certificate to bind the token to"))
+					return
+				}
+				requestOpts = append(validateOpts[:len(validateOpts):len(validateOpts)], WithCertificateBinding(r.TLS.PeerCertificates[0]))
+			}
+			svid, err := ParseAndValidate(token, bundles, audience, requestOpts...)
+			switch {
+			case errors.Is(err, ErrUnauthorized):
+				config.errorHandler(w, r, http.StatusForbidden, err)
+				return
+			case err != nil:
+				config.errorHandler(w, r, http.StatusUnauthorized, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(ContextWithSVID(r.Context(), svid)))
+		})
+	}
+}
+
+// BearerToken returns the bearer token of the Authorization header of the
+// request, if any.
+func BearerToken(r *http.Request) (string, bool) {
+	fields := strings.Fields(r.Header.Get("Authorization"))
+	if len(fields) != 2 || !strings.EqualFold(fields[0], "Bearer") {
+		return "", false
+	}
+	return fields[1], true
+}
+
+type svidKey struct{}
+
+// ContextWithSVID returns a copy of the context holding the JWT-SVID, as
+// the middleware returned by Middleware does for authorized requests.
+func ContextWithSVID(ctx context.Context, svid *SVID) context.Context {
+	return context.WithValue(ctx, svidKey{}, svid)
+}
+
+// SVIDFromContext returns the JWT-SVID held by the context, e.g. the one of
+// the caller added by the middleware returned by Middleware. If there is
+// none, the boolean is false.
+func SVIDFromContext(ctx context.Context) (*SVID, bool) {
+	svid, ok := ctx.Value(svidKey{}).(*SVID)
+	return svid, ok
+}
+
+func defaultErrorHandler(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+	if statusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	}
+	http.Error(w, http.StatusText(statusCode), statusCode)
+}
+
+type middlewareConfig struct {
+	validateOpts []ValidateOption
+	errorHandler func(w http.ResponseWriter, r *http.Request, statusCode int, err error)
+	tlsBinding   bool
+}
+
+type middlewareOption func(config *middlewareConfig)
+
+func (fn middlewareOption) applyMiddleware(config *middlewareConfig) {
+	fn(config)
+}
@@ -0,0 +1,125 @@
+package jwtsvid_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	bundle1 := jwtbundle.New(trustDomain1)
+	require.NoError(t, bundle1.AddJWTAuthority("authority1", key1.Public()))
+	allowed := spiffeid.RequireFromPath(trustDomain1, "/allowed")
+	denied := spiffeid.RequireFromPath(trustDomain1, "/denied")
+
+	newToken := func(id spiffeid.ID, audience string) string {
+		return generateToken(t, jwt.Claims{
+			Subject:  id.String(),
+			Audience: []string{audience},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}, key1, "authority1")
+	}
+
+	handler := jwtsvid.Middleware(bundle1, []string{"audience"}, spiffeid.MatchID(allowed))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		svid, ok := jwtsvid.SVIDFromContext(r.Context())
+		require.True(t, ok)
+		_, _ = w.Write([]byte(svid.ID.String()))
+	}))
+
+	testCases := []struct {
+		name          string
+		authorization string
+		statusCode    int
+		body          string
+	}{
+		{
+			name:          "authorized",
+			authorization: "Bearer " + newToken(allowed, "audience"),
+			statusCode:    http.StatusOK,
+			body:          allowed.String(),
+		},
+		{
+			name:          "case insensitive scheme",
+			authorization: "bearer " + newToken(allowed, "audience"),
+			statusCode:    http.StatusOK,
+			body:          allowed.String(),
+		},
+		{
+			name:       "missing token",
+			statusCode: http.StatusUnauthorized,
+			body:       "Unauthorized\n",
+		},
+		{
+			name:          "unsupported scheme",
+			authorization: "Basic dXNlcjpwYXNz",
+			statusCode:    http.StatusUnauthorized,
+			body:          "Unauthorized\n",
+		},
+		{
+			name:          "invalid token",
+			authorization: "Bearer " + newToken(allowed, "other"),
+			statusCode:    http.StatusUnauthorized,
+			body:          "Unauthorized\n",
+		},
+		{
+			name:          "unauthorized ID",
+			authorization: "Bearer " + newToken(denied, "audience"),
+			statusCode:    http.StatusForbidden,
+			body:          "Forbidden\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if testCase.authorization != "" {
+				req.Header.Set("Authorization", testCase.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, testCase.statusCode, rec.Code)
+			assert.Equal(t, testCase.body, rec.Body.String())
+			if testCase.statusCode == http.StatusUnauthorized {
+				assert.Equal(t, `Bearer error="invalid_token"`, rec.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestMiddlewareErrorHandler(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	var gotStatus int
+	var gotErr error
+	handler := jwtsvid.Middleware(jwtbundle.New(trustDomain1), []string{"audience"}, spiffeid.MatchAny(),
+		jwtsvid.WithErrorHandler(func(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+			gotStatus, gotErr = statusCode, err
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "next handler called")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusTeapot, rec.Code)
+	assert.Equal(t, http.StatusUnauthorized, gotStatus)
+	assert.EqualError(t, gotErr, "jwtsvid: missing bearer token")
+}
+
+func TestMiddlewareRequiresMatcher(t *testing.T) {
+	bundle := jwtbundle.New(spiffeid.RequireTrustDomainFromString("trustdomain"))
+	assert.PanicsWithError(t, "jwtsvid: middleware matcher is required", func() {
+		jwtsvid.Middleware(bundle, []string{"audience"}, nil)
+	})
+}
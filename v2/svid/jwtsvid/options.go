@@ -0,0 +1,140 @@
+package jwtsvid
+
+import (
+	"crypto/x509"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// ValidateOption is an option used when parsing and validating JWT-SVIDs.
+type ValidateOption interface {
+	applyValidate(config *validateConfig)
+}
+
+// WithAllowedAlgorithms restricts the signature algorithms accepted for
+// JWT-SVIDs to the given ones, e.g. jose.ES256 and jose.RS256. Tokens signed
+// with any other algorithm are rejected before their signature is verified.
+// Algorithms not supported by JWT-SVIDs are always rejected. If not used, all
+// the supported algorithms are accepted.
+func WithAllowedAlgorithms(algorithms ...jose.SignatureAlgorithm) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.allowedAlgorithms = algorithms
+	})
+}
+
+// WithLeeway sets the leeway allowed when checking the time based claims of
+// JWT-SVIDs, i.e. 'exp', 'nbf' and 'iat', to tolerate clock drift between
+// issuers and validators. If not used, a leeway of one minute is allowed.
+func WithLeeway(leeway time.Duration) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.leeway = leeway
+	})
+}
+
+// WithClock sets the function returning the current time against which the
+// time based claims of JWT-SVIDs are checked. If not used, time.Now is used.
+func WithClock(now func() time.Time) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.now = now
+	})
+}
+
+// TrustDomainMapping maps the tokens of an issuer or key to the trust domain
+// whose bundle is used to verify their signature. Since the issuer and key ID
+// of a token are not verified before its signature, the SPIFFE ID of the
+// token must be in the mapped trust domain or in one of the trust domains the
+// mapping explicitly allows; otherwise, the token is rejected.
+type TrustDomainMapping struct {
+	// TrustDomain is the trust domain whose bundle verifies the tokens.
+	TrustDomain spiffeid.TrustDomain
+
+	// Subjects are the trust domains of the SPIFFE IDs, besides TrustDomain,
+	// that the tokens are allowed to be issued for.
+	Subjects spiffeid.TrustDomainSet
+}
+
+// WithIssuerTrustDomains maps 'iss' claim values to the trust domain whose
+// bundle is used to verify the signature of the tokens of the issuer, instead
+// of the trust domain of the SPIFFE ID of the token, e.g. when an issuer signs
+// tokens for several trust domains with the keys of one of them. Tokens from
+// issuers without a mapping are verified as usual. The mapping takes
+// precedence over the one set with WithKeyIDTrustDomains.
+func WithIssuerTrustDomains(issuers map[string]TrustDomainMapping) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.issuerDomains = issuers
+	})
+}
+
+// WithKeyIDTrustDomains maps key IDs to the trust domain whose bundle is used
+// to verify the signature of the tokens signed with the key, instead of the
+// trust domain of the SPIFFE ID of the token. Tokens signed with keys
+// without a mapping are verified as usual.
+func WithKeyIDTrustDomains(keyIDs map[string]TrustDomainMapping) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.keyIDDomains = keyIDs
+	})
+}
+
+// WithBundleRefresh makes ParseAndValidate refresh the bundle of the trust
+// domain and look for the JWT authority again when a token is signed by an
+// unknown key, if the bundle source implements jwtbundle.RefreshableSource.
+// This smooths over JWT authority rotations racing with the propagation of
+// the bundle to the validator.
+func WithBundleRefresh() ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.bundleRefresh = true
+	})
+}
+
+type validateConfig struct {
+	allowedAlgorithms []jose.SignatureAlgorithm
+	leeway            time.Duration
+	now               func() time.Time
+	maxTTL            time.Duration
+	strictProfile     bool
+	subjectMatcher    spiffeid.Matcher
+	metrics           Metrics
+	issuerDomains     map[string]TrustDomainMapping
+	keyIDDomains      map[string]TrustDomainMapping
+	bundleRefresh     bool
+	requiredTypes     []string
+	boundCert         *x509.Certificate
+	normalizeAudience bool
+}
+
+func newValidateConfig(opts []ValidateOption) *validateConfig {
+	config := &validateConfig{
+		leeway: jwt.DefaultLeeway,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt.applyValidate(config)
+	}
+	return config
+}
+
+type validateOption func(config *validateConfig)
+
+func (fn validateOption) applyValidate(config *validateConfig) {
+	fn(config)
+}
+
+// bundleTrustDomain returns the trust domain whose bundle is used to verify
+// the signature of a token with the given issuer, key ID and SPIFFE ID. An
+// error is returned if the SPIFFE ID is not allowed by the mapping.
+func (c *validateConfig) bundleTrustDomain(issuer, keyID string, id spiffeid.ID) (spiffeid.TrustDomain, error) {
+	mapping, ok := c.issuerDomains[issuer]
+	if !ok || issuer == "" {
+		mapping, ok = c.keyIDDomains[keyID]
+		if !ok || keyID == "" {
+			return id.TrustDomain(), nil
+		}
+	}
+	if id.TrustDomain() != mapping.TrustDomain && !mapping.Subjects.Contains(id.TrustDomain()) {
+		return spiffeid.TrustDomain{}, validationError(ErrUntrustedTrustDomain, "subject trust domain %q is not allowed for tokens verified with the bundle of trust domain %q", id.TrustDomain(), mapping.TrustDomain)
+	}
+	return mapping.TrustDomain, nil
+}
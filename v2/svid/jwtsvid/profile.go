@@ -0,0 +1,106 @@
+package jwtsvid
+
+import (
+	"strings"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+)
+
+// WithMaxTTL rejects JWT-SVIDs with a lifetime exceeding the given maximum,
+// e.g. long-lived tokens issued by partners. The lifetime is measured from
+// the 'iat' claim to the 'exp' claim or, if the token has no 'iat' claim,
+// from the validation time. An error classified as ErrPolicyViolation is
+// returned if the maximum is exceeded.
+func WithMaxTTL(ttl time.Duration) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.maxTTL = ttl
+	})
+}
+
+// WithStrictProfile enforces the JWT-SVID profile beyond the checks always
+// made, rejecting tokens without an 'aud' claim and tokens with critical
+// header parameters ('crit'), since no extension is defined for JWT-SVIDs.
+// The 'exp' and 'sub' claims are always required. An error classified as
+// ErrPolicyViolation is returned if the token does not conform.
+func WithStrictProfile() ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.strictProfile = true
+	})
+}
+
+// WithRequiredType requires the 'typ' header parameter of JWT-SVIDs to be
+// one of the given types, e.g. "JWT" or "application/at+jwt" for systems
+// following RFC 9068. Types are compared case-insensitively, and the
+// "application/" prefix may be omitted, as specified by RFC 7515. Tokens
+// without a type are rejected. An error classified as ErrPolicyViolation is
+// returned if the type does not match.
+func WithRequiredType(types ...string) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.requiredTypes = types
+	})
+}
+
+// WithSubjectMatcher authorizes the SPIFFE ID of JWT-SVIDs with the matcher
+// once they are otherwise valid, so that validation enforces both the
+// signature and audience checks and the identity policy of the caller. An
+// error classified as ErrUnauthorized is returned if the matcher rejects the
+// SPIFFE ID.
+func WithSubjectMatcher(matcher spiffeid.Matcher) ValidateOption {
+	return validateOption(func(config *validateConfig) {
+		config.subjectMatcher = matcher
+	})
+}
+
+func checkProfile(tok *jwt.JSONWebToken, claims *jwt.Claims, now time.Time, config *validateConfig) error {
+	if config.maxTTL > 0 {
+		issuedAt := now
+		if claims.IssuedAt != nil {
+			issuedAt = claims.IssuedAt.Time()
+		}
+		if ttl := claims.Expiry.Time().Sub(issuedAt); ttl > config.maxTTL {
+			return validationError(ErrPolicyViolation, "token lifetime of %s exceeds the maximum of %s", ttl, config.maxTTL)
+		}
+	}
+
+	if config.requiredTypes != nil {
+		typ, _ := tok.Headers[0].ExtraHeaders[jose.HeaderType].(string)
+		if !matchesType(typ, config.requiredTypes) {
+			return validationError(ErrPolicyViolation, "token type %q is not one of %q", typ, config.requiredTypes)
+		}
+	}
+
+	if config.strictProfile {
+		if len(claims.Audience) == 0 {
+			return validationError(ErrPolicyViolation, "token missing aud claim")
+		}
+		if crit, ok := tok.Headers[0].ExtraHeaders["crit"]; ok {
+			return validationError(ErrPolicyViolation, "token has unexpected critical header parameters %v", crit)
+		}
+	}
+	return nil
+}
+
+func matchesType(typ string, types []string) bool {
+	if typ == "" {
+		return false
+	}
+	for _, t := range types {
+		if normalizeType(t) == normalizeType(typ) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeType normalizes a media type used in the 'typ' header parameter,
+// which is case-insensitive and may omit the "application/" prefix.
+func normalizeType(typ string) string {
+	typ = strings.ToLower(typ)
+	if !strings.Contains(typ, "/") {
+		typ = "application/" + typ
+	}
+	return typ
+}
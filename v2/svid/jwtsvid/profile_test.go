@@ -0,0 +1,173 @@
+package jwtsvid_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/cryptosigner"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMaxTTL(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	subject := spiffeid.RequireFromPath(trustDomain1, "/host").String()
+	audience := []string{"audience"}
+	now := time.Now()
+
+	shortLived := generateToken(t, jwt.Claims{
+		Subject:  subject,
+		Audience: audience,
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(5 * time.Minute)),
+	}, key1, "authority1")
+	_, err := jwtsvid.ParseInsecure(shortLived, audience, jwtsvid.WithMaxTTL(10*time.Minute))
+	require.NoError(t, err)
+
+	longLived := generateToken(t, jwt.Claims{
+		Subject:  subject,
+		Audience: audience,
+		IssuedAt: jwt.NewNumericDate(now.Add(-time.Hour)),
+		Expiry:   jwt.NewNumericDate(now.Add(5 * time.Minute)),
+	}, key1, "authority1")
+	_, err = jwtsvid.ParseInsecure(longLived, audience)
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseInsecure(longLived, audience, jwtsvid.WithMaxTTL(10*time.Minute))
+	require.EqualError(t, err, "jwtsvid: token lifetime of 1h5m0s exceeds the maximum of 10m0s")
+	assert.True(t, errors.Is(err, jwtsvid.ErrPolicyViolation))
+
+	// Without 'iat', the lifetime is measured from the validation time.
+	noIssuedAt := generateToken(t, jwt.Claims{
+		Subject:  subject,
+		Audience: audience,
+		Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+	}, key1, "authority1")
+	_, err = jwtsvid.ParseInsecure(noIssuedAt, audience, jwtsvid.WithMaxTTL(10*time.Minute), jwtsvid.WithClock(func() time.Time {
+		return now.Add(55 * time.Minute)
+	}))
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseInsecure(noIssuedAt, audience, jwtsvid.WithMaxTTL(10*time.Minute))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, jwtsvid.ErrPolicyViolation))
+}
+
+func TestWithStrictProfile(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	claims := jwt.Claims{
+		Subject:  spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+		Audience: []string{"audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	token := generateToken(t, claims, key1, "authority1")
+	_, err := jwtsvid.ParseInsecure(token, []string{"audience"}, jwtsvid.WithStrictProfile())
+	require.NoError(t, err)
+
+	noAudience := claims
+	noAudience.Audience = nil
+	token = generateToken(t, noAudience, key1, "authority1")
+	_, err = jwtsvid.ParseInsecure(token, nil)
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseInsecure(token, nil, jwtsvid.WithStrictProfile())
+	require.EqualError(t, err, "jwtsvid: token missing aud claim")
+	assert.True(t, errors.Is(err, jwtsvid.ErrPolicyViolation))
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{
+			Algorithm: jose.ES384,
+			Key:       jose.JSONWebKey{Key: cryptosigner.Opaque(key1), KeyID: "authority1"},
+		},
+		new(jose.SignerOptions).WithType("JWT").WithHeader("crit", []string{"ext"}).WithHeader("ext", true),
+	)
+	require.NoError(t, err)
+	token, err = jwt.Signed(signer).Claims(claims).CompactSerialize()
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseInsecure(token, []string{"audience"})
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseInsecure(token, []string{"audience"}, jwtsvid.WithStrictProfile())
+	require.EqualError(t, err, "jwtsvid: token has unexpected critical header parameters [ext]")
+	assert.True(t, errors.Is(err, jwtsvid.ErrPolicyViolation))
+}
+
+func TestWithSubjectMatcher(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	bundle1 := jwtbundle.New(trustDomain1)
+	require.NoError(t, bundle1.AddJWTAuthority("authority1", key1.Public()))
+	allowed := spiffeid.RequireFromPath(trustDomain1, "/allowed")
+	denied := spiffeid.RequireFromPath(trustDomain1, "/denied")
+	matcher := jwtsvid.WithSubjectMatcher(spiffeid.MatchID(allowed))
+
+	newToken := func(id spiffeid.ID) string {
+		return generateToken(t, jwt.Claims{
+			Subject:  id.String(),
+			Audience: []string{"audience"},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}, key1, "authority1")
+	}
+
+	svid, err := jwtsvid.ParseAndValidate(newToken(allowed), bundle1, []string{"audience"}, matcher)
+	require.NoError(t, err)
+	assert.Equal(t, allowed, svid.ID)
+
+	_, err = jwtsvid.ParseAndValidate(newToken(denied), bundle1, []string{"audience"}, matcher)
+	require.EqualError(t, err, `jwtsvid: unauthorized subject "spiffe://trustdomain/denied": unexpected ID "spiffe://trustdomain/denied"`)
+	assert.True(t, errors.Is(err, jwtsvid.ErrUnauthorized))
+
+	// Tokens failing validation are not authorized.
+	_, err = jwtsvid.ParseAndValidate(newToken(allowed), bundle1, []string{"other"}, matcher)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, jwtsvid.ErrInvalidAudience))
+}
+
+func TestWithRequiredType(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	claims := jwt.Claims{
+		Subject:  spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+		Audience: []string{"audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	newToken := func(typ string) string {
+		opts := new(jose.SignerOptions)
+		if typ != "" {
+			opts = opts.WithType(jose.ContentType(typ))
+		}
+		signer, err := jose.NewSigner(jose.SigningKey{
+			Algorithm: jose.ES384,
+			Key:       jose.JSONWebKey{Key: cryptosigner.Opaque(key1), KeyID: "authority1"},
+		}, opts)
+		require.NoError(t, err)
+		token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	testCases := []struct {
+		typ   string
+		types []string
+		err   string
+	}{
+		{typ: "JWT", types: []string{"JWT"}},
+		{typ: "jwt", types: []string{"JWT"}},
+		{typ: "at+jwt", types: []string{"application/at+jwt"}},
+		{typ: "application/AT+JWT", types: []string{"at+jwt"}},
+		{typ: "JWT", types: []string{"at+jwt", "JWT"}},
+		{typ: "JWT", types: []string{"at+jwt"}, err: `jwtsvid: token type "JWT" is not one of ["at+jwt"]`},
+		{typ: "", types: []string{"JWT"}, err: `jwtsvid: token type "" is not one of ["JWT"]`},
+	}
+
+	for _, testCase := range testCases {
+		_, err := jwtsvid.ParseInsecure(newToken(testCase.typ), []string{"audience"}, jwtsvid.WithRequiredType(testCase.types...))
+		if testCase.err != "" {
+			require.EqualError(t, err, testCase.err)
+			assert.True(t, errors.Is(err, jwtsvid.ErrPolicyViolation))
+			continue
+		}
+		require.NoError(t, err, "type %q", testCase.typ)
+	}
+}
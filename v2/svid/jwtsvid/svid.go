@@ -1,7 +1,6 @@
 package jwtsvid
 
 import (
-	"fmt"
 	"time"
 
 	"github.com/go-jose/go-jose/v3"
@@ -34,34 +33,48 @@ type SVID struct {
 
 	// token is the serialized JWT token
 	token string
+
+	// algorithm, keyID and typ are the 'alg', 'kid' and 'typ' header
+	// parameters of the token
+	algorithm string
+	keyID     string
+	typ       string
 }
 
 // ParseAndValidate parses and validates a JWT-SVID token and returns the
 // JWT-SVID. The JWT-SVID signature is verified using the JWT bundle source.
-func ParseAndValidate(token string, bundles jwtbundle.Source, audience []string) (*SVID, error) {
-	return parse(token, audience, func(tok *jwt.JSONWebToken, trustDomain spiffeid.TrustDomain) (map[string]interface{}, error) {
+func ParseAndValidate(token string, bundles jwtbundle.Source, audience []string, opts ...ValidateOption) (*SVID, error) {
+	config := newValidateConfig(opts)
+	return parse(token, audience, config, func(tok *jwt.JSONWebToken, trustDomain spiffeid.TrustDomain) (map[string]interface{}, error) {
 		// Obtain the key ID from the header
 		keyID := tok.Headers[0].KeyID
 		if keyID == "" {
-			return nil, jwtsvidErr.New("token header missing key id")
+			return nil, validationError(ErrMalformed, "token header missing key id")
 		}
 
 		// Get JWT Bundle
 		bundle, err := bundles.GetJWTBundleForTrustDomain(trustDomain)
 		if err != nil {
-			return nil, jwtsvidErr.New("no bundle found for trust domain %q", trustDomain)
+			return nil, validationError(ErrUntrustedTrustDomain, "no bundle found for trust domain %q", trustDomain)
 		}
 
 		// Find JWT authority using the key ID from the token header
 		authority, ok := bundle.FindJWTAuthority(keyID)
+		if refreshable, canRefresh := bundles.(jwtbundle.RefreshableSource); !ok && canRefresh && config.bundleRefresh {
+			// The authority may have been added since the bundle was last
+			// updated, so refresh the bundle and look again
+			if bundle, err = refreshable.RefreshJWTBundleForTrustDomain(trustDomain); err == nil {
+				authority, ok = bundle.FindJWTAuthority(keyID)
+			}
+		}
 		if !ok {
-			return nil, jwtsvidErr.New("no JWT authority %q found for trust domain %q", keyID, trustDomain)
+			return nil, validationError(ErrUnknownKeyID, "no JWT authority %q found for trust domain %q", keyID, trustDomain)
 		}
 
 		// Obtain and verify the token claims using the obtained JWT authority
 		claimsMap := make(map[string]interface{})
 		if err := tok.Claims(authority, &claimsMap); err != nil {
-			return nil, jwtsvidErr.New("unable to get claims from token: %v", err)
+			return nil, validationError(ErrInvalidSignature, "unable to get claims from token: %v", err)
 		}
 
 		return claimsMap, nil
@@ -70,33 +83,60 @@ func ParseAndValidate(token string, bundles jwtbundle.Source, audience []string)
 
 // ParseInsecure parses and validates a JWT-SVID token and returns the
 // JWT-SVID. The JWT-SVID signature is not verified.
-func ParseInsecure(token string, audience []string) (*SVID, error) {
-	return parse(token, audience, func(tok *jwt.JSONWebToken, td spiffeid.TrustDomain) (map[string]interface{}, error) {
+func ParseInsecure(token string, audience []string, opts ...ValidateOption) (*SVID, error) {
+	return parse(token, audience, newValidateConfig(opts), func(tok *jwt.JSONWebToken, td spiffeid.TrustDomain) (map[string]interface{}, error) {
 		// Obtain the token claims insecurely, i.e. without signature verification
 		claimsMap := make(map[string]interface{})
 		if err := tok.UnsafeClaimsWithoutVerification(&claimsMap); err != nil {
-			return nil, jwtsvidErr.New("unable to get claims from token: %v", err)
+			return nil, validationError(ErrMalformed, "unable to get claims from token: %v", err)
 		}
 
 		return claimsMap, nil
 	})
 }
 
+// Algorithm returns the signature algorithm of the token, as present in the
+// 'alg' header parameter, e.g. "ES256".
+func (svid *SVID) Algorithm() string {
+	return svid.algorithm
+}
+
+// KeyID returns the ID of the key that signed the token, as present in the
+// 'kid' header parameter.
+func (svid *SVID) KeyID() string {
+	return svid.keyID
+}
+
+// Type returns the type of the token, as present in the 'typ' header
+// parameter, e.g. "JWT". It is empty if the token has no type.
+func (svid *SVID) Type() string {
+	return svid.typ
+}
+
 // Marshal returns the JWT-SVID marshaled to a string. The returned value is
 // the same token value originally passed to ParseAndValidate.
 func (svid *SVID) Marshal() string {
 	return svid.token
 }
 
-func parse(token string, audience []string, getClaims tokenValidator) (*SVID, error) {
+func parse(token string, audience []string, config *validateConfig, getClaims tokenValidator) (_ *SVID, err error) {
+	// Report the outcome of the validation, if required.
+	var trustDomain spiffeid.TrustDomain
+	if config.metrics != nil {
+		start := time.Now()
+		defer func() {
+			reportValidation(config.metrics, trustDomain, start, err)
+		}()
+	}
+
 	// Parse serialized token
 	tok, err := jwt.ParseSigned(token)
 	if err != nil {
-		return nil, jwtsvidErr.New("unable to parse JWT token")
+		return nil, validationError(ErrMalformed, "unable to parse JWT token")
 	}
 
 	// Validates supported token signed algorithm
-	if err := validateTokenAlgorithm(tok); err != nil {
+	if err := validateTokenAlgorithm(tok, config.allowedAlgorithms); err != nil {
 		return nil, err
 	}
 
@@ -104,56 +144,94 @@ func parse(token string, audience []string, getClaims tokenValidator) (*SVID, er
 	// domain of the SPIFFE ID.
 	var claims jwt.Claims
 	if err := tok.UnsafeClaimsWithoutVerification(&claims); err != nil {
-		return nil, jwtsvidErr.New("unable to get claims from token: %v", err)
+		return nil, validationError(ErrMalformed, "unable to get claims from token: %v", err)
 	}
 
 	switch {
 	case claims.Subject == "":
-		return nil, jwtsvidErr.New("token missing subject claim")
+		return nil, validationError(ErrMalformed, "token missing subject claim")
 	case claims.Expiry == nil:
-		return nil, jwtsvidErr.New("token missing exp claim")
+		return nil, validationError(ErrMalformed, "token missing exp claim")
 	}
 
 	spiffeID, err := spiffeid.FromString(claims.Subject)
 	if err != nil {
-		return nil, jwtsvidErr.New("token has an invalid subject claim: %v", err)
+		return nil, validationError(ErrMalformed, "token has an invalid subject claim: %v", err)
+	}
+	trustDomain, err = config.bundleTrustDomain(claims.Issuer, tok.Headers[0].KeyID, spiffeID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create generic map of claims
-	claimsMap, err := getClaims(tok, spiffeID.TrustDomain())
+	claimsMap, err := getClaims(tok, trustDomain)
 	if err != nil {
 		return nil, err
 	}
 
 	// Validate the standard claims.
-	if err := claims.Validate(jwt.Expected{
-		Audience: audience,
-		Time:     time.Now(),
-	}); err != nil {
+	now := config.now()
+	validatedClaims, expectedAudience := claims, audience
+	if config.normalizeAudience {
+		validatedClaims.Audience = normalizeAudiences(claims.Audience)
+		expectedAudience = normalizeAudiences(audience)
+	}
+	if err := validatedClaims.ValidateWithLeeway(jwt.Expected{
+		Audience: expectedAudience,
+		Time:     now,
+	}, config.leeway); err != nil {
 		// Convert expected validation errors for pretty errors
 		switch err {
 		case jwt.ErrExpired:
-			err = jwtsvidErr.New("token has expired")
+			err = validationError(ErrExpired, "token has expired")
+		case jwt.ErrNotValidYet:
+			err = validationError(ErrNotValidYet, "token is not valid yet")
+		case jwt.ErrIssuedInTheFuture:
+			err = validationError(ErrNotValidYet, "token was issued in the future")
 		case jwt.ErrInvalidAudience:
-			err = jwtsvidErr.New("expected audience in %q (audience=%q)", audience, claims.Audience)
+			err = validationError(ErrInvalidAudience, "expected audience in %q (audience=%q)", audience, claims.Audience)
 		}
 		return nil, err
 	}
 
+	// Enforce the profile requirements, if any.
+	if err := checkProfile(tok, &claims, now, config); err != nil {
+		return nil, err
+	}
+
+	// Check the binding of the token to the certificate, if required.
+	if config.boundCert != nil {
+		if err := checkBinding(claimsMap, config.boundCert); err != nil {
+			return nil, err
+		}
+	}
+
+	// Authorize the subject, if required.
+	if config.subjectMatcher != nil {
+		if err := config.subjectMatcher(spiffeID); err != nil {
+			return nil, validationError(ErrUnauthorized, "unauthorized subject %q: %w", spiffeID, err)
+		}
+	}
+
+	typ, _ := tok.Headers[0].ExtraHeaders[jose.HeaderType].(string)
 	return &SVID{
-		ID:       spiffeID,
-		Audience: claims.Audience,
-		Expiry:   claims.Expiry.Time().UTC(),
-		Claims:   claimsMap,
-		token:    token,
+		ID:        spiffeID,
+		Audience:  claims.Audience,
+		Expiry:    claims.Expiry.Time().UTC(),
+		Claims:    claimsMap,
+		token:     token,
+		algorithm: tok.Headers[0].Algorithm,
+		keyID:     tok.Headers[0].KeyID,
+		typ:       typ,
 	}, nil
 }
 
 // validateTokenAlgorithm json web token have only one header, and it is signed for a supported algorithm
-func validateTokenAlgorithm(tok *jwt.JSONWebToken) error {
+// that is allowed, if an allowlist is given
+func validateTokenAlgorithm(tok *jwt.JSONWebToken, allowed []jose.SignatureAlgorithm) error {
 	// Only one header is expected
 	if len(tok.Headers) != 1 {
-		return fmt.Errorf("expected a single token header; got %d", len(tok.Headers))
+		return validationError(ErrMalformed, "expected a single token header; got %d", len(tok.Headers))
 	}
 
 	// Make sure it has an algorithm supported by JWT-SVID
@@ -163,8 +241,16 @@ func validateTokenAlgorithm(tok *jwt.JSONWebToken) error {
 		jose.ES256, jose.ES384, jose.ES512,
 		jose.PS256, jose.PS384, jose.PS512:
 	default:
-		return jwtsvidErr.New("unsupported token signature algorithm %q", alg)
+		return validationError(ErrUnsupportedAlgorithm, "unsupported token signature algorithm %q", alg)
 	}
 
-	return nil
+	if allowed == nil {
+		return nil
+	}
+	for _, allowedAlg := range allowed {
+		if jose.SignatureAlgorithm(alg) == allowedAlg {
+			return nil
+		}
+	}
+	return validationError(ErrUnsupportedAlgorithm, "token signature algorithm %q is not allowed", alg)
 }
@@ -48,6 +48,7 @@ func TestParseAndValidate(t *testing.T) {
 		bundle        *jwtbundle.Bundle
 		audience      []string
 		generateToken func(testing.TB) string
+		opts          []jwtsvid.ValidateOption
 		err           string
 		svid          *jwtsvid.SVID
 	}{
@@ -236,6 +237,46 @@ func TestParseAndValidate(t *testing.T) {
 			},
 			err: "jwtsvid: unable to get claims from token: go-jose/go-jose: error in cryptographic primitive",
 		},
+		{
+			name:     "allowed algorithm",
+			bundle:   bundle1,
+			audience: []string{"audience"},
+			generateToken: func(tb testing.TB) string {
+				claims := jwt.Claims{
+					Subject:  spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+					Issuer:   "issuer",
+					Expiry:   expires,
+					Audience: []string{"audience"},
+					IssuedAt: issuedAt,
+				}
+
+				return generateToken(tb, claims, key2, "authority2")
+			},
+			opts: []jwtsvid.ValidateOption{jwtsvid.WithAllowedAlgorithms(jose.ES256, jose.RS256)},
+			svid: &jwtsvid.SVID{
+				ID:       spiffeid.RequireFromPath(trustDomain1, "/host"),
+				Audience: []string{"audience"},
+				Expiry:   expiresTime,
+			},
+		},
+		{
+			name:     "disallowed algorithm",
+			bundle:   bundle1,
+			audience: []string{"audience"},
+			generateToken: func(tb testing.TB) string {
+				claims := jwt.Claims{
+					Subject:  spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+					Issuer:   "issuer",
+					Expiry:   expires,
+					Audience: []string{"audience"},
+					IssuedAt: issuedAt,
+				}
+
+				return generateToken(tb, claims, key1, "authority1")
+			},
+			opts: []jwtsvid.ValidateOption{jwtsvid.WithAllowedAlgorithms(jose.ES256, jose.RS256)},
+			err:  `jwtsvid: token signature algorithm "ES384" is not allowed`,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -245,7 +286,7 @@ func TestParseAndValidate(t *testing.T) {
 			token := testCase.generateToken(t)
 
 			// Parse and validate
-			svid, err := jwtsvid.ParseAndValidate(token, testCase.bundle, testCase.audience)
+			svid, err := jwtsvid.ParseAndValidate(token, testCase.bundle, testCase.audience, testCase.opts...)
 
 			// Verify returned error, in case it is expected
 			if testCase.err != "" {
@@ -419,6 +460,167 @@ func TestParseInsecure(t *testing.T) {
 	}
 }
 
+func TestLeewayAndClock(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	now := time.Now()
+	newToken := func(notBefore, expiry time.Time) string {
+		claims := jwt.Claims{
+			Subject:   spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+			Expiry:    jwt.NewNumericDate(expiry),
+			NotBefore: jwt.NewNumericDate(notBefore),
+			Audience:  []string{"audience"},
+		}
+		return generateToken(t, claims, key1, "authority1")
+	}
+	audience := []string{"audience"}
+
+	// The default leeway tolerates a token that has just expired.
+	expired := newToken(now.Add(-time.Hour), now.Add(-30*time.Second))
+	_, err := jwtsvid.ParseInsecure(expired, audience)
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseInsecure(expired, audience, jwtsvid.WithLeeway(0))
+	require.EqualError(t, err, "jwtsvid: token has expired")
+	_, err = jwtsvid.ParseInsecure(expired, audience, jwtsvid.WithLeeway(0), jwtsvid.WithClock(func() time.Time {
+		return now.Add(-time.Minute)
+	}))
+	require.NoError(t, err)
+
+	// The leeway applies to tokens that are not valid yet.
+	notYetValid := newToken(now.Add(2*time.Minute), now.Add(time.Hour))
+	_, err = jwtsvid.ParseInsecure(notYetValid, audience)
+	require.EqualError(t, err, "jwtsvid: token is not valid yet")
+	_, err = jwtsvid.ParseInsecure(notYetValid, audience, jwtsvid.WithLeeway(5*time.Minute))
+	require.NoError(t, err)
+}
+
+func TestTrustDomainMapping(t *testing.T) {
+	shared := spiffeid.RequireTrustDomainFromString("shared")
+	tenant := spiffeid.RequireTrustDomainFromString("tenant")
+	other := spiffeid.RequireTrustDomainFromString("other")
+	sharedBundle := jwtbundle.New(shared)
+	require.NoError(t, sharedBundle.AddJWTAuthority("authority1", key1.Public()))
+	bundles := jwtbundle.NewSet(sharedBundle)
+
+	newToken := func(td spiffeid.TrustDomain, issuer string) string {
+		return generateToken(t, jwt.Claims{
+			Subject:  spiffeid.RequireFromPath(td, "/host").String(),
+			Issuer:   issuer,
+			Audience: []string{"audience"},
+			Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		}, key1, "authority1")
+	}
+
+	// Without a mapping, the bundle of the trust domain of the SPIFFE ID is
+	// used.
+	_, err := jwtsvid.ParseAndValidate(newToken(tenant, "https://issuer.test"), bundles, []string{"audience"})
+	require.EqualError(t, err, `jwtsvid: no bundle found for trust domain "tenant"`)
+
+	byIssuer := jwtsvid.WithIssuerTrustDomains(map[string]jwtsvid.TrustDomainMapping{
+		"https://issuer.test": {TrustDomain: shared, Subjects: spiffeid.NewTrustDomainSet(tenant)},
+	})
+	svid, err := jwtsvid.ParseAndValidate(newToken(tenant, "https://issuer.test"), bundles, []string{"audience"}, byIssuer)
+	require.NoError(t, err)
+	require.Equal(t, tenant, svid.ID.TrustDomain())
+	_, err = jwtsvid.ParseAndValidate(newToken(shared, "https://issuer.test"), bundles, []string{"audience"}, byIssuer)
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseAndValidate(newToken(tenant, "https://other.test"), bundles, []string{"audience"}, byIssuer)
+	require.EqualError(t, err, `jwtsvid: no bundle found for trust domain "tenant"`)
+
+	// Subjects in trust domains not allowed by the mapping are rejected,
+	// even though the signature is valid.
+	_, err = jwtsvid.ParseAndValidate(newToken(other, "https://issuer.test"), bundles, []string{"audience"}, byIssuer)
+	require.EqualError(t, err, `jwtsvid: subject trust domain "other" is not allowed for tokens verified with the bundle of trust domain "shared"`)
+	require.ErrorIs(t, err, jwtsvid.ErrUntrustedTrustDomain)
+
+	byKeyID := jwtsvid.WithKeyIDTrustDomains(map[string]jwtsvid.TrustDomainMapping{
+		"authority1": {TrustDomain: shared},
+	})
+	_, err = jwtsvid.ParseAndValidate(newToken(shared, ""), bundles, []string{"audience"}, byKeyID)
+	require.NoError(t, err)
+	_, err = jwtsvid.ParseAndValidate(newToken(tenant, ""), bundles, []string{"audience"}, byKeyID)
+	require.EqualError(t, err, `jwtsvid: subject trust domain "tenant" is not allowed for tokens verified with the bundle of trust domain "shared"`)
+
+	// The issuer mapping takes precedence over the key ID mapping.
+	byIssuer = jwtsvid.WithIssuerTrustDomains(map[string]jwtsvid.TrustDomainMapping{
+		"https://issuer.test": {TrustDomain: tenant},
+	})
+	_, err = jwtsvid.ParseAndValidate(newToken(tenant, "https://issuer.test"), bundles, []string{"audience"}, byIssuer, byKeyID)
+	require.EqualError(t, err, `jwtsvid: no bundle found for trust domain "tenant"`)
+}
+
+func TestWithBundleRefresh(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	stale := jwtbundle.New(trustDomain1)
+	require.NoError(t, stale.AddJWTAuthority("authority1", key1.Public()))
+	rotated := stale.Clone()
+	require.NoError(t, rotated.AddJWTAuthority("authority2", key2.Public()))
+	bundles := &refreshableSource{current: stale, refreshed: rotated}
+
+	token := generateToken(t, jwt.Claims{
+		Subject:  spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+		Audience: []string{"audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}, key2, "authority2")
+
+	_, err := jwtsvid.ParseAndValidate(token, bundles, []string{"audience"})
+	require.EqualError(t, err, `jwtsvid: no JWT authority "authority2" found for trust domain "trustdomain"`)
+	require.Equal(t, 0, bundles.refreshes)
+
+	_, err = jwtsvid.ParseAndValidate(token, bundles, []string{"audience"}, jwtsvid.WithBundleRefresh())
+	require.NoError(t, err)
+	require.Equal(t, 1, bundles.refreshes)
+
+	// Known keys do not trigger a refresh.
+	_, err = jwtsvid.ParseAndValidate(token, bundles, []string{"audience"}, jwtsvid.WithBundleRefresh())
+	require.NoError(t, err)
+	require.Equal(t, 1, bundles.refreshes)
+
+	// The bundle source must be refreshable.
+	_, err = jwtsvid.ParseAndValidate(token, stale, []string{"audience"}, jwtsvid.WithBundleRefresh())
+	require.EqualError(t, err, `jwtsvid: no JWT authority "authority2" found for trust domain "trustdomain"`)
+}
+
+type refreshableSource struct {
+	current   *jwtbundle.Bundle
+	refreshed *jwtbundle.Bundle
+	refreshes int
+}
+
+func (s *refreshableSource) GetJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*jwtbundle.Bundle, error) {
+	return s.current.GetJWTBundleForTrustDomain(trustDomain)
+}
+
+func (s *refreshableSource) RefreshJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*jwtbundle.Bundle, error) {
+	s.refreshes++
+	s.current = s.refreshed
+	return s.GetJWTBundleForTrustDomain(trustDomain)
+}
+
+func TestHeader(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	claims := jwt.Claims{
+		Subject:  spiffeid.RequireFromPath(trustDomain1, "/host").String(),
+		Audience: []string{"audience"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+
+	svid, err := jwtsvid.ParseInsecure(generateToken(t, claims, key1, "authority1"), []string{"audience"})
+	require.NoError(t, err)
+	require.Equal(t, "ES384", svid.Algorithm())
+	require.Equal(t, "authority1", svid.KeyID())
+	require.Equal(t, "JWT", svid.Type())
+
+	svid, err = jwtsvid.ParseInsecure(generateToken(t, claims, key2, "authority2"), []string{"audience"})
+	require.NoError(t, err)
+	require.Equal(t, "RS256", svid.Algorithm())
+	require.Equal(t, "authority2", svid.KeyID())
+
+	svid = &jwtsvid.SVID{}
+	require.Empty(t, svid.Algorithm())
+	require.Empty(t, svid.KeyID())
+	require.Empty(t, svid.Type())
+}
+
 func TestMarshal(t *testing.T) {
 	// Generate trust domain
 	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
@@ -458,7 +660,7 @@ func parseToken(t testing.TB, token string) map[string]interface{} {
 }
 
 // Generate generates a signed string token
-func generateToken(tb testing.TB, claims jwt.Claims, signer crypto.Signer, keyID string) string {
+func generateToken(tb testing.TB, claims interface{}, signer crypto.Signer, keyID string) string {
 	// Get signer algorithm
 	alg, err := getSignerAlgorithm(signer)
 	require.NoError(tb, err)
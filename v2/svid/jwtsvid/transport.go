@@ -0,0 +1,62 @@
+package jwtsvid
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// SetAuthHeader sets the Authorization header of the request to the
+// JWT-SVID as a bearer token, as expected by the middleware returned by
+// Middleware.
+func SetAuthHeader(req *http.Request, svid *SVID) {
+	req.Header.Set("Authorization", "Bearer "+svid.Marshal())
+}
+
+// NewTransport returns an http.RoundTripper attaching a JWT-SVID fetched from
+// the source to each request as a bearer token, before sending it with the
+// base round tripper. The audience of the JWT-SVID is derived from the
+// request with the audience function. If the audience function is nil, the
+// origin of the request URL, normalized with AudienceFromURL, is used as the
+// audience, e.g. "https://example.org". If the base round tripper is nil,
+// http.DefaultTransport is used. JWT-SVIDs are cached and fetched again
+// before they expire (see NewCachingSource).
+func NewTransport(source Source, audience func(req *http.Request) string, base http.RoundTripper) http.RoundTripper {
+	if audience == nil {
+		audience = requestOrigin
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{
+		source:   NewCachingSource(source),
+		audience: audience,
+		base:     base,
+	}
+}
+
+type transport struct {
+	source   Source
+	audience func(req *http.Request) string
+	base     http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	svid, err := t.source.FetchJWTSVID(req.Context(), Params{
+		Audience: t.audience(req),
+	})
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	// Round trippers must not modify the request.
+	req = req.Clone(req.Context())
+	SetAuthHeader(req, svid)
+	return t.base.RoundTrip(req)
+}
+
+func requestOrigin(req *http.Request) string {
+	return AudienceFromURL(&url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host})
+}
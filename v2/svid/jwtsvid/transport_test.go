@@ -0,0 +1,74 @@
+package jwtsvid_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetAuthHeader(t *testing.T) {
+	svid, err := newFakeSource(t, time.Hour).FetchJWTSVID(context.Background(), jwtsvid.Params{Audience: "audience"})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	jwtsvid.SetAuthHeader(req, svid)
+
+	token, ok := jwtsvid.BearerToken(req)
+	require.True(t, ok)
+	assert.Equal(t, svid.Marshal(), token)
+}
+
+func TestTransport(t *testing.T) {
+	trustDomain1 := spiffeid.RequireTrustDomainFromString("trustdomain")
+	bundle1 := jwtbundle.New(trustDomain1)
+	require.NoError(t, bundle1.AddJWTAuthority("authority1", key1.Public()))
+
+	// The server expects its own origin as the audience.
+	var audience []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jwtsvid.Middleware(bundle1, audience, spiffeid.MatchAny())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			svid, _ := jwtsvid.SVIDFromContext(r.Context())
+			_, _ = w.Write([]byte(svid.ID.String()))
+		})).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+	audience = []string{server.URL}
+
+	source := newFakeSource(t, time.Hour)
+	client := &http.Client{Transport: jwtsvid.NewTransport(source, nil, nil)}
+	resp, err := client.Get(server.URL + "/resource")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The JWT-SVID is cached and the request is not modified.
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/resource", nil)
+	require.NoError(t, err)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, req.Header.Get("Authorization"))
+	assert.Equal(t, int32(1), source.calls())
+
+	// Custom audiences are requested.
+	client = &http.Client{Transport: jwtsvid.NewTransport(source, func(*http.Request) string { return "other" }, nil)}
+	resp, err = client.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	source.setErr(errors.New("oh no"))
+	client = &http.Client{Transport: jwtsvid.NewTransport(source, func(*http.Request) string { return "failing" }, nil)}
+	_, err = client.Get(server.URL)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "oh no")
+}
@@ -0,0 +1,98 @@
+package x509svid
+
+import (
+	"bytes"
+	"crypto/x509"
+)
+
+// WithStrictOrder requires the certificates to be ordered from the leaf to
+// the root, each certificate being issued by the next one, when parsing. If
+// not used, out-of-order certificates are reordered.
+func WithStrictOrder() ParseOption {
+	return parseOption(func(config *parseConfig) {
+		config.strictOrder = true
+	})
+}
+
+// orderChain returns the certificates ordered from the leaf to the root,
+// following the issuers of the certificates. The leaf is the only
+// certificate that is not a CA; if there is not exactly one, the
+// certificates are returned as they are and left for validation to reject.
+// Certificates that are not part of the path from the leaf are kept at the
+// end, in their original order. Chains that are already ordered, as the ones
+// from the Workload API are, are returned as they are without checking
+// signatures.
+func orderChain(certs []*x509.Certificate) []*x509.Certificate {
+	if hasOrderedNames(certs) {
+		return certs
+	}
+
+	leafIndex := -1
+	for i, cert := range certs {
+		if cert.IsCA {
+			continue
+		}
+		if leafIndex >= 0 {
+			return certs
+		}
+		leafIndex = i
+	}
+	if leafIndex < 0 {
+		return certs
+	}
+
+	used := make([]bool, len(certs))
+	used[leafIndex] = true
+	ordered := make([]*x509.Certificate, 0, len(certs))
+	ordered = append(ordered, certs[leafIndex])
+	for current := certs[leafIndex]; ; {
+		next := -1
+		for i, cert := range certs {
+			if !used[i] && isIssuedBy(current, cert) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		used[next] = true
+		current = certs[next]
+		ordered = append(ordered, current)
+	}
+	for i, cert := range certs {
+		if !used[i] {
+			ordered = append(ordered, cert)
+		}
+	}
+	return ordered
+}
+
+// isOrdered returns true if the certificates are in the same order.
+func isOrdered(certs, ordered []*x509.Certificate) bool {
+	for i := range certs {
+		if certs[i] != ordered[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasOrderedNames returns true if the first certificate is the leaf and the
+// issuer of each certificate is the subject of the next one. The signatures
+// are left for validation to check.
+func hasOrderedNames(certs []*x509.Certificate) bool {
+	if len(certs) == 0 || certs[0].IsCA {
+		return false
+	}
+	for i := 0; i < len(certs)-1; i++ {
+		if !bytes.Equal(certs[i].RawIssuer, certs[i+1].RawSubject) {
+			return false
+		}
+	}
+	return true
+}
+
+func isIssuedBy(cert, issuer *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, issuer.RawSubject) && cert.CheckSignatureFrom(issuer) == nil
+}
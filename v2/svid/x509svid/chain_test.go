@@ -0,0 +1,99 @@
+package x509svid_test
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/pemutil"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReordersChain(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	certSign := test.WithKeyUsage(x509.KeyUsageCertSign)
+	svid := ca.ChildCA(certSign).ChildCA(certSign).CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"))
+	require.Len(t, svid.Certificates, 3)
+	leaf, intermediate1, intermediate2 := svid.Certificates[0], svid.Certificates[1], svid.Certificates[2]
+	unrelated := ca.ChildCA(certSign).CreateX509SVID(spiffeid.RequireFromPath(td, "/other")).Certificates[1]
+	_, keyPEM, err := svid.Marshal()
+	require.NoError(t, err)
+	_, keyDER, err := svid.MarshalRaw()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		certs    []*x509.Certificate
+		expected []*x509.Certificate
+	}{
+		{
+			name:     "ordered",
+			certs:    []*x509.Certificate{leaf, intermediate1, intermediate2},
+			expected: []*x509.Certificate{leaf, intermediate1, intermediate2},
+		},
+		{
+			name:     "leaf not first",
+			certs:    []*x509.Certificate{intermediate1, intermediate2, leaf},
+			expected: []*x509.Certificate{leaf, intermediate1, intermediate2},
+		},
+		{
+			name:     "interleaved intermediates",
+			certs:    []*x509.Certificate{intermediate2, leaf, intermediate1},
+			expected: []*x509.Certificate{leaf, intermediate1, intermediate2},
+		},
+		{
+			name:     "unrelated CA kept at the end",
+			certs:    []*x509.Certificate{unrelated, intermediate2, leaf, intermediate1},
+			expected: []*x509.Certificate{leaf, intermediate1, intermediate2, unrelated},
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			parsed, err := x509svid.Parse(pemutil.EncodeCertificates(testCase.certs), keyPEM)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, parsed.Certificates)
+
+			parsed, err = x509svid.ParseRaw(x509util.ConcatRawCertsFromCerts(testCase.certs), keyDER)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expected, parsed.Certificates)
+
+			_, err = x509svid.Parse(pemutil.EncodeCertificates(testCase.certs), keyPEM, x509svid.WithStrictOrder())
+			if testCase.name == "ordered" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, "x509svid: certificates are not ordered from the leaf to the root")
+			}
+		})
+	}
+}
+
+func BenchmarkParseRawOrderedChain(b *testing.B) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(b, td)
+	certSign := test.WithKeyUsage(x509.KeyUsageCertSign)
+	svid := ca.ChildCA(certSign).ChildCA(certSign).CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"))
+	certsDER, keyDER, err := svid.MarshalRaw()
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_, _ = x509svid.ParseRaw(certsDER, keyDER)
+	}
+}
+
+func TestParseRawWithKeyPassword(t *testing.T) {
+	svid, err := x509svid.Load(certSingle, keyRSA)
+	require.NoError(t, err)
+	certBytes, keyBytes, err := svid.MarshalRaw()
+	require.NoError(t, err)
+
+	_, err = x509svid.ParseRaw(certBytes, keyBytes, x509svid.WithKeyPassword([]byte("password")))
+	require.EqualError(t, err, "x509svid: key passwords are not supported for DER encoded private keys")
+}
@@ -0,0 +1,94 @@
+package x509svid
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// ChainErrorReason is the reason for a ChainError.
+type ChainErrorReason int
+
+const (
+	// ChainTooDeep means that the chain has more intermediate certificates
+	// than allowed by WithMaxChainDepth.
+	ChainTooDeep ChainErrorReason = iota + 1
+
+	// PathLenExceeded means that the chain has more intermediate certificates
+	// below a CA certificate than allowed by its path length constraint.
+	PathLenExceeded
+)
+
+// ChainError is returned, possibly wrapped, when verification fails because
+// of the shape of the chain.
+type ChainError struct {
+	// Reason is the reason of the error.
+	Reason ChainErrorReason
+
+	// Certificate is the CA certificate whose path length constraint is
+	// exceeded, for PathLenExceeded.
+	Certificate *x509.Certificate
+
+	// Depth is the number of intermediate certificates of the chain, for
+	// ChainTooDeep.
+	Depth int
+
+	// MaxDepth is the maximum number of intermediate certificates, for
+	// ChainTooDeep.
+	MaxDepth int
+}
+
+// Error implements the error interface.
+func (e *ChainError) Error() string {
+	switch e.Reason {
+	case ChainTooDeep:
+		return fmt.Sprintf("chain has %d intermediate certificates, exceeding the maximum of %d", e.Depth, e.MaxDepth)
+	case PathLenExceeded:
+		return fmt.Sprintf("path length constraint of CA %q exceeded", e.Certificate.Subject)
+	default:
+		return "invalid chain"
+	}
+}
+
+// WithMaxChainDepth limits the number of intermediate certificates between
+// the leaf and the root of the verified chains. Presented chains with more
+// certificates than the limit allows, even counting a root, are rejected
+// before being verified, which protects verifiers from the cost of building
+// chains out of pathological inputs. Only the verified chains within the
+// limit are returned. A *ChainError is returned if the limit is exceeded.
+// CA path length constraints are always enforced, also resulting in a
+// *ChainError when exceeded.
+func WithMaxChainDepth(depth int) VerifyOption {
+	return verifyOption(func(config *verifyConfig) {
+		config.maxChainDepth = &depth
+	})
+}
+
+// checkPresentedDepth fails if the presented certificates cannot make up a
+// chain within the maximum depth, i.e. the leaf, the intermediates and
+// possibly a root.
+func checkPresentedDepth(certs []*x509.Certificate, maxDepth int) error {
+	if depth := len(certs) - 2; depth > maxDepth {
+		return &ChainError{Reason: ChainTooDeep, Depth: depth, MaxDepth: maxDepth}
+	}
+	return nil
+}
+
+func checkChainDepth(maxDepth int) func([]*x509.Certificate) error {
+	return func(chain []*x509.Certificate) error {
+		if depth := len(chain) - 2; depth > maxDepth {
+			return &ChainError{Reason: ChainTooDeep, Depth: depth, MaxDepth: maxDepth}
+		}
+		return nil
+	}
+}
+
+// pathLenError converts path length constraint errors from the x509 package
+// into a *ChainError.
+func pathLenError(err error) error {
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) && invalidErr.Reason == x509.TooManyIntermediates {
+		return &ChainError{Reason: PathLenExceeded, Certificate: invalidErr.Cert}
+	}
+	return err
+}
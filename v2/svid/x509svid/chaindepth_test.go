@@ -0,0 +1,61 @@
+package x509svid_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyWithMaxChainDepth(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	id := spiffeid.RequireFromPath(td, "/workload")
+	bundle := ca.X509Bundle()
+	certs := ca.ChildCA().ChildCA().CreateX509SVID(id).Certificates
+
+	_, chains, err := x509svid.Verify(certs, bundle, x509svid.WithMaxChainDepth(2))
+	require.NoError(t, err)
+	assert.Len(t, chains, 1)
+
+	// The presented chain cannot fit, even if the last certificate was a root.
+	actualID, chains, err := x509svid.Verify(certs, bundle, x509svid.WithMaxChainDepth(0))
+	require.EqualError(t, err, "x509svid: chain has 1 intermediate certificates, exceeding the maximum of 0")
+	assert.Equal(t, id, actualID)
+	assert.Nil(t, chains)
+
+	// The verified chain is too deep.
+	_, _, err = x509svid.Verify(certs, bundle, x509svid.WithMaxChainDepth(1))
+	require.EqualError(t, err, "x509svid: chain has 2 intermediate certificates, exceeding the maximum of 1")
+	var chainErr *x509svid.ChainError
+	require.True(t, errors.As(err, &chainErr))
+	assert.Equal(t, x509svid.ChainTooDeep, chainErr.Reason)
+	assert.Equal(t, 2, chainErr.Depth)
+	assert.Equal(t, 1, chainErr.MaxDepth)
+}
+
+func TestVerifyPathLenConstraint(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	id := spiffeid.RequireFromPath(td, "/workload")
+	bundle := ca.X509Bundle()
+
+	constrained := ca.ChildCA(test.WithMaxPathLen(0))
+	certs := constrained.CreateX509SVID(id).Certificates
+	_, _, err := x509svid.Verify(certs, bundle)
+	require.NoError(t, err)
+
+	certs = constrained.ChildCA().CreateX509SVID(id).Certificates
+	_, chains, err := x509svid.Verify(certs, bundle)
+	require.Error(t, err)
+	assert.Nil(t, chains)
+	var chainErr *x509svid.ChainError
+	require.True(t, errors.As(err, &chainErr))
+	assert.Equal(t, x509svid.PathLenExceeded, chainErr.Reason)
+	assert.Equal(t, certs[2], chainErr.Certificate)
+	assert.EqualError(t, err, `x509svid: could not verify leaf certificate: path length constraint of CA "`+certs[2].Subject.String()+`" exceeded`)
+}
@@ -0,0 +1,113 @@
+package x509svid
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/url"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+var (
+	oidExtensionKeyUsage         = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtensionBasicConstraints = asn1.ObjectIdentifier{2, 5, 29, 19}
+	oidExtensionExtendedKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidExtKeyUsageServerAuth     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 1}
+	oidExtKeyUsageClientAuth     = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 2}
+)
+
+// CSROption is an option used when creating certificate signing requests.
+type CSROption interface {
+	apply(config *csrConfig)
+}
+
+// WithDNSNames sets DNS SANs on the certificate signing request, in addition
+// to the URI SAN with the SPIFFE ID.
+func WithDNSNames(dnsNames ...string) CSROption {
+	return csrOption(func(config *csrConfig) {
+		config.dnsNames = append(config.dnsNames, dnsNames...)
+	})
+}
+
+// WithSubject sets the subject of the certificate signing request. If not
+// used, the subject is empty, since X509-SVIDs are identified by the URI SAN
+// alone.
+func WithSubject(subject pkix.Name) CSROption {
+	return csrOption(func(config *csrConfig) {
+		config.subject = subject
+	})
+}
+
+// NewCSR returns an ASN.1 DER encoded certificate signing request for an
+// X509-SVID with the given SPIFFE ID, signed by the given key. The request
+// conforms to the X509-SVID specification: the SPIFFE ID is the only URI SAN
+// and the requested extensions mark the certificate as a non-CA leaf with
+// the digitalSignature key usage and the serverAuth and clientAuth extended
+// key usages. The key can be an opaque signer, e.g. backed by an HSM.
+// See https://github.com/spiffe/spiffe/blob/main/standards/X509-SVID.md
+func NewCSR(id spiffeid.ID, key crypto.Signer, opts ...CSROption) ([]byte, error) {
+	config := &csrConfig{}
+	for _, opt := range opts {
+		opt.apply(config)
+	}
+
+	switch {
+	case id.IsZero():
+		return nil, x509svidErr.New("SPIFFE ID is required")
+	case key == nil:
+		return nil, x509svidErr.New("key is required")
+	}
+
+	extensions, err := csrExtensions()
+	if err != nil {
+		return nil, x509svidErr.New("cannot marshal extensions: %w", err)
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:         config.subject,
+		URIs:            []*url.URL{id.URL()},
+		DNSNames:        config.dnsNames,
+		ExtraExtensions: extensions,
+	}, key)
+	if err != nil {
+		return nil, x509svidErr.New("cannot create certificate signing request: %w", err)
+	}
+	return csr, nil
+}
+
+func csrExtensions() ([]pkix.Extension, error) {
+	// digitalSignature is the first bit of the key usage bit string.
+	keyUsage, err := asn1.Marshal(asn1.BitString{Bytes: []byte{0x80}, BitLength: 1})
+	if err != nil {
+		return nil, err
+	}
+	// An empty sequence, since cA defaults to false.
+	basicConstraints, err := asn1.Marshal(struct{}{})
+	if err != nil {
+		return nil, err
+	}
+	extKeyUsage, err := asn1.Marshal([]asn1.ObjectIdentifier{oidExtKeyUsageServerAuth, oidExtKeyUsageClientAuth})
+	if err != nil {
+		return nil, err
+	}
+
+	return []pkix.Extension{
+		{Id: oidExtensionKeyUsage, Critical: true, Value: keyUsage},
+		{Id: oidExtensionBasicConstraints, Critical: true, Value: basicConstraints},
+		{Id: oidExtensionExtendedKeyUsage, Value: extKeyUsage},
+	}, nil
+}
+
+type csrConfig struct {
+	dnsNames []string
+	subject  pkix.Name
+}
+
+type csrOption func(config *csrConfig)
+
+func (fn csrOption) apply(config *csrConfig) {
+	fn(config)
+}
@@ -0,0 +1,76 @@
+package x509svid_test
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCSR(t *testing.T) {
+	id := spiffeid.RequireFromString("spiffe://example.org/workload")
+	key := test.NewEC256Key(t)
+
+	der, err := x509svid.NewCSR(id, opaqueSigner{key},
+		x509svid.WithDNSNames("workload.example.org"),
+		x509svid.WithSubject(pkix.Name{Organization: []string{"SPIFFE"}}),
+	)
+	require.NoError(t, err)
+
+	csr, err := x509.ParseCertificateRequest(der)
+	require.NoError(t, err)
+	require.NoError(t, csr.CheckSignature())
+	require.Len(t, csr.URIs, 1)
+	assert.Equal(t, id.String(), csr.URIs[0].String())
+	assert.Equal(t, []string{"workload.example.org"}, csr.DNSNames)
+	assert.Equal(t, []string{"SPIFFE"}, csr.Subject.Organization)
+
+	// A certificate issued with the requested extensions is a valid
+	// X509-SVID.
+	now := time.Now()
+	certDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		NotBefore:       now,
+		NotAfter:        now.Add(time.Hour),
+		URIs:            csr.URIs,
+		DNSNames:        csr.DNSNames,
+		ExtraExtensions: csr.Extensions,
+	}, &x509.Certificate{Subject: pkix.Name{CommonName: "CA"}}, csr.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err)
+	assert.Equal(t, x509.KeyUsageDigitalSignature, cert.KeyUsage)
+	assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}, cert.ExtKeyUsage)
+	assert.True(t, cert.BasicConstraintsValid)
+	assert.False(t, cert.IsCA)
+
+	svid, err := x509svid.FromSigner([]*x509.Certificate{cert}, key)
+	require.NoError(t, err)
+	assert.Equal(t, id, svid.ID)
+}
+
+func TestNewCSRWithoutOptions(t *testing.T) {
+	der, err := x509svid.NewCSR(spiffeid.RequireFromString("spiffe://example.org/workload"), test.NewEC256Key(t))
+	require.NoError(t, err)
+
+	csr, err := x509.ParseCertificateRequest(der)
+	require.NoError(t, err)
+	assert.Empty(t, csr.DNSNames)
+	assert.Empty(t, csr.Subject.String())
+}
+
+func TestNewCSRFailures(t *testing.T) {
+	_, err := x509svid.NewCSR(spiffeid.ID{}, test.NewEC256Key(t))
+	assert.EqualError(t, err, "x509svid: SPIFFE ID is required")
+
+	_, err = x509svid.NewCSR(spiffeid.RequireFromString("spiffe://example.org/workload"), nil)
+	assert.EqualError(t, err, "x509svid: key is required")
+}
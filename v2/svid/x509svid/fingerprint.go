@@ -0,0 +1,56 @@
+package x509svid
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+)
+
+// Fingerprint returns the SHA-256 fingerprint of the ASN.1 DER of the leaf
+// certificate of the X509-SVID, hex encoded. It is a canonical identifier for
+// the X509-SVID certificate, suitable for rotation detection, deduplication
+// and audit logging. If the X509-SVID has no certificates, an empty string is
+// returned.
+func (s *SVID) Fingerprint() string {
+	if len(s.Certificates) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(s.Certificates[0].Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainFingerprint returns the SHA-256 fingerprint of the certificates of the
+// X509-SVID, hex encoded. See ChainFingerprint.
+func (s *SVID) ChainFingerprint() string {
+	return ChainFingerprint(s.Certificates)
+}
+
+// Equal returns true if both X509-SVIDs have the same SPIFFE ID, certificates
+// and hint. Private keys are not compared, since the leaf certificate
+// already binds the public key of the X509-SVID.
+func (s *SVID) Equal(other *SVID) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	return s.ID == other.ID &&
+		s.Hint == other.Hint &&
+		x509util.CertsEqual(s.Certificates, other.Certificates)
+}
+
+// ChainFingerprint returns the SHA-256 fingerprint of the concatenated ASN.1
+// DER of the certificates, hex encoded, e.g. of the chains returned by
+// Verify. Since DER is self-delimiting, chains with different certificates or
+// different orders have different fingerprints. If there are no
+// certificates, an empty string is returned.
+func ChainFingerprint(certs []*x509.Certificate) string {
+	if len(certs) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, cert := range certs {
+		_, _ = h.Write(cert.Raw)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,54 @@
+package x509svid_test
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	svid, err := x509svid.Load(certMultiple, keyECDSA)
+	require.NoError(t, err)
+
+	leafSum := sha256.Sum256(svid.Certificates[0].Raw)
+	assert.Equal(t, hex.EncodeToString(leafSum[:]), svid.Fingerprint())
+
+	chainSum := sha256.Sum256(append(append([]byte{}, svid.Certificates[0].Raw...), svid.Certificates[1].Raw...))
+	assert.Equal(t, hex.EncodeToString(chainSum[:]), svid.ChainFingerprint())
+	assert.Equal(t, svid.ChainFingerprint(), x509svid.ChainFingerprint(svid.Certificates))
+
+	// The order of the certificates matters.
+	reversed := []*x509.Certificate{svid.Certificates[1], svid.Certificates[0]}
+	assert.NotEqual(t, svid.ChainFingerprint(), x509svid.ChainFingerprint(reversed))
+
+	empty := &x509svid.SVID{}
+	assert.Empty(t, empty.Fingerprint())
+	assert.Empty(t, empty.ChainFingerprint())
+}
+
+func TestEqual(t *testing.T) {
+	svid1, err := x509svid.Load(certMultiple, keyECDSA)
+	require.NoError(t, err)
+	svid2, err := x509svid.Load(certMultiple, keyECDSA)
+	require.NoError(t, err)
+	other, err := x509svid.Load(certSingle, keyRSA)
+	require.NoError(t, err)
+
+	assert.True(t, svid1.Equal(svid2))
+	assert.False(t, svid1.Equal(other))
+	assert.False(t, svid1.Equal(nil))
+	assert.True(t, (*x509svid.SVID)(nil).Equal(nil))
+
+	leafOnly := *svid2
+	leafOnly.Certificates = leafOnly.Certificates[:1]
+	assert.False(t, svid1.Equal(&leafOnly))
+
+	hinted := *svid2
+	hinted.Hint = "internal"
+	assert.False(t, svid1.Equal(&hinted))
+}
@@ -0,0 +1,97 @@
+package x509svid
+
+import "github.com/damarescavalcante/go-spiffe/v2/internal/pemutil"
+
+// MarshalOption is an option used when marshaling X509-SVIDs.
+type MarshalOption interface {
+	applyMarshal(config *marshalConfig)
+}
+
+// ParseOption is an option used when loading or parsing X509-SVIDs.
+type ParseOption interface {
+	applyParse(config *parseConfig)
+}
+
+// KeyPasswordOption is an option that can be used both as a MarshalOption and
+// a ParseOption.
+type KeyPasswordOption interface {
+	MarshalOption
+	ParseOption
+}
+
+// KeyDerivation is the function used to derive the key encrypting the
+// private key from its password.
+type KeyDerivation int
+
+const (
+	// KeyDerivationScrypt derives the key with scrypt. It is the default.
+	KeyDerivationScrypt KeyDerivation = iota
+
+	// KeyDerivationPBKDF2 derives the key with PBKDF2 and HMAC-SHA256, for
+	// consumers that do not support scrypt.
+	KeyDerivationPBKDF2
+)
+
+// WithKeyPassword provides the password protecting the private key. When
+// marshaling, the private key is emitted as an encrypted PKCS#8 PEM block
+// ("ENCRYPTED PRIVATE KEY"), encrypted with AES-256-CBC under a key derived
+// from the password (see WithKeyDerivation). When parsing, the encrypted
+// private key is decrypted with the password.
+func WithKeyPassword(password []byte) KeyPasswordOption {
+	return keyPasswordOption{password: password}
+}
+
+// WithKeyDerivation sets the function used to derive the key encrypting the
+// private key from the password provided with WithKeyPassword. If not used,
+// scrypt is used.
+func WithKeyDerivation(kdf KeyDerivation) MarshalOption {
+	return marshalOption(func(config *marshalConfig) {
+		config.kdf = kdf
+	})
+}
+
+type keyPasswordOption struct {
+	password []byte
+}
+
+func (o keyPasswordOption) applyMarshal(config *marshalConfig) {
+	config.password = o.password
+}
+
+func (o keyPasswordOption) applyParse(config *parseConfig) {
+	config.password = o.password
+}
+
+type marshalConfig struct {
+	password []byte
+	kdf      KeyDerivation
+}
+
+func (c *marshalConfig) pemKDF() pemutil.KDF {
+	switch c.kdf {
+	case KeyDerivationPBKDF2:
+		return pemutil.KDFPBKDF2
+	case KeyDerivationScrypt:
+		return pemutil.KDFScrypt
+	default:
+		return pemutil.KDF(c.kdf)
+	}
+}
+
+type marshalOption func(config *marshalConfig)
+
+func (fn marshalOption) applyMarshal(config *marshalConfig) {
+	fn(config)
+}
+
+type parseConfig struct {
+	password    []byte
+	keyPolicy   *KeyPolicy
+	strictOrder bool
+}
+
+type parseOption func(config *parseConfig)
+
+func (fn parseOption) applyParse(config *parseConfig) {
+	fn(config)
+}
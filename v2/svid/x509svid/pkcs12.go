@@ -0,0 +1,53 @@
+package x509svid
+
+import (
+	"crypto"
+	"crypto/x509"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/pkcs12util"
+)
+
+// ParsePKCS12 parses the X509-SVID from a PKCS#12 keystore (.p12 or .pfx)
+// protected with the given password, e.g. one exported from a Java or Windows
+// keystore. The keystore must contain a single private key and the X509-SVID
+// certificate chain. Keystores protected with PBES2 (e.g. AES, the default of
+// OpenSSL 3 and Java 20) and with the legacy PKCS#12 algorithms (3DES or RC2
+// encryption and an SHA-1 MAC) are supported, like the ones produced by
+// MarshalPKCS12 and MarshalPKCS12Legacy. Certificates out of order are
+// reordered like with Parse.
+func ParsePKCS12(data []byte, password string) (*SVID, error) {
+	privateKey, certs, err := pkcs12util.Decode(data, password)
+	if err != nil {
+		return nil, x509svidErr.New("cannot decode PKCS#12 keystore: %v", err)
+	}
+	return newParsedSVID(certs, privateKey, &parseConfig{})
+}
+
+// MarshalPKCS12 marshals the X509-SVID into a PKCS#12 keystore protected with
+// the given password, which can be consumed by Java and Windows components
+// that only understand .p12 keystores. The keystore is encrypted with PBES2
+// and AES-256, which is understood by OpenSSL 1.1.1, Java 12, Windows Server
+// 2019 and later versions; use MarshalPKCS12Legacy for older consumers.
+// Opaque private keys (see FromSigner) cannot be marshaled.
+func (s *SVID) MarshalPKCS12(password string) ([]byte, error) {
+	return s.marshalPKCS12(pkcs12util.Encode, password)
+}
+
+// MarshalPKCS12Legacy is like MarshalPKCS12, but the keystore is encrypted
+// with 3DES and its integrity protected with an SHA-1 MAC, the most widely
+// supported PKCS#12 algorithms. Since they are weak, the keystore should be
+// protected by other means.
+func (s *SVID) MarshalPKCS12Legacy(password string) ([]byte, error) {
+	return s.marshalPKCS12(pkcs12util.EncodeLegacy, password)
+}
+
+func (s *SVID) marshalPKCS12(encode func(crypto.PrivateKey, []*x509.Certificate, string) ([]byte, error), password string) ([]byte, error) {
+	if len(s.Certificates) == 0 {
+		return nil, x509svidErr.New("no certificates to marshal")
+	}
+	data, err := encode(s.PrivateKey, s.Certificates, password)
+	if err != nil {
+		return nil, x509svidErr.New("cannot encode PKCS#12 keystore: %v", err)
+	}
+	return data, nil
+}
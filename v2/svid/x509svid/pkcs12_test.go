@@ -0,0 +1,90 @@
+package x509svid_test
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPKCS12(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		certFile string
+		keyFile  string
+		password string
+	}{
+		{name: "RSA leaf only", certFile: certSingle, keyFile: keyRSA, password: "changeit"},
+		{name: "ECDSA leaf and intermediate", certFile: certMultiple, keyFile: keyECDSA, password: "changeit"},
+		{name: "empty password", certFile: certSingle, keyFile: keyRSA},
+		{name: "non-ASCII password", certFile: certSingle, keyFile: keyRSA, password: "pässwörd"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			svid, err := x509svid.Load(tt.certFile, tt.keyFile)
+			require.NoError(t, err)
+
+			for _, marshal := range []func(string) ([]byte, error){svid.MarshalPKCS12, svid.MarshalPKCS12Legacy} {
+				data, err := marshal(tt.password)
+				require.NoError(t, err)
+
+				parsed, err := x509svid.ParsePKCS12(data, tt.password)
+				require.NoError(t, err)
+				assert.Equal(t, svid, parsed)
+
+				_, err = x509svid.ParsePKCS12(data, tt.password+"wrong")
+				assert.EqualError(t, err, "x509svid: cannot decode PKCS#12 keystore: pkcs12: decryption password incorrect")
+			}
+		})
+	}
+}
+
+func TestParsePKCS12FromOpenSSL(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		file     string
+		certFile string
+		keyFile  string
+	}{
+		// openssl pkcs12 -export -passout pass:changeit
+		{name: "PBES2 and AES", file: "testdata/openssl3.p12", certFile: certMultiple, keyFile: keyECDSA},
+		// openssl pkcs12 -export -passout pass:changeit -certpbe PBE-SHA1-3DES -keypbe PBE-SHA1-3DES -macalg sha1
+		{name: "legacy 3DES", file: "testdata/openssl-legacy.p12", certFile: certSingle, keyFile: keyRSA},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			expected, err := x509svid.Load(tt.certFile, tt.keyFile)
+			require.NoError(t, err)
+			data, err := ioutil.ReadFile(tt.file)
+			require.NoError(t, err)
+
+			svid, err := x509svid.ParsePKCS12(data, "changeit")
+			require.NoError(t, err)
+			assert.Equal(t, expected, svid)
+		})
+	}
+}
+
+func TestMarshalPKCS12Failures(t *testing.T) {
+	_, err := (&x509svid.SVID{}).MarshalPKCS12("changeit")
+	assert.EqualError(t, err, "x509svid: no certificates to marshal")
+
+	svid, err := x509svid.Load(certSingle, keyRSA)
+	require.NoError(t, err)
+	privateKey := svid.PrivateKey
+	svid.PrivateKey = opaqueSigner{privateKey}
+	_, err = svid.MarshalPKCS12("changeit")
+	assert.EqualError(t, err, "x509svid: cannot encode PKCS#12 keystore: unsupported private key type x509svid_test.opaqueSigner")
+
+	svid.PrivateKey = privateKey
+	_, err = svid.MarshalPKCS12("\U0001F511")
+	assert.EqualError(t, err, "x509svid: cannot encode PKCS#12 keystore: pkcs12: string contains characters that cannot be encoded in UCS-2")
+}
+
+func TestParsePKCS12Failures(t *testing.T) {
+	_, err := x509svid.ParsePKCS12([]byte("not a keystore"), "changeit")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "x509svid: cannot decode PKCS#12 keystore:")
+}
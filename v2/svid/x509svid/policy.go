@@ -0,0 +1,132 @@
+package x509svid
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+)
+
+// KeyPolicy restricts the keys and signature algorithms of the certificates
+// of X509-SVIDs, e.g. to comply with regulatory requirements. The zero value
+// allows everything.
+type KeyPolicy struct {
+	// MinRSAKeySize is the minimum size, in bits, of RSA public keys. If
+	// zero, RSA keys of any size are allowed.
+	MinRSAKeySize int
+
+	// AllowedCurves are the elliptic curves allowed for ECDSA public keys. If
+	// empty, any curve is allowed.
+	AllowedCurves []elliptic.Curve
+
+	// AllowedSignatureAlgorithms are the signature algorithms allowed for
+	// certificate signatures. The signatures of self-signed roots are not
+	// checked since they are not relied upon. If empty, any signature
+	// algorithm is allowed.
+	AllowedSignatureAlgorithms []x509.SignatureAlgorithm
+}
+
+// PolicyViolationError is returned, possibly wrapped, when a certificate
+// does not comply with the KeyPolicy provided with WithKeyPolicy.
+type PolicyViolationError struct {
+	// Certificate is the certificate violating the policy.
+	Certificate *x509.Certificate
+
+	// Index is the position of the certificate in the chain, the leaf being
+	// at position 0.
+	Index int
+
+	// Reason describes the violation.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *PolicyViolationError) Error() string {
+	return fmt.Sprintf("certificate %d violates key policy: %s", e.Index, e.Reason)
+}
+
+// KeyPolicyOption is an option that can be used both as a ParseOption and a
+// VerifyOption.
+type KeyPolicyOption interface {
+	ParseOption
+	VerifyOption
+}
+
+// WithKeyPolicy enforces the key policy on the certificates of X509-SVIDs.
+// When parsing, the certificates of the X509-SVID are checked. When
+// verifying, the certificates of the verified chains are checked, including
+// the roots from the bundle, and only the chains complying with the policy
+// are returned. A *PolicyViolationError is returned if the policy is
+// violated.
+func WithKeyPolicy(policy KeyPolicy) KeyPolicyOption {
+	return keyPolicyOption{policy: &policy}
+}
+
+type keyPolicyOption struct {
+	policy *KeyPolicy
+}
+
+func (o keyPolicyOption) applyParse(config *parseConfig) {
+	config.keyPolicy = o.policy
+}
+
+func (o keyPolicyOption) apply(config *verifyConfig) {
+	config.keyPolicy = o.policy
+}
+
+// checkChain returns a *PolicyViolationError for the first certificate of the
+// chain violating the policy.
+func (p *KeyPolicy) checkChain(chain []*x509.Certificate) error {
+	for i, cert := range chain {
+		if reason := p.check(cert); reason != "" {
+			return &PolicyViolationError{
+				Certificate: cert,
+				Index:       i,
+				Reason:      reason,
+			}
+		}
+	}
+	return nil
+}
+
+func (p *KeyPolicy) check(cert *x509.Certificate) string {
+	switch publicKey := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if size := publicKey.N.BitLen(); size < p.MinRSAKeySize {
+			return fmt.Sprintf("RSA key size %d is below the minimum of %d", size, p.MinRSAKeySize)
+		}
+	case *ecdsa.PublicKey:
+		if len(p.AllowedCurves) > 0 && !containsCurve(p.AllowedCurves, publicKey.Curve) {
+			return fmt.Sprintf("elliptic curve %s is not allowed", publicKey.Curve.Params().Name)
+		}
+	}
+
+	if len(p.AllowedSignatureAlgorithms) > 0 && !isSelfSigned(cert) && !containsSignatureAlgorithm(p.AllowedSignatureAlgorithms, cert.SignatureAlgorithm) {
+		return fmt.Sprintf("signature algorithm %s is not allowed", cert.SignatureAlgorithm)
+	}
+	return ""
+}
+
+func containsCurve(curves []elliptic.Curve, curve elliptic.Curve) bool {
+	for _, c := range curves {
+		if c.Params().Name == curve.Params().Name {
+			return true
+		}
+	}
+	return false
+}
+
+func containsSignatureAlgorithm(algorithms []x509.SignatureAlgorithm, algorithm x509.SignatureAlgorithm) bool {
+	for _, a := range algorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
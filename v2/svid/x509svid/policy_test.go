@@ -0,0 +1,120 @@
+package x509svid_test
+
+import (
+	"crypto/elliptic"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWithKeyPolicy(t *testing.T) {
+	testCases := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		policy   x509svid.KeyPolicy
+		err      string
+	}{
+		{
+			name:     "zero policy",
+			certFile: certSingle,
+			keyFile:  keyRSA,
+		},
+		{
+			name:     "RSA key size allowed",
+			certFile: certSingle,
+			keyFile:  keyRSA,
+			policy:   x509svid.KeyPolicy{MinRSAKeySize: 2048},
+		},
+		{
+			name:     "RSA key size too small",
+			certFile: certSingle,
+			keyFile:  keyRSA,
+			policy:   x509svid.KeyPolicy{MinRSAKeySize: 3072},
+			err:      "x509svid: certificate 0 violates key policy: RSA key size 2048 is below the minimum of 3072",
+		},
+		{
+			name:     "curve allowed",
+			certFile: certMultiple,
+			keyFile:  keyECDSA,
+			policy:   x509svid.KeyPolicy{AllowedCurves: []elliptic.Curve{elliptic.P256(), elliptic.P384()}},
+		},
+		{
+			name:     "curve not allowed",
+			certFile: certMultiple,
+			keyFile:  keyECDSA,
+			policy:   x509svid.KeyPolicy{AllowedCurves: []elliptic.Curve{elliptic.P384()}},
+			err:      "x509svid: certificate 0 violates key policy: elliptic curve P-256 is not allowed",
+		},
+		{
+			name:     "signature algorithm allowed",
+			certFile: certMultiple,
+			keyFile:  keyECDSA,
+			policy:   x509svid.KeyPolicy{AllowedSignatureAlgorithms: []x509.SignatureAlgorithm{x509.ECDSAWithSHA384}},
+		},
+		{
+			name:     "signature algorithm not allowed",
+			certFile: certMultiple,
+			keyFile:  keyECDSA,
+			policy:   x509svid.KeyPolicy{AllowedSignatureAlgorithms: []x509.SignatureAlgorithm{x509.ECDSAWithSHA256}},
+			err:      "x509svid: certificate 0 violates key policy: signature algorithm ECDSA-SHA384 is not allowed",
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			svid, err := x509svid.Load(testCase.certFile, testCase.keyFile, x509svid.WithKeyPolicy(testCase.policy))
+			if testCase.err != "" {
+				require.EqualError(t, err, testCase.err)
+				assert.Nil(t, svid)
+
+				var policyErr *x509svid.PolicyViolationError
+				require.True(t, errors.As(err, &policyErr))
+				assert.Equal(t, 0, policyErr.Index)
+				assert.NotNil(t, policyErr.Certificate)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, svid)
+		})
+	}
+}
+
+func TestVerifyWithKeyPolicy(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	id := spiffeid.RequireFromPath(td, "/workload")
+	certs := ca.ChildCA().CreateX509SVID(id).Certificates
+	bundle := ca.X509Bundle()
+
+	actualID, chains, err := x509svid.Verify(certs, bundle, x509svid.WithKeyPolicy(x509svid.KeyPolicy{
+		MinRSAKeySize:              2048,
+		AllowedCurves:              []elliptic.Curve{elliptic.P256()},
+		AllowedSignatureAlgorithms: []x509.SignatureAlgorithm{x509.ECDSAWithSHA256},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, id, actualID)
+	assert.Len(t, chains, 1)
+
+	actualID, chains, err = x509svid.Verify(certs, bundle, x509svid.WithKeyPolicy(x509svid.KeyPolicy{
+		AllowedCurves: []elliptic.Curve{elliptic.P384()},
+	}))
+	require.EqualError(t, err, "x509svid: certificate 0 violates key policy: elliptic curve P-256 is not allowed")
+	assert.Equal(t, id, actualID)
+	assert.Nil(t, chains)
+
+	_, _, err = x509svid.Verify(certs, bundle, x509svid.WithKeyPolicy(x509svid.KeyPolicy{
+		AllowedSignatureAlgorithms: []x509.SignatureAlgorithm{x509.ECDSAWithSHA384},
+	}))
+	require.EqualError(t, err, "x509svid: certificate 0 violates key policy: signature algorithm ECDSA-SHA256 is not allowed")
+	var policyErr *x509svid.PolicyViolationError
+	require.True(t, errors.As(err, &policyErr))
+	assert.Equal(t, certs[0], policyErr.Certificate)
+}
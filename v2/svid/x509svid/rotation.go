@@ -0,0 +1,73 @@
+package x509svid
+
+import (
+	"time"
+)
+
+// RotationPolicy computes when an X509-SVID should be rotated, given the
+// validity period of its leaf certificate.
+type RotationPolicy interface {
+	// RotationTime returns the time at which a certificate valid from
+	// notBefore to notAfter should be rotated.
+	RotationTime(notBefore, notAfter time.Time) time.Time
+}
+
+// RotationPolicyFunc is a function adapter to a RotationPolicy.
+type RotationPolicyFunc func(notBefore, notAfter time.Time) time.Time
+
+// RotationTime calls fn.
+func (fn RotationPolicyFunc) RotationTime(notBefore, notAfter time.Time) time.Time {
+	return fn(notBefore, notAfter)
+}
+
+// HalfLife returns a policy rotating X509-SVIDs halfway through their
+// lifetime, as the Workload API does. It is the default policy.
+func HalfLife() RotationPolicy {
+	return FractionOfLifetime(0.5)
+}
+
+// FractionOfLifetime returns a policy rotating X509-SVIDs once the given
+// fraction of their lifetime has elapsed, e.g. 0.8 to rotate them when a
+// fifth of their lifetime is left. Fractions outside of [0, 1] are clamped.
+func FractionOfLifetime(fraction float64) RotationPolicy {
+	switch {
+	case fraction < 0:
+		fraction = 0
+	case fraction > 1:
+		fraction = 1
+	}
+	return RotationPolicyFunc(func(notBefore, notAfter time.Time) time.Time {
+		lifetime := notAfter.Sub(notBefore)
+		return notBefore.Add(time.Duration(float64(lifetime) * fraction))
+	})
+}
+
+// RotationTime returns the time at which the X509-SVID should be rotated
+// according to the policy, based on the validity period of the leaf
+// certificate. If the policy is nil, HalfLife is used. If the X509-SVID has
+// no certificates, the zero time is returned.
+func (s *SVID) RotationTime(policy RotationPolicy) time.Time {
+	if len(s.Certificates) == 0 {
+		return time.Time{}
+	}
+	if policy == nil {
+		policy = HalfLife()
+	}
+	leaf := s.Certificates[0]
+	return policy.RotationTime(leaf.NotBefore, leaf.NotAfter)
+}
+
+// RotationTimer returns a timer firing at the rotation time of the X509-SVID
+// according to the policy (see RotationTime). The timer fires immediately if
+// the rotation time has passed. It is meant for rotation loops, e.g. to renew
+// the X509-SVID or to close connections established with it, and should be
+// stopped when no longer needed. Connections created by the spiffetls package
+// are not closed on rotation; applications that need it can close them when
+// the timer fires.
+func (s *SVID) RotationTimer(policy RotationPolicy) *time.Timer {
+	d := time.Until(s.RotationTime(policy))
+	if d < 0 {
+		d = 0
+	}
+	return time.NewTimer(d)
+}
@@ -0,0 +1,55 @@
+package x509svid_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotationTime(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	now := time.Now().Truncate(time.Second)
+	ca := test.NewCA(t, td)
+	svid := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"), test.WithLifetime(now, now.Add(time.Hour)))
+
+	assert.Equal(t, now.Add(30*time.Minute), svid.RotationTime(nil).Local())
+	assert.Equal(t, now.Add(30*time.Minute), svid.RotationTime(x509svid.HalfLife()).Local())
+	assert.Equal(t, now.Add(48*time.Minute), svid.RotationTime(x509svid.FractionOfLifetime(0.8)).Local())
+	assert.Equal(t, now, svid.RotationTime(x509svid.FractionOfLifetime(-1)).Local())
+	assert.Equal(t, now.Add(time.Hour), svid.RotationTime(x509svid.FractionOfLifetime(2)).Local())
+
+	beforeExpiry := x509svid.RotationPolicyFunc(func(notBefore, notAfter time.Time) time.Time {
+		return notAfter.Add(-5 * time.Minute)
+	})
+	assert.Equal(t, now.Add(55*time.Minute), svid.RotationTime(beforeExpiry).Local())
+
+	empty := &x509svid.SVID{}
+	assert.True(t, empty.RotationTime(nil).IsZero())
+}
+
+func TestRotationTimer(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	now := time.Now()
+	ca := test.NewCA(t, td)
+
+	expiring := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"), test.WithLifetime(now.Add(-time.Hour), now.Add(time.Minute)))
+	timer := expiring.RotationTimer(nil)
+	select {
+	case <-timer.C:
+	case <-time.After(time.Second):
+		assert.Fail(t, "timer did not fire for a rotation time in the past")
+	}
+
+	fresh := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"), test.WithLifetime(now, now.Add(time.Hour)))
+	timer = fresh.RotationTimer(nil)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		assert.Fail(t, "timer fired before the rotation time")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,129 @@
+package x509svid
+
+import (
+	"sync"
+
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// Set is an ordered set of X509-SVIDs, e.g. the X509-SVIDs of a workload with
+// multiple identities, with lookups by SPIFFE ID, hint or DNS name. It is safe
+// for concurrent use.
+type Set struct {
+	mtx   sync.RWMutex
+	svids []*SVID
+}
+
+// NewSet creates a new set initialized with the given X509-SVIDs, as with
+// Add.
+func NewSet(svids ...*SVID) *Set {
+	s := &Set{}
+	for _, svid := range svids {
+		s.Add(svid)
+	}
+	return s
+}
+
+// Add adds an X509-SVID to the end of the set. If the set already has an
+// X509-SVID with the same SPIFFE ID and hint, it is replaced in place.
+func (s *Set) Add(svid *SVID) {
+	if svid == nil {
+		return
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, existing := range s.svids {
+		if existing.ID == svid.ID && existing.Hint == svid.Hint {
+			s.svids[i] = svid
+			return
+		}
+	}
+	s.svids = append(s.svids, svid)
+}
+
+// Len returns the number of X509-SVIDs in the set.
+func (s *Set) Len() int {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return len(s.svids)
+}
+
+// SVIDs returns the X509-SVIDs in the set, in the order they were added. The
+// returned slice can be modified by the caller.
+func (s *Set) SVIDs() []*SVID {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	return append([]*SVID(nil), s.svids...)
+}
+
+// GetByID returns the first X509-SVID with the given SPIFFE ID. If there is
+// one, it is returned and the boolean is true. Otherwise, the returned value
+// is nil and the boolean is false.
+func (s *Set) GetByID(id spiffeid.ID) (*SVID, bool) {
+	return s.find(func(svid *SVID) bool {
+		return svid.ID == id
+	})
+}
+
+// GetByHint returns the first X509-SVID with the given hint. If there is one,
+// it is returned and the boolean is true. Otherwise, the returned value is
+// nil and the boolean is false.
+func (s *Set) GetByHint(hint string) (*SVID, bool) {
+	return s.find(func(svid *SVID) bool {
+		return svid.Hint == hint
+	})
+}
+
+// GetByDNSName returns the first X509-SVID valid for the given DNS name, e.g.
+// the server name of a TLS handshake (see SVID.MatchesDNSName). If there is
+// one, it is returned and the boolean is true. Otherwise, the returned value
+// is nil and the boolean is false.
+func (s *Set) GetByDNSName(name string) (*SVID, bool) {
+	return s.find(func(svid *SVID) bool {
+		return svid.MatchesDNSName(name)
+	})
+}
+
+// Source returns a Source returning the default X509-SVID of the set, as
+// chosen by the picker from the X509-SVIDs of the set at the time of the
+// call. If the picker is nil, the first X509-SVID is the default, as with the
+// Workload API.
+func (s *Set) Source(picker func([]*SVID) *SVID) Source {
+	return setSource{set: s, picker: picker}
+}
+
+func (s *Set) find(match func(*SVID) bool) (*SVID, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	for _, svid := range s.svids {
+		if match(svid) {
+			return svid, true
+		}
+	}
+	return nil, false
+}
+
+type setSource struct {
+	set    *Set
+	picker func([]*SVID) *SVID
+}
+
+func (s setSource) GetX509SVID() (*SVID, error) {
+	svids := s.set.SVIDs()
+	if len(svids) == 0 {
+		return nil, x509svidErr.New("no X509-SVIDs in set")
+	}
+	if s.picker == nil {
+		return svids[0], nil
+	}
+	svid := s.picker(svids)
+	if svid == nil {
+		return nil, x509svidErr.New("no default X509-SVID picked from set")
+	}
+	return svid, nil
+}
@@ -0,0 +1,81 @@
+package x509svid_test
+
+import (
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	id1 := spiffeid.RequireFromPath(td, "/api")
+	id2 := spiffeid.RequireFromPath(td, "/admin")
+	svid1 := ca.CreateX509SVID(id1, test.WithHint("external"), test.WithDNSNames("api.example.org"))
+	svid2 := ca.CreateX509SVID(id2, test.WithHint("internal"), test.WithDNSNames("*.internal.example.org"))
+
+	set := x509svid.NewSet(svid1, nil, svid2)
+	assert.Equal(t, 2, set.Len())
+	assert.Equal(t, []*x509svid.SVID{svid1, svid2}, set.SVIDs())
+
+	svid, ok := set.GetByID(id2)
+	assert.True(t, ok)
+	assert.Equal(t, svid2, svid)
+	svid, ok = set.GetByHint("external")
+	assert.True(t, ok)
+	assert.Equal(t, svid1, svid)
+	svid, ok = set.GetByDNSName("admin.internal.example.org")
+	assert.True(t, ok)
+	assert.Equal(t, svid2, svid)
+
+	svid, ok = set.GetByID(spiffeid.RequireFromPath(td, "/other"))
+	assert.False(t, ok)
+	assert.Nil(t, svid)
+	_, ok = set.GetByHint("other")
+	assert.False(t, ok)
+	_, ok = set.GetByDNSName("example.org")
+	assert.False(t, ok)
+
+	// An X509-SVID with the same ID and hint is replaced in place.
+	rotated := ca.CreateX509SVID(id1, test.WithHint("external"))
+	set.Add(rotated)
+	assert.Equal(t, []*x509svid.SVID{rotated, svid2}, set.SVIDs())
+
+	// An X509-SVID with the same ID but another hint is added.
+	other := ca.CreateX509SVID(id1, test.WithHint("legacy"))
+	set.Add(other)
+	assert.Equal(t, []*x509svid.SVID{rotated, svid2, other}, set.SVIDs())
+}
+
+func TestSetSource(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	svid1 := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/one"))
+	svid2 := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/two"))
+	set := x509svid.NewSet()
+
+	source := set.Source(nil)
+	_, err := source.GetX509SVID()
+	require.EqualError(t, err, "x509svid: no X509-SVIDs in set")
+
+	set.Add(svid1)
+	set.Add(svid2)
+	svid, err := source.GetX509SVID()
+	require.NoError(t, err)
+	assert.Equal(t, svid1, svid)
+
+	source = set.Source(func(svids []*x509svid.SVID) *x509svid.SVID {
+		return svids[len(svids)-1]
+	})
+	svid, err = source.GetX509SVID()
+	require.NoError(t, err)
+	assert.Equal(t, svid2, svid)
+
+	source = set.Source(func([]*x509svid.SVID) *x509svid.SVID { return nil })
+	_, err = source.GetX509SVID()
+	require.EqualError(t, err, "x509svid: no default X509-SVID picked from set")
+}
@@ -1,7 +1,34 @@
 package x509svid
 
+import (
+	"crypto/tls"
+	"crypto/x509"
+)
+
 // Source represents a source of X509-SVIDs.
 type Source interface {
 	// GetX509SVID returns an X509-SVID from the source.
 	GetX509SVID() (*SVID, error)
 }
+
+// FromTLSCertificate returns an X509-SVID from a TLS certificate, e.g. one
+// loaded with tls.LoadX509KeyPair or provisioned by other means. The
+// certificates are validated like the ones parsed by Parse, and the private
+// key must be a crypto.Signer. Since an SVID implements Source, the result
+// can be used wherever a Source is expected, as can the SVIDs returned by
+// Parse for static PEM bytes. For files that are reloaded when they change,
+// see workloadapi.FileX509Source.
+func FromTLSCertificate(cert *tls.Certificate) (*SVID, error) {
+	if cert == nil {
+		return nil, x509svidErr.New("TLS certificate is required")
+	}
+	certs := make([]*x509.Certificate, 0, len(cert.Certificate))
+	for _, rawCert := range cert.Certificate {
+		c, err := x509.ParseCertificate(rawCert)
+		if err != nil {
+			return nil, x509svidErr.New("cannot parse DER encoded certificate: %v", err)
+		}
+		certs = append(certs, c)
+	}
+	return newParsedSVID(certs, cert.PrivateKey, &parseConfig{})
+}
@@ -0,0 +1,36 @@
+package x509svid_test
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromTLSCertificate(t *testing.T) {
+	expected, err := x509svid.Load(certMultiple, keyECDSA)
+	require.NoError(t, err)
+	tlsCert, err := tls.LoadX509KeyPair(certMultiple, keyECDSA)
+	require.NoError(t, err)
+
+	svid, err := x509svid.FromTLSCertificate(&tlsCert)
+	require.NoError(t, err)
+	assert.Equal(t, expected, svid)
+
+	var source x509svid.Source = svid
+	actual, err := source.GetX509SVID()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	_, err = x509svid.FromTLSCertificate(nil)
+	assert.EqualError(t, err, "x509svid: TLS certificate is required")
+
+	_, err = x509svid.FromTLSCertificate(&tls.Certificate{Certificate: [][]byte{[]byte("not a certificate")}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "x509svid: cannot parse DER encoded certificate")
+
+	_, err = x509svid.FromTLSCertificate(&tls.Certificate{Certificate: tlsCert.Certificate})
+	assert.EqualError(t, err, "x509svid: private key validation failed: no private key found")
+}
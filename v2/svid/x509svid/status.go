@@ -0,0 +1,164 @@
+package x509svid
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/fileutil"
+)
+
+// StatusChecker checks the status of the certificates of a verified X509-SVID
+// chain, e.g. whether they have been revoked.
+type StatusChecker interface {
+	// CheckStatus is called with a verified chain, ordered from the leaf to
+	// the root, and returns an error if the chain must be rejected.
+	CheckStatus(chain []*x509.Certificate) error
+}
+
+// StatusCheckerFunc is a function adapter to a StatusChecker.
+type StatusCheckerFunc func(chain []*x509.Certificate) error
+
+// CheckStatus calls fn.
+func (fn StatusCheckerFunc) CheckStatus(chain []*x509.Certificate) error {
+	return fn(chain)
+}
+
+// WithStatusChecker sets a checker called with every verified chain. Only
+// the chains accepted by the checker are returned; if it rejects all of them,
+// verification fails with the error of the first one.
+func WithStatusChecker(checker StatusChecker) VerifyOption {
+	return verifyOption(func(config *verifyConfig) {
+		config.statusChecker = checker
+	})
+}
+
+// CRLFileChecker is a StatusChecker rejecting chains with certificates
+// revoked by certificate revocation lists (CRLs) loaded from files. Each file
+// may hold PEM encoded ("X509 CRL") or ASN.1 DER CRLs. A CRL applies to the
+// certificates issued by the next certificate of the chain if its issuer
+// matches and its signature is valid. Certificates without an applicable CRL
+// are not considered revoked, and stale CRLs keep being used until replaced.
+// The files are reloaded when they change; if a reload fails, the previously
+// loaded CRLs are kept. It is safe for concurrent use.
+type CRLFileChecker struct {
+	paths []string
+
+	mtx    sync.Mutex
+	stamps []fileutil.Stamp
+	crls   [][]*pkix.CertificateList
+}
+
+// NewCRLFileChecker creates a new CRLFileChecker loading CRLs from the given
+// files. An error is returned if the files cannot be loaded initially.
+func NewCRLFileChecker(paths ...string) (*CRLFileChecker, error) {
+	c := &CRLFileChecker{
+		paths:  paths,
+		stamps: make([]fileutil.Stamp, len(paths)),
+		crls:   make([][]*pkix.CertificateList, len(paths)),
+	}
+	for i := range paths {
+		if err := c.load(i); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// CheckStatus returns an error if a certificate of the chain is revoked. It
+// implements the StatusChecker interface.
+func (c *CRLFileChecker) CheckStatus(chain []*x509.Certificate) error {
+	crls := c.currentCRLs()
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+		for _, crl := range crls {
+			if !crlIssuedBy(crl, issuer) {
+				continue
+			}
+			for _, revoked := range crl.TBSCertList.RevokedCertificates {
+				if revoked.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+					return fmt.Errorf("certificate %d with serial number %s is revoked", i, cert.SerialNumber)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *CRLFileChecker) currentCRLs() []*pkix.CertificateList {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var crls []*pkix.CertificateList
+	for i, path := range c.paths {
+		if stamp, err := fileutil.Stat(path); err == nil && !stamp.Equal(c.stamps[i]) {
+			// Keep using the previous CRLs if the file cannot be loaded.
+			_ = c.load(i)
+		}
+		crls = append(crls, c.crls[i]...)
+	}
+	return crls
+}
+
+func (c *CRLFileChecker) load(i int) error {
+	stamp, err := fileutil.Stat(c.paths[i])
+	if err != nil {
+		return x509svidErr.New("cannot read CRL file: %w", err)
+	}
+	// The file is not loaded again until it changes, even if loading it
+	// fails.
+	c.stamps[i] = stamp
+
+	data, err := ioutil.ReadFile(c.paths[i])
+	if err != nil {
+		return x509svidErr.New("cannot read CRL file: %w", err)
+	}
+	crls, err := parseCRLs(data)
+	if err != nil {
+		return x509svidErr.New("cannot parse CRL file %q: %w", c.paths[i], err)
+	}
+	c.crls[i] = crls
+	return nil
+}
+
+func parseCRLs(data []byte) ([]*pkix.CertificateList, error) {
+	var crls []*pkix.CertificateList
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "X509 CRL" {
+			continue
+		}
+		crl, err := x509.ParseDERCRL(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		crls = append(crls, crl)
+	}
+	if len(crls) > 0 {
+		return crls, nil
+	}
+
+	crl, err := x509.ParseDERCRL(data)
+	if err != nil {
+		return nil, err
+	}
+	return []*pkix.CertificateList{crl}, nil
+}
+
+func crlIssuedBy(crl *pkix.CertificateList, issuer *x509.Certificate) bool {
+	rawIssuer, err := asn1.Marshal(crl.TBSCertList.Issuer)
+	if err != nil || !bytes.Equal(rawIssuer, issuer.RawSubject) {
+		return false
+	}
+	return issuer.CheckCRLSignature(crl) == nil
+}
@@ -0,0 +1,115 @@
+package x509svid_test
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyWithStatusChecker(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	id := spiffeid.RequireFromPath(td, "/workload")
+	certs := ca.CreateX509SVID(id).Certificates
+	bundle := ca.X509Bundle()
+
+	var checked [][]*x509.Certificate
+	actualID, chains, err := x509svid.Verify(certs, bundle, x509svid.WithStatusChecker(x509svid.StatusCheckerFunc(func(chain []*x509.Certificate) error {
+		checked = append(checked, chain)
+		return nil
+	})))
+	require.NoError(t, err)
+	assert.Equal(t, id, actualID)
+	assert.Equal(t, chains, checked)
+
+	actualID, chains, err = x509svid.Verify(certs, bundle, x509svid.WithStatusChecker(x509svid.StatusCheckerFunc(func(chain []*x509.Certificate) error {
+		return errors.New("revoked")
+	})))
+	require.EqualError(t, err, "x509svid: certificate status check failed: revoked")
+	assert.Equal(t, id, actualID)
+	assert.Nil(t, chains)
+}
+
+func TestCRLFileChecker(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	rootCert, rootKey := test.CreateCACertificate(t, nil, nil)
+	intermediateCert, intermediateKey := test.CreateCACertificate(t, rootCert, rootKey,
+		test.WithKeyUsage(x509.KeyUsageCertSign|x509.KeyUsageCRLSign))
+	leaf1, _ := test.CreateX509SVID(t, intermediateCert, intermediateKey, spiffeid.RequireFromPath(td, "/one"))
+	leaf2, _ := test.CreateX509SVID(t, intermediateCert, intermediateKey, spiffeid.RequireFromPath(td, "/two"))
+	chain1 := []*x509.Certificate{leaf1, intermediateCert}
+	chain2 := []*x509.Certificate{leaf2, intermediateCert}
+	bundle := x509bundle.FromX509Authorities(td, []*x509.Certificate{rootCert})
+
+	crlFile := filepath.Join(t.TempDir(), "crl.pem")
+	writeCRL := func(modTime time.Time, serials ...*big.Int) {
+		var revoked []pkix.RevokedCertificate
+		for _, serial := range serials {
+			revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: time.Now()})
+		}
+		crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+			Number:              big.NewInt(modTime.Unix()),
+			ThisUpdate:          time.Now(),
+			NextUpdate:          time.Now().Add(time.Hour),
+			RevokedCertificates: revoked,
+		}, intermediateCert, intermediateKey)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), 0600))
+		require.NoError(t, os.Chtimes(crlFile, modTime, modTime))
+	}
+
+	_, err := x509svid.NewCRLFileChecker(crlFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "x509svid: cannot read CRL file")
+
+	modTime := time.Now().Add(-time.Hour)
+	writeCRL(modTime, leaf1.SerialNumber)
+	checker, err := x509svid.NewCRLFileChecker(crlFile)
+	require.NoError(t, err)
+
+	_, _, err = x509svid.Verify(chain1, bundle, x509svid.WithStatusChecker(checker))
+	require.EqualError(t, err, "x509svid: certificate status check failed: certificate 0 with serial number "+leaf1.SerialNumber.String()+" is revoked")
+	_, _, err = x509svid.Verify(chain2, bundle, x509svid.WithStatusChecker(checker))
+	require.NoError(t, err)
+
+	// The CRL file is reloaded when it changes.
+	writeCRL(modTime.Add(time.Second), leaf2.SerialNumber)
+	_, _, err = x509svid.Verify(chain1, bundle, x509svid.WithStatusChecker(checker))
+	require.NoError(t, err)
+	_, _, err = x509svid.Verify(chain2, bundle, x509svid.WithStatusChecker(checker))
+	require.Error(t, err)
+
+	// CRLs signed by another issuer are ignored.
+	otherCert, otherKey := test.CreateCACertificate(t, nil, nil, test.WithKeyUsage(x509.KeyUsageCertSign|x509.KeyUsageCRLSign))
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(1),
+		ThisUpdate:          time.Now(),
+		NextUpdate:          time.Now().Add(time.Hour),
+		RevokedCertificates: []pkix.RevokedCertificate{{SerialNumber: leaf1.SerialNumber, RevocationTime: time.Now()}},
+	}, otherCert, otherKey)
+	require.NoError(t, err)
+	derFile := filepath.Join(t.TempDir(), "crl.der")
+	require.NoError(t, os.WriteFile(derFile, crl, 0600))
+	checker, err = x509svid.NewCRLFileChecker(derFile)
+	require.NoError(t, err)
+	require.NoError(t, checker.CheckStatus(chain1))
+
+	require.NoError(t, os.WriteFile(derFile, []byte("not a CRL"), 0600))
+	_, err = x509svid.NewCRLFileChecker(derFile)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "x509svid: cannot parse CRL file")
+}
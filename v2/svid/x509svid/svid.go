@@ -5,7 +5,9 @@ import (
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/pem"
 	"io/ioutil"
+	"time"
 
 	"github.com/damarescavalcante/go-spiffe/v2/internal/pemutil"
 	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
@@ -24,7 +26,10 @@ type SVID struct {
 	// trust domain.
 	Certificates []*x509.Certificate
 
-	// PrivateKey is the private key for the X509-SVID.
+	// PrivateKey is the private key for the X509-SVID. It does not need to be
+	// an in-memory key; any crypto.Signer can be used, e.g. one backed by an
+	// HSM, a TPM or a cloud KMS (see FromSigner). Such opaque signers cannot
+	// be marshaled.
 	PrivateKey crypto.Signer
 
 	// Hint is an operator-specified string used to provide guidance on how this
@@ -33,8 +38,10 @@ type SVID struct {
 }
 
 // Load loads the X509-SVID from PEM encoded files on disk. certFile and
-// keyFile may be the same file.
-func Load(certFile, keyFile string) (*SVID, error) {
+// keyFile may be the same file. If the private key is encrypted, its password
+// must be provided with WithKeyPassword. ASN.1 DER encoded files are also
+// supported, as with Parse.
+func Load(certFile, keyFile string, opts ...ParseOption) (*SVID, error) {
 	certBytes, err := ioutil.ReadFile(certFile)
 	if err != nil {
 		return nil, x509svidErr.New("cannot read certificate file: %w", err)
@@ -45,31 +52,73 @@ func Load(certFile, keyFile string) (*SVID, error) {
 		return nil, x509svidErr.New("cannot read key file: %w", err)
 	}
 
-	return Parse(certBytes, keyBytes)
+	return Parse(certBytes, keyBytes, opts...)
 }
 
 // Parse parses the X509-SVID from PEM blocks containing certificate and key
 // bytes. The certificate must be one or more PEM blocks with ASN.1 DER. The
-// key must be a PEM block with PKCS#8 ASN.1 DER.
-func Parse(certBytes, keyBytes []byte) (*SVID, error) {
-	certs, err := pemutil.ParseCertificates(certBytes)
-	if err != nil {
-		return nil, x509svidErr.New("cannot parse PEM encoded certificate: %v", err)
+// key must be a PEM block with PKCS#8 ASN.1 DER. If the key is an encrypted
+// PKCS#8 PEM block, its password must be provided with WithKeyPassword.
+// Certificates out of order are reordered from the leaf to the root, unless
+// WithStrictOrder is used.
+// If the certificate or key bytes hold no PEM blocks, they are parsed as in
+// ParseRaw instead, i.e. as concatenated ASN.1 DER certificates and a PKCS#8
+// ASN.1 DER key, which is how some tools deliver the material.
+func Parse(certBytes, keyBytes []byte, opts ...ParseOption) (*SVID, error) {
+	config := &parseConfig{}
+	for _, opt := range opts {
+		opt.applyParse(config)
+	}
+
+	var certs []*x509.Certificate
+	var err error
+	if isDER(certBytes) {
+		certs, err = x509.ParseCertificates(certBytes)
+		if err != nil {
+			return nil, x509svidErr.New("cannot parse DER encoded certificate: %v", err)
+		}
+	} else {
+		certs, err = pemutil.ParseCertificates(certBytes)
+		if err != nil {
+			return nil, x509svidErr.New("cannot parse PEM encoded certificate: %v", err)
+		}
 	}
 
-	privateKey, err := pemutil.ParsePrivateKey(keyBytes)
+	var privateKey crypto.PrivateKey
+	switch {
+	case config.password == nil && isDER(keyBytes):
+		privateKey, err = x509.ParsePKCS8PrivateKey(keyBytes)
+		if err != nil {
+			return nil, x509svidErr.New("cannot parse DER encoded private key: %v", err)
+		}
+	case config.password != nil:
+		privateKey, err = pemutil.ParseEncryptedPrivateKey(keyBytes, config.password)
+	case pemutil.HasEncryptedPrivateKey(keyBytes):
+		return nil, x509svidErr.New("private key is encrypted but no password was provided")
+	default:
+		privateKey, err = pemutil.ParsePrivateKey(keyBytes)
+	}
 	if err != nil {
 		return nil, x509svidErr.New("cannot parse PEM encoded private key: %v", err)
 	}
 
-	return newSVID(certs, privateKey)
+	return newParsedSVID(certs, privateKey, config)
 }
 
 // ParseRaw parses the X509-SVID from certificate and key bytes. The
 // certificate must be ASN.1 DER (concatenated with no intermediate
 // padding if there are more than one certificate). The key must be a PKCS#8
-// ASN.1 DER.
-func ParseRaw(certBytes, keyBytes []byte) (*SVID, error) {
+// ASN.1 DER. Encrypted keys are not supported, so WithKeyPassword cannot be
+// used. Certificates out of order are reordered like with Parse.
+func ParseRaw(certBytes, keyBytes []byte, opts ...ParseOption) (*SVID, error) {
+	config := &parseConfig{}
+	for _, opt := range opts {
+		opt.applyParse(config)
+	}
+	if config.password != nil {
+		return nil, x509svidErr.New("key passwords are not supported for DER encoded private keys")
+	}
+
 	certificates, err := x509.ParseCertificates(certBytes)
 	if err != nil {
 		return nil, x509svidErr.New("cannot parse DER encoded certificate: %v", err)
@@ -80,17 +129,41 @@ func ParseRaw(certBytes, keyBytes []byte) (*SVID, error) {
 		return nil, x509svidErr.New("cannot parse DER encoded private key: %v", err)
 	}
 
-	return newSVID(certificates, privateKey)
+	return newParsedSVID(certificates, privateKey, config)
+}
+
+// FromSigner returns an X509-SVID from already parsed certificates and a
+// signer for the private key of the leaf certificate. The signer can be
+// opaque, e.g. backed by an HSM, a TPM or a cloud KMS, so that the private
+// key never has to live in process memory. The certificates are validated
+// like the ones parsed by Parse, and the public key of the signer must match
+// the one of the leaf certificate.
+// When used for TLS handshakes, the signer must support the signature
+// algorithms negotiated by crypto/tls, i.e. RSA-PSS for RSA keys under TLS 1.3.
+func FromSigner(certificates []*x509.Certificate, signer crypto.Signer) (*SVID, error) {
+	return newSVID(certificates, signer)
 }
 
 // Marshal marshals the X509-SVID and returns PEM encoded blocks for the SVID
-// and private key.
-func (s *SVID) Marshal() ([]byte, []byte, error) {
+// and private key. If a password is provided with WithKeyPassword, the private
+// key is encrypted.
+func (s *SVID) Marshal(opts ...MarshalOption) ([]byte, []byte, error) {
+	config := &marshalConfig{}
+	for _, opt := range opts {
+		opt.applyMarshal(config)
+	}
+
 	if len(s.Certificates) == 0 {
 		return nil, nil, x509svidErr.New("no certificates to marshal")
 	}
 	certBytes := pemutil.EncodeCertificates(s.Certificates)
-	keyBytes, err := pemutil.EncodePKCS8PrivateKey(s.PrivateKey)
+	var keyBytes []byte
+	var err error
+	if config.password != nil {
+		keyBytes, err = pemutil.EncodeEncryptedPKCS8PrivateKey(s.PrivateKey, config.password, config.pemKDF())
+	} else {
+		keyBytes, err = pemutil.EncodePKCS8PrivateKey(s.PrivateKey)
+	}
 	if err != nil {
 		return nil, nil, x509svidErr.New("cannot encode private key: %v", err)
 	}
@@ -120,6 +193,96 @@ func (s *SVID) GetX509SVID() (*SVID, error) {
 	return s, nil
 }
 
+// DNSNames returns the DNS SANs of the leaf certificate of the X509-SVID. If
+// the X509-SVID has no certificates, nil is returned.
+func (s *SVID) DNSNames() []string {
+	if len(s.Certificates) == 0 {
+		return nil
+	}
+	return append([]string(nil), s.Certificates[0].DNSNames...)
+}
+
+// MatchesDNSName returns true if the leaf certificate of the X509-SVID is
+// valid for the given DNS name, e.g. the server name of a TLS handshake,
+// taking wildcard DNS SANs into account.
+func (s *SVID) MatchesDNSName(name string) bool {
+	if len(s.Certificates) == 0 {
+		return false
+	}
+	return s.Certificates[0].VerifyHostname(name) == nil
+}
+
+// WithHint returns a copy of the X509-SVID with the given hint.
+func (s *SVID) WithHint(hint string) *SVID {
+	clone := s.Clone()
+	clone.Hint = hint
+	return clone
+}
+
+// Clone returns a copy of the X509-SVID. The slice of certificates is copied,
+// so it can be modified independently, but the certificates themselves and
+// the private key are shared since they are not meant to be modified.
+func (s *SVID) Clone() *SVID {
+	return &SVID{
+		ID:           s.ID,
+		Certificates: append([]*x509.Certificate(nil), s.Certificates...),
+		PrivateKey:   s.PrivateKey,
+		Hint:         s.Hint,
+	}
+}
+
+// ExpiresAt returns the expiration time of the X509-SVID, i.e. the NotAfter
+// time of the leaf certificate. If the X509-SVID has no certificates, the zero
+// time is returned.
+func (s *SVID) ExpiresAt() time.Time {
+	if len(s.Certificates) == 0 {
+		return time.Time{}
+	}
+	return s.Certificates[0].NotAfter
+}
+
+// TTL returns the time left until the X509-SVID expires, relative to now. It
+// is zero or negative if the X509-SVID has expired by then.
+func (s *SVID) TTL(now time.Time) time.Duration {
+	return s.ExpiresAt().Sub(now)
+}
+
+// NeedsRenewal returns true if the X509-SVID expires within the threshold
+// from the current time, or has already expired.
+func (s *SVID) NeedsRenewal(threshold time.Duration) bool {
+	return s.TTL(time.Now()) <= threshold
+}
+
+// isDER returns true if the bytes hold no PEM blocks but look like an ASN.1
+// DER sequence, as certificates and PKCS#8 keys are.
+func isDER(b []byte) bool {
+	if len(b) == 0 || b[0] != 0x30 {
+		return false
+	}
+	block, _ := pem.Decode(b)
+	return block == nil
+}
+
+// newParsedSVID returns an X509-SVID from parsed certificates, ordering them
+// and enforcing the key policy as configured.
+func newParsedSVID(certificates []*x509.Certificate, privateKey crypto.PrivateKey, config *parseConfig) (*SVID, error) {
+	ordered := orderChain(certificates)
+	if config.strictOrder && !isOrdered(certificates, ordered) {
+		return nil, x509svidErr.New("certificates are not ordered from the leaf to the root")
+	}
+
+	svid, err := newSVID(ordered, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if config.keyPolicy != nil {
+		if err := config.keyPolicy.checkChain(svid.Certificates); err != nil {
+			return nil, x509svidErr.Wrap(err)
+		}
+	}
+	return svid, nil
+}
+
 func newSVID(certificates []*x509.Certificate, privateKey crypto.PrivateKey) (*SVID, error) {
 	spiffeID, err := validateCertificates(certificates)
 	if err != nil {
@@ -205,7 +368,12 @@ func validatePrivateKey(privateKey crypto.PrivateKey, leaf *x509.Certificate) (c
 		return nil, errs.New("no private key found")
 	}
 
-	matched, err := keyMatches(privateKey, leaf.PublicKey)
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, errs.New("expected crypto.Signer; got %T", privateKey)
+	}
+
+	matched, err := keyMatches(signer, leaf.PublicKey)
 	if err != nil {
 		return nil, err
 	}
@@ -213,24 +381,19 @@ func validatePrivateKey(privateKey crypto.PrivateKey, leaf *x509.Certificate) (c
 		return nil, errs.New("leaf certificate does not match private key")
 	}
 
-	signer, ok := privateKey.(crypto.Signer)
-	if !ok {
-		return nil, errs.New("expected crypto.Signer; got %T", privateKey)
-	}
-
 	return signer, nil
 }
 
-func keyMatches(privateKey crypto.PrivateKey, publicKey crypto.PublicKey) (bool, error) {
-	switch privateKey := privateKey.(type) {
-	case *rsa.PrivateKey:
+func keyMatches(signer crypto.Signer, publicKey crypto.PublicKey) (bool, error) {
+	switch signerPublicKey := signer.Public().(type) {
+	case *rsa.PublicKey:
 		rsaPublicKey, ok := publicKey.(*rsa.PublicKey)
-		return ok && rsaPublicKeyEqual(&privateKey.PublicKey, rsaPublicKey), nil
-	case *ecdsa.PrivateKey:
+		return ok && rsaPublicKeyEqual(signerPublicKey, rsaPublicKey), nil
+	case *ecdsa.PublicKey:
 		ecdsaPublicKey, ok := publicKey.(*ecdsa.PublicKey)
-		return ok && ecdsaPublicKeyEqual(&privateKey.PublicKey, ecdsaPublicKey), nil
+		return ok && ecdsaPublicKeyEqual(signerPublicKey, ecdsaPublicKey), nil
 	default:
-		return false, errs.New("unsupported private key type %T", privateKey)
+		return false, errs.New("unsupported private key type %T", signer)
 	}
 }
 
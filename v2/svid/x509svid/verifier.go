@@ -0,0 +1,172 @@
+package x509svid
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"sync"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+const defaultVerifierCacheSize = 1024
+
+// VerifierOption is an option used when creating a Verifier.
+type VerifierOption interface {
+	apply(config *verifierConfig)
+}
+
+// WithCacheSize sets the maximum number of parsed intermediate certificates
+// and intermediate certificate pools cached by the Verifier. If not used, up
+// to 1024 entries are cached.
+func WithCacheSize(size int) VerifierOption {
+	return verifierOption(func(config *verifierConfig) {
+		config.cacheSize = size
+	})
+}
+
+// Verifier verifies X509-SVID chains using an X.509 bundle source, like
+// Verify and ParseAndVerify. Unlike them, it caches the certificate pools
+// built from the bundle authorities and from the intermediates presented by
+// peers, as well as the parsed intermediate certificates, which are usually
+// shared by many peers. It is meant for servers verifying a large number of
+// distinct peers. Roots are rebuilt whenever the bundle authorities change.
+// It is safe for concurrent use.
+type Verifier struct {
+	bundleSource x509bundle.Source
+	cacheSize    int
+
+	mu sync.Mutex
+	// rootPools caches the root pools by trust domain, along with the
+	// authorities they were built from.
+	rootPools map[spiffeid.TrustDomain]cachedRoots
+	// intermediatePools caches the intermediate pools by the digest of the
+	// intermediates.
+	intermediatePools map[[sha256.Size]byte]*x509.CertPool
+	// parsed caches the parsed intermediate certificates by their DER bytes.
+	parsed map[string]*x509.Certificate
+}
+
+type cachedRoots struct {
+	authorities []*x509.Certificate
+	pool        *x509.CertPool
+}
+
+// NewVerifier returns a new Verifier that verifies X509-SVID chains using the
+// X.509 bundle source.
+func NewVerifier(bundleSource x509bundle.Source, opts ...VerifierOption) *Verifier {
+	config := &verifierConfig{cacheSize: defaultVerifierCacheSize}
+	for _, opt := range opts {
+		opt.apply(config)
+	}
+	return &Verifier{
+		bundleSource:      bundleSource,
+		cacheSize:         config.cacheSize,
+		rootPools:         make(map[spiffeid.TrustDomain]cachedRoots),
+		intermediatePools: make(map[[sha256.Size]byte]*x509.CertPool),
+		parsed:            make(map[string]*x509.Certificate),
+	}
+}
+
+// Verify verifies an X509-SVID chain. It returns the SPIFFE ID of the
+// X509-SVID and one or more chains back to a root in the bundle.
+func (v *Verifier) Verify(certs []*x509.Certificate, opts ...VerifyOption) (spiffeid.ID, [][]*x509.Certificate, error) {
+	result, err := verifyWithPools(certs, v.bundleSource, opts, v)
+	if err != nil {
+		return result.ID, nil, err
+	}
+	return result.ID, result.VerifiedChains, nil
+}
+
+// VerifyRaw parses and verifies an X509-SVID chain, e.g. the raw certificates
+// presented by a peer during a TLS handshake. It returns the SPIFFE ID of the
+// X509-SVID and one or more chains back to a root in the bundle.
+func (v *Verifier) VerifyRaw(rawCerts [][]byte, opts ...VerifyOption) (spiffeid.ID, [][]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for i, rawCert := range rawCerts {
+		var cert *x509.Certificate
+		var err error
+		if i == 0 {
+			// Leaves are distinct for every peer and not worth caching.
+			cert, err = x509.ParseCertificate(rawCert)
+		} else {
+			cert, err = v.parseIntermediate(rawCert)
+		}
+		if err != nil {
+			return spiffeid.ID{}, nil, x509svidErr.New("unable to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return v.Verify(certs, opts...)
+}
+
+func (v *Verifier) parseIntermediate(rawCert []byte) (*x509.Certificate, error) {
+	v.mu.Lock()
+	cert, ok := v.parsed[string(rawCert)]
+	v.mu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	cert, err := x509.ParseCertificate(rawCert)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if len(v.parsed) >= v.cacheSize {
+		v.parsed = make(map[string]*x509.Certificate)
+	}
+	v.parsed[string(rawCert)] = cert
+	return cert, nil
+}
+
+func (v *Verifier) roots(bundle *x509bundle.Bundle) *x509.CertPool {
+	authorities := bundle.X509Authorities()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if cached, ok := v.rootPools[bundle.TrustDomain()]; ok && x509util.CertsEqual(cached.authorities, authorities) {
+		return cached.pool
+	}
+	pool := x509util.NewCertPool(authorities)
+	v.rootPools[bundle.TrustDomain()] = cachedRoots{authorities: authorities, pool: pool}
+	return pool
+}
+
+func (v *Verifier) intermediates(certs []*x509.Certificate) *x509.CertPool {
+	if len(certs) == 0 {
+		return nil
+	}
+
+	h := sha256.New()
+	for _, cert := range certs {
+		_, _ = h.Write(cert.Raw)
+	}
+	var key [sha256.Size]byte
+	copy(key[:], h.Sum(nil))
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if pool, ok := v.intermediatePools[key]; ok {
+		return pool
+	}
+	if len(v.intermediatePools) >= v.cacheSize {
+		v.intermediatePools = make(map[[sha256.Size]byte]*x509.CertPool)
+	}
+	pool := x509util.NewCertPool(certs)
+	v.intermediatePools[key] = pool
+	return pool
+}
+
+type verifierConfig struct {
+	cacheSize int
+}
+
+type verifierOption func(config *verifierConfig)
+
+func (fn verifierOption) apply(config *verifierConfig) {
+	fn(config)
+}
@@ -0,0 +1,120 @@
+package x509svid_test
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifier(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	intermediate := ca.ChildCA()
+	bundle := ca.X509Bundle()
+	verifier := x509svid.NewVerifier(bundle)
+
+	for _, path := range []string{"/workload1", "/workload2"} {
+		id := spiffeid.RequireFromPath(td, path)
+		certs := intermediate.CreateX509SVID(id).Certificates
+
+		expectedID, expectedChains, err := x509svid.Verify(certs, bundle)
+		require.NoError(t, err)
+
+		actualID, actualChains, err := verifier.Verify(certs)
+		require.NoError(t, err)
+		require.Equal(t, expectedID, actualID)
+		require.Equal(t, expectedChains, actualChains)
+
+		actualID, actualChains, err = verifier.VerifyRaw(rawCerts(certs))
+		require.NoError(t, err)
+		require.Equal(t, expectedID, actualID)
+		require.Len(t, actualChains, 1)
+		require.Equal(t, certs[0].Raw, actualChains[0][0].Raw)
+		// The intermediate is reused from the cache.
+		require.Same(t, certs[1], actualChains[0][1])
+	}
+
+	t.Run("bundle authorities are rotated", func(t *testing.T) {
+		certs := intermediate.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload")).Certificates
+		_, _, err := verifier.Verify(certs)
+		require.NoError(t, err)
+
+		bundle.SetX509Authorities(test.NewCA(t, td).X509Authorities())
+		_, _, err = verifier.Verify(certs)
+		require.EqualError(t, err, "x509svid: could not verify leaf certificate: x509: certificate signed by unknown authority")
+
+		bundle.SetX509Authorities(ca.X509Authorities())
+		_, _, err = verifier.Verify(certs)
+		require.NoError(t, err)
+	})
+
+	t.Run("verification fails", func(t *testing.T) {
+		certs := test.NewCA(t, td).CreateX509SVID(spiffeid.RequireFromPath(td, "/workload")).Certificates
+		id, chains, err := verifier.Verify(certs)
+		require.EqualError(t, err, "x509svid: could not verify leaf certificate: x509: certificate signed by unknown authority")
+		require.Equal(t, spiffeid.RequireFromPath(td, "/workload"), id)
+		require.Nil(t, chains)
+	})
+
+	t.Run("unparseable certificate", func(t *testing.T) {
+		id, chains, err := verifier.VerifyRaw([][]byte{[]byte("not a certificate")})
+		require.Contains(t, err.Error(), "x509svid: unable to parse certificate")
+		require.True(t, id.IsZero())
+		require.Nil(t, chains)
+	})
+
+	t.Run("nil bundle source", func(t *testing.T) {
+		_, _, err := x509svid.NewVerifier(nil).Verify(intermediate.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload")).Certificates)
+		require.EqualError(t, err, "x509svid: bundleSource is required")
+	})
+}
+
+func TestVerifierWithCacheSize(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(t, td)
+	verifier := x509svid.NewVerifier(ca.X509Bundle(), x509svid.WithCacheSize(1))
+
+	// Peers with distinct intermediates evict each other from the cache.
+	for i := 0; i < 3; i++ {
+		certs := ca.ChildCA().CreateX509SVID(spiffeid.RequireFromPath(td, "/workload")).Certificates
+		_, chains, err := verifier.VerifyRaw(rawCerts(certs))
+		require.NoError(t, err)
+		require.Len(t, chains, 1)
+	}
+}
+
+func BenchmarkParseAndVerify(b *testing.B) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(b, td)
+	raw := rawCerts(ca.ChildCA().CreateX509SVID(spiffeid.RequireFromPath(td, "/workload")).Certificates)
+	bundle := ca.X509Bundle()
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_, _, _ = x509svid.ParseAndVerify(raw, bundle)
+	}
+}
+
+func BenchmarkVerifierVerifyRaw(b *testing.B) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	ca := test.NewCA(b, td)
+	raw := rawCerts(ca.ChildCA().CreateX509SVID(spiffeid.RequireFromPath(td, "/workload")).Certificates)
+	verifier := x509svid.NewVerifier(ca.X509Bundle())
+
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_, _, _ = verifier.VerifyRaw(raw)
+	}
+}
+
+func rawCerts(certs []*x509.Certificate) [][]byte {
+	var raw [][]byte
+	for _, cert := range certs {
+		raw = append(raw, cert.Raw)
+	}
+	return raw
+}
@@ -29,49 +29,155 @@ func WithTime(now time.Time) VerifyOption {
 // returns the SPIFFE ID of the X509-SVID and one or more chains back to a root
 // in the bundle.
 func Verify(certs []*x509.Certificate, bundleSource x509bundle.Source, opts ...VerifyOption) (spiffeid.ID, [][]*x509.Certificate, error) {
+	result, err := verify(certs, bundleSource, opts)
+	if err != nil {
+		return result.ID, nil, err
+	}
+	return result.ID, result.VerifiedChains, nil
+}
+
+// VerifyResult is the result of verifying an X509-SVID chain.
+type VerifyResult struct {
+	// ID is the SPIFFE ID of the X509-SVID.
+	ID spiffeid.ID
+
+	// VerifiedChains are the chains from the leaf certificate back to a root
+	// in the bundle.
+	VerifiedChains [][]*x509.Certificate
+
+	// LeafNotAfter is the expiration time of the leaf certificate.
+	LeafNotAfter time.Time
+
+	// ExpiresAt is when the X509-SVID stops being verifiable, i.e. the
+	// earliest expiration time of the certificates in the verified chain
+	// that expires last.
+	ExpiresAt time.Time
+
+	// Bundle is the X.509 bundle of the trust domain of the X509-SVID used
+	// for the verification.
+	Bundle *x509bundle.Bundle
+
+	// Authorities are the X.509 authorities of the bundle that the verified
+	// chains lead to.
+	Authorities []*x509.Certificate
+}
+
+// VerifyDetailed is like Verify but returns a VerifyResult with details about
+// the verification, sparing callers from re-deriving them for logging and
+// policy decisions.
+func VerifyDetailed(certs []*x509.Certificate, bundleSource x509bundle.Source, opts ...VerifyOption) (*VerifyResult, error) {
+	result, err := verify(certs, bundleSource, opts)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func verify(certs []*x509.Certificate, bundleSource x509bundle.Source, opts []VerifyOption) (*VerifyResult, error) {
+	return verifyWithPools(certs, bundleSource, opts, newCertPools{})
+}
+
+// certPools provides the certificate pools used to verify chains, so that
+// they can be cached across verifications.
+type certPools interface {
+	roots(bundle *x509bundle.Bundle) *x509.CertPool
+	intermediates(certs []*x509.Certificate) *x509.CertPool
+}
+
+// newCertPools builds new certificate pools for every verification.
+type newCertPools struct{}
+
+func (newCertPools) roots(bundle *x509bundle.Bundle) *x509.CertPool {
+	return x509util.NewCertPool(bundle.X509Authorities())
+}
+
+func (newCertPools) intermediates(certs []*x509.Certificate) *x509.CertPool {
+	return x509util.NewCertPool(certs)
+}
+
+func verifyWithPools(certs []*x509.Certificate, bundleSource x509bundle.Source, opts []VerifyOption, pools certPools) (*VerifyResult, error) {
 	config := &verifyConfig{}
 	for _, opt := range opts {
 		opt.apply(config)
 	}
 
+	result := &VerifyResult{}
 	switch {
 	case len(certs) == 0:
-		return spiffeid.ID{}, nil, x509svidErr.New("empty certificates chain")
+		return result, x509svidErr.New("empty certificates chain")
 	case bundleSource == nil:
-		return spiffeid.ID{}, nil, x509svidErr.New("bundleSource is required")
+		return result, x509svidErr.New("bundleSource is required")
 	}
 
 	leaf := certs[0]
 	id, err := IDFromCert(leaf)
 	if err != nil {
-		return spiffeid.ID{}, nil, x509svidErr.New("could not get leaf SPIFFE ID: %w", err)
+		return result, x509svidErr.New("could not get leaf SPIFFE ID: %w", err)
 	}
+	result.ID = id
 
 	switch {
 	case leaf.IsCA:
-		return id, nil, x509svidErr.New("leaf certificate with CA flag set to true")
+		return result, x509svidErr.New("leaf certificate with CA flag set to true")
 	case leaf.KeyUsage&x509.KeyUsageCertSign > 0:
-		return id, nil, x509svidErr.New("leaf certificate with KeyCertSign key usage")
+		return result, x509svidErr.New("leaf certificate with KeyCertSign key usage")
 	case leaf.KeyUsage&x509.KeyUsageCRLSign > 0:
-		return id, nil, x509svidErr.New("leaf certificate with KeyCrlSign key usage")
+		return result, x509svidErr.New("leaf certificate with KeyCrlSign key usage")
+	}
+
+	if config.maxChainDepth != nil {
+		if err := checkPresentedDepth(certs, *config.maxChainDepth); err != nil {
+			return result, x509svidErr.Wrap(err)
+		}
 	}
 
 	bundle, err := bundleSource.GetX509BundleForTrustDomain(id.TrustDomain())
 	if err != nil {
-		return id, nil, x509svidErr.New("could not get X509 bundle: %w", err)
+		return result, x509svidErr.New("could not get X509 bundle: %w", err)
 	}
 
 	verifiedChains, err := leaf.Verify(x509.VerifyOptions{
-		Roots:         x509util.NewCertPool(bundle.X509Authorities()),
-		Intermediates: x509util.NewCertPool(certs[1:]),
+		Roots:         pools.roots(bundle),
+		Intermediates: pools.intermediates(certs[1:]),
 		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
 		CurrentTime:   config.now,
 	})
 	if err != nil {
-		return id, nil, x509svidErr.New("could not verify leaf certificate: %w", err)
+		return result, x509svidErr.New("could not verify leaf certificate: %w", pathLenError(err))
 	}
 
-	return id, verifiedChains, nil
+	if config.maxChainDepth != nil {
+		verifiedChains, err = filterChains(verifiedChains, checkChainDepth(*config.maxChainDepth))
+		if err != nil {
+			return result, x509svidErr.Wrap(err)
+		}
+	}
+	if config.keyPolicy != nil {
+		verifiedChains, err = filterChains(verifiedChains, config.keyPolicy.checkChain)
+		if err != nil {
+			return result, x509svidErr.Wrap(err)
+		}
+	}
+	if config.statusChecker != nil {
+		verifiedChains, err = filterChains(verifiedChains, config.statusChecker.CheckStatus)
+		if err != nil {
+			return result, x509svidErr.New("certificate status check failed: %w", err)
+		}
+	}
+
+	result.VerifiedChains = verifiedChains
+	result.LeafNotAfter = leaf.NotAfter
+	result.Bundle = bundle
+	for _, chain := range verifiedChains {
+		if expiresAt := chainExpiresAt(chain); expiresAt.After(result.ExpiresAt) {
+			result.ExpiresAt = expiresAt
+		}
+		root := chain[len(chain)-1]
+		if !containsCert(result.Authorities, root) {
+			result.Authorities = append(result.Authorities, root)
+		}
+	}
+	return result, nil
 }
 
 // ParseAndVerify parses and verifies an X509-SVID chain using the X.509
@@ -102,8 +208,50 @@ func IDFromCert(cert *x509.Certificate) (spiffeid.ID, error) {
 	return spiffeid.FromURI(cert.URIs[0])
 }
 
+// filterChains returns the chains passing the check, or the error of the
+// first chain if none does.
+func filterChains(chains [][]*x509.Certificate, check func([]*x509.Certificate) error) ([][]*x509.Certificate, error) {
+	var compliant [][]*x509.Certificate
+	var firstErr error
+	for _, chain := range chains {
+		if err := check(chain); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		compliant = append(compliant, chain)
+	}
+	if len(compliant) == 0 {
+		return nil, firstErr
+	}
+	return compliant, nil
+}
+
+func chainExpiresAt(chain []*x509.Certificate) time.Time {
+	expiresAt := chain[0].NotAfter
+	for _, cert := range chain[1:] {
+		if cert.NotAfter.Before(expiresAt) {
+			expiresAt = cert.NotAfter
+		}
+	}
+	return expiresAt
+}
+
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
 type verifyConfig struct {
-	now time.Time
+	now           time.Time
+	keyPolicy     *KeyPolicy
+	statusChecker StatusChecker
+	maxChainDepth *int
 }
 
 type verifyOption func(config *verifyConfig)
@@ -182,3 +182,26 @@ func appendKeyUsage(cert *x509.Certificate, ku x509.KeyUsage) []*x509.Certificat
 	c.KeyUsage |= ku
 	return []*x509.Certificate{&c}
 }
+
+func TestVerifyDetailed(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("domain1.test")
+	now := time.Now().Truncate(time.Second)
+	ca := test.NewCA(t, td)
+	intermediate := ca.ChildCA(test.WithLifetime(now.Add(-time.Minute), now.Add(30*time.Minute)))
+	id := spiffeid.RequireFromPath(td, "/workload")
+	svid := intermediate.CreateX509SVID(id, test.WithLifetime(now.Add(-time.Minute), now.Add(time.Hour)))
+	bundle := ca.X509Bundle()
+
+	result, err := x509svid.VerifyDetailed(svid.Certificates, bundle)
+	require.NoError(t, err)
+	require.Equal(t, id, result.ID)
+	require.Len(t, result.VerifiedChains, 1)
+	require.Equal(t, now.Add(time.Hour), result.LeafNotAfter.Local())
+	require.Equal(t, now.Add(30*time.Minute), result.ExpiresAt.Local())
+	require.Equal(t, bundle, result.Bundle)
+	require.Equal(t, bundle.X509Authorities(), result.Authorities)
+
+	result, err = x509svid.VerifyDetailed(svid.Certificates, x509bundle.New(td))
+	require.EqualError(t, err, "x509svid: could not verify leaf certificate: x509: certificate signed by unknown authority")
+	require.Nil(t, result)
+}
@@ -0,0 +1,55 @@
+package x509svid
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"math/big"
+)
+
+// Zeroize makes a best-effort attempt at wiping the private key material of
+// the X509-SVID from memory, and sets the private key to nil so that the
+// X509-SVID can no longer be used to sign. It is meant to be called when the
+// X509-SVID is no longer in use, e.g. after rotation in long-lived processes.
+// ECDSA, RSA and Ed25519 private keys are wiped in place; other signers are
+// wiped if they have a Zeroize method. Copies of the key made elsewhere, e.g.
+// by the Go runtime or the standard library, cannot be wiped. Zeroize must
+// not be called while the X509-SVID may still be in use, e.g. by a TLS
+// handshake in another goroutine.
+func (s *SVID) Zeroize() {
+	switch key := s.PrivateKey.(type) {
+	case *ecdsa.PrivateKey:
+		zeroizeInt(key.D)
+	case *rsa.PrivateKey:
+		zeroizeInt(key.D)
+		for _, prime := range key.Primes {
+			zeroizeInt(prime)
+		}
+		zeroizeInt(key.Precomputed.Dp)
+		zeroizeInt(key.Precomputed.Dq)
+		zeroizeInt(key.Precomputed.Qinv)
+		for _, value := range key.Precomputed.CRTValues {
+			zeroizeInt(value.Exp)
+			zeroizeInt(value.Coeff)
+			zeroizeInt(value.R)
+		}
+	case ed25519.PrivateKey:
+		for i := range key {
+			key[i] = 0
+		}
+	case interface{ Zeroize() }:
+		key.Zeroize()
+	}
+	s.PrivateKey = nil
+}
+
+func zeroizeInt(n *big.Int) {
+	if n == nil {
+		return
+	}
+	words := n.Bits()
+	for i := range words {
+		words[i] = 0
+	}
+	n.SetInt64(0)
+}
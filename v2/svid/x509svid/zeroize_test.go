@@ -0,0 +1,74 @@
+package x509svid_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZeroize(t *testing.T) {
+	t.Run("ECDSA", func(t *testing.T) {
+		svid, err := x509svid.Load(certMultiple, keyECDSA)
+		require.NoError(t, err)
+		key := svid.PrivateKey.(*ecdsa.PrivateKey)
+
+		svid.Zeroize()
+		assert.Nil(t, svid.PrivateKey)
+		assert.Zero(t, key.D.Sign())
+	})
+
+	t.Run("RSA", func(t *testing.T) {
+		svid, err := x509svid.Load(certSingle, keyRSA)
+		require.NoError(t, err)
+		key := svid.PrivateKey.(*rsa.PrivateKey)
+
+		svid.Zeroize()
+		assert.Nil(t, svid.PrivateKey)
+		assert.Zero(t, key.D.Sign())
+		for _, prime := range key.Primes {
+			assert.Zero(t, prime.Sign())
+		}
+	})
+
+	t.Run("Ed25519", func(t *testing.T) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+		svid := &x509svid.SVID{PrivateKey: key}
+
+		svid.Zeroize()
+		assert.Nil(t, svid.PrivateKey)
+		assert.Equal(t, make(ed25519.PrivateKey, ed25519.PrivateKeySize), key)
+	})
+
+	t.Run("signer with Zeroize method", func(t *testing.T) {
+		svid, err := x509svid.Load(certMultiple, keyECDSA)
+		require.NoError(t, err)
+		signer := &zeroizingSigner{opaqueSigner: opaqueSigner{svid.PrivateKey}}
+		svid.PrivateKey = signer
+
+		svid.Zeroize()
+		assert.Nil(t, svid.PrivateKey)
+		assert.True(t, signer.zeroized)
+	})
+
+	t.Run("no private key", func(t *testing.T) {
+		svid := &x509svid.SVID{}
+		svid.Zeroize()
+		assert.Nil(t, svid.PrivateKey)
+	})
+}
+
+type zeroizingSigner struct {
+	opaqueSigner
+	zeroized bool
+}
+
+func (s *zeroizingSigner) Zeroize() {
+	s.zeroized = true
+}
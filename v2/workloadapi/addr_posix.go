@@ -9,7 +9,7 @@ import (
 )
 
 var (
-	ErrInvalidEndpointScheme = errors.New("workload endpoint socket URI must have a \"tcp\" or \"unix\" scheme")
+	ErrInvalidEndpointScheme = errors.New("workload endpoint socket URI must have a \"tcp\", \"unix\" or \"vsock\" scheme")
 )
 
 func parseTargetFromURLAddrOS(u *url.URL) (string, error) {
@@ -28,6 +28,8 @@ func parseTargetFromURLAddrOS(u *url.URL) (string, error) {
 			return "", errors.New("workload endpoint unix socket URI must not include a fragment")
 		}
 		return u.String(), nil
+	case "vsock":
+		return parseVsockTarget(u)
 	default:
 		return "", ErrInvalidEndpointScheme
 	}
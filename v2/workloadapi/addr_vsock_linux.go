@@ -0,0 +1,172 @@
+//go:build linux
+// +build linux
+
+package workloadapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const vsockTargetPrefix = "vsock:"
+
+// parseVsockTarget validates a vsock endpoint URI of the form
+// vsock://<CID>:<port> and returns the gRPC target used to dial it.
+func parseVsockTarget(u *url.URL) (string, error) {
+	switch {
+	case u.Opaque != "":
+		return "", errors.New("workload endpoint vsock URI must not be opaque")
+	case u.User != nil:
+		return "", errors.New("workload endpoint vsock URI must not include user info")
+	case u.Host == "":
+		return "", errors.New("workload endpoint vsock URI must include a host")
+	case u.Path != "":
+		return "", errors.New("workload endpoint vsock URI must not include a path")
+	case u.RawQuery != "":
+		return "", errors.New("workload endpoint vsock URI must not include query values")
+	case u.Fragment != "":
+		return "", errors.New("workload endpoint vsock URI must not include a fragment")
+	}
+
+	if _, _, err := splitVsockHostPort(u.Host); err != nil {
+		return "", err
+	}
+	return vsockTargetPrefix + u.Host, nil
+}
+
+func splitVsockHostPort(hostport string) (uint32, uint32, error) {
+	host, port, err := net.SplitHostPort(hostport)
+	if err != nil || port == "" {
+		return 0, 0, errors.New("workload endpoint vsock URI host component must be a CID:port")
+	}
+	cid, err := strconv.ParseUint(host, 10, 32)
+	if err != nil {
+		return 0, 0, errors.New("workload endpoint vsock URI CID must be a 32-bit unsigned integer")
+	}
+	p, err := strconv.ParseUint(port, 10, 32)
+	if err != nil {
+		return 0, 0, errors.New("workload endpoint vsock URI port must be a 32-bit unsigned integer")
+	}
+	return uint32(cid), uint32(p), nil
+}
+
+func isVsockTarget(target string) bool {
+	return strings.HasPrefix(target, vsockTargetPrefix)
+}
+
+// dialVsock dials the vsock target returned by parseVsockTarget.
+func dialVsock(ctx context.Context, target string) (net.Conn, error) {
+	cid, port, err := splitVsockHostPort(strings.TrimPrefix(target, vsockTargetPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Socket(unix.AF_VSOCK, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create vsock socket: %w", err)
+	}
+
+	remote := &unix.SockaddrVM{CID: cid, Port: port}
+	if err := connectVsock(ctx, fd, remote); err != nil {
+		_ = unix.Close(fd)
+		return nil, fmt.Errorf("unable to connect to vsock %d:%d: %w", cid, port, err)
+	}
+
+	localCID, localPort := uint32(unix.VMADDR_CID_ANY), uint32(unix.VMADDR_PORT_ANY)
+	if sa, err := unix.Getsockname(fd); err == nil {
+		if vm, ok := sa.(*unix.SockaddrVM); ok {
+			localCID, localPort = vm.CID, vm.Port
+		}
+	}
+
+	// The descriptor is non-blocking at this point, which makes os.File use
+	// the runtime network poller and support deadlines.
+	return &vsockConn{
+		File:   os.NewFile(uintptr(fd), "vsock:"+strconv.FormatUint(uint64(cid), 10)),
+		local:  vsockAddr{cid: localCID, port: localPort},
+		remote: vsockAddr{cid: cid, port: port},
+	}, nil
+}
+
+// connectVsock performs a non-blocking connect on the socket, waiting for the
+// connection to be established or the context to be done.
+func connectVsock(ctx context.Context, fd int, sa unix.Sockaddr) error {
+	if err := unix.SetNonblock(fd, true); err != nil {
+		return err
+	}
+	err := unix.Connect(fd, sa)
+	switch {
+	case err == nil:
+		return nil
+	case !errors.Is(err, unix.EINPROGRESS):
+		return err
+	}
+
+	for {
+		timeout := -1
+		if deadline, ok := ctx.Deadline(); ok {
+			timeout = int(time.Until(deadline).Milliseconds())
+		}
+		// Poll in short intervals so that cancellation of the context is
+		// noticed in a timely fashion.
+		if timeout < 0 || timeout > 100 {
+			timeout = 100
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLOUT}}
+		n, err := unix.Poll(fds, timeout)
+		switch {
+		case errors.Is(err, unix.EINTR):
+			continue
+		case err != nil:
+			return err
+		case n > 0:
+			soErr, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR)
+			if err != nil {
+				return err
+			}
+			if soErr != 0 {
+				return unix.Errno(soErr)
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+type vsockConn struct {
+	*os.File
+	local  vsockAddr
+	remote vsockAddr
+}
+
+func (c *vsockConn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *vsockConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+type vsockAddr struct {
+	cid  uint32
+	port uint32
+}
+
+func (a vsockAddr) Network() string {
+	return "vsock"
+}
+
+func (a vsockAddr) String() string {
+	return fmt.Sprintf("%d:%d", a.cid, a.port)
+}
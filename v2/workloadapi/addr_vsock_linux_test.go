@@ -0,0 +1,74 @@
+//go:build linux
+// +build linux
+
+package workloadapi
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVsockTarget(t *testing.T) {
+	testCases := []validateAddressCase{
+		{
+			addr: "vsock:opaque",
+			err:  "workload endpoint vsock URI must not be opaque",
+		},
+		{
+			addr: "vsock://",
+			err:  "workload endpoint vsock URI must include a host",
+		},
+		{
+			addr: "vsock://john:doe@2:8000",
+			err:  "workload endpoint vsock URI must not include user info",
+		},
+		{
+			addr: "vsock://2:8000/path",
+			err:  "workload endpoint vsock URI must not include a path",
+		},
+		{
+			addr: "vsock://2:8000?whatever",
+			err:  "workload endpoint vsock URI must not include query values",
+		},
+		{
+			addr: "vsock://2:8000#whatever",
+			err:  "workload endpoint vsock URI must not include a fragment",
+		},
+		{
+			addr: "vsock://2",
+			err:  "workload endpoint vsock URI host component must be a CID:port",
+		},
+		{
+			addr: "vsock://host:8000",
+			err:  "workload endpoint vsock URI CID must be a 32-bit unsigned integer",
+		},
+		{
+			addr: "vsock://2:99999999999",
+			err:  "workload endpoint vsock URI port must be a 32-bit unsigned integer",
+		},
+		{
+			addr: "vsock://2:8000",
+			err:  "",
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := ValidateAddress(testCase.addr)
+		if testCase.err != "" {
+			require.Error(t, err, testCase.addr)
+			assert.Contains(t, err.Error(), testCase.err)
+			continue
+		}
+		assert.NoError(t, err)
+	}
+
+	u, err := url.Parse("vsock://3:1234")
+	require.NoError(t, err)
+	target, err := parseVsockTarget(u)
+	require.NoError(t, err)
+	assert.Equal(t, "vsock:3:1234", target)
+	assert.True(t, isVsockTarget(target))
+}
@@ -0,0 +1,25 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package workloadapi
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/url"
+)
+
+func parseVsockTarget(*url.URL) (string, error) {
+	return "", errors.New("workload endpoint vsock URIs are not supported in this platform")
+}
+
+func isVsockTarget(string) bool {
+	return false
+}
+
+func dialVsock(context.Context, string) (net.Conn, error) {
+	// Purely defensive. This should never happen since vsock targets cannot
+	// be parsed in this platform.
+	return nil, errors.New("vsock not supported in this platform")
+}
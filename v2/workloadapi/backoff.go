@@ -3,6 +3,10 @@ package workloadapi
 import (
 	"math"
 	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // backoff defines an linear backoff policy.
@@ -32,3 +36,32 @@ func (b *backoff) Duration() time.Duration {
 func (b *backoff) Reset() {
 	b.n = 0
 }
+
+// retryHintFromError returns the retry delay requested by the Workload API
+// via a google.rpc.RetryInfo detail on a RESOURCE_EXHAUSTED or UNAVAILABLE
+// status, if any.
+func retryHintFromError(err error) (time.Duration, bool) {
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	switch st.Code() {
+	case codes.ResourceExhausted, codes.Unavailable:
+	default:
+		return 0, false
+	}
+
+	for _, detail := range st.Details() {
+		retryInfo, ok := detail.(*errdetails.RetryInfo)
+		if !ok || retryInfo.RetryDelay == nil {
+			continue
+		}
+		if err := retryInfo.RetryDelay.CheckValid(); err != nil {
+			continue
+		}
+		if delay := retryInfo.RetryDelay.AsDuration(); delay > 0 {
+			return delay, true
+		}
+	}
+	return 0, false
+}
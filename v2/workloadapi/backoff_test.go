@@ -1,10 +1,15 @@
 package workloadapi
 
 import (
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 func TestBackoff(t *testing.T) {
@@ -31,3 +36,33 @@ func TestBackoff(t *testing.T) {
 		require.Equal(t, 30*time.Second, b.Duration())
 	})
 }
+
+func TestRetryHintFromError(t *testing.T) {
+	withRetryInfo := func(code codes.Code, delay time.Duration) error {
+		st, err := status.New(code, "slow down").WithDetails(&errdetails.RetryInfo{
+			RetryDelay: durationpb.New(delay),
+		})
+		require.NoError(t, err)
+		return st.Err()
+	}
+
+	hint, ok := retryHintFromError(withRetryInfo(codes.ResourceExhausted, time.Minute))
+	require.True(t, ok)
+	require.Equal(t, time.Minute, hint)
+
+	hint, ok = retryHintFromError(withRetryInfo(codes.Unavailable, 5*time.Second))
+	require.True(t, ok)
+	require.Equal(t, 5*time.Second, hint)
+
+	_, ok = retryHintFromError(withRetryInfo(codes.Internal, time.Minute))
+	require.False(t, ok, "hints are only honored for throttling codes")
+
+	_, ok = retryHintFromError(withRetryInfo(codes.ResourceExhausted, -time.Minute))
+	require.False(t, ok, "negative hints are ignored")
+
+	_, ok = retryHintFromError(status.Error(codes.ResourceExhausted, "no details"))
+	require.False(t, ok)
+
+	_, ok = retryHintFromError(errors.New("not a status"))
+	require.False(t, ok)
+}
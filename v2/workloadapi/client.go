@@ -22,6 +22,8 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+const securityHeaderKey = "workload.spiffe.io"
+
 // Client is a Workload API client.
 type Client struct {
 	conn     *grpc.ClientConn
@@ -61,7 +63,7 @@ func (c *Client) Close() error {
 // FetchX509SVID fetches the default X509-SVID, i.e. the first in the list
 // returned by the Workload API.
 func (c *Client) FetchX509SVID(ctx context.Context) (*x509svid.SVID, error) {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := c.newCallContext(ctx)
 	defer cancel()
 
 	stream, err := c.wlClient.FetchX509SVID(ctx, &workload.X509SVIDRequest{})
@@ -84,7 +86,7 @@ func (c *Client) FetchX509SVID(ctx context.Context) (*x509svid.SVID, error) {
 
 // FetchX509SVIDs fetches all X509-SVIDs.
 func (c *Client) FetchX509SVIDs(ctx context.Context) ([]*x509svid.SVID, error) {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := c.newCallContext(ctx)
 	defer cancel()
 
 	stream, err := c.wlClient.FetchX509SVID(ctx, &workload.X509SVIDRequest{})
@@ -102,7 +104,7 @@ func (c *Client) FetchX509SVIDs(ctx context.Context) ([]*x509svid.SVID, error) {
 
 // FetchX509Bundles fetches the X.509 bundles.
 func (c *Client) FetchX509Bundles(ctx context.Context) (*x509bundle.Set, error) {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := c.newCallContext(ctx)
 	defer cancel()
 
 	stream, err := c.wlClient.FetchX509Bundles(ctx, &workload.X509BundlesRequest{})
@@ -114,6 +116,7 @@ func (c *Client) FetchX509Bundles(ctx context.Context) (*x509bundle.Set, error)
 		return nil, err
 	}
 
+	resp.Bundles = c.filterBundles(resp.Bundles)
 	return parseX509BundlesResponse(resp)
 }
 
@@ -122,9 +125,10 @@ func (c *Client) FetchX509Bundles(ctx context.Context) (*x509bundle.Set, error)
 func (c *Client) WatchX509Bundles(ctx context.Context, watcher X509BundleWatcher) error {
 	backoff := newBackoff()
 	for {
-		err := c.watchX509Bundles(ctx, watcher, backoff)
+		err := classifyWatchError(c.watchX509Bundles(ctx, watcher, backoff))
 		watcher.OnX509BundlesWatchError(err)
-		err = c.handleWatchError(ctx, err, backoff)
+		notifier, _ := watcher.(WatchRetryNotifier)
+		err = c.handleWatchError(ctx, err, backoff, notifier)
 		if err != nil {
 			return err
 		}
@@ -134,7 +138,7 @@ func (c *Client) WatchX509Bundles(ctx context.Context, watcher X509BundleWatcher
 // FetchX509Context fetches the X.509 context, which contains both X509-SVIDs
 // and X.509 bundles.
 func (c *Client) FetchX509Context(ctx context.Context) (*X509Context, error) {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := c.newCallContext(ctx)
 	defer cancel()
 
 	stream, err := c.wlClient.FetchX509SVID(ctx, &workload.X509SVIDRequest{})
@@ -147,6 +151,7 @@ func (c *Client) FetchX509Context(ctx context.Context) (*X509Context, error) {
 		return nil, err
 	}
 
+	resp.FederatedBundles = c.filterBundles(resp.FederatedBundles)
 	return parseX509Context(resp)
 }
 
@@ -155,9 +160,10 @@ func (c *Client) FetchX509Context(ctx context.Context) (*X509Context, error) {
 func (c *Client) WatchX509Context(ctx context.Context, watcher X509ContextWatcher) error {
 	backoff := newBackoff()
 	for {
-		err := c.watchX509Context(ctx, watcher, backoff)
+		err := classifyWatchError(c.watchX509Context(ctx, watcher, backoff))
 		watcher.OnX509ContextWatchError(err)
-		err = c.handleWatchError(ctx, err, backoff)
+		notifier, _ := watcher.(WatchRetryNotifier)
+		err = c.handleWatchError(ctx, err, backoff, notifier)
 		if err != nil {
 			return err
 		}
@@ -166,7 +172,7 @@ func (c *Client) WatchX509Context(ctx context.Context, watcher X509ContextWatche
 
 // FetchJWTSVID fetches a JWT-SVID.
 func (c *Client) FetchJWTSVID(ctx context.Context, params jwtsvid.Params) (*jwtsvid.SVID, error) {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := c.newCallContext(ctx)
 	defer cancel()
 
 	audience := append([]string{params.Audience}, params.ExtraAudiences...)
@@ -188,7 +194,7 @@ func (c *Client) FetchJWTSVID(ctx context.Context, params jwtsvid.Params) (*jwts
 
 // FetchJWTSVIDs fetches all JWT-SVIDs.
 func (c *Client) FetchJWTSVIDs(ctx context.Context, params jwtsvid.Params) ([]*jwtsvid.SVID, error) {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := c.newCallContext(ctx)
 	defer cancel()
 
 	audience := append([]string{params.Audience}, params.ExtraAudiences...)
@@ -206,7 +212,7 @@ func (c *Client) FetchJWTSVIDs(ctx context.Context, params jwtsvid.Params) ([]*j
 // FetchJWTBundles fetches the JWT bundles for JWT-SVID validation, keyed
 // by a SPIFFE ID of the trust domain to which they belong.
 func (c *Client) FetchJWTBundles(ctx context.Context) (*jwtbundle.Set, error) {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := c.newCallContext(ctx)
 	defer cancel()
 
 	stream, err := c.wlClient.FetchJWTBundles(ctx, &workload.JWTBundlesRequest{})
@@ -219,6 +225,7 @@ func (c *Client) FetchJWTBundles(ctx context.Context) (*jwtbundle.Set, error) {
 		return nil, err
 	}
 
+	resp.Bundles = c.filterBundles(resp.Bundles)
 	return parseJWTSVIDBundles(resp)
 }
 
@@ -227,9 +234,10 @@ func (c *Client) FetchJWTBundles(ctx context.Context) (*jwtbundle.Set, error) {
 func (c *Client) WatchJWTBundles(ctx context.Context, watcher JWTBundleWatcher) error {
 	backoff := newBackoff()
 	for {
-		err := c.watchJWTBundles(ctx, watcher, backoff)
+		err := classifyWatchError(c.watchJWTBundles(ctx, watcher, backoff))
 		watcher.OnJWTBundlesWatchError(err)
-		err = c.handleWatchError(ctx, err, backoff)
+		notifier, _ := watcher.(WatchRetryNotifier)
+		err = c.handleWatchError(ctx, err, backoff, notifier)
 		if err != nil {
 			return err
 		}
@@ -239,7 +247,7 @@ func (c *Client) WatchJWTBundles(ctx context.Context, watcher JWTBundleWatcher)
 // ValidateJWTSVID validates the JWT-SVID token. The parsed and validated
 // JWT-SVID is returned.
 func (c *Client) ValidateJWTSVID(ctx context.Context, token, audience string) (*jwtsvid.SVID, error) {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := c.newCallContext(ctx)
 	defer cancel()
 
 	_, err := c.wlClient.ValidateJWTSVID(ctx, &workload.ValidateJWTSVIDRequest{
@@ -253,13 +261,26 @@ func (c *Client) ValidateJWTSVID(ctx context.Context, token, audience string) (*
 	return jwtsvid.ParseInsecure(token, []string{audience})
 }
 
+// newCallContext returns the context for a non-streaming Workload API call.
+// If a call timeout has been configured and the caller did not provide a
+// deadline, the call timeout is applied.
+func (c *Client) newCallContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = c.withHeader(ctx)
+	if _, ok := ctx.Deadline(); !ok && c.config.callTimeout > 0 {
+		return context.WithTimeout(ctx, c.config.callTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 func (c *Client) newConn(ctx context.Context) (*grpc.ClientConn, error) {
 	c.config.dialOptions = append(c.config.dialOptions, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	c.appendDialOptionsOS()
 	return grpc.DialContext(ctx, c.config.address, c.config.dialOptions...)
 }
 
-func (c *Client) handleWatchError(ctx context.Context, err error, backoff *backoff) error {
+// handleWatchError waits before the watch is retried, unless the error ends
+// the watch. The notifier, if not nil, is told when the retry will be made.
+func (c *Client) handleWatchError(ctx context.Context, err error, backoff *backoff, notifier WatchRetryNotifier) error {
 	code := status.Code(err)
 	if code == codes.Canceled {
 		return err
@@ -272,7 +293,19 @@ func (c *Client) handleWatchError(ctx context.Context, err error, backoff *backo
 
 	c.config.log.Errorf("Failed to watch the Workload API: %v", err)
 	retryAfter := backoff.Duration()
+	if hint, ok := retryHintFromError(err); ok && hint > retryAfter {
+		// The Workload API is overloaded and asked us to back off for
+		// longer than we would otherwise, but no longer than the backoff
+		// would at most.
+		retryAfter = hint
+		if retryAfter > backoff.MaxDelay {
+			retryAfter = backoff.MaxDelay
+		}
+	}
 	c.config.log.Debugf("Retrying watch in %s", retryAfter)
+	if notifier != nil {
+		notifier.OnWatchRetry(time.Now().Add(retryAfter))
+	}
 	select {
 	case <-time.After(retryAfter):
 		return nil
@@ -283,7 +316,7 @@ func (c *Client) handleWatchError(ctx context.Context, err error, backoff *backo
 }
 
 func (c *Client) watchX509Context(ctx context.Context, watcher X509ContextWatcher, backoff *backoff) error {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := context.WithCancel(c.withHeader(ctx))
 	defer cancel()
 
 	c.config.log.Debugf("Watching X.509 contexts")
@@ -299,10 +332,11 @@ func (c *Client) watchX509Context(ctx context.Context, watcher X509ContextWatche
 		}
 
 		backoff.Reset()
+		resp.FederatedBundles = c.filterBundles(resp.FederatedBundles)
 		x509Context, err := parseX509Context(resp)
 		if err != nil {
 			c.config.log.Errorf("Failed to parse X509-SVID response: %v", err)
-			watcher.OnX509ContextWatchError(err)
+			watcher.OnX509ContextWatchError(malformedResponseError(err))
 			continue
 		}
 		watcher.OnX509ContextUpdate(x509Context)
@@ -310,7 +344,7 @@ func (c *Client) watchX509Context(ctx context.Context, watcher X509ContextWatche
 }
 
 func (c *Client) watchJWTBundles(ctx context.Context, watcher JWTBundleWatcher, backoff *backoff) error {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := context.WithCancel(c.withHeader(ctx))
 	defer cancel()
 
 	c.config.log.Debugf("Watching JWT bundles")
@@ -326,10 +360,11 @@ func (c *Client) watchJWTBundles(ctx context.Context, watcher JWTBundleWatcher,
 		}
 
 		backoff.Reset()
+		resp.Bundles = c.filterBundles(resp.Bundles)
 		jwtbundleSet, err := parseJWTSVIDBundles(resp)
 		if err != nil {
 			c.config.log.Errorf("Failed to parse JWT bundle response: %v", err)
-			watcher.OnJWTBundlesWatchError(err)
+			watcher.OnJWTBundlesWatchError(malformedResponseError(err))
 			continue
 		}
 		watcher.OnJWTBundlesUpdate(jwtbundleSet)
@@ -337,7 +372,7 @@ func (c *Client) watchJWTBundles(ctx context.Context, watcher JWTBundleWatcher,
 }
 
 func (c *Client) watchX509Bundles(ctx context.Context, watcher X509BundleWatcher, backoff *backoff) error {
-	ctx, cancel := context.WithCancel(withHeader(ctx))
+	ctx, cancel := context.WithCancel(c.withHeader(ctx))
 	defer cancel()
 
 	c.config.log.Debugf("Watching X.509 bundles")
@@ -353,16 +388,49 @@ func (c *Client) watchX509Bundles(ctx context.Context, watcher X509BundleWatcher
 		}
 
 		backoff.Reset()
+		resp.Bundles = c.filterBundles(resp.Bundles)
 		x509bundleSet, err := parseX509BundlesResponse(resp)
 		if err != nil {
 			c.config.log.Errorf("Failed to parse X.509 bundle response: %v", err)
-			watcher.OnX509BundlesWatchError(err)
+			watcher.OnX509BundlesWatchError(malformedResponseError(err))
 			continue
 		}
 		watcher.OnX509BundlesUpdate(x509bundleSet)
 	}
 }
 
+// filterBundles removes the bundles for trust domains that were not selected
+// via the WithTrustDomainFilter option. Bundles keyed by a malformed trust
+// domain are kept so that parsing surfaces the error.
+func (c *Client) filterBundles(bundles map[string][]byte) map[string][]byte {
+	if c.config.trustDomains == nil {
+		return bundles
+	}
+	filtered := make(map[string][]byte, len(c.config.trustDomains))
+	for tdID, bundle := range bundles {
+		td, err := spiffeid.TrustDomainFromString(tdID)
+		if err == nil {
+			if _, ok := c.config.trustDomains[td]; !ok {
+				continue
+			}
+		}
+		filtered[tdID] = bundle
+	}
+	return filtered
+}
+
+// WatchRetryNotifier can optionally be implemented by the watchers passed to
+// WatchX509Context, WatchX509Bundles and WatchJWTBundles to learn when the
+// next attempt to re-establish the watch will be made. The retry time takes
+// into account throttling hints (RetryInfo details on RESOURCE_EXHAUSTED or
+// UNAVAILABLE errors) returned by the Workload API, up to the maximum backoff
+// of 30 seconds.
+type WatchRetryNotifier interface {
+	// OnWatchRetry is called after a watch failure with the time at which
+	// the watch will be retried.
+	OnWatchRetry(nextRetry time.Time)
+}
+
 // X509ContextWatcher receives X509Context updates from the Workload API.
 type X509ContextWatcher interface {
 	// OnX509ContextUpdate is called with the latest X.509 context retrieved
@@ -370,7 +438,8 @@ type X509ContextWatcher interface {
 	OnX509ContextUpdate(*X509Context)
 
 	// OnX509ContextWatchError is called when there is a problem establishing
-	// or maintaining connectivity with the Workload API.
+	// or maintaining connectivity with the Workload API. Errors that can be
+	// classified are passed as a *WatchError.
 	OnX509ContextWatchError(error)
 }
 
@@ -381,7 +450,8 @@ type JWTBundleWatcher interface {
 	OnJWTBundlesUpdate(*jwtbundle.Set)
 
 	// OnJWTBundlesWatchError is called when there is a problem establishing
-	// or maintaining connectivity with the Workload API.
+	// or maintaining connectivity with the Workload API. Errors that can be
+	// classified are passed as a *WatchError.
 	OnJWTBundlesWatchError(error)
 }
 
@@ -392,13 +462,20 @@ type X509BundleWatcher interface {
 	OnX509BundlesUpdate(*x509bundle.Set)
 
 	// OnX509BundlesWatchError is called when there is a problem establishing
-	// or maintaining connectivity with the Workload API.
+	// or maintaining connectivity with the Workload API. Errors that can be
+	// classified are passed as a *WatchError.
 	OnX509BundlesWatchError(error)
 }
 
-func withHeader(ctx context.Context) context.Context {
-	header := metadata.Pairs("workload.spiffe.io", "true")
-	return metadata.NewOutgoingContext(ctx, header)
+// withHeader returns a context carrying the outgoing metadata for a Workload
+// API call. Metadata already attached to the context by the caller and
+// metadata configured via WithMetadata are preserved. The mandatory security
+// header is always set and cannot be overridden.
+func (c *Client) withHeader(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = metadata.Join(c.config.metadata, md)
+	md.Set(securityHeaderKey, "true")
+	return metadata.NewOutgoingContext(ctx, md)
 }
 
 func defaultClientConfig() clientConfig {
@@ -3,7 +3,11 @@
 
 package workloadapi
 
-import "errors"
+import (
+	"errors"
+
+	"google.golang.org/grpc"
+)
 
 // appendDialOptionsOS appends OS specific dial options
 func (c *Client) appendDialOptionsOS() {
@@ -25,5 +29,14 @@ func (c *Client) setAddress() error {
 
 	var err error
 	c.config.address, err = parseTargetFromStringAddr(c.config.address)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if isVsockTarget(c.config.address) {
+		// Use the dialer to connect to vsock sockets only if the address
+		// has the "vsock" scheme
+		c.config.dialOptions = append(c.config.dialOptions, grpc.WithContextDialer(dialVsock))
+	}
+	return nil
 }
@@ -17,6 +17,11 @@ import (
 	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 var (
@@ -202,6 +207,36 @@ func TestFetchX509Context(t *testing.T) {
 	require.Nil(t, x509Ctx)
 }
 
+func TestWithTrustDomainFilter(t *testing.T) {
+	ca := test.NewCA(t, td)
+	federatedCA := test.NewCA(t, federatedTD)
+	otherCA := test.NewCA(t, spiffeid.RequireTrustDomainFromString("other.test"))
+	wl := fakeworkloadapi.New(t)
+	defer wl.Stop()
+	c, err := New(context.Background(), WithAddr(wl.Addr()), WithTrustDomainFilter(federatedTD))
+	require.NoError(t, err)
+	defer c.Close()
+
+	wl.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		Bundle:           ca.X509Bundle(),
+		SVIDs:            makeX509SVIDs(ca, "", fooID),
+		FederatedBundles: []*x509bundle.Bundle{federatedCA.X509Bundle(), otherCA.X509Bundle()},
+	})
+
+	x509Ctx, err := c.FetchX509Context(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, x509Ctx.Bundles.Len())
+	assertX509Bundle(t, x509Ctx.Bundles, td, ca.X509Bundle())
+	assertX509Bundle(t, x509Ctx.Bundles, federatedTD, federatedCA.X509Bundle())
+
+	wl.SetX509Bundles(ca.X509Bundle(), federatedCA.X509Bundle(), otherCA.X509Bundle())
+
+	bundles, err := c.FetchX509Bundles(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, bundles.Len())
+	assertX509Bundle(t, bundles, federatedTD, federatedCA.X509Bundle())
+}
+
 func TestWatchX509Context(t *testing.T) {
 	ca := test.NewCA(t, td)
 	federatedCA := test.NewCA(t, federatedTD)
@@ -224,6 +259,7 @@ func TestWatchX509Context(t *testing.T) {
 	tw.WaitForUpdates(1)
 	require.Len(t, tw.Errors(), 1)
 	require.Len(t, tw.X509Contexts(), 0)
+	require.ErrorIs(t, tw.Errors()[0], ErrPermissionDenied)
 
 	fooSVID := ca.CreateX509SVID(fooID, test.WithHint(hintInternal))
 	barSVID := ca.CreateX509SVID(barID, test.WithHint(hintExternal))
@@ -451,6 +487,92 @@ func TestValidateJWTSVID(t *testing.T) {
 	})
 }
 
+func TestWithMetadata(t *testing.T) {
+	c := &Client{config: defaultClientConfig()}
+	WithMetadata(metadata.Pairs("tenant", "blue", "workload.spiffe.io", "false")).configureClient(&c.config)
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "request-id", "1234")
+	md, ok := metadata.FromOutgoingContext(c.withHeader(ctx))
+	require.True(t, ok)
+	assert.Equal(t, []string{"blue"}, md.Get("tenant"))
+	assert.Equal(t, []string{"1234"}, md.Get("request-id"))
+	assert.Equal(t, []string{"true"}, md.Get("workload.spiffe.io"), "security header must not be overridden")
+
+	// The configured metadata must not be mutated by the call.
+	assert.Empty(t, c.config.metadata.Get("request-id"))
+}
+
+func TestWithCallTimeout(t *testing.T) {
+	c := &Client{config: defaultClientConfig()}
+
+	t.Run("no timeout configured", func(t *testing.T) {
+		ctx, cancel := c.newCallContext(context.Background())
+		defer cancel()
+		_, ok := ctx.Deadline()
+		assert.False(t, ok)
+	})
+
+	WithCallTimeout(time.Minute).configureClient(&c.config)
+
+	t.Run("applied when the context has no deadline", func(t *testing.T) {
+		ctx, cancel := c.newCallContext(context.Background())
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), deadline, 5*time.Second)
+	})
+
+	t.Run("caller deadline is preserved", func(t *testing.T) {
+		expected := time.Now().Add(time.Hour)
+		parent, parentCancel := context.WithDeadline(context.Background(), expected)
+		defer parentCancel()
+		ctx, cancel := c.newCallContext(parent)
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		require.True(t, ok)
+		assert.Equal(t, expected, deadline)
+	})
+}
+
+func TestHandleWatchErrorHonorsRetryHint(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		hint     time.Duration
+		expected time.Duration
+	}{
+		{name: "longer than backoff", hint: 10 * time.Second, expected: 10 * time.Second},
+		{name: "shorter than backoff", hint: time.Millisecond, expected: time.Second},
+		{name: "clamped to max backoff", hint: time.Hour, expected: 30 * time.Second},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{config: defaultClientConfig()}
+			st, err := status.New(codes.ResourceExhausted, "slow down").WithDetails(&errdetails.RetryInfo{
+				RetryDelay: durationpb.New(tt.hint),
+			})
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			notifier := &retryNotifier{onRetry: func(time.Time) { cancel() }}
+
+			start := time.Now()
+			err = c.handleWatchError(ctx, st.Err(), newBackoff(), notifier)
+			require.Equal(t, context.Canceled, err)
+			assert.WithinDuration(t, start.Add(tt.expected), notifier.nextRetry, time.Second/2)
+		})
+	}
+}
+
+type retryNotifier struct {
+	nextRetry time.Time
+	onRetry   func(time.Time)
+}
+
+func (n *retryNotifier) OnWatchRetry(nextRetry time.Time) {
+	n.nextRetry = nextRetry
+	n.onRetry(nextRetry)
+}
+
 func makeX509SVIDs(ca *test.CA, hint string, ids ...spiffeid.ID) []*x509svid.SVID {
 	svids := []*x509svid.SVID{}
 	for _, id := range ids {
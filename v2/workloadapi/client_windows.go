@@ -8,7 +8,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/Microsoft/go-winio"
 	"google.golang.org/grpc"
 )
 
@@ -17,7 +16,7 @@ func (c *Client) appendDialOptionsOS() {
 	if c.config.namedPipeName != "" {
 		// Use the dialer to connect to named pipes only if a named pipe
 		// is defined (i.e. WithNamedPipeName is used).
-		c.config.dialOptions = append(c.config.dialOptions, grpc.WithContextDialer(winio.DialPipeContext))
+		c.config.dialOptions = append(c.config.dialOptions, grpc.WithContextDialer(namedPipeDialer(c.config.namedPipe)))
 	}
 }
 
@@ -42,7 +41,7 @@ func (c *Client) setAddress() error {
 	if strings.HasPrefix(c.config.address, "npipe:") {
 		// Use the dialer to connect to named pipes only if the gRPC target
 		// string has the "npipe" scheme
-		c.config.dialOptions = append(c.config.dialOptions, grpc.WithContextDialer(winio.DialPipeContext))
+		c.config.dialOptions = append(c.config.dialOptions, grpc.WithContextDialer(namedPipeDialer(c.config.namedPipe)))
 	}
 
 	c.config.address, err = parseTargetFromStringAddr(c.config.address)
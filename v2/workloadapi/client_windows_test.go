@@ -5,12 +5,14 @@ package workloadapi
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
 	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakeworkloadapi"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/windows"
 )
 
 func TestWithNamedPipeName(t *testing.T) {
@@ -47,3 +49,60 @@ func TestWithNamedPipeNameError(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), `ohno: The system cannot find the file specified`)
 }
+
+func TestWithNamedPipeSecurityCheck(t *testing.T) {
+	ca := test.NewCA(t, td)
+	wl := fakeworkloadapi.NewWithNamedPipeListener(t)
+	defer wl.Stop()
+	wl.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		Bundle: ca.X509Bundle(),
+		SVIDs:  makeX509SVIDs(ca, "", fooID),
+	})
+	pipeName := strings.TrimPrefix(wl.Addr(), "npipe:")
+
+	t.Run("check passes", func(t *testing.T) {
+		checked := false
+		c, err := New(context.Background(),
+			WithNamedPipeName(pipeName),
+			WithNamedPipeImpersonationLevel(NamedPipeImpersonationIdentification),
+			WithNamedPipeSecurityCheck(func(sd *windows.SECURITY_DESCRIPTOR) error {
+				owner, _, err := sd.Owner()
+				require.NoError(t, err)
+				require.NotNil(t, owner)
+				checked = true
+				return nil
+			}))
+		require.NoError(t, err)
+		defer c.Close()
+
+		_, err = c.FetchX509SVID(context.Background())
+		require.NoError(t, err)
+		require.True(t, checked)
+	})
+
+	t.Run("check fails", func(t *testing.T) {
+		c, err := New(context.Background(),
+			WithNamedPipeName(pipeName),
+			WithNamedPipeSecurityCheck(func(sd *windows.SECURITY_DESCRIPTOR) error {
+				return errors.New("untrusted DACL")
+			}))
+		require.NoError(t, err)
+		defer c.Close()
+
+		_, err = c.FetchX509SVID(context.Background())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "named pipe failed security check: untrusted DACL")
+	})
+
+	t.Run("invalid owner SID", func(t *testing.T) {
+		c, err := New(context.Background(),
+			WithNamedPipeName(pipeName),
+			WithNamedPipeOwner("not-a-sid"))
+		require.NoError(t, err)
+		defer c.Close()
+
+		_, err = c.FetchX509SVID(context.Background())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `invalid named pipe owner SID "not-a-sid"`)
+	})
+}
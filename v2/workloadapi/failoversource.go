@@ -0,0 +1,401 @@
+package workloadapi
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/zeebo/errs"
+)
+
+var failoverSourceErr = errs.Class("failoversource")
+
+const (
+	defaultFailoverDelay = 10 * time.Second
+	defaultFailbackDelay = 30 * time.Second
+)
+
+// FailoverSourceKind identifies where the material served by a
+// FailoverX509Source comes from.
+type FailoverSourceKind int
+
+const (
+	// FailoverSourceFiles means the material is loaded from files.
+	FailoverSourceFiles FailoverSourceKind = iota
+
+	// FailoverSourceWorkloadAPI means the material is received from the
+	// Workload API.
+	FailoverSourceWorkloadAPI
+)
+
+// String returns the name of the source kind.
+func (k FailoverSourceKind) String() string {
+	switch k {
+	case FailoverSourceFiles:
+		return "files"
+	case FailoverSourceWorkloadAPI:
+		return "workload API"
+	default:
+		return "unknown"
+	}
+}
+
+// FailoverStatus describes the state of a FailoverX509Source.
+type FailoverStatus struct {
+	// Active is where the material currently served comes from.
+	Active FailoverSourceKind
+
+	// Since is when Active became the active source.
+	Since time.Time
+
+	// LastWorkloadAPIUpdate is when the last update was received from the
+	// Workload API, or the zero time if no update has been received.
+	LastWorkloadAPIUpdate time.Time
+
+	// LastWorkloadAPIError is the last error encountered watching the
+	// Workload API, or nil if the watch is healthy.
+	LastWorkloadAPIError error
+}
+
+// FailoverX509Source is a source of an X509-SVID and X.509 bundles that
+// prefers the Workload API but falls back to material loaded from files when
+// the Workload API is unavailable. It switches back to the Workload API once
+// it has been healthy for a while. It is useful on nodes where the agent is
+// not always reachable.
+type FailoverX509Source struct {
+	config failoverX509SourceConfig
+	files  *FileX509Source
+
+	client     sourceClient
+	ownsClient bool
+
+	updatedCh chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mtx                 sync.RWMutex
+	status              FailoverStatus
+	failedOver          bool
+	primarySVID         *x509svid.SVID
+	primaryBundles      *x509bundle.Set
+	primaryHealthySince time.Time
+	primaryFailingSince time.Time
+	timer               *time.Timer
+	switches            uint64
+
+	// notifyMtx serializes the calls to the status handler, which are made
+	// without holding mtx so that the handler can use the source.
+	notifyMtx sync.Mutex
+	notified  uint64
+
+	closeMtx sync.RWMutex
+	closed   bool
+	closeErr error
+}
+
+// NewFailoverX509Source creates a new FailoverX509Source. The files are
+// loaded before returning and an error is returned if they cannot be loaded.
+// The source serves the files until the first update is received from the
+// Workload API, which happens in the background. The source should be closed
+// when no longer in use to free underlying resources.
+func NewFailoverX509Source(ctx context.Context, files X509Files, options ...FailoverX509SourceOption) (_ *FailoverX509Source, err error) {
+	config := failoverX509SourceConfig{
+		failoverDelay: defaultFailoverDelay,
+		failbackDelay: defaultFailbackDelay,
+	}
+	for _, option := range options {
+		option.configureFailoverX509Source(&config)
+	}
+
+	s := &FailoverX509Source{
+		config:    config,
+		client:    config.watcher.client,
+		updatedCh: make(chan struct{}, 1),
+		status: FailoverStatus{
+			Active: FailoverSourceFiles,
+			Since:  time.Now(),
+		},
+	}
+
+	s.files, err = NewFileX509Source(files, config.fileOptions...)
+	if err != nil {
+		return nil, failoverSourceErr.Wrap(err)
+	}
+
+	if s.client == nil {
+		client, err := New(ctx, config.watcher.clientOptions...)
+		if err != nil {
+			return nil, errs.Combine(failoverSourceErr.Wrap(err), s.files.Close())
+		}
+		s.client = client
+		s.ownsClient = true
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg.Add(2)
+	go func() {
+		defer s.wg.Done()
+		// The watch only returns when the source is closed or the Workload
+		// API rejects it, in which case the files remain in use.
+		_ = s.client.WatchX509Context(s.ctx, failoverWatcher{s: s})
+	}()
+	go func() {
+		defer s.wg.Done()
+		s.forwardFileUpdates()
+	}()
+
+	return s, nil
+}
+
+// Close closes the source, dropping the connection to the Workload API and
+// stopping the file watcher. Other source methods will return an error after
+// Close has been called. The underlying Workload API client will also be
+// closed if it is owned by the FailoverX509Source (i.e. not provided via the
+// WithClient option).
+func (s *FailoverX509Source) Close() error {
+	s.closeMtx.Lock()
+	defer s.closeMtx.Unlock()
+
+	if !s.closed {
+		s.cancel()
+		s.wg.Wait()
+
+		s.mtx.Lock()
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		s.mtx.Unlock()
+
+		var group errs.Group
+		if s.ownsClient {
+			group.Add(s.client.Close())
+		}
+		group.Add(s.files.Close())
+		s.closeErr = group.Err()
+		s.closed = true
+	}
+	return s.closeErr
+}
+
+// GetX509SVID returns the X509-SVID from the active source. It implements
+// the x509svid.Source interface.
+func (s *FailoverX509Source) GetX509SVID() (*x509svid.SVID, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	s.mtx.RLock()
+	active, svid := s.status.Active, s.primarySVID
+	s.mtx.RUnlock()
+
+	if active == FailoverSourceFiles {
+		return s.files.GetX509SVID()
+	}
+	return svid, nil
+}
+
+// GetX509BundleForTrustDomain returns the X.509 bundle for the given trust
+// domain from the active source. It implements the x509bundle.Source
+// interface.
+func (s *FailoverX509Source) GetX509BundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	s.mtx.RLock()
+	active, bundles := s.status.Active, s.primaryBundles
+	s.mtx.RUnlock()
+
+	if active == FailoverSourceFiles {
+		return s.files.GetX509BundleForTrustDomain(trustDomain)
+	}
+	return bundles.GetX509BundleForTrustDomain(trustDomain)
+}
+
+// Status returns the current status of the source.
+func (s *FailoverX509Source) Status() FailoverStatus {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.status
+}
+
+// WaitUntilUpdated waits until the source is updated or the context is done,
+// in which case ctx.Err() is returned. Switching between the Workload API
+// and the files counts as an update.
+func (s *FailoverX509Source) WaitUntilUpdated(ctx context.Context) error {
+	select {
+	case <-s.updatedCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Updated returns a channel that is sent on whenever the source is updated.
+func (s *FailoverX509Source) Updated() <-chan struct{} {
+	return s.updatedCh
+}
+
+func (s *FailoverX509Source) forwardFileUpdates() {
+	for {
+		select {
+		case <-s.files.Updated():
+			if s.Status().Active == FailoverSourceFiles {
+				s.triggerUpdated()
+			}
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *FailoverX509Source) onPrimaryUpdate(x509Context *X509Context) {
+	s.mtx.Lock()
+
+	now := time.Now()
+	s.primarySVID = x509Context.DefaultSVID()
+	s.primaryBundles = x509Context.Bundles
+	s.primaryFailingSince = time.Time{}
+	if s.primaryHealthySince.IsZero() {
+		s.primaryHealthySince = now
+	}
+	s.status.LastWorkloadAPIUpdate = now
+	s.status.LastWorkloadAPIError = nil
+
+	if s.status.Active == FailoverSourceWorkloadAPI {
+		s.triggerUpdated()
+	}
+	s.evaluateLocked(now)
+	status, switches := s.status, s.switches
+	s.mtx.Unlock()
+	s.notifyStatus(status, switches)
+}
+
+func (s *FailoverX509Source) onPrimaryError(err error) {
+	if s.ctx.Err() != nil {
+		// The source is being closed.
+		return
+	}
+
+	s.mtx.Lock()
+	now := time.Now()
+	s.primaryHealthySince = time.Time{}
+	if s.primaryFailingSince.IsZero() {
+		s.primaryFailingSince = now
+	}
+	s.status.LastWorkloadAPIError = err
+	s.evaluateLocked(now)
+	status, switches := s.status, s.switches
+	s.mtx.Unlock()
+	s.notifyStatus(status, switches)
+}
+
+func (s *FailoverX509Source) evaluate() {
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	s.mtx.Lock()
+	s.evaluateLocked(time.Now())
+	status, switches := s.status, s.switches
+	s.mtx.Unlock()
+	s.notifyStatus(status, switches)
+}
+
+// evaluateLocked switches the active source if the Workload API has been
+// failing, or healthy, for long enough. If not yet, it schedules itself to
+// run again when it would be. The delays give the switch some hysteresis so
+// that a flapping agent does not cause the material to flap as well.
+func (s *FailoverX509Source) evaluateLocked(now time.Time) {
+	switch s.status.Active {
+	case FailoverSourceFiles:
+		if s.primarySVID == nil || s.primaryHealthySince.IsZero() {
+			return
+		}
+		// The initial switch to the Workload API is immediate. Only
+		// switching back after a failover is delayed.
+		if at := s.primaryHealthySince.Add(s.config.failbackDelay); s.failedOver && now.Before(at) {
+			s.scheduleEvaluateLocked(at.Sub(now))
+			return
+		}
+		s.switchLocked(FailoverSourceWorkloadAPI, now)
+	case FailoverSourceWorkloadAPI:
+		if s.primaryFailingSince.IsZero() {
+			return
+		}
+		if at := s.primaryFailingSince.Add(s.config.failoverDelay); now.Before(at) {
+			s.scheduleEvaluateLocked(at.Sub(now))
+			return
+		}
+		s.failedOver = true
+		s.switchLocked(FailoverSourceFiles, now)
+	}
+}
+
+func (s *FailoverX509Source) scheduleEvaluateLocked(d time.Duration) {
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.timer = time.AfterFunc(d, s.evaluate)
+}
+
+func (s *FailoverX509Source) switchLocked(active FailoverSourceKind, now time.Time) {
+	s.status.Active = active
+	s.status.Since = now
+	s.switches++
+	s.triggerUpdated()
+}
+
+// notifyStatus calls the status handler with the status captured after the
+// given number of switches, unless the handler has already been called for
+// that switch or a later one. It must be called without holding mtx, so that
+// the handler can call the methods of the source.
+func (s *FailoverX509Source) notifyStatus(status FailoverStatus, switches uint64) {
+	if s.config.statusHandler == nil {
+		return
+	}
+	s.notifyMtx.Lock()
+	defer s.notifyMtx.Unlock()
+	if switches <= s.notified {
+		return
+	}
+	s.notified = switches
+	s.config.statusHandler(status)
+}
+
+func (s *FailoverX509Source) triggerUpdated() {
+	// Updates are triggered from more than one goroutine. If an update is
+	// already pending there is no need to queue another.
+	select {
+	case s.updatedCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *FailoverX509Source) checkClosed() error {
+	s.closeMtx.RLock()
+	defer s.closeMtx.RUnlock()
+	if s.closed {
+		return failoverSourceErr.New("source is closed")
+	}
+	return nil
+}
+
+// failoverWatcher receives the Workload API updates for a
+// FailoverX509Source without exposing the watcher methods on the source.
+type failoverWatcher struct {
+	s *FailoverX509Source
+}
+
+func (w failoverWatcher) OnX509ContextUpdate(x509Context *X509Context) {
+	w.s.onPrimaryUpdate(x509Context)
+}
+
+func (w failoverWatcher) OnX509ContextWatchError(err error) {
+	w.s.onPrimaryError(err)
+}
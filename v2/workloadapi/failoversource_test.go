@@ -0,0 +1,128 @@
+package workloadapi_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakeworkloadapi"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverX509Source(t *testing.T) {
+	// Time out the test after a minute if something goes wrong.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	td := spiffeid.RequireTrustDomainFromString("domain.test")
+	ca := test.NewCA(t, td)
+	filesID := spiffeid.RequireFromPath(td, "/files")
+	apiID := spiffeid.RequireFromPath(td, "/api")
+
+	files := workloadapi.SPIFFEHelperFiles(t.TempDir())
+	writeX509Files(t, files, ca, ca.CreateX509SVID(filesID))
+
+	api := fakeworkloadapi.New(t)
+	defer api.Stop()
+
+	var mtx sync.Mutex
+	var switches []workloadapi.FailoverSourceKind
+	var handlerSource *workloadapi.FailoverX509Source
+	source, err := workloadapi.NewFailoverX509Source(ctx, files,
+		withAddr(api),
+		workloadapi.WithFailoverDelay(50*time.Millisecond),
+		workloadapi.WithFailbackDelay(100*time.Millisecond),
+		workloadapi.WithFailoverStatusHandler(func(status workloadapi.FailoverStatus) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			switches = append(switches, status.Active)
+			// The handler can use the source.
+			if handlerSource != nil {
+				handlerSource.Status()
+				_, err := handlerSource.GetX509SVID()
+				assert.NoError(t, err)
+			}
+		}),
+	)
+	require.NoError(t, err)
+	defer source.Close()
+	mtx.Lock()
+	handlerSource = source
+	mtx.Unlock()
+
+	waitForActive := func(kind workloadapi.FailoverSourceKind, id spiffeid.ID) {
+		for source.Status().Active != kind {
+			require.NoError(t, source.WaitUntilUpdated(ctx))
+		}
+		svid, err := source.GetX509SVID()
+		require.NoError(t, err)
+		require.Equal(t, id, svid.ID)
+		bundle, err := source.GetX509BundleForTrustDomain(td)
+		require.NoError(t, err)
+		require.Equal(t, ca.X509Authorities(), bundle.X509Authorities())
+	}
+
+	// The agent has no identity yet, so the files are served.
+	status := source.Status()
+	assert.Equal(t, workloadapi.FailoverSourceFiles, status.Active)
+	waitForActive(workloadapi.FailoverSourceFiles, filesID)
+
+	// Once the Workload API delivers, it is switched to right away.
+	api.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{ca.CreateX509SVID(apiID)},
+		Bundle: ca.X509Bundle(),
+	})
+	waitForActive(workloadapi.FailoverSourceWorkloadAPI, apiID)
+	status = source.Status()
+	assert.NoError(t, status.LastWorkloadAPIError)
+	assert.False(t, status.LastWorkloadAPIUpdate.IsZero())
+
+	// The agent loses the identity, so the source fails over to the files.
+	api.SetX509SVIDResponse(nil)
+	waitForActive(workloadapi.FailoverSourceFiles, filesID)
+	assert.Error(t, source.Status().LastWorkloadAPIError)
+
+	// The agent recovers, so the source fails back after the delay.
+	api.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{ca.CreateX509SVID(apiID)},
+		Bundle: ca.X509Bundle(),
+	})
+	waitForActive(workloadapi.FailoverSourceWorkloadAPI, apiID)
+	status = source.Status()
+	assert.GreaterOrEqual(t, status.Since.Sub(status.LastWorkloadAPIUpdate), 100*time.Millisecond)
+
+	// The handler is called after the switch is visible.
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(switches) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	mtx.Lock()
+	assert.Equal(t, []workloadapi.FailoverSourceKind{
+		workloadapi.FailoverSourceWorkloadAPI,
+		workloadapi.FailoverSourceFiles,
+		workloadapi.FailoverSourceWorkloadAPI,
+	}, switches)
+	mtx.Unlock()
+
+	require.NoError(t, source.Close())
+	_, err = source.GetX509SVID()
+	require.EqualError(t, err, "failoversource: source is closed")
+	_, err = source.GetX509BundleForTrustDomain(td)
+	require.EqualError(t, err, "failoversource: source is closed")
+}
+
+func TestNewFailoverX509SourceFailsOnMissingFiles(t *testing.T) {
+	api := fakeworkloadapi.New(t)
+	defer api.Stop()
+
+	_, err := workloadapi.NewFailoverX509Source(context.Background(), workloadapi.SPIFFEHelperFiles(t.TempDir()), withAddr(api))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "failoversource: filesource: x509svid: cannot read certificate file")
+}
@@ -0,0 +1,330 @@
+package workloadapi
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/fsnotify/fsnotify"
+	"github.com/zeebo/errs"
+)
+
+var fileSourceErr = errs.Class("filesource")
+
+// defaultFileReloadDelay is how long the file source waits after the last
+// file system event before reloading. Writers commonly update the files
+// one after the other, so this avoids loading a mismatched certificate and
+// key.
+const defaultFileReloadDelay = 100 * time.Millisecond
+
+// X509Files locates the PEM encoded files holding X.509 SVID material.
+type X509Files struct {
+	// CertFile holds the X509-SVID certificate, optionally followed by
+	// intermediates.
+	CertFile string
+
+	// KeyFile holds the PKCS#8 private key of the X509-SVID. It may be the
+	// same file as CertFile.
+	KeyFile string
+
+	// BundleFile holds the X.509 authorities of the trust domain of the
+	// X509-SVID. It is optional: without it, the source only provides the
+	// X509-SVID, e.g. for TLS servers whose peers do not use SPIFFE.
+	BundleFile string
+}
+
+// SPIFFEHelperFiles returns the files written to the given directory by
+// spiffe-helper and the SPIRE agent svidstore (svid.pem, svid_key.pem and
+// svid_bundle.pem).
+func SPIFFEHelperFiles(dir string) X509Files {
+	return X509Files{
+		CertFile:   filepath.Join(dir, "svid.pem"),
+		KeyFile:    filepath.Join(dir, "svid_key.pem"),
+		BundleFile: filepath.Join(dir, "svid_bundle.pem"),
+	}
+}
+
+// CertManagerCSIFiles returns the files written to the given directory by
+// the cert-manager CSI drivers (tls.crt, tls.key and ca.crt).
+func CertManagerCSIFiles(dir string) X509Files {
+	return X509Files{
+		CertFile:   filepath.Join(dir, "tls.crt"),
+		KeyFile:    filepath.Join(dir, "tls.key"),
+		BundleFile: filepath.Join(dir, "ca.crt"),
+	}
+}
+
+// FileX509SourceOption is an option for the FileX509Source.
+type FileX509SourceOption interface {
+	configureFileX509Source(*fileX509SourceConfig)
+}
+
+// WithFileSourceLogger provides a logger to the FileX509Source, used to
+// report reload failures.
+func WithFileSourceLogger(log logger.Logger) FileX509SourceOption {
+	return fileX509SourceOption(func(c *fileX509SourceConfig) {
+		c.log = log
+	})
+}
+
+// WithFileParseOptions sets the options used to parse the X509-SVID files,
+// e.g. x509svid.WithKeyPassword for an encrypted private key.
+func WithFileParseOptions(opts ...x509svid.ParseOption) FileX509SourceOption {
+	return fileX509SourceOption(func(c *fileX509SourceConfig) {
+		c.parseOpts = opts
+	})
+}
+
+// FileX509Source is a source of an X509-SVID and X.509 bundle loaded from
+// files on disk. The files are reloaded when they change. It is useful in
+// environments where the material is delivered to the workload as files
+// instead of via a Workload API socket.
+type FileX509Source struct {
+	files  X509Files
+	config fileX509SourceConfig
+
+	watcher   *fsnotify.Watcher
+	updatedCh chan struct{}
+
+	mtx    sync.RWMutex
+	svid   *x509svid.SVID
+	bundle *x509bundle.Bundle
+
+	// retired holds the X509-SVID replaced on the last reload, to be
+	// zeroized on the next one when WithZeroizeOnRotation is used.
+	retired []*x509svid.SVID
+
+	closeMtx sync.RWMutex
+	closed   bool
+	closeErr error
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewFileX509Source creates a new FileX509Source. The files are loaded before
+// returning and an error is returned if they cannot be loaded. The source
+// should be closed when no longer in use to free underlying resources.
+func NewFileX509Source(files X509Files, options ...FileX509SourceOption) (_ *FileX509Source, err error) {
+	config := fileX509SourceConfig{
+		log:         logger.Null,
+		reloadDelay: defaultFileReloadDelay,
+	}
+	for _, option := range options {
+		option.configureFileX509Source(&config)
+	}
+
+	s := &FileX509Source{
+		files:     files,
+		config:    config,
+		updatedCh: make(chan struct{}, 1),
+		cancel:    func() {},
+	}
+
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+
+	s.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fileSourceErr.New("unable to create file watcher: %w", err)
+	}
+
+	// Watch the parent directories instead of the files themselves, so
+	// that atomic updates that swap files or symlinks (e.g. Kubernetes
+	// volumes) are noticed.
+	dirs := make(map[string]struct{})
+	for _, file := range s.paths() {
+		dirs[filepath.Dir(file)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := s.watcher.Add(dir); err != nil {
+			s.watcher.Close()
+			return nil, fileSourceErr.New("unable to watch %q: %w", dir, err)
+		}
+	}
+
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(context.Background())
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx)
+	}()
+
+	return s, nil
+}
+
+// Close closes the source, stopping the file watcher. Other source methods
+// will return an error after Close has been called.
+func (s *FileX509Source) Close() error {
+	s.closeMtx.Lock()
+	defer s.closeMtx.Unlock()
+
+	if !s.closed {
+		s.cancel()
+		s.closeErr = s.watcher.Close()
+		s.wg.Wait()
+		s.closed = true
+		if s.config.zeroize {
+			s.mtx.Lock()
+			zeroizeSVIDs(s.retired)
+			s.svid.Zeroize()
+			s.mtx.Unlock()
+		}
+	}
+	return s.closeErr
+}
+
+// GetX509SVID returns the X509-SVID loaded from disk. It implements the
+// x509svid.Source interface.
+func (s *FileX509Source) GetX509SVID() (*x509svid.SVID, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.svid, nil
+}
+
+// GetX509BundleForTrustDomain returns the X.509 bundle for the given trust
+// domain. Only the bundle for the trust domain of the X509-SVID is
+// available, and only if a bundle file is configured. It implements the
+// x509bundle.Source interface.
+func (s *FileX509Source) GetX509BundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	if s.bundle == nil {
+		return nil, fileSourceErr.New("no bundle file configured")
+	}
+	return s.bundle.GetX509BundleForTrustDomain(trustDomain)
+}
+
+// WaitUntilUpdated waits until the source is updated or the context is done,
+// in which case ctx.Err() is returned.
+func (s *FileX509Source) WaitUntilUpdated(ctx context.Context) error {
+	select {
+	case <-s.updatedCh:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Updated returns a channel that is sent on whenever the source is updated.
+func (s *FileX509Source) Updated() <-chan struct{} {
+	return s.updatedCh
+}
+
+func (s *FileX509Source) run(ctx context.Context) {
+	var reloadCh <-chan time.Time
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if !s.isWatchedFile(event.Name) {
+				continue
+			}
+			reloadCh = time.After(s.config.reloadDelay)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.config.log.Errorf("File watcher error: %v", err)
+		case <-reloadCh:
+			reloadCh = nil
+			if err := s.reload(); err != nil {
+				s.config.log.Errorf("Failed to reload X.509 material from disk: %v", err)
+				continue
+			}
+			s.triggerUpdated()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// isWatchedFile returns true if the event is for one of the files, or for
+// the "..data" symlink that Kubernetes swaps when updating volumes.
+func (s *FileX509Source) isWatchedFile(name string) bool {
+	name = filepath.Clean(name)
+	for _, file := range s.paths() {
+		if name == filepath.Clean(file) {
+			return true
+		}
+	}
+	return filepath.Base(name) == "..data"
+}
+
+// paths returns the paths of the files to load.
+func (s *FileX509Source) paths() []string {
+	paths := []string{s.files.CertFile, s.files.KeyFile}
+	if s.files.BundleFile != "" {
+		paths = append(paths, s.files.BundleFile)
+	}
+	return paths
+}
+
+func (s *FileX509Source) reload() error {
+	svid, err := x509svid.Load(s.files.CertFile, s.files.KeyFile, s.config.parseOpts...)
+	if err != nil {
+		return fileSourceErr.Wrap(err)
+	}
+	var bundle *x509bundle.Bundle
+	if s.files.BundleFile != "" {
+		bundle, err = x509bundle.Load(svid.ID.TrustDomain(), s.files.BundleFile)
+		if err != nil {
+			return fileSourceErr.Wrap(err)
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if s.config.zeroize && s.svid != nil {
+		s.retired = retireSVIDs(s.retired, []*x509svid.SVID{s.svid}, []*x509svid.SVID{svid})
+	}
+	s.svid = svid
+	s.bundle = bundle
+	return nil
+}
+
+func (s *FileX509Source) triggerUpdated() {
+	select {
+	case <-s.updatedCh:
+	default:
+	}
+	s.updatedCh <- struct{}{}
+}
+
+func (s *FileX509Source) checkClosed() error {
+	s.closeMtx.RLock()
+	defer s.closeMtx.RUnlock()
+	if s.closed {
+		return fileSourceErr.New("source is closed")
+	}
+	return nil
+}
+
+type fileX509SourceConfig struct {
+	log         logger.Logger
+	reloadDelay time.Duration
+	zeroize     bool
+	parseOpts   []x509svid.ParseOption
+}
+
+type fileX509SourceOption func(*fileX509SourceConfig)
+
+func (fn fileX509SourceOption) configureFileX509Source(config *fileX509SourceConfig) {
+	fn(config)
+}
@@ -0,0 +1,152 @@
+package workloadapi_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileX509Source(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	files := workloadapi.SPIFFEHelperFiles(t.TempDir())
+
+	svid1 := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/one"))
+	writeX509Files(t, files, ca, svid1)
+
+	source, err := workloadapi.NewFileX509Source(files)
+	require.NoError(t, err)
+	defer source.Close()
+
+	svid, err := source.GetX509SVID()
+	require.NoError(t, err)
+	assert.Equal(t, svid1.ID, svid.ID)
+	assert.Equal(t, svid1.Certificates, svid.Certificates)
+
+	bundle, err := source.GetX509BundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Equal(t, ca.X509Authorities(), bundle.X509Authorities())
+
+	_, err = source.GetX509BundleForTrustDomain(spiffeid.RequireTrustDomainFromString("other.test"))
+	require.EqualError(t, err, `x509bundle: no X.509 bundle found for trust domain: "other.test"`)
+
+	// Rewrite the files and wait for the source to pick up the change.
+	svid2 := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/two"))
+	writeX509Files(t, files, ca, svid2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, source.WaitUntilUpdated(ctx))
+
+	svid, err = source.GetX509SVID()
+	require.NoError(t, err)
+	assert.Equal(t, svid2.ID, svid.ID)
+
+	require.NoError(t, source.Close())
+	_, err = source.GetX509SVID()
+	require.EqualError(t, err, "filesource: source is closed")
+	_, err = source.GetX509BundleForTrustDomain(td)
+	require.EqualError(t, err, "filesource: source is closed")
+}
+
+func TestFileX509SourceWithZeroizeOnRotation(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	files := workloadapi.SPIFFEHelperFiles(t.TempDir())
+	writeX509Files(t, files, ca, ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/one")))
+
+	source, err := workloadapi.NewFileX509Source(files, workloadapi.WithZeroizeOnRotation())
+	require.NoError(t, err)
+	defer source.Close()
+
+	initial, err := source.GetX509SVID()
+	require.NoError(t, err)
+	require.NotNil(t, initial.PrivateKey)
+
+	// The replaced X509-SVID is only zeroized on the next reload.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	writeX509Files(t, files, ca, ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/two")))
+	require.NoError(t, source.WaitUntilUpdated(ctx))
+	assert.NotNil(t, initial.PrivateKey)
+
+	update, err := source.GetX509SVID()
+	require.NoError(t, err)
+	writeX509Files(t, files, ca, ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/three")))
+	require.NoError(t, source.WaitUntilUpdated(ctx))
+	assert.Nil(t, initial.PrivateKey)
+	assert.NotNil(t, update.PrivateKey)
+
+	require.NoError(t, source.Close())
+	assert.Nil(t, update.PrivateKey)
+}
+
+func TestNewFileX509SourceFailsOnMissingFiles(t *testing.T) {
+	_, err := workloadapi.NewFileX509Source(workloadapi.CertManagerCSIFiles(t.TempDir()))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "filesource: x509svid: cannot read certificate file")
+}
+
+func TestFileX509SourceWithoutBundleFile(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	files := workloadapi.SPIFFEHelperFiles(t.TempDir())
+	svid1 := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/one"))
+	writeX509Files(t, files, ca, svid1)
+	files.BundleFile = ""
+
+	source, err := workloadapi.NewFileX509Source(files)
+	require.NoError(t, err)
+	defer source.Close()
+
+	svid, err := source.GetX509SVID()
+	require.NoError(t, err)
+	assert.Equal(t, svid1.ID, svid.ID)
+
+	_, err = source.GetX509BundleForTrustDomain(td)
+	require.EqualError(t, err, "filesource: no bundle file configured")
+}
+
+func TestFileX509SourceWithFileParseOptions(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	files := workloadapi.SPIFFEHelperFiles(t.TempDir())
+	svid1 := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/one"))
+	writeX509Files(t, files, ca, svid1)
+
+	password := []byte("password")
+	_, keyPEM, err := svid1.Marshal(x509svid.WithKeyPassword(password))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(files.KeyFile, keyPEM, 0600))
+
+	_, err = workloadapi.NewFileX509Source(files)
+	require.Error(t, err)
+
+	source, err := workloadapi.NewFileX509Source(files, workloadapi.WithFileParseOptions(x509svid.WithKeyPassword(password)))
+	require.NoError(t, err)
+	defer source.Close()
+
+	svid, err := source.GetX509SVID()
+	require.NoError(t, err)
+	assert.Equal(t, svid1.ID, svid.ID)
+	assert.Equal(t, svid1.PrivateKey, svid.PrivateKey)
+}
+
+func writeX509Files(t *testing.T, files workloadapi.X509Files, ca *test.CA, svid *x509svid.SVID) {
+	certPEM, keyPEM, err := svid.Marshal()
+	require.NoError(t, err)
+	bundlePEM, err := ca.X509Bundle().Marshal()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(files.CertFile, certPEM, 0600))
+	require.NoError(t, os.WriteFile(files.KeyFile, keyPEM, 0600))
+	require.NoError(t, os.WriteFile(files.BundleFile, bundlePEM, 0600))
+}
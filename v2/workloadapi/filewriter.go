@@ -0,0 +1,231 @@
+package workloadapi
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/zeebo/errs"
+)
+
+var fileWriterErr = errs.Class("filewriter")
+
+// FileFormat is the encoding of the files written by the X509FileWriter.
+type FileFormat int
+
+const (
+	// FileFormatPEM writes PEM encoded certificates and a PKCS#8 PEM encoded
+	// private key.
+	FileFormatPEM FileFormat = iota
+
+	// FileFormatDER writes concatenated ASN.1 DER certificates and a PKCS#8
+	// ASN.1 DER private key.
+	FileFormatDER
+)
+
+// X509FileWriterOption is an option for the X509FileWriter.
+type X509FileWriterOption interface {
+	configureX509FileWriter(*x509FileWriterConfig)
+}
+
+// WithFileWriterLogger provides a logger to the X509FileWriter, used to
+// report write failures when it is used as an X509ContextWatcher.
+func WithFileWriterLogger(log logger.Logger) X509FileWriterOption {
+	return x509FileWriterOption(func(c *x509FileWriterConfig) {
+		c.log = log
+	})
+}
+
+// WithFileFormat sets the encoding of the written files. The default is
+// FileFormatPEM.
+func WithFileFormat(format FileFormat) X509FileWriterOption {
+	return x509FileWriterOption(func(c *x509FileWriterConfig) {
+		c.format = format
+	})
+}
+
+// WithFileModes sets the permissions of the written files. certMode applies
+// to the certificate and bundle files and keyMode to the key file, including
+// when the key is written to the same file as the certificates. The defaults
+// are 0644 and 0600.
+func WithFileModes(certMode, keyMode os.FileMode) X509FileWriterOption {
+	return x509FileWriterOption(func(c *x509FileWriterConfig) {
+		c.certMode = certMode
+		c.keyMode = keyMode
+	})
+}
+
+// WithFileOwner sets the numeric user and group IDs owning the written files.
+// A value of -1 leaves the corresponding ID unchanged. Changing the owner
+// usually requires privileges and is not supported on Windows.
+func WithFileOwner(uid, gid int) X509FileWriterOption {
+	return x509FileWriterOption(func(c *x509FileWriterConfig) {
+		c.uid = uid
+		c.gid = gid
+		c.chown = true
+	})
+}
+
+// X509FileWriter writes an X509-SVID and X.509 bundle to files on disk. Every
+// file is written to a temporary file in the same directory and then renamed
+// over the destination, so readers never observe a partially written file.
+// The files are not replaced together though; see Write.
+// It can be passed to Client.WatchX509Context to keep the files up to date,
+// and the files it writes can be read by FileX509Source.
+type X509FileWriter struct {
+	files  X509Files
+	config x509FileWriterConfig
+}
+
+// NewX509FileWriter creates a new X509FileWriter writing to the given files.
+func NewX509FileWriter(files X509Files, options ...X509FileWriterOption) *X509FileWriter {
+	config := x509FileWriterConfig{
+		log:      logger.Null,
+		format:   FileFormatPEM,
+		certMode: 0644,
+		keyMode:  0600,
+	}
+	for _, option := range options {
+		option.configureX509FileWriter(&config)
+	}
+	return &X509FileWriter{
+		files:  files,
+		config: config,
+	}
+}
+
+// Write writes the X509-SVID and the X.509 bundle to the files. The bundle is
+// written first, then the key and the certificates last. Each file is
+// replaced atomically, but not the files as a whole: between the key and
+// certificate renames, readers can briefly see the new key with the previous
+// certificates, and must tolerate the mismatch, e.g. by reloading once the
+// files have settled as FileX509Source does. Readers that need the key and
+// certificates to always match should use the same file for both, which are
+// then written together. This is only supported with FileFormatPEM.
+func (w *X509FileWriter) Write(svid *x509svid.SVID, bundle *x509bundle.Bundle) error {
+	switch {
+	case svid == nil:
+		return fileWriterErr.New("X509-SVID is required")
+	case bundle == nil:
+		return fileWriterErr.New("X.509 bundle is required")
+	}
+
+	var certBytes, keyBytes, bundleBytes []byte
+	var err error
+	switch w.config.format {
+	case FileFormatPEM:
+		certBytes, keyBytes, err = svid.Marshal()
+		if err != nil {
+			return fileWriterErr.Wrap(err)
+		}
+		bundleBytes, err = bundle.Marshal()
+		if err != nil {
+			return fileWriterErr.Wrap(err)
+		}
+	case FileFormatDER:
+		if samePath(w.files.CertFile, w.files.KeyFile) {
+			return fileWriterErr.New("certificates and key cannot be written to the same DER file")
+		}
+		certBytes, keyBytes, err = svid.MarshalRaw()
+		if err != nil {
+			return fileWriterErr.Wrap(err)
+		}
+		bundleBytes = x509util.ConcatRawCertsFromCerts(bundle.X509Authorities())
+	default:
+		return fileWriterErr.New("unsupported file format %d", w.config.format)
+	}
+
+	if err := w.writeFile(w.files.BundleFile, bundleBytes, w.config.certMode); err != nil {
+		return err
+	}
+	if samePath(w.files.CertFile, w.files.KeyFile) {
+		return w.writeFile(w.files.CertFile, append(certBytes, keyBytes...), w.config.keyMode)
+	}
+	if err := w.writeFile(w.files.KeyFile, keyBytes, w.config.keyMode); err != nil {
+		return err
+	}
+	return w.writeFile(w.files.CertFile, certBytes, w.config.certMode)
+}
+
+// OnX509ContextUpdate writes the default X509-SVID of the X.509 context and
+// the bundle for its trust domain. It implements the X509ContextWatcher
+// interface. Failures are logged.
+func (w *X509FileWriter) OnX509ContextUpdate(x509Context *X509Context) {
+	if len(x509Context.SVIDs) == 0 {
+		w.config.log.Errorf("Failed to write X.509 material to disk: no X509-SVIDs in the X.509 context")
+		return
+	}
+	svid := x509Context.DefaultSVID()
+	bundle, err := x509Context.Bundles.GetX509BundleForTrustDomain(svid.ID.TrustDomain())
+	if err != nil {
+		w.config.log.Errorf("Failed to write X.509 material to disk: %v", err)
+		return
+	}
+	if err := w.Write(svid, bundle); err != nil {
+		w.config.log.Errorf("Failed to write X.509 material to disk: %v", err)
+	}
+}
+
+// OnX509ContextWatchError logs the error. It implements the
+// X509ContextWatcher interface.
+func (w *X509FileWriter) OnX509ContextWatchError(err error) {
+	w.config.log.Errorf("X.509 context watch error: %v", err)
+}
+
+func (w *X509FileWriter) writeFile(path string, data []byte, mode os.FileMode) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return fileWriterErr.New("unable to create temporary file for %q: %w", path, err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err := tmp.Chmod(mode); err != nil {
+		return fileWriterErr.New("unable to set permissions of %q: %w", path, err)
+	}
+	if w.config.chown {
+		if err := tmp.Chown(w.config.uid, w.config.gid); err != nil {
+			return fileWriterErr.New("unable to set owner of %q: %w", path, err)
+		}
+	}
+	if _, err := tmp.Write(data); err != nil {
+		return fileWriterErr.New("unable to write %q: %w", path, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fileWriterErr.New("unable to write %q: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fileWriterErr.New("unable to write %q: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fileWriterErr.New("unable to replace %q: %w", path, err)
+	}
+	return nil
+}
+
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+type x509FileWriterConfig struct {
+	log      logger.Logger
+	format   FileFormat
+	certMode os.FileMode
+	keyMode  os.FileMode
+	chown    bool
+	uid      int
+	gid      int
+}
+
+type x509FileWriterOption func(*x509FileWriterConfig)
+
+func (fn x509FileWriterOption) configureX509FileWriter(config *x509FileWriterConfig) {
+	fn(config)
+}
@@ -0,0 +1,142 @@
+package workloadapi_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestX509FileWriter(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	svid := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"))
+	files := workloadapi.SPIFFEHelperFiles(t.TempDir())
+
+	writer := workloadapi.NewX509FileWriter(files)
+	require.NoError(t, writer.Write(svid, ca.X509Bundle()))
+
+	source, err := workloadapi.NewFileX509Source(files)
+	require.NoError(t, err)
+	defer source.Close()
+	actual, err := source.GetX509SVID()
+	require.NoError(t, err)
+	assert.Equal(t, svid.Certificates, actual.Certificates)
+	bundle, err := source.GetX509BundleForTrustDomain(td)
+	require.NoError(t, err)
+	assert.Equal(t, ca.X509Authorities(), bundle.X509Authorities())
+
+	if runtime.GOOS != "windows" {
+		requireFileMode(t, files.CertFile, 0644)
+		requireFileMode(t, files.KeyFile, 0600)
+		requireFileMode(t, files.BundleFile, 0644)
+	}
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(filepath.Dir(files.CertFile))
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	require.EqualError(t, writer.Write(nil, ca.X509Bundle()), "filewriter: X509-SVID is required")
+	require.EqualError(t, writer.Write(svid, nil), "filewriter: X.509 bundle is required")
+}
+
+func TestX509FileWriterWithFileModesAndOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes and owners are not supported on Windows")
+	}
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	files := workloadapi.SPIFFEHelperFiles(t.TempDir())
+
+	writer := workloadapi.NewX509FileWriter(files, workloadapi.WithFileModes(0640, 0400), workloadapi.WithFileOwner(os.Getuid(), os.Getgid()))
+	require.NoError(t, writer.Write(ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload")), ca.X509Bundle()))
+	requireFileMode(t, files.CertFile, 0640)
+	requireFileMode(t, files.KeyFile, 0400)
+	requireFileMode(t, files.BundleFile, 0640)
+
+	// Read-only files are replaced.
+	require.NoError(t, writer.Write(ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload")), ca.X509Bundle()))
+}
+
+func TestX509FileWriterWithFileFormatDER(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	svid := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"))
+	files := workloadapi.CertManagerCSIFiles(t.TempDir())
+
+	writer := workloadapi.NewX509FileWriter(files, workloadapi.WithFileFormat(workloadapi.FileFormatDER))
+	require.NoError(t, writer.Write(svid, ca.X509Bundle()))
+
+	certBytes, err := os.ReadFile(files.CertFile)
+	require.NoError(t, err)
+	keyBytes, err := os.ReadFile(files.KeyFile)
+	require.NoError(t, err)
+	actual, err := x509svid.ParseRaw(certBytes, keyBytes)
+	require.NoError(t, err)
+	assert.Equal(t, svid.Certificates, actual.Certificates)
+
+	bundleBytes, err := os.ReadFile(files.BundleFile)
+	require.NoError(t, err)
+	bundle, err := x509bundle.ParseRaw(td, bundleBytes)
+	require.NoError(t, err)
+	assert.Equal(t, ca.X509Authorities(), bundle.X509Authorities())
+
+	files.KeyFile = files.CertFile
+	writer = workloadapi.NewX509FileWriter(files, workloadapi.WithFileFormat(workloadapi.FileFormatDER))
+	require.EqualError(t, writer.Write(svid, ca.X509Bundle()), "filewriter: certificates and key cannot be written to the same DER file")
+}
+
+func TestX509FileWriterSameCertAndKeyFile(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	svid := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"))
+	dir := t.TempDir()
+	files := workloadapi.X509Files{
+		CertFile:   filepath.Join(dir, "svid.pem"),
+		KeyFile:    filepath.Join(dir, "svid.pem"),
+		BundleFile: filepath.Join(dir, "bundle.pem"),
+	}
+
+	require.NoError(t, workloadapi.NewX509FileWriter(files).Write(svid, ca.X509Bundle()))
+	actual, err := x509svid.Load(files.CertFile, files.KeyFile)
+	require.NoError(t, err)
+	assert.Equal(t, svid.Certificates, actual.Certificates)
+	if runtime.GOOS != "windows" {
+		requireFileMode(t, files.CertFile, 0600)
+	}
+}
+
+func TestX509FileWriterOnX509ContextUpdate(t *testing.T) {
+	td := spiffeid.RequireTrustDomainFromString("example.org")
+	ca := test.NewCA(t, td)
+	svid := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"))
+	files := workloadapi.SPIFFEHelperFiles(t.TempDir())
+
+	var watcher workloadapi.X509ContextWatcher = workloadapi.NewX509FileWriter(files)
+	watcher.OnX509ContextUpdate(&workloadapi.X509Context{
+		SVIDs:   []*x509svid.SVID{svid},
+		Bundles: x509bundle.NewSet(ca.X509Bundle()),
+	})
+
+	actual, err := x509svid.Load(files.CertFile, files.KeyFile)
+	require.NoError(t, err)
+	assert.Equal(t, svid.Certificates, actual.Certificates)
+	bundle, err := x509bundle.Load(td, files.BundleFile)
+	require.NoError(t, err)
+	assert.Equal(t, ca.X509Authorities(), bundle.X509Authorities())
+}
+
+func requireFileMode(t *testing.T, path string, mode os.FileMode) {
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, mode, info.Mode().Perm())
+}
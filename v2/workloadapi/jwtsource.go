@@ -3,6 +3,7 @@ package workloadapi
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
 	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
@@ -12,6 +13,17 @@ import (
 
 var jwtsourceErr = errs.Class("jwtsource")
 
+const (
+	// minJWTBundleRefreshInterval is the minimum time between the refreshes
+	// requested with RefreshJWTBundleForTrustDomain, which can be triggered
+	// by untrusted tokens.
+	minJWTBundleRefreshInterval = 5 * time.Second
+
+	// jwtBundleRefreshTimeout bounds the refreshes requested with
+	// RefreshJWTBundleForTrustDomain.
+	jwtBundleRefreshTimeout = 10 * time.Second
+)
+
 // JWTSource is a source of JWT-SVID and JWT bundles maintained via the
 // Workload API.
 type JWTSource struct {
@@ -20,6 +32,9 @@ type JWTSource struct {
 	mtx     sync.RWMutex
 	bundles *jwtbundle.Set
 
+	refreshMtx  sync.Mutex
+	lastRefresh time.Time
+
 	closeMtx sync.RWMutex
 	closed   bool
 }
@@ -82,6 +97,40 @@ func (s *JWTSource) GetJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain)
 	return s.bundles.GetJWTBundleForTrustDomain(trustDomain)
 }
 
+// RefreshJWTBundleForTrustDomain fetches the JWT bundles from the Workload
+// API, without waiting for the next update, and returns the JWT bundle for
+// the given trust domain. Refreshes happen at most once every 5 seconds;
+// more frequent calls return the current bundle. It implements the
+// jwtbundle.RefreshableSource interface.
+func (s *JWTSource) RefreshJWTBundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*jwtbundle.Bundle, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+	if err := s.refreshJWTBundles(); err != nil {
+		return nil, err
+	}
+	return s.GetJWTBundleForTrustDomain(trustDomain)
+}
+
+func (s *JWTSource) refreshJWTBundles() error {
+	s.refreshMtx.Lock()
+	defer s.refreshMtx.Unlock()
+
+	if time.Since(s.lastRefresh) < minJWTBundleRefreshInterval {
+		return nil
+	}
+	s.lastRefresh = time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), jwtBundleRefreshTimeout)
+	defer cancel()
+	bundles, err := s.watcher.client.FetchJWTBundles(ctx)
+	if err != nil {
+		return jwtsourceErr.New("unable to refresh JWT bundles: %w", err)
+	}
+	s.setJWTBundles(bundles)
+	return nil
+}
+
 // WaitUntilUpdated waits until the source is updated or the context is done,
 // in which case ctx.Err() is returned.
 func (s *JWTSource) WaitUntilUpdated(ctx context.Context) error {
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
 	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
 	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakeworkloadapi"
 	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
@@ -100,3 +101,31 @@ func TestJWTSourceGetsUpdates(t *testing.T) {
 	requireNoJWTBundle(t, source, domain1TD, `jwtbundle: no JWT bundle for trust domain "domain1.test"`)
 	requireJWTBundle(t, source, domain2TD, domain2Bundle)
 }
+
+func TestJWTSourceRefreshJWTBundleForTrustDomain(t *testing.T) {
+	// Time out the test after a minute if something goes wrong.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	api := fakeworkloadapi.New(t)
+	defer api.Stop()
+
+	td := spiffeid.RequireTrustDomainFromString("domain.test")
+	ca := test.NewCA(t, td)
+	api.SetJWTBundles(ca.JWTBundle())
+
+	source, err := workloadapi.NewJWTSource(ctx, withAddr(api))
+	require.NoError(t, err)
+
+	var refreshable jwtbundle.RefreshableSource = source
+	bundle, err := refreshable.RefreshJWTBundleForTrustDomain(td)
+	require.NoError(t, err)
+	require.Equal(t, ca.JWTBundle(), bundle)
+
+	_, err = refreshable.RefreshJWTBundleForTrustDomain(spiffeid.RequireTrustDomainFromString("other.test"))
+	require.Error(t, err)
+
+	require.NoError(t, source.Close())
+	_, err = refreshable.RefreshJWTBundleForTrustDomain(td)
+	require.EqualError(t, err, "jwtsource: source is closed")
+}
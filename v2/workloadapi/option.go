@@ -1,9 +1,13 @@
 package workloadapi
 
 import (
+	"time"
+
 	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
 	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 // ClientOption is an option used when creating a new Client.
@@ -28,6 +32,50 @@ func WithDialOptions(options ...grpc.DialOption) ClientOption {
 	})
 }
 
+// WithMetadata provides gRPC metadata that is attached to every Workload API
+// call made by the Client, in addition to the mandatory security header. The
+// security header cannot be overridden. Per-call metadata can be provided by
+// attaching it to the context passed to the call (e.g. via
+// metadata.AppendToOutgoingContext); it is merged with the metadata provided
+// here.
+func WithMetadata(md metadata.MD) ClientOption {
+	return clientOption(func(c *clientConfig) {
+		c.metadata = metadata.Join(c.metadata, md)
+	})
+}
+
+// WithCallTimeout provides a default timeout for non-streaming Workload API
+// calls (e.g. FetchJWTSVID, ValidateJWTSVID or FetchX509SVID). The timeout is
+// only applied when the context passed to the call has no deadline. Watch
+// calls are not affected. A zero or negative duration disables the timeout,
+// which is the default.
+func WithCallTimeout(timeout time.Duration) ClientOption {
+	return clientOption(func(c *clientConfig) {
+		c.callTimeout = timeout
+	})
+}
+
+// WithTrustDomainFilter restricts the bundles retained from Workload API
+// responses to those of the given trust domains, which cuts memory usage and
+// update churn for workloads that only talk to a few of the trust domains
+// federated with their own. The bundles for the trust domains of the
+// X509-SVIDs in X.509 context responses are always retained, so only the
+// federated trust domains need to be provided. Responses that only carry
+// bundles (e.g. FetchX509Bundles or WatchJWTBundles) do not distinguish
+// between local and federated bundles; the local trust domain must be
+// provided for its bundle to be retained from them. The option can be used
+// more than once, in which case the trust domains are accumulated.
+func WithTrustDomainFilter(trustDomains ...spiffeid.TrustDomain) ClientOption {
+	return clientOption(func(c *clientConfig) {
+		if c.trustDomains == nil {
+			c.trustDomains = make(map[spiffeid.TrustDomain]struct{}, len(trustDomains))
+		}
+		for _, td := range trustDomains {
+			c.trustDomains[td] = struct{}{}
+		}
+	})
+}
+
 // WithLogger provides a logger to the Client.
 func WithLogger(logger logger.Logger) ClientOption {
 	return clientOption(func(c *clientConfig) {
@@ -40,6 +88,7 @@ type SourceOption interface {
 	configureX509Source(*x509SourceConfig)
 	configureJWTSource(*jwtSourceConfig)
 	configureBundleSource(*bundleSourceConfig)
+	configureFailoverX509Source(*failoverX509SourceConfig)
 }
 
 // WithClient provides a Client for the source to use. If unset, a new Client
@@ -54,6 +103,16 @@ func WithClientOptions(options ...ClientOption) SourceOption {
 	return withClientOptions{options: options}
 }
 
+// WithInitTimeout bounds how long the source waits for the initial update
+// from the Workload API when it is created. If the update does not arrive in
+// time, creating the source fails with an *InitTimeoutError. By default, the
+// source waits until the context passed at creation is done. The
+// FailoverX509Source ignores this option since it does not wait for the
+// Workload API.
+func WithInitTimeout(timeout time.Duration) SourceOption {
+	return withInitTimeout{timeout: timeout}
+}
+
 // X509SourceOption is an option for the X509Source. A SourceOption is also an
 // X509SourceOption.
 type X509SourceOption interface {
@@ -68,6 +127,68 @@ func WithDefaultX509SVIDPicker(picker func([]*x509svid.SVID) *x509svid.SVID) X50
 	return withDefaultX509SVIDPicker{picker: picker}
 }
 
+// ZeroizeOption is an option for both the X509Source and the
+// FileX509Source.
+type ZeroizeOption interface {
+	X509SourceOption
+	FileX509SourceOption
+}
+
+// WithZeroizeOnRotation makes the source zeroize the private keys of the
+// X509-SVIDs it replaces on rotation, and of the X509-SVIDs it holds when it
+// is closed (see x509svid.SVID.Zeroize). The replaced X509-SVIDs are only
+// zeroized on the rotation after the one replacing them, so that callers that
+// obtained them shortly before, e.g. in-flight TLS handshakes of the
+// tlsconfig package, can finish using them. X509-SVIDs returned by the
+// source must therefore not be used after the next rotation, nor after the
+// source is closed; this option should not be used if the application
+// retains them for longer, e.g. to sign with them later.
+func WithZeroizeOnRotation() ZeroizeOption {
+	return withZeroizeOnRotation{}
+}
+
+// FailoverX509SourceOption is an option for the FailoverX509Source. A
+// SourceOption is also a FailoverX509SourceOption.
+type FailoverX509SourceOption interface {
+	configureFailoverX509Source(*failoverX509SourceConfig)
+}
+
+// WithFailoverDelay sets how long the Workload API must be failing before the
+// FailoverX509Source switches to the files. Defaults to 10 seconds.
+func WithFailoverDelay(delay time.Duration) FailoverX509SourceOption {
+	return failoverX509SourceOption(func(c *failoverX509SourceConfig) {
+		c.failoverDelay = delay
+	})
+}
+
+// WithFailbackDelay sets how long the Workload API must be healthy after a
+// failover before the FailoverX509Source switches back to it. Defaults to 30
+// seconds.
+func WithFailbackDelay(delay time.Duration) FailoverX509SourceOption {
+	return failoverX509SourceOption(func(c *failoverX509SourceConfig) {
+		c.failbackDelay = delay
+	})
+}
+
+// WithFileSourceOptions controls the options used to create the
+// FileX509Source that provides the fallback material.
+func WithFileSourceOptions(options ...FileX509SourceOption) FailoverX509SourceOption {
+	return failoverX509SourceOption(func(c *failoverX509SourceConfig) {
+		c.fileOptions = options
+	})
+}
+
+// WithFailoverStatusHandler provides a function that is called with the
+// status of the FailoverX509Source whenever it switches between the Workload
+// API and the files. The calls are serialized, and the function can call the
+// methods of the source, e.g. Status or GetX509SVID. It should return quickly,
+// as it delays the processing of the next updates.
+func WithFailoverStatusHandler(handler func(FailoverStatus)) FailoverX509SourceOption {
+	return failoverX509SourceOption(func(c *failoverX509SourceConfig) {
+		c.statusHandler = handler
+	})
+}
+
 // JWTSourceOption is an option for the JWTSource. A SourceOption is also a
 // JWTSourceOption.
 type JWTSourceOption interface {
@@ -83,7 +204,11 @@ type BundleSourceOption interface {
 type clientConfig struct {
 	address       string
 	namedPipeName string
+	namedPipe     namedPipeConfig
 	dialOptions   []grpc.DialOption
+	metadata      metadata.MD
+	callTimeout   time.Duration
+	trustDomains  map[spiffeid.TrustDomain]struct{}
 	log           logger.Logger
 }
 
@@ -96,6 +221,7 @@ func (fn clientOption) configureClient(config *clientConfig) {
 type x509SourceConfig struct {
 	watcher watcherConfig
 	picker  func([]*x509svid.SVID) *x509svid.SVID
+	zeroize bool
 }
 
 type jwtSourceConfig struct {
@@ -106,6 +232,20 @@ type bundleSourceConfig struct {
 	watcher watcherConfig
 }
 
+type failoverX509SourceConfig struct {
+	watcher       watcherConfig
+	fileOptions   []FileX509SourceOption
+	failoverDelay time.Duration
+	failbackDelay time.Duration
+	statusHandler func(FailoverStatus)
+}
+
+type failoverX509SourceOption func(*failoverX509SourceConfig)
+
+func (fn failoverX509SourceOption) configureFailoverX509Source(config *failoverX509SourceConfig) {
+	fn(config)
+}
+
 type withClient struct {
 	client *Client
 }
@@ -122,6 +262,10 @@ func (o withClient) configureBundleSource(config *bundleSourceConfig) {
 	config.watcher.client = o.client
 }
 
+func (o withClient) configureFailoverX509Source(config *failoverX509SourceConfig) {
+	config.watcher.client = o.client
+}
+
 type withClientOptions struct {
 	options []ClientOption
 }
@@ -138,6 +282,30 @@ func (o withClientOptions) configureBundleSource(config *bundleSourceConfig) {
 	config.watcher.clientOptions = o.options
 }
 
+func (o withClientOptions) configureFailoverX509Source(config *failoverX509SourceConfig) {
+	config.watcher.clientOptions = o.options
+}
+
+type withInitTimeout struct {
+	timeout time.Duration
+}
+
+func (o withInitTimeout) configureX509Source(config *x509SourceConfig) {
+	config.watcher.initTimeout = o.timeout
+}
+
+func (o withInitTimeout) configureJWTSource(config *jwtSourceConfig) {
+	config.watcher.initTimeout = o.timeout
+}
+
+func (o withInitTimeout) configureBundleSource(config *bundleSourceConfig) {
+	config.watcher.initTimeout = o.timeout
+}
+
+func (o withInitTimeout) configureFailoverX509Source(config *failoverX509SourceConfig) {
+	config.watcher.initTimeout = o.timeout
+}
+
 type withDefaultX509SVIDPicker struct {
 	picker func([]*x509svid.SVID) *x509svid.SVID
 }
@@ -145,3 +313,13 @@ type withDefaultX509SVIDPicker struct {
 func (o withDefaultX509SVIDPicker) configureX509Source(config *x509SourceConfig) {
 	config.picker = o.picker
 }
+
+type withZeroizeOnRotation struct{}
+
+func (withZeroizeOnRotation) configureX509Source(config *x509SourceConfig) {
+	config.zeroize = true
+}
+
+func (withZeroizeOnRotation) configureFileX509Source(config *fileX509SourceConfig) {
+	config.zeroize = true
+}
@@ -3,6 +3,8 @@
 
 package workloadapi
 
+import "golang.org/x/sys/windows"
+
 // WithNamedPipeName provides a Pipe Name for the Workload API
 // endpoint in the form \\.\pipe\<pipeName>.
 func WithNamedPipeName(pipeName string) ClientOption {
@@ -10,3 +12,54 @@ func WithNamedPipeName(pipeName string) ClientOption {
 		c.namedPipeName = pipeName
 	})
 }
+
+// NamedPipeImpersonationLevel is the impersonation level granted to the
+// Workload API server when connecting through a named pipe.
+type NamedPipeImpersonationLevel uint32
+
+const (
+	// NamedPipeImpersonationAnonymous prevents the server from obtaining
+	// identification information about the client. This is the default.
+	NamedPipeImpersonationAnonymous = NamedPipeImpersonationLevel(windows.SECURITY_ANONYMOUS)
+
+	// NamedPipeImpersonationIdentification allows the server to obtain
+	// information about the client, but not to impersonate it.
+	NamedPipeImpersonationIdentification = NamedPipeImpersonationLevel(windows.SECURITY_IDENTIFICATION)
+
+	// NamedPipeImpersonationImpersonation allows the server to impersonate
+	// the client on the local system.
+	NamedPipeImpersonationImpersonation = NamedPipeImpersonationLevel(windows.SECURITY_IMPERSONATION)
+
+	// NamedPipeImpersonationDelegation allows the server to impersonate the
+	// client on remote systems.
+	NamedPipeImpersonationDelegation = NamedPipeImpersonationLevel(windows.SECURITY_DELEGATION)
+)
+
+// WithNamedPipeImpersonationLevel sets the impersonation level granted to the
+// Workload API server when connecting through a named pipe. Defaults to
+// NamedPipeImpersonationAnonymous.
+func WithNamedPipeImpersonationLevel(level NamedPipeImpersonationLevel) ClientOption {
+	return clientOption(func(c *clientConfig) {
+		c.namedPipe.impersonationLevel = level
+	})
+}
+
+// WithNamedPipeOwner sets the SID, in its string form (e.g. "S-1-5-18" for
+// the LocalSystem account), that must own the named pipe serving the
+// Workload API. Connections to pipes owned by any other principal are
+// rejected, which protects against pipe squatting by unprivileged processes.
+func WithNamedPipeOwner(sid string) ClientOption {
+	return clientOption(func(c *clientConfig) {
+		c.namedPipe.ownerSID = sid
+	})
+}
+
+// WithNamedPipeSecurityCheck provides a function that is called with the
+// security descriptor (owner and DACL) of the named pipe serving the Workload
+// API after connecting. If the function returns an error, the connection is
+// closed and the error returned from the dial.
+func WithNamedPipeSecurityCheck(check func(sd *windows.SECURITY_DESCRIPTOR) error) ClientOption {
+	return clientOption(func(c *clientConfig) {
+		c.namedPipe.securityCheck = check
+	})
+}
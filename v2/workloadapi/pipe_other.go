@@ -0,0 +1,8 @@
+//go:build !windows
+// +build !windows
+
+package workloadapi
+
+// namedPipeConfig holds the named pipe hardening settings. Named pipes are
+// only supported on Windows.
+type namedPipeConfig struct{}
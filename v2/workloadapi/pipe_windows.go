@@ -0,0 +1,161 @@
+//go:build windows
+// +build windows
+
+package workloadapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+// namedPipeConfig holds the named pipe hardening settings.
+type namedPipeConfig struct {
+	impersonationLevel NamedPipeImpersonationLevel
+	ownerSID           string
+	securityCheck      func(*windows.SECURITY_DESCRIPTOR) error
+}
+
+func (c namedPipeConfig) isHardened() bool {
+	return c.impersonationLevel != NamedPipeImpersonationAnonymous || c.ownerSID != "" || c.securityCheck != nil
+}
+
+// namedPipeDialer returns the dialer used to connect to the named pipe. The
+// go-winio dialer is used unless hardening options have been provided.
+func namedPipeDialer(config namedPipeConfig) func(context.Context, string) (net.Conn, error) {
+	if !config.isHardened() {
+		return winio.DialPipeContext
+	}
+	return func(ctx context.Context, path string) (net.Conn, error) {
+		return dialHardenedPipe(ctx, path, config)
+	}
+}
+
+func dialHardenedPipe(ctx context.Context, path string, config namedPipeConfig) (net.Conn, error) {
+	var expectedOwner *windows.SID
+	if config.ownerSID != "" {
+		var err error
+		expectedOwner, err = windows.StringToSid(config.ownerSID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid named pipe owner SID %q: %w", config.ownerSID, err)
+		}
+	}
+
+	h, err := openPipe(ctx, path, uint32(config.impersonationLevel))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkPipeSecurity(h, expectedOwner, config.securityCheck); err != nil {
+		_ = windows.CloseHandle(h)
+		return nil, err
+	}
+
+	f, err := winio.MakeOpenFile(syscall.Handle(h))
+	if err != nil {
+		_ = windows.CloseHandle(h)
+		return nil, err
+	}
+	pf, ok := f.(pipeFile)
+	if !ok {
+		// Purely defensive. The go-winio file supports deadlines.
+		_ = f.Close()
+		return nil, errors.New("named pipe file does not support deadlines")
+	}
+	return &pipeConn{pipeFile: pf, addr: pipeAddr(path)}, nil
+}
+
+// openPipe opens the named pipe with the requested impersonation level,
+// retrying while all pipe instances are busy.
+func openPipe(ctx context.Context, path string, impersonationLevel uint32) (windows.Handle, error) {
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		h, err := windows.CreateFile(path16,
+			windows.GENERIC_READ|windows.GENERIC_WRITE,
+			0,
+			nil,
+			windows.OPEN_EXISTING,
+			windows.FILE_FLAG_OVERLAPPED|windows.SECURITY_SQOS_PRESENT|impersonationLevel,
+			0)
+		if err == nil {
+			return h, nil
+		}
+		if !errors.Is(err, windows.ERROR_PIPE_BUSY) {
+			return 0, &os.PathError{Op: "open", Path: path, Err: err}
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func checkPipeSecurity(h windows.Handle, expectedOwner *windows.SID, check func(*windows.SECURITY_DESCRIPTOR) error) error {
+	sd, err := windows.GetSecurityInfo(h, windows.SE_KERNEL_OBJECT, windows.OWNER_SECURITY_INFORMATION|windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return fmt.Errorf("unable to get named pipe security info: %w", err)
+	}
+
+	if expectedOwner != nil {
+		owner, _, err := sd.Owner()
+		if err != nil {
+			return fmt.Errorf("unable to get named pipe owner: %w", err)
+		}
+		if owner == nil {
+			return errors.New("named pipe has no owner")
+		}
+		if !owner.Equals(expectedOwner) {
+			return fmt.Errorf("named pipe is owned by %q; expected %q", owner.String(), expectedOwner.String())
+		}
+	}
+
+	if check != nil {
+		if err := check(sd); err != nil {
+			return fmt.Errorf("named pipe failed security check: %w", err)
+		}
+	}
+	return nil
+}
+
+type pipeFile interface {
+	Read([]byte) (int, error)
+	Write([]byte) (int, error)
+	Close() error
+	SetDeadline(time.Time) error
+	SetReadDeadline(time.Time) error
+	SetWriteDeadline(time.Time) error
+}
+
+type pipeConn struct {
+	pipeFile
+	addr pipeAddr
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string {
+	return "pipe"
+}
+
+func (a pipeAddr) String() string {
+	return string(a)
+}
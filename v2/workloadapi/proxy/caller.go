@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Caller describes the process calling the proxied Workload API. It is only
+// available when the proxy is served over a unix domain socket on platforms
+// that support peer credentials (i.e. Linux).
+type Caller struct {
+	// PID is the process ID of the caller.
+	PID int32
+
+	// UID is the user ID of the caller.
+	UID uint32
+
+	// GID is the group ID of the caller.
+	GID uint32
+}
+
+// CallerFromContext returns the caller information for the request. It
+// returns false if the information is not available.
+func CallerFromContext(ctx context.Context) (Caller, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return Caller{}, false
+	}
+	authInfo, ok := p.AuthInfo.(callerAuthInfo)
+	if !ok || !authInfo.ok {
+		return Caller{}, false
+	}
+	return authInfo.caller, true
+}
+
+// callerCredentials are insecure transport credentials that capture the peer
+// credentials of the connection during the handshake.
+type callerCredentials struct{}
+
+func (callerCredentials) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	return conn, callerAuthInfo{}, nil
+}
+
+func (callerCredentials) ServerHandshake(conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	caller, ok := peerCaller(conn)
+	return conn, callerAuthInfo{caller: caller, ok: ok}, nil
+}
+
+func (callerCredentials) Info() credentials.ProtocolInfo {
+	return credentials.ProtocolInfo{SecurityProtocol: "insecure"}
+}
+
+func (c callerCredentials) Clone() credentials.TransportCredentials {
+	return c
+}
+
+func (callerCredentials) OverrideServerName(string) error {
+	return nil
+}
+
+type callerAuthInfo struct {
+	credentials.CommonAuthInfo
+	caller Caller
+	ok     bool
+}
+
+func (callerAuthInfo) AuthType() string {
+	return "insecure"
+}
@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package proxy
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+func peerCaller(conn net.Conn) (Caller, bool) {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return Caller{}, false
+	}
+	rawConn, err := unixConn.SyscallConn()
+	if err != nil {
+		return Caller{}, false
+	}
+
+	var ucred *unix.Ucred
+	var ucredErr error
+	if err := rawConn.Control(func(fd uintptr) {
+		ucred, ucredErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil || ucredErr != nil {
+		return Caller{}, false
+	}
+	return Caller{PID: ucred.Pid, UID: ucred.Uid, GID: ucred.Gid}, true
+}
@@ -0,0 +1,70 @@
+//go:build linux
+// +build linux
+
+package proxy_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakeworkloadapi"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallerFromContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	ca := test.NewCA(t, td)
+	upstream := fakeworkloadapi.New(t)
+	defer upstream.Stop()
+	upstream.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{ca.CreateX509SVID(fooID)},
+		Bundle: ca.X509Bundle(),
+	})
+
+	upstreamClient, err := workloadapi.New(ctx, workloadapi.WithAddr(upstream.Addr()))
+	require.NoError(t, err)
+	defer upstreamClient.Close()
+
+	callerCh := make(chan proxy.Caller, 1)
+	server := proxy.New(upstreamClient, proxy.WithFilter(func(ctx context.Context, id spiffeid.ID) bool {
+		caller, ok := proxy.CallerFromContext(ctx)
+		assert.True(t, ok)
+		callerCh <- caller
+		return true
+	}))
+
+	socketPath := filepath.Join(t.TempDir(), "agent.sock")
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.ListenAndServe(socketPath) }()
+	defer func() {
+		server.Stop()
+		<-errCh
+	}()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(socketPath)
+		return err == nil
+	}, 10*time.Second, 10*time.Millisecond)
+
+	client, err := workloadapi.New(ctx, workloadapi.WithAddr("unix://"+socketPath))
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.FetchX509SVID(ctx)
+	require.NoError(t, err)
+
+	caller := <-callerCh
+	assert.Equal(t, int32(os.Getpid()), caller.PID)
+	assert.Equal(t, uint32(os.Getuid()), caller.UID)
+	assert.Equal(t, uint32(os.Getgid()), caller.GID)
+}
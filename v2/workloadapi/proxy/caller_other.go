@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package proxy
+
+import "net"
+
+func peerCaller(net.Conn) (Caller, bool) {
+	// Peer credentials are not supported in this platform
+	return Caller{}, false
+}
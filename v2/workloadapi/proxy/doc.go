@@ -0,0 +1,17 @@
+// Package proxy provides an in-process Workload API server that forwards
+// requests to an upstream Workload API through an existing
+// workloadapi.Client.
+//
+// A parent process can use it to broker identities to child processes or
+// containers without giving them direct access to the SPIFFE agent:
+//
+//	client, err := workloadapi.New(ctx)
+//	...
+//	server := proxy.New(client, proxy.WithFilter(func(ctx context.Context, id spiffeid.ID) bool {
+//		caller, ok := proxy.CallerFromContext(ctx)
+//		return ok && caller.UID == childUID && id == childID
+//	}))
+//	err = server.ListenAndServe("/run/child/agent.sock")
+//
+// Children then point SPIFFE_ENDPOINT_SOCKET at the new socket.
+package proxy
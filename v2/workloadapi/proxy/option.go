@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"context"
+
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+)
+
+// Filter decides whether the caller of the proxied Workload API may receive
+// the SVID with the given SPIFFE ID. Information about the caller is available
+// via CallerFromContext.
+type Filter func(ctx context.Context, id spiffeid.ID) bool
+
+// Option is an option for the proxy Server.
+type Option interface {
+	configure(*config)
+}
+
+// WithFilter provides a filter that is applied to the X509-SVIDs and
+// JWT-SVIDs served to each caller. By default, all SVIDs are served.
+func WithFilter(filter Filter) Option {
+	return option(func(c *config) {
+		c.filter = filter
+	})
+}
+
+// WithLogger provides a logger to the Server.
+func WithLogger(log logger.Logger) Option {
+	return option(func(c *config) {
+		c.log = log
+	})
+}
+
+type config struct {
+	filter Filter
+	log    logger.Logger
+}
+
+type option func(*config)
+
+func (fn option) configure(c *config) {
+	fn(c)
+}
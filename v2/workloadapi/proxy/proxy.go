@@ -0,0 +1,448 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/x509util"
+	"github.com/damarescavalcante/go-spiffe/v2/logger"
+	"github.com/damarescavalcante/go-spiffe/v2/proto/spiffe/workload"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var errNoIdentity = status.Error(codes.PermissionDenied, "no identity issued")
+
+// Client is the upstream Workload API client used by the Server. It is
+// implemented by *workloadapi.Client.
+type Client interface {
+	WatchX509Context(context.Context, workloadapi.X509ContextWatcher) error
+	WatchX509Bundles(context.Context, workloadapi.X509BundleWatcher) error
+	WatchJWTBundles(context.Context, workloadapi.JWTBundleWatcher) error
+	FetchJWTSVIDs(context.Context, jwtsvid.Params) ([]*jwtsvid.SVID, error)
+	ValidateJWTSVID(ctx context.Context, token, audience string) (*jwtsvid.SVID, error)
+}
+
+// Server serves the Workload API, forwarding requests to an upstream
+// Workload API through a Client.
+type Server struct {
+	workload.UnimplementedSpiffeWorkloadAPIServer
+
+	client Client
+	config config
+
+	mtx     sync.Mutex
+	server  *grpc.Server
+	stopped bool
+}
+
+// New returns a new Server that forwards requests to the given client. The
+// client is not closed by the Server.
+func New(client Client, options ...Option) *Server {
+	s := &Server{
+		client: client,
+		config: config{
+			log: logger.Null,
+		},
+	}
+	for _, option := range options {
+		option.configure(&s.config)
+	}
+	return s
+}
+
+// Serve serves the Workload API on the given listener. It blocks until the
+// listener fails or Stop is called. A Server can only serve once.
+func (s *Server) Serve(listener net.Listener) error {
+	s.mtx.Lock()
+	switch {
+	case s.stopped:
+		s.mtx.Unlock()
+		listener.Close()
+		return errors.New("proxy: server stopped")
+	case s.server != nil:
+		s.mtx.Unlock()
+		return errors.New("proxy: server already serving")
+	}
+	s.server = grpc.NewServer(grpc.Creds(callerCredentials{}))
+	workload.RegisterSpiffeWorkloadAPIServer(s.server, s)
+	server := s.server
+	s.mtx.Unlock()
+
+	return server.Serve(listener)
+}
+
+// ListenAndServe listens on the unix domain socket at the given path and
+// serves the Workload API on it. Any stale socket at the path is removed
+// first. If something other than a socket exists at the path, an error is
+// returned and it is left untouched.
+func (s *Server) ListenAndServe(socketPath string) error {
+	if err := removeStaleSocket(socketPath); err != nil {
+		return err
+	}
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// removeStaleSocket removes the socket at the given path, if any. It refuses
+// to remove anything that is not a socket.
+func removeStaleSocket(socketPath string) error {
+	info, err := os.Lstat(socketPath)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSocket == 0:
+		return fmt.Errorf("proxy: refusing to remove %q: not a socket", socketPath)
+	}
+	return os.Remove(socketPath)
+}
+
+// Stop stops the Server, closing the listener and any open streams.
+func (s *Server) Stop() {
+	s.mtx.Lock()
+	server := s.server
+	s.stopped = true
+	s.mtx.Unlock()
+	if server != nil {
+		server.Stop()
+	}
+}
+
+// FetchX509SVID implements the Workload API FetchX509SVID method.
+func (s *Server) FetchX509SVID(_ *workload.X509SVIDRequest, stream workload.SpiffeWorkloadAPI_FetchX509SVIDServer) error {
+	ctx := stream.Context()
+	if err := checkHeader(ctx); err != nil {
+		return err
+	}
+
+	w := newStreamWatcher()
+	return s.forward(ctx, w, func(ctx context.Context) error {
+		return s.client.WatchX509Context(ctx, w)
+	}, func(update interface{}) error {
+		resp, err := s.makeX509SVIDResponse(ctx, update.(*workloadapi.X509Context))
+		if err != nil {
+			return err
+		}
+		return stream.Send(resp)
+	})
+}
+
+// FetchX509Bundles implements the Workload API FetchX509Bundles method.
+func (s *Server) FetchX509Bundles(_ *workload.X509BundlesRequest, stream workload.SpiffeWorkloadAPI_FetchX509BundlesServer) error {
+	ctx := stream.Context()
+	if err := checkHeader(ctx); err != nil {
+		return err
+	}
+
+	w := newStreamWatcher()
+	return s.forward(ctx, w, func(ctx context.Context) error {
+		return s.client.WatchX509Bundles(ctx, w)
+	}, func(update interface{}) error {
+		return stream.Send(&workload.X509BundlesResponse{
+			Bundles: marshalX509Bundles(update.(*x509bundle.Set).Bundles()),
+		})
+	})
+}
+
+// FetchJWTBundles implements the Workload API FetchJWTBundles method.
+func (s *Server) FetchJWTBundles(_ *workload.JWTBundlesRequest, stream workload.SpiffeWorkloadAPI_FetchJWTBundlesServer) error {
+	ctx := stream.Context()
+	if err := checkHeader(ctx); err != nil {
+		return err
+	}
+
+	w := newStreamWatcher()
+	return s.forward(ctx, w, func(ctx context.Context) error {
+		return s.client.WatchJWTBundles(ctx, w)
+	}, func(update interface{}) error {
+		bundles, err := marshalJWTBundles(update.(*jwtbundle.Set).Bundles())
+		if err != nil {
+			return err
+		}
+		return stream.Send(&workload.JWTBundlesResponse{
+			Bundles: bundles,
+		})
+	})
+}
+
+// FetchJWTSVID implements the Workload API FetchJWTSVID method.
+func (s *Server) FetchJWTSVID(ctx context.Context, req *workload.JWTSVIDRequest) (*workload.JWTSVIDResponse, error) {
+	if err := checkHeader(ctx); err != nil {
+		return nil, err
+	}
+	if len(req.Audience) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "audience must be specified")
+	}
+
+	params := jwtsvid.Params{
+		Audience:       req.Audience[0],
+		ExtraAudiences: req.Audience[1:],
+	}
+	if req.SpiffeId != "" {
+		subject, err := spiffeid.FromString(req.SpiffeId)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid requested SPIFFE ID: %v", err)
+		}
+		if !s.allowed(ctx, subject) {
+			return nil, errNoIdentity
+		}
+		params.Subject = subject
+	}
+
+	svids, err := s.client.FetchJWTSVIDs(outgoingContext(ctx), params)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+
+	resp := new(workload.JWTSVIDResponse)
+	for _, svid := range svids {
+		if !s.allowed(ctx, svid.ID) {
+			continue
+		}
+		resp.Svids = append(resp.Svids, &workload.JWTSVID{
+			SpiffeId: svid.ID.String(),
+			Svid:     svid.Marshal(),
+			Hint:     svid.Hint,
+		})
+	}
+	if len(resp.Svids) == 0 {
+		return nil, errNoIdentity
+	}
+	return resp, nil
+}
+
+// ValidateJWTSVID implements the Workload API ValidateJWTSVID method.
+func (s *Server) ValidateJWTSVID(ctx context.Context, req *workload.ValidateJWTSVIDRequest) (*workload.ValidateJWTSVIDResponse, error) {
+	if err := checkHeader(ctx); err != nil {
+		return nil, err
+	}
+	if req.Audience == "" {
+		return nil, status.Error(codes.InvalidArgument, "audience must be specified")
+	}
+	if req.Svid == "" {
+		return nil, status.Error(codes.InvalidArgument, "svid must be specified")
+	}
+
+	svid, err := s.client.ValidateJWTSVID(outgoingContext(ctx), req.Svid, req.Audience)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+
+	claims, err := structpb.NewStruct(svid.Claims)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "unable to marshal claims: %v", err)
+	}
+	return &workload.ValidateJWTSVIDResponse{
+		SpiffeId: svid.ID.String(),
+		Claims:   claims,
+	}, nil
+}
+
+// forward runs the upstream watch and calls send for every update until the
+// downstream stream is done. Permission denied errors from the upstream
+// are forwarded to the caller; other errors are retried by the upstream
+// client. If the upstream watch ends, e.g. on a non-retryable error or
+// because the upstream client was closed, its error is returned.
+func (s *Server) forward(ctx context.Context, w *streamWatcher, watch func(context.Context) error, send func(interface{}) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	watchErr := make(chan error, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		watchErr <- watch(ctx)
+	}()
+
+	for {
+		select {
+		case update := <-w.updates:
+			if err := send(update); err != nil {
+				return err
+			}
+		case err := <-w.errs:
+			if errors.Is(err, workloadapi.ErrPermissionDenied) {
+				return upstreamError(err)
+			}
+			s.config.log.Warnf("Upstream Workload API watch failed: %v", err)
+		case err := <-watchErr:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == nil {
+				err = errors.New("upstream Workload API watch ended")
+			}
+			return upstreamError(err)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Server) makeX509SVIDResponse(ctx context.Context, x509Context *workloadapi.X509Context) (*workload.X509SVIDResponse, error) {
+	resp := &workload.X509SVIDResponse{
+		FederatedBundles: make(map[string][]byte),
+	}
+
+	local := make(map[spiffeid.TrustDomain]struct{})
+	for _, svid := range x509Context.SVIDs {
+		if !s.allowed(ctx, svid.ID) {
+			continue
+		}
+		certs, key, err := svid.MarshalRaw()
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to marshal X509-SVID: %v", err)
+		}
+
+		td := svid.ID.TrustDomain()
+		var bundle []byte
+		if b, ok := x509Context.Bundles.Get(td); ok {
+			bundle = x509util.ConcatRawCertsFromCerts(b.X509Authorities())
+		}
+		local[td] = struct{}{}
+
+		resp.Svids = append(resp.Svids, &workload.X509SVID{
+			SpiffeId:    svid.ID.String(),
+			X509Svid:    certs,
+			X509SvidKey: key,
+			Bundle:      bundle,
+			Hint:        svid.Hint,
+		})
+	}
+	if len(resp.Svids) == 0 {
+		return nil, errNoIdentity
+	}
+
+	for _, b := range x509Context.Bundles.Bundles() {
+		if _, ok := local[b.TrustDomain()]; ok {
+			continue
+		}
+		resp.FederatedBundles[b.TrustDomain().IDString()] = x509util.ConcatRawCertsFromCerts(b.X509Authorities())
+	}
+	return resp, nil
+}
+
+func (s *Server) allowed(ctx context.Context, id spiffeid.ID) bool {
+	return s.config.filter == nil || s.config.filter(ctx, id)
+}
+
+// streamWatcher receives updates from the upstream watches. Only the latest
+// update is retained if the downstream stream falls behind.
+type streamWatcher struct {
+	updates chan interface{}
+	errs    chan error
+}
+
+func newStreamWatcher() *streamWatcher {
+	return &streamWatcher{
+		updates: make(chan interface{}, 1),
+		errs:    make(chan error, 1),
+	}
+}
+
+func (w *streamWatcher) OnX509ContextUpdate(x509Context *workloadapi.X509Context) {
+	w.update(x509Context)
+}
+
+func (w *streamWatcher) OnX509ContextWatchError(err error) {
+	w.error(err)
+}
+
+func (w *streamWatcher) OnX509BundlesUpdate(bundles *x509bundle.Set) {
+	w.update(bundles)
+}
+
+func (w *streamWatcher) OnX509BundlesWatchError(err error) {
+	w.error(err)
+}
+
+func (w *streamWatcher) OnJWTBundlesUpdate(bundles *jwtbundle.Set) {
+	w.update(bundles)
+}
+
+func (w *streamWatcher) OnJWTBundlesWatchError(err error) {
+	w.error(err)
+}
+
+func (w *streamWatcher) update(update interface{}) {
+	for {
+		select {
+		case w.updates <- update:
+			return
+		default:
+			select {
+			case <-w.updates:
+			default:
+			}
+		}
+	}
+}
+
+func (w *streamWatcher) error(err error) {
+	select {
+	case w.errs <- err:
+	default:
+	}
+}
+
+func checkHeader(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok || len(md.Get("workload.spiffe.io")) == 0 || md.Get("workload.spiffe.io")[0] != "true" {
+		return status.Error(codes.InvalidArgument, "security header missing from request")
+	}
+	return nil
+}
+
+// outgoingContext returns a context for the upstream call that carries the
+// downstream deadline and cancellation, but not its metadata.
+func outgoingContext(ctx context.Context) context.Context {
+	return metadata.NewOutgoingContext(ctx, metadata.MD{})
+}
+
+// upstreamError converts an error from the upstream Workload API into a
+// status that can be returned to the caller.
+func upstreamError(err error) error {
+	if st, ok := status.FromError(err); ok {
+		return st.Err()
+	}
+	return status.Error(codes.Unavailable, err.Error())
+}
+
+func marshalX509Bundles(bundles []*x509bundle.Bundle) map[string][]byte {
+	out := make(map[string][]byte, len(bundles))
+	for _, b := range bundles {
+		out[b.TrustDomain().IDString()] = x509util.ConcatRawCertsFromCerts(b.X509Authorities())
+	}
+	return out
+}
+
+func marshalJWTBundles(bundles []*jwtbundle.Bundle) (map[string][]byte, error) {
+	out := make(map[string][]byte, len(bundles))
+	for _, b := range bundles {
+		bundleBytes, err := b.Marshal()
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "unable to marshal JWT bundle: %v", err)
+		}
+		out[b.TrustDomain().IDString()] = bundleBytes
+	}
+	return out, nil
+}
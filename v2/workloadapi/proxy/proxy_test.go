@@ -0,0 +1,218 @@
+package proxy_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/bundle/x509bundle"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakeworkloadapi"
+	"github.com/damarescavalcante/go-spiffe/v2/proto/spiffe/workload"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi/proxy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	td          = spiffeid.RequireTrustDomainFromString("example.org")
+	federatedTD = spiffeid.RequireTrustDomainFromString("federated.test")
+	fooID       = spiffeid.RequireFromPath(td, "/foo")
+	barID       = spiffeid.RequireFromPath(td, "/bar")
+)
+
+func TestProxy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	ca := test.NewCA(t, td)
+	federatedCA := test.NewCA(t, federatedTD)
+	fooSVID := ca.CreateX509SVID(fooID, test.WithHint("foo"))
+	barSVID := ca.CreateX509SVID(barID, test.WithHint("bar"))
+
+	upstream := fakeworkloadapi.New(t)
+	defer upstream.Stop()
+	upstream.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:            []*x509svid.SVID{fooSVID, barSVID},
+		Bundle:           ca.X509Bundle(),
+		FederatedBundles: []*x509bundle.Bundle{federatedCA.X509Bundle()},
+	})
+	upstream.SetX509Bundles(ca.X509Bundle(), federatedCA.X509Bundle())
+	upstream.SetJWTBundles(ca.JWTBundle(), federatedCA.JWTBundle())
+
+	fooJWT := ca.CreateJWTSVID(fooID, []string{"audience"})
+	barJWT := ca.CreateJWTSVID(barID, []string{"audience"})
+	upstream.SetJWTSVIDResponse(&workload.JWTSVIDResponse{
+		Svids: []*workload.JWTSVID{
+			{SpiffeId: fooID.String(), Svid: fooJWT.Marshal()},
+			{SpiffeId: barID.String(), Svid: barJWT.Marshal()},
+		},
+	})
+
+	upstreamClient, err := workloadapi.New(ctx, workloadapi.WithAddr(upstream.Addr()))
+	require.NoError(t, err)
+	defer upstreamClient.Close()
+
+	// Only serve the foo identity to callers of the proxy.
+	server := proxy.New(upstreamClient, proxy.WithFilter(func(ctx context.Context, id spiffeid.ID) bool {
+		return id == fooID
+	}))
+	client := startProxy(ctx, t, server)
+
+	t.Run("X.509 context", func(t *testing.T) {
+		x509Context, err := client.FetchX509Context(ctx)
+		require.NoError(t, err)
+		require.Len(t, x509Context.SVIDs, 1)
+		assert.Equal(t, fooSVID, x509Context.SVIDs[0])
+		assert.Equal(t, x509bundle.NewSet(ca.X509Bundle(), federatedCA.X509Bundle()), x509Context.Bundles)
+	})
+
+	t.Run("X.509 bundles", func(t *testing.T) {
+		bundles, err := client.FetchX509Bundles(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, x509bundle.NewSet(ca.X509Bundle(), federatedCA.X509Bundle()), bundles)
+	})
+
+	t.Run("JWT bundles", func(t *testing.T) {
+		bundles, err := client.FetchJWTBundles(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, bundles.Len())
+		assert.True(t, bundles.Has(federatedTD))
+	})
+
+	t.Run("JWT-SVIDs", func(t *testing.T) {
+		svids, err := client.FetchJWTSVIDs(ctx, jwtsvid.Params{Audience: "audience"})
+		require.NoError(t, err)
+		require.Len(t, svids, 1)
+		assert.Equal(t, fooJWT.Marshal(), svids[0].Marshal())
+	})
+
+	t.Run("JWT-SVID for filtered identity", func(t *testing.T) {
+		_, err := client.FetchJWTSVID(ctx, jwtsvid.Params{Audience: "audience", Subject: barID})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("validate JWT-SVID", func(t *testing.T) {
+		svid, err := client.ValidateJWTSVID(ctx, barJWT.Marshal(), "audience")
+		require.NoError(t, err)
+		assert.Equal(t, barID, svid.ID)
+	})
+
+	t.Run("updates are forwarded", func(t *testing.T) {
+		tw := newX509ContextWatcher()
+		watchCtx, watchCancel := context.WithCancel(ctx)
+		defer watchCancel()
+		go func() { _ = client.WatchX509Context(watchCtx, tw) }()
+
+		first := <-tw.updates
+		assert.Equal(t, fooSVID, first.SVIDs[0])
+
+		newFooSVID := ca.CreateX509SVID(fooID)
+		upstream.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+			SVIDs:  []*x509svid.SVID{newFooSVID},
+			Bundle: ca.X509Bundle(),
+		})
+		second := <-tw.updates
+		assert.Equal(t, newFooSVID, second.SVIDs[0])
+	})
+}
+
+func TestProxyNoIdentity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	ca := test.NewCA(t, td)
+	upstream := fakeworkloadapi.New(t)
+	defer upstream.Stop()
+	upstream.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{ca.CreateX509SVID(barID)},
+		Bundle: ca.X509Bundle(),
+	})
+
+	upstreamClient, err := workloadapi.New(ctx, workloadapi.WithAddr(upstream.Addr()))
+	require.NoError(t, err)
+	defer upstreamClient.Close()
+
+	server := proxy.New(upstreamClient, proxy.WithFilter(func(ctx context.Context, id spiffeid.ID) bool {
+		return false
+	}))
+	client := startProxy(ctx, t, server)
+
+	_, err = client.FetchX509SVID(ctx)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestProxyUpstreamWatchEnds(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	server := proxy.New(endingClient{err: status.Error(codes.InvalidArgument, "bad request")})
+	client := startProxy(ctx, t, server)
+
+	_, err := client.FetchX509SVID(ctx)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestListenAndServeDoesNotRemoveNonSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	require.NoError(t, ioutil.WriteFile(path, []byte("data"), 0600))
+
+	err := proxy.New(nil).ListenAndServe(path)
+	require.EqualError(t, err, fmt.Sprintf("proxy: refusing to remove %q: not a socket", path))
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "data", string(data))
+}
+
+func startProxy(ctx context.Context, t *testing.T, server *proxy.Server) *workloadapi.Client {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- server.Serve(listener) }()
+	t.Cleanup(func() {
+		server.Stop()
+		<-errCh
+	})
+
+	client, err := workloadapi.New(ctx, workloadapi.WithAddr("tcp://"+listener.Addr().String()))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+type x509ContextWatcher struct {
+	updates chan *workloadapi.X509Context
+}
+
+func newX509ContextWatcher() *x509ContextWatcher {
+	return &x509ContextWatcher{updates: make(chan *workloadapi.X509Context, 10)}
+}
+
+func (w *x509ContextWatcher) OnX509ContextUpdate(x509Context *workloadapi.X509Context) {
+	w.updates <- x509Context
+}
+
+func (w *x509ContextWatcher) OnX509ContextWatchError(error) {}
+
+// endingClient is an upstream client whose watches end immediately with an
+// error.
+type endingClient struct {
+	proxy.Client
+	err error
+}
+
+func (c endingClient) WatchX509Context(context.Context, workloadapi.X509ContextWatcher) error {
+	return c.err
+}
@@ -0,0 +1,75 @@
+package workloadapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+)
+
+// InitTimeoutError is returned when a source does not become ready in time,
+// either because the initial Workload API update did not arrive within the
+// timeout set with WithInitTimeout, or because the context passed to
+// WaitForSource expired. It matches context.DeadlineExceeded using
+// errors.Is.
+type InitTimeoutError struct {
+	// LastError is the last error encountered while waiting, if any (e.g. a
+	// *WatchError explaining why the Workload API did not deliver).
+	LastError error
+}
+
+// Error returns a message describing the timeout and, if known, its cause.
+func (e *InitTimeoutError) Error() string {
+	if e.LastError == nil {
+		return "timed out waiting for the initial update"
+	}
+	return fmt.Sprintf("timed out waiting for the initial update: %v", e.LastError)
+}
+
+// Unwrap returns the last error encountered while waiting.
+func (e *InitTimeoutError) Unwrap() error {
+	return e.LastError
+}
+
+// Is returns true if the target is context.DeadlineExceeded.
+func (e *InitTimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
+
+// WaitableSource is a source that notifies when it is updated. All of the
+// sources in this package are WaitableSources.
+type WaitableSource interface {
+	WaitUntilUpdated(ctx context.Context) error
+}
+
+// WaitForSource waits until the source is ready to serve or the context is
+// done. X.509 sources are ready once they return an X509-SVID; other sources
+// are ready once created. If the context deadline expires first, an
+// *InitTimeoutError holding the last error returned by the source is
+// returned. Otherwise, if the context is canceled, ctx.Err() is returned.
+//
+// It is intended to be called at startup so that a service fails fast with
+// an actionable message instead of serving without an identity.
+func WaitForSource(ctx context.Context, source WaitableSource) error {
+	for {
+		err := sourceReady(source)
+		if err == nil {
+			return nil
+		}
+		if waitErr := source.WaitUntilUpdated(ctx); waitErr != nil {
+			if errors.Is(waitErr, context.DeadlineExceeded) {
+				return &InitTimeoutError{LastError: err}
+			}
+			return waitErr
+		}
+	}
+}
+
+func sourceReady(source WaitableSource) error {
+	if x509Source, ok := source.(x509svid.Source); ok {
+		_, err := x509Source.GetX509SVID()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package workloadapi_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test"
+	"github.com/damarescavalcante/go-spiffe/v2/internal/test/fakeworkloadapi"
+	"github.com/damarescavalcante/go-spiffe/v2/spiffeid"
+	"github.com/damarescavalcante/go-spiffe/v2/svid/x509svid"
+	"github.com/damarescavalcante/go-spiffe/v2/workloadapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithInitTimeout(t *testing.T) {
+	api := fakeworkloadapi.New(t)
+	defer api.Stop()
+
+	// The Workload API has no identity for the workload, so the initial
+	// update never arrives.
+	source, err := workloadapi.NewX509Source(context.Background(), withAddr(api), workloadapi.WithInitTimeout(200*time.Millisecond))
+	if !assert.Error(t, err) {
+		source.Close()
+		return
+	}
+
+	var initErr *workloadapi.InitTimeoutError
+	require.True(t, errors.As(err, &initErr))
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, errors.Is(err, workloadapi.ErrPermissionDenied))
+	assert.Contains(t, err.Error(), "timed out waiting for the initial update: rpc error: code = PermissionDenied")
+}
+
+func TestWithInitTimeoutDoesNotOverrideContext(t *testing.T) {
+	api := fakeworkloadapi.New(t)
+	defer api.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := workloadapi.NewJWTSource(ctx, withAddr(api), workloadapi.WithInitTimeout(time.Minute))
+	require.EqualError(t, err, context.Canceled.Error())
+}
+
+func TestWaitForSource(t *testing.T) {
+	api := fakeworkloadapi.New(t)
+	defer api.Stop()
+
+	td := spiffeid.RequireTrustDomainFromString("domain.test")
+	ca := test.NewCA(t, td)
+	api.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload"))},
+		Bundle: ca.X509Bundle(),
+	})
+
+	source, err := workloadapi.NewX509Source(context.Background(), withAddr(api))
+	require.NoError(t, err)
+	defer source.Close()
+
+	require.NoError(t, workloadapi.WaitForSource(context.Background(), source))
+
+	// A closed source never becomes ready.
+	require.NoError(t, source.Close())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = workloadapi.WaitForSource(ctx, source)
+	require.EqualError(t, err, "timed out waiting for the initial update: x509source: source is closed")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	err = workloadapi.WaitForSource(ctx, source)
+	require.EqualError(t, err, context.Canceled.Error())
+}
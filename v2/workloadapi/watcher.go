@@ -3,6 +3,7 @@ package workloadapi
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/damarescavalcante/go-spiffe/v2/bundle/jwtbundle"
 	"github.com/damarescavalcante/go-spiffe/v2/svid/jwtsvid"
@@ -14,12 +15,14 @@ type sourceClient interface {
 	WatchJWTBundles(context.Context, JWTBundleWatcher) error
 	FetchJWTSVID(context.Context, jwtsvid.Params) (*jwtsvid.SVID, error)
 	FetchJWTSVIDs(context.Context, jwtsvid.Params) ([]*jwtsvid.SVID, error)
+	FetchJWTBundles(context.Context) (*jwtbundle.Set, error)
 	Close() error
 }
 
 type watcherConfig struct {
 	client        sourceClient
 	clientOptions []ClientOption
+	initTimeout   time.Duration
 }
 
 type watcher struct {
@@ -35,6 +38,9 @@ type watcher struct {
 	closed   bool
 	closeErr error
 
+	lastErrMtx sync.Mutex
+	lastErr    error
+
 	x509ContextFn      func(*X509Context)
 	x509ContextSet     chan struct{}
 	x509ContextSetOnce sync.Once
@@ -72,6 +78,13 @@ func newWatcher(ctx context.Context, config watcherConfig, x509ContextFn func(*X
 		w.ownsClient = true
 	}
 
+	initCtx := ctx
+	if config.initTimeout > 0 {
+		var cancel context.CancelFunc
+		initCtx, cancel = context.WithTimeout(ctx, config.initTimeout)
+		defer cancel()
+	}
+
 	errCh := make(chan error, 2)
 	waitFor := func(has <-chan struct{}) error {
 		select {
@@ -79,8 +92,11 @@ func newWatcher(ctx context.Context, config watcherConfig, x509ContextFn func(*X
 			return nil
 		case err := <-errCh:
 			return err
-		case <-ctx.Done():
-			return ctx.Err()
+		case <-initCtx.Done():
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &InitTimeoutError{LastError: w.lastError()}
 		}
 	}
 
@@ -148,8 +164,9 @@ func (w *watcher) OnX509ContextUpdate(x509Context *X509Context) {
 }
 
 func (w *watcher) OnX509ContextWatchError(err error) {
-	// The watcher doesn't do anything special with the error. If logging is
-	// desired, it should be provided to the Workload API client.
+	// The error is only kept to explain an initialization timeout. If
+	// logging is desired, it should be provided to the Workload API client.
+	w.setLastError(err)
 }
 
 func (w *watcher) OnJWTBundlesUpdate(jwtBundles *jwtbundle.Set) {
@@ -160,9 +177,10 @@ func (w *watcher) OnJWTBundlesUpdate(jwtBundles *jwtbundle.Set) {
 	w.triggerUpdated()
 }
 
-func (w *watcher) OnJWTBundlesWatchError(error) {
-	// The watcher doesn't do anything special with the error. If logging is
-	// desired, it should be provided to the Workload API client.
+func (w *watcher) OnJWTBundlesWatchError(err error) {
+	// The error is only kept to explain an initialization timeout. If
+	// logging is desired, it should be provided to the Workload API client.
+	w.setLastError(err)
 }
 
 func (w *watcher) WaitUntilUpdated(ctx context.Context) error {
@@ -178,6 +196,18 @@ func (w *watcher) Updated() <-chan struct{} {
 	return w.updatedCh
 }
 
+func (w *watcher) setLastError(err error) {
+	w.lastErrMtx.Lock()
+	defer w.lastErrMtx.Unlock()
+	w.lastErr = err
+}
+
+func (w *watcher) lastError() error {
+	w.lastErrMtx.Lock()
+	defer w.lastErrMtx.Unlock()
+	return w.lastErr
+}
+
 func (w *watcher) drainUpdated() {
 	select {
 	case <-w.updatedCh:
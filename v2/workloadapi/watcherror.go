@@ -0,0 +1,88 @@
+package workloadapi
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrPermissionDenied classifies watch errors caused by the Workload API
+	// refusing to serve the workload, typically because no identity has been
+	// issued to it (yet).
+	ErrPermissionDenied = errors.New("workload API permission denied")
+
+	// ErrAgentUnavailable classifies watch errors caused by the Workload API
+	// being unreachable or overloaded.
+	ErrAgentUnavailable = errors.New("workload API unavailable")
+
+	// ErrMalformedResponse classifies watch errors caused by a Workload API
+	// response that could not be parsed.
+	ErrMalformedResponse = errors.New("malformed workload API response")
+
+	// ErrCanceled classifies watch errors caused by the watch being canceled.
+	ErrCanceled = errors.New("workload API watch canceled")
+)
+
+// WatchError is the error passed to the watch error callbacks (e.g.
+// OnX509ContextWatchError) and returned from the Watch* methods when the
+// error could be classified. It can be matched against ErrPermissionDenied,
+// ErrAgentUnavailable, ErrMalformedResponse or ErrCanceled using errors.Is,
+// and it still wraps the underlying error, so gRPC status codes and context
+// errors can be inspected as before.
+type WatchError struct {
+	// Class is the classification of the error.
+	Class error
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *WatchError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *WatchError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if the target is the classification of the error.
+func (e *WatchError) Is(target error) bool {
+	return e.Class == target
+}
+
+// classifyWatchError wraps the error in a WatchError if it can be classified.
+// Otherwise the error is returned unchanged.
+func classifyWatchError(err error) error {
+	var watchErr *WatchError
+	if err == nil || errors.As(err, &watchErr) {
+		return err
+	}
+
+	var class error
+	switch {
+	case errors.Is(err, context.Canceled):
+		class = ErrCanceled
+	default:
+		switch status.Code(err) {
+		case codes.Canceled:
+			class = ErrCanceled
+		case codes.PermissionDenied:
+			class = ErrPermissionDenied
+		case codes.Unavailable, codes.ResourceExhausted:
+			class = ErrAgentUnavailable
+		}
+	}
+	if class == nil {
+		return err
+	}
+	return &WatchError{Class: class, Err: err}
+}
+
+func malformedResponseError(err error) error {
+	return &WatchError{Class: ErrMalformedResponse, Err: err}
+}
@@ -0,0 +1,73 @@
+package workloadapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestClassifyWatchError(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		err   error
+		class error
+	}{
+		{
+			name:  "permission denied",
+			err:   status.Error(codes.PermissionDenied, "no identity issued"),
+			class: ErrPermissionDenied,
+		},
+		{
+			name:  "unavailable",
+			err:   status.Error(codes.Unavailable, "connection refused"),
+			class: ErrAgentUnavailable,
+		},
+		{
+			name:  "resource exhausted",
+			err:   status.Error(codes.ResourceExhausted, "slow down"),
+			class: ErrAgentUnavailable,
+		},
+		{
+			name:  "canceled status",
+			err:   status.Error(codes.Canceled, "context canceled"),
+			class: ErrCanceled,
+		},
+		{
+			name:  "canceled context",
+			err:   context.Canceled,
+			class: ErrCanceled,
+		},
+		{
+			name: "unclassified",
+			err:  status.Error(codes.InvalidArgument, "bad request"),
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := classifyWatchError(tt.err)
+			if tt.class == nil {
+				require.Equal(t, tt.err, err)
+				return
+			}
+
+			var watchErr *WatchError
+			require.True(t, errors.As(err, &watchErr))
+			assert.Equal(t, tt.class, watchErr.Class)
+			assert.ErrorIs(t, err, tt.class)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.err.Error(), err.Error())
+			assert.Equal(t, status.Code(tt.err), status.Code(err))
+		})
+	}
+
+	assert.Nil(t, classifyWatchError(nil))
+
+	malformed := malformedResponseError(errors.New("oh no"))
+	assert.ErrorIs(t, malformed, ErrMalformedResponse)
+	assert.Equal(t, malformed, classifyWatchError(malformed), "already classified errors are returned unchanged")
+}
@@ -17,11 +17,17 @@ var x509sourceErr = errs.Class("x509source")
 type X509Source struct {
 	watcher *watcher
 	picker  func([]*x509svid.SVID) *x509svid.SVID
+	zeroize bool
 
 	mtx     sync.RWMutex
 	svid    *x509svid.SVID
+	svids   []*x509svid.SVID
 	bundles *x509bundle.Set
 
+	// retired holds the X509-SVIDs replaced on the last rotation, to be
+	// zeroized on the next one when WithZeroizeOnRotation is used.
+	retired []*x509svid.SVID
+
 	closeMtx sync.RWMutex
 	closed   bool
 }
@@ -36,7 +42,8 @@ func NewX509Source(ctx context.Context, options ...X509SourceOption) (_ *X509Sou
 	}
 
 	s := &X509Source{
-		picker: config.picker,
+		picker:  config.picker,
+		zeroize: config.zeroize,
 	}
 
 	s.watcher, err = newWatcher(ctx, config.watcher, s.setX509Context, nil)
@@ -56,7 +63,14 @@ func (s *X509Source) Close() (err error) {
 	s.closed = true
 	s.closeMtx.Unlock()
 
-	return s.watcher.Close()
+	err = s.watcher.Close()
+	if s.zeroize {
+		s.mtx.Lock()
+		zeroizeSVIDs(s.retired)
+		zeroizeSVIDs(s.svids)
+		s.mtx.Unlock()
+	}
+	return err
 }
 
 // GetX509SVID returns an X509-SVID from the source. It implements the
@@ -79,6 +93,29 @@ func (s *X509Source) GetX509SVID() (*x509svid.SVID, error) {
 	return svid, nil
 }
 
+// GetX509SVIDs returns all of the X509-SVIDs delivered by the Workload API,
+// in the order they were received. The hint of each X509-SVID, if any, is
+// available via its Hint field. The returned slice can be modified by the
+// caller.
+func (s *X509Source) GetX509SVIDs() ([]*x509svid.SVID, error) {
+	if err := s.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	s.mtx.RLock()
+	svids := make([]*x509svid.SVID, len(s.svids))
+	copy(svids, s.svids)
+	s.mtx.RUnlock()
+
+	if len(svids) == 0 {
+		// This is a defensive check and should be unreachable since the source
+		// waits for the initial Workload API update before returning from
+		// New().
+		return nil, x509sourceErr.New("missing X509-SVIDs")
+	}
+	return svids, nil
+}
+
 // GetX509BundleForTrustDomain returns the X.509 bundle for the given trust
 // domain. It implements the x509bundle.Source interface.
 func (s *X509Source) GetX509BundleForTrustDomain(trustDomain spiffeid.TrustDomain) (*x509bundle.Bundle, error) {
@@ -110,10 +147,45 @@ func (s *X509Source) setX509Context(x509Context *X509Context) {
 
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
+	if s.zeroize {
+		s.retired = retireSVIDs(s.retired, s.svids, x509Context.SVIDs)
+	}
 	s.svid = svid
+	s.svids = x509Context.SVIDs
 	s.bundles = x509Context.Bundles
 }
 
+// retireSVIDs zeroizes the X509-SVIDs retired on the previous rotation and
+// returns the ones retired on this rotation, i.e. the current X509-SVIDs that
+// are not kept. Zeroizing the X509-SVIDs one rotation late lets the callers
+// that obtained them shortly before the rotation, e.g. in-flight TLS
+// handshakes, finish using them.
+func retireSVIDs(retired, current, kept []*x509svid.SVID) []*x509svid.SVID {
+	zeroizeSVIDs(retired)
+	var retiring []*x509svid.SVID
+	for _, svid := range current {
+		if !containsSVID(kept, svid) {
+			retiring = append(retiring, svid)
+		}
+	}
+	return retiring
+}
+
+func zeroizeSVIDs(svids []*x509svid.SVID) {
+	for _, svid := range svids {
+		svid.Zeroize()
+	}
+}
+
+func containsSVID(svids []*x509svid.SVID, svid *x509svid.SVID) bool {
+	for _, s := range svids {
+		if s == svid {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *X509Source) checkClosed() error {
 	s.closeMtx.RLock()
 	defer s.closeMtx.RUnlock()
@@ -58,6 +58,9 @@ func TestX509SourceFailsCallsIfClosed(t *testing.T) {
 	_, err = source.GetX509SVID()
 	require.EqualError(t, err, "x509source: source is closed")
 
+	_, err = source.GetX509SVIDs()
+	require.EqualError(t, err, "x509source: source is closed")
+
 	_, err = source.GetX509BundleForTrustDomain(td)
 	require.EqualError(t, err, "x509source: source is closed")
 }
@@ -164,3 +167,95 @@ func TestX509SourceX509SVIDPicker(t *testing.T) {
 	// Assert that the right SVID was picked.
 	requireX509SVID(t, source, svid2)
 }
+
+func TestX509SourceGetX509SVIDs(t *testing.T) {
+	// Time out the test after a minute if something goes wrong.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	api := fakeworkloadapi.New(t)
+	defer api.Stop()
+
+	td := spiffeid.RequireTrustDomainFromString("domain.test")
+	ca := test.NewCA(t, td)
+
+	svid1 := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload1"), test.WithHint("internal"))
+	svid2 := ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/workload2"), test.WithHint("external"))
+
+	api.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{svid1, svid2},
+		Bundle: ca.X509Bundle(),
+	})
+
+	// Create the source. It will wait for the initial response.
+	source, err := workloadapi.NewX509Source(ctx, withAddr(api))
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, source.Close())
+	}()
+
+	// Assert that the default SVID is still the first one.
+	requireX509SVID(t, source, svid1)
+
+	// Assert that all SVIDs, along with their hints, are returned.
+	svids, err := source.GetX509SVIDs()
+	require.NoError(t, err)
+	require.Equal(t, []*x509svid.SVID{svid1, svid2}, svids)
+	require.Equal(t, "internal", svids[0].Hint)
+	require.Equal(t, "external", svids[1].Hint)
+}
+
+func TestX509SourceWithZeroizeOnRotation(t *testing.T) {
+	// Time out the test after a minute if something goes wrong.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	api := fakeworkloadapi.New(t)
+	defer api.Stop()
+
+	td := spiffeid.RequireTrustDomainFromString("domain.test")
+	ca := test.NewCA(t, td)
+
+	api.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/initial"))},
+		Bundle: ca.X509Bundle(),
+	})
+
+	source, err := workloadapi.NewX509Source(ctx, withAddr(api), workloadapi.WithZeroizeOnRotation())
+	require.NoError(t, err)
+
+	initial, err := source.GetX509SVID()
+	require.NoError(t, err)
+	require.NotNil(t, initial.PrivateKey)
+
+	// The replaced X509-SVID is still usable until the next rotation, e.g. by
+	// in-flight handshakes.
+	api.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/update1"))},
+		Bundle: ca.X509Bundle(),
+	})
+	require.NoError(t, source.WaitUntilUpdated(ctx))
+	assert.NotNil(t, initial.PrivateKey)
+
+	update1, err := source.GetX509SVID()
+	require.NoError(t, err)
+	require.NotNil(t, update1.PrivateKey)
+
+	// It is zeroized on the next rotation.
+	api.SetX509SVIDResponse(&fakeworkloadapi.X509SVIDResponse{
+		SVIDs:  []*x509svid.SVID{ca.CreateX509SVID(spiffeid.RequireFromPath(td, "/update2"))},
+		Bundle: ca.X509Bundle(),
+	})
+	require.NoError(t, source.WaitUntilUpdated(ctx))
+	assert.Nil(t, initial.PrivateKey)
+	assert.NotNil(t, update1.PrivateKey)
+
+	update2, err := source.GetX509SVID()
+	require.NoError(t, err)
+	require.NotNil(t, update2.PrivateKey)
+
+	// The retired and current X509-SVIDs are zeroized on close.
+	require.NoError(t, source.Close())
+	assert.Nil(t, update1.PrivateKey)
+	assert.Nil(t, update2.PrivateKey)
+}